@@ -6,22 +6,30 @@ package main
      - Version detection via "VERSION" file or --version <version>
      - Build for windows/mac/linux on amd64/arm64
      - Creates .tar.gz for mac/linux with files set to executable permissions and a .zip for Windows
+     - Archives are reproducible (sorted entries, fixed timestamps or SOURCE_DATE_EPOCH) and include a MANIFEST
+     - Prints the size and SHA-256 of every archive and a summary table across all targets
 */
 
 import (
 	"archive/tar"
 	"archive/zip"
 	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
 	"flag"
 	"fmt"
 	"io"
+	"io/fs"
 	"log"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"runtime"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 )
 
 var BINARY_NAME string
@@ -35,6 +43,12 @@ var (
 
 const DEFAULT_VERSION = ""
 
+// MANIFEST_NAME is the file in every archive that describes the build
+const MANIFEST_NAME = "MANIFEST"
+
+// ARCHIVE_TIME is the fixed timestamp of archive entries when SOURCE_DATE_EPOCH is not set (the earliest zip time)
+var ARCHIVE_TIME = time.Date(1980, 1, 1, 0, 0, 0, 0, time.UTC)
+
 type BuildTarget struct {
 	OS   string
 	Arch string
@@ -147,9 +161,13 @@ func main() {
 	}
 	fmt.Printf("%s\n", msgBuilding)
 
+	// results are stored by target index, so the summary follows the target order regardless of build timing
+	artifacts := make([]*artifact, len(buildTargets))
+	errs := make([]error, len(buildTargets))
+
 	var wg sync.WaitGroup
 
-	for _, target := range buildTargets {
+	for i, target := range buildTargets {
 		wg.Add(1)
 		go func() {
 			prefix := fmt.Sprintf(
@@ -157,11 +175,59 @@ func main() {
 				fmt.Sprintf("[%s/%s] ", target.OS, target.Arch),
 			)
 			defer wg.Done()
-			buildAndPackage(prefix, target, v, *release)
+			artifacts[i], errs[i] = buildAndPackage(prefix, target, v, *release)
+			if errs[i] != nil {
+				fmt.Printf("%s -> %v\n", prefix, errs[i])
+			}
 		}()
 	}
 
 	wg.Wait()
+
+	if !printSummary(os.Stdout, buildTargets, artifacts, errs) {
+		os.Exit(1)
+	}
+}
+
+// artifact describes a packaged build archive
+type artifact struct {
+	Path   string
+	Size   int64
+	SHA256 string
+}
+
+// newArtifact reads the archive at path to record its size and SHA-256 checksum
+func newArtifact(path string) (*artifact, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open archive: %w", err)
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	size, err := io.Copy(h, f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash archive: %w", err)
+	}
+	return &artifact{Path: path, Size: size, SHA256: hex.EncodeToString(h.Sum(nil))}, nil
+}
+
+// printSummary prints a table of every target's artifact, or its error, in target order. It returns false if any
+// target failed.
+func printSummary(w io.Writer, targets []BuildTarget, artifacts []*artifact, errs []error) bool {
+	ok := true
+	fmt.Fprintf(w, "\n%-16s %12s  %-64s  %s\n", "TARGET", "SIZE", "SHA-256", "ARCHIVE")
+	for i, target := range targets {
+		name := fmt.Sprintf("%s/%s", target.OS, target.Arch)
+		if errs[i] != nil || artifacts[i] == nil {
+			ok = false
+			fmt.Fprintf(w, "%-16s %12s  %-64s  %v\n", name, "-", "FAILED", errs[i])
+			continue
+		}
+		a := artifacts[i]
+		fmt.Fprintf(w, "%-16s %12d  %-64s  %s\n", name, a.Size, a.SHA256, a.Path)
+	}
+	return ok
 }
 
 // readversion attempts to read the VERSION file, defaults to the VERSION constant if not found
@@ -179,12 +245,13 @@ func readVersion() (string, error) {
 	return v, nil
 }
 
-func buildAndPackage(prefix string, target BuildTarget, version string, release bool) error {
+// buildAndPackage builds the binary for the target and packages it, returning the archive it created
+func buildAndPackage(prefix string, target BuildTarget, version string, release bool) (*artifact, error) {
 	// Create output directory
 	outDirName := fmt.Sprintf("%s-%s-%s", BINARY_NAME, target.OS, target.Arch)
 	outDir := filepath.Join(DIST_DIR, version, outDirName)
 	if err := os.MkdirAll(outDir, 0755); err != nil {
-		return fmt.Errorf("failed to create dist dir: %w", err)
+		return nil, fmt.Errorf("failed to create dist dir: %w", err)
 	}
 
 	// Build the binary name and path
@@ -222,21 +289,32 @@ func buildAndPackage(prefix string, target BuildTarget, version string, release
 	cmd.Stderr = os.Stderr
 
 	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("build failed for %s/%s: %w", target.OS, target.Arch, err)
+		return nil, fmt.Errorf("build failed for %s/%s: %w", target.OS, target.Arch, err)
 	}
 
-	if err := packageDir(prefix, target, outDirName, version); err != nil {
-		return err
+	if err := writeManifest(outDir, binName, version, target); err != nil {
+		return nil, err
+	}
+
+	archivePath, err := packageDir(prefix, target, outDirName, version)
+	if err != nil {
+		return nil, err
 	}
 
 	if err := os.RemoveAll(outDir); err != nil {
-		return fmt.Errorf("failed to clean up build dir: %w", err)
+		return nil, fmt.Errorf("failed to clean up build dir: %w", err)
 	}
 
-	return nil
+	a, err := newArtifact(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Printf("%s -> %s (%d bytes, sha256 %s)\n", prefix, a.Path, a.Size, a.SHA256)
+	return a, nil
 }
 
-func packageDir(prefix string, target BuildTarget, dir, version string) error {
+// packageDir archives the build directory for the target and returns the path of the archive
+func packageDir(prefix string, target BuildTarget, dir, version string) (string, error) {
 	switch target.OS {
 	case "windows":
 		return createZip(prefix, dir, version)
@@ -245,27 +323,43 @@ func packageDir(prefix string, target BuildTarget, dir, version string) error {
 	}
 }
 
-func createZip(prefix string, dir, version string) error {
-	archivePath := filepath.Join(DIST_DIR, version, dir+".zip")
-	fmt.Printf("%s -> creating zip archive: %s\n", prefix, archivePath)
-
-	f, err := os.Create(archivePath)
-	if err != nil {
-		return err
+// writeManifest writes the MANIFEST file describing the build into the directory that gets archived
+func writeManifest(outDir, binName, version string, target BuildTarget) error {
+	if version == "" {
+		version = VERSION
 	}
-	defer f.Close()
+	manifest := fmt.Sprintf("name: %s\nbinary: %s\nversion: %s\ntarget: %s/%s\n", BINARY_NAME, binName, version, target.OS, target.Arch)
+	if err := os.WriteFile(filepath.Join(outDir, MANIFEST_NAME), []byte(manifest), 0644); err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+	return nil
+}
 
-	zw := zip.NewWriter(f)
-	defer zw.Close()
+// archiveTime returns the timestamp of every archive entry, SOURCE_DATE_EPOCH if set, so that archives only depend
+// on the file contents
+func archiveTime() time.Time {
+	if epoch, err := strconv.ParseInt(os.Getenv("SOURCE_DATE_EPOCH"), 10, 64); err == nil {
+		return time.Unix(epoch, 0).UTC()
+	}
+	return ARCHIVE_TIME
+}
 
-	srcDir := filepath.Join(DIST_DIR, version, dir)
+// archiveEntry is a file added to an archive
+type archiveEntry struct {
+	Name string      // slash-separated path inside the archive
+	Path string      // path of the file on disk
+	Mode fs.FileMode // normalized permissions
+}
 
-	return filepath.Walk(srcDir, func(path string, info os.FileInfo, errWalk error) error {
+// archiveEntries lists the files below srcDir sorted by name, with the binary executable and everything else
+// read-only, so that the archive does not depend on the filesystem order or umask
+func archiveEntries(srcDir string) ([]archiveEntry, error) {
+	var entries []archiveEntry
+	err := filepath.WalkDir(srcDir, func(path string, d fs.DirEntry, errWalk error) error {
 		if errWalk != nil {
 			return fmt.Errorf("error walking path %s: %w", path, errWalk)
 		}
-
-		if info.IsDir() {
+		if d.IsDir() {
 			return nil
 		}
 
@@ -274,98 +368,130 @@ func createZip(prefix string, dir, version string) error {
 			return fmt.Errorf("failed to get relative path: %w", err)
 		}
 
-		zipPath := filepath.ToSlash(relPath)
-		header, err := zip.FileInfoHeader(info)
-		if err != nil {
-			return fmt.Errorf("failed to get file info header: %w", err)
+		mode := fs.FileMode(0o644)
+		if base := filepath.Base(relPath); base == BINARY_NAME || base == BINARY_NAME+".exe" {
+			mode = 0o755
 		}
+		entries = append(entries, archiveEntry{Name: filepath.ToSlash(relPath), Path: path, Mode: mode})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
 
-		header.Name = zipPath
-		header.Method = zip.Deflate
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+	return entries, nil
+}
 
-		w, err := zw.CreateHeader(header)
-		if err != nil {
-			return fmt.Errorf("failed to create header: %w", err)
-		}
+func createZip(prefix string, dir, version string) (string, error) {
+	archivePath := filepath.Join(DIST_DIR, version, dir+".zip")
+	fmt.Printf("%s -> creating zip archive: %s\n", prefix, archivePath)
 
-		in, err := os.Open(path)
-		if err != nil {
-			return fmt.Errorf("failed to open file for zipping: %w", err)
+	entries, err := archiveEntries(filepath.Join(DIST_DIR, version, dir))
+	if err != nil {
+		return "", err
+	}
+
+	f, err := os.Create(archivePath)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	if err := writeZip(f, entries, archiveTime()); err != nil {
+		return "", err
+	}
+	fmt.Printf("%s -> build complete\n", prefix)
+	return archivePath, nil
+}
+
+// writeZip writes the entries as a zip archive with fixed timestamps
+func writeZip(w io.Writer, entries []archiveEntry, modTime time.Time) error {
+	zw := zip.NewWriter(w)
+
+	for _, entry := range entries {
+		header := &zip.FileHeader{
+			Name:     entry.Name,
+			Method:   zip.Deflate,
+			Modified: modTime,
 		}
-		defer in.Close()
+		header.SetMode(entry.Mode)
 
-		_, err = io.Copy(w, in)
+		fw, err := zw.CreateHeader(header)
 		if err != nil {
+			return fmt.Errorf("failed to create header: %w", err)
+		}
+		if err := copyFile(fw, entry.Path); err != nil {
 			return fmt.Errorf("failed to copy file data to zip: %w", err)
 		}
+	}
 
-		return nil
-	})
+	return zw.Close()
 }
 
-func createTarGz(prefix string, dir, version string) error {
+func createTarGz(prefix string, dir, version string) (string, error) {
 	archivePath := filepath.Join(DIST_DIR, version, dir+".tar.gz")
 	fmt.Printf("%s -> creating tar.gz archive: %s\n", prefix, archivePath)
 
+	entries, err := archiveEntries(filepath.Join(DIST_DIR, version, dir))
+	if err != nil {
+		return "", err
+	}
+
 	f, err := os.Create(archivePath)
 	if err != nil {
-		return err
+		return "", err
 	}
 	defer f.Close()
 
-	gw := gzip.NewWriter(f)
-	defer gw.Close()
+	if err := writeTarGz(f, entries, archiveTime()); err != nil {
+		return "", err
+	}
+	fmt.Printf("%s -> build complete\n", prefix)
+	return archivePath, nil
+}
 
+// writeTarGz writes the entries as a gzip-compressed tar archive with fixed timestamps and no owner information
+func writeTarGz(w io.Writer, entries []archiveEntry, modTime time.Time) error {
+	gw := gzip.NewWriter(w)
 	tw := tar.NewWriter(gw)
-	defer tw.Close()
-
-	srcDir := filepath.Join(DIST_DIR, version, dir)
-
-	defer func() {
-		fmt.Printf("%s -> build complete\n", prefix)
-	}()
-	return filepath.Walk(srcDir, func(path string, info os.FileInfo, errWalk error) error {
-		if errWalk != nil {
-			return fmt.Errorf("error walking path %s: %w", path, errWalk)
-		}
 
-		if info.IsDir() {
-			return nil
-		}
-
-		relPath, err := filepath.Rel(srcDir, path)
-		if err != nil {
-			return fmt.Errorf("failed to get relative path: %w", err)
-		}
-
-		tarPath := filepath.ToSlash(relPath)
-
-		header, err := tar.FileInfoHeader(info, "")
+	for _, entry := range entries {
+		info, err := os.Stat(entry.Path)
 		if err != nil {
-			return fmt.Errorf("failed to get tar file info header: %w", err)
+			return fmt.Errorf("failed to stat file for tarring: %w", err)
 		}
 
-		header.Name = tarPath
-
-		if filepath.Base(tarPath) == BINARY_NAME {
-			header.Mode = 0o755
+		header := &tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     entry.Name,
+			Size:     info.Size(),
+			Mode:     int64(entry.Mode),
+			ModTime:  modTime,
+			Format:   tar.FormatUSTAR,
 		}
-
 		if err := tw.WriteHeader(header); err != nil {
 			return fmt.Errorf("failed to write tar header: %w", err)
 		}
-
-		in, err := os.Open(path)
-		if err != nil {
-			return fmt.Errorf("failed to open file for tarring: %w", err)
-		}
-		defer in.Close()
-
-		_, err = io.Copy(tw, in)
-		if err != nil {
+		if err := copyFile(tw, entry.Path); err != nil {
 			return fmt.Errorf("failed to copy file data to tar: %w", err)
 		}
+	}
 
-		return nil
-	})
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gw.Close()
+}
+
+// copyFile copies the contents of the file at path to w
+func copyFile(w io.Writer, path string) error {
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	_, err = io.Copy(w, in)
+	return err
 }
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeBuildDir creates a build directory with the given file modification time, as a fresh build would
+func writeBuildDir(t *testing.T, modTime time.Time) string {
+	dir := t.TempDir()
+	target := BuildTarget{OS: "linux", Arch: "amd64"}
+
+	// files are created in reverse order, so the filesystem order differs from the sorted order
+	if err := writeManifest(dir, BINARY_NAME, "1.2.3", target); err != nil {
+		t.Fatalf("failed to write manifest: %v", err)
+	}
+	for _, name := range []string{BINARY_NAME, "LICENSE"} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte("contents of "+name), 0600); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatalf("failed to set time of %s: %v", name, err)
+		}
+	}
+	return dir
+}
+
+func TestArchivesAreReproducible(t *testing.T) {
+	BINARY_NAME = "bipkey"
+
+	first := writeBuildDir(t, time.Now())
+	second := writeBuildDir(t, time.Now().Add(-time.Hour))
+
+	for name, write := range map[string]func(*bytes.Buffer, []archiveEntry) error{
+		"zip":    func(b *bytes.Buffer, e []archiveEntry) error { return writeZip(b, e, ARCHIVE_TIME) },
+		"tar.gz": func(b *bytes.Buffer, e []archiveEntry) error { return writeTarGz(b, e, ARCHIVE_TIME) },
+	} {
+		var archives [2]bytes.Buffer
+		for i, dir := range []string{first, second} {
+			entries, err := archiveEntries(dir)
+			if err != nil {
+				t.Fatalf("failed to list %s: %v", dir, err)
+			}
+			if err := write(&archives[i], entries); err != nil {
+				t.Fatalf("failed to write %s archive: %v", name, err)
+			}
+		}
+		if !bytes.Equal(archives[0].Bytes(), archives[1].Bytes()) {
+			t.Fatalf("%s archives of identical builds differ", name)
+		}
+	}
+
+	// the entries are sorted, the binary is executable, and the manifest describes the build
+	entries, err := archiveEntries(first)
+	if err != nil {
+		t.Fatalf("failed to list entries: %v", err)
+	}
+	var archive bytes.Buffer
+	if err := writeTarGz(&archive, entries, ARCHIVE_TIME); err != nil {
+		t.Fatalf("failed to write tar.gz archive: %v", err)
+	}
+	gr, err := gzip.NewReader(&archive)
+	if err != nil {
+		t.Fatalf("failed to read gzip stream: %v", err)
+	}
+	tr := tar.NewReader(gr)
+
+	expected := []struct {
+		name string
+		mode int64
+	}{{"LICENSE", 0o644}, {MANIFEST_NAME, 0o644}, {BINARY_NAME, 0o755}}
+	for _, want := range expected {
+		header, err := tr.Next()
+		if err != nil {
+			t.Fatalf("failed to read tar entry: %v", err)
+		}
+		if header.Name != want.name || header.Mode != want.mode || !header.ModTime.Equal(ARCHIVE_TIME) {
+			t.Fatalf("unexpected tar entry %s (mode %o, time %s), want %s (mode %o)", header.Name, header.Mode, header.ModTime, want.name, want.mode)
+		}
+		if header.Name == MANIFEST_NAME {
+			var manifest bytes.Buffer
+			if _, err := manifest.ReadFrom(tr); err != nil {
+				t.Fatalf("failed to read manifest: %v", err)
+			}
+			if manifest.String() != "name: bipkey\nbinary: bipkey\nversion: 1.2.3\ntarget: linux/amd64\n" {
+				t.Fatalf("unexpected manifest:\n%s", manifest.String())
+			}
+		}
+	}
+}
+
+func TestPrintSummary(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "bipkey-linux-amd64.tar.gz")
+	if err := os.WriteFile(path, []byte("abc"), 0644); err != nil {
+		t.Fatalf("failed to write archive: %v", err)
+	}
+	a, err := newArtifact(path)
+	if err != nil {
+		t.Fatalf("failed to read artifact: %v", err)
+	}
+	if a.Size != 3 || a.SHA256 != "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad" {
+		t.Fatalf("unexpected artifact: %+v", a)
+	}
+
+	targets := []BuildTarget{{"linux", "amd64"}, {"windows", "arm64"}}
+	var out bytes.Buffer
+	if printSummary(&out, targets, []*artifact{a, a}, []error{nil, nil}) != true {
+		t.Fatalf("expected a successful summary")
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[1], "linux/amd64") || !strings.HasPrefix(lines[2], "windows/arm64") ||
+		!strings.Contains(lines[1], a.SHA256) {
+		t.Fatalf("unexpected summary:\n%s", out.String())
+	}
+
+	out.Reset()
+	if printSummary(&out, targets, []*artifact{a, nil}, []error{nil, errors.New("build failed")}) {
+		t.Fatalf("expected a failed target to fail the summary")
+	}
+	if !strings.Contains(out.String(), "FAILED") {
+		t.Fatalf("expected the failed target in the summary:\n%s", out.String())
+	}
+}
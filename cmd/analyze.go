@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/goodieshq/bipkey/pkg/keys"
+	"github.com/rs/zerolog/log"
+	"github.com/urfave/cli/v3"
+)
+
+// ANALYSIS_BUCKETS is the number of word list ranges the position distribution is shown in
+const ANALYSIS_BUCKETS = 8
+
+// cmdAnalyzeMnemonic shows how the words of a mnemonic are spread over the word list
+var cmdAnalyzeMnemonic = &cli.Command{
+	Name:      "analyze-mnemonic",
+	Usage:     "Show the word list positions of a mnemonic and flag low-entropy patterns, e.g. a human-chosen mnemonic",
+	UsageText: "bipkey analyze-mnemonic [--mnemonic <words>]",
+	Action:    actionAnalyzeMnemonic,
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:    "mnemonic",
+			Aliases: []string{"m"},
+			Usage:   "24-word mnemonic to analyze (prompted if not given)",
+		},
+	},
+}
+
+// actionAnalyzeMnemonic prints the distinct word count, the word positions, and their distribution. The findings are
+// advisory, so it exits zero for a low-entropy mnemonic as well.
+func actionAnalyzeMnemonic(ctx context.Context, c *cli.Command) error {
+	setLogging(c)
+
+	mnemonicString := c.String("mnemonic")
+	if mnemonicString == "" {
+		var err error
+		mnemonicString, err = promptMnemonic()
+		if err != nil {
+			return err
+		}
+	}
+
+	gen, err := getGenerator(c)
+	if err != nil {
+		return err
+	}
+	if gen, err = mnemonicGenerator(c, gen, mnemonicString); err != nil {
+		return err
+	}
+	mnemonic, err := gen.ParseMnemonic(mnemonicString)
+	if err != nil {
+		return cli.Exit(fmt.Sprintf("Invalid mnemonic: %v", err), 1)
+	}
+	analysis, err := gen.AnalyzeMnemonic(mnemonic)
+	if err != nil {
+		return cli.Exit(err.Error(), 1)
+	}
+
+	fmt.Printf("Distinct Words: %d of %d\n", analysis.DistinctWords, keys.MNEMONIC_WORD_COUNT)
+	fmt.Printf("Longest Evenly Spaced Run: %d words\n", analysis.LongestRun)
+	fmt.Printf("Position Span: %d of %d\n", analysis.Span+1, keys.BIP39_WORD_COUNT)
+
+	fmt.Println("\nWord Positions:")
+	for i, word := range mnemonic {
+		fmt.Printf("  %02d: %-10s %4d\n", i+1, word, analysis.Positions[i])
+	}
+
+	fmt.Println("\nDistribution:")
+	width := keys.BIP39_WORD_COUNT / ANALYSIS_BUCKETS
+	for i, count := range analysis.Histogram(ANALYSIS_BUCKETS) {
+		fmt.Printf("  %4d-%4d: %-*s %d\n", i*width, (i+1)*width-1, keys.MNEMONIC_WORD_COUNT, strings.Repeat("#", count), count)
+	}
+
+	fmt.Println()
+	if !analysis.LowEntropy() {
+		fmt.Println("No low-entropy patterns found.")
+		return nil
+	}
+	for _, finding := range analysis.Findings {
+		fmt.Printf("LOW ENTROPY: %s\n", finding)
+	}
+	return nil
+}
+
+// adviseLowEntropy warns about low-entropy patterns in a mnemonic being restored, see keys.AnalyzeMnemonic.
+// --allow-low-entropy turns the advisory off.
+func adviseLowEntropy(c *cli.Command, gen *keys.Generator, m keys.Mnemonic) {
+	if c.Bool("allow-low-entropy") {
+		return
+	}
+	analysis, err := gen.AnalyzeMnemonic(m)
+	if err != nil || !analysis.LowEntropy() {
+		return
+	}
+	log.Warn().Strs("findings", analysis.Findings).Msg("The mnemonic looks low-entropy, e.g. chosen by hand rather than generated. " +
+		"Anyone who guesses the pattern can derive the keys. Consider generating a new mnemonic.")
+}
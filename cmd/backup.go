@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+
+	"github.com/goodieshq/bipkey/pkg/keys"
+	"github.com/rs/zerolog/log"
+	"github.com/urfave/cli/v3"
+)
+
+// cmdEncryptMnemonic writes a password-encrypted backup of an existing mnemonic
+var cmdEncryptMnemonic = &cli.Command{
+	Name:  "encrypt-mnemonic",
+	Usage: "Encrypt an existing mnemonic under a password (Argon2id and AES-256-GCM) as a self-contained backup",
+	Description: "The backup is a PEM block holding the encrypted words, with the Argon2id parameters, salt, and nonce in " +
+		"its headers, so decrypt-mnemonic needs only the password. The password comes from --password, --password-file, " +
+		PASSWORD_ENV_VAR + ", or a prompt.",
+	UsageText: "bipkey [-password <password>] [-out <file>] encrypt-mnemonic [--mnemonic <words> | --entropy <hex>] [--argon2-time <n>] [--argon2-memory <MiB>] [--argon2-threads <n>]",
+	Action:    actionEncryptMnemonic,
+	Flags: []cli.Flag{
+		newMnemonicFlag(),
+		newEntropyFlag(),
+		&cli.UintFlag{
+			Name:  "argon2-time",
+			Usage: "Argon2id passes over the memory",
+			Value: uint(keys.DefaultArgon2Params.Time),
+		},
+		&cli.UintFlag{
+			Name:  "argon2-memory",
+			Usage: "Argon2id memory in MiB",
+			Value: uint(keys.DefaultArgon2Params.Memory / 1024),
+		},
+		&cli.UintFlag{
+			Name:  "argon2-threads",
+			Usage: "Argon2id parallelism",
+			Value: uint(keys.DefaultArgon2Params.Threads),
+		},
+	},
+}
+
+// cmdDecryptMnemonic recovers the mnemonic from a backup written by encrypt-mnemonic
+var cmdDecryptMnemonic = &cli.Command{
+	Name:      "decrypt-mnemonic",
+	Usage:     "Decrypt a mnemonic backup written by encrypt-mnemonic and print the words",
+	UsageText: "bipkey [-password <password>] [-out <file>] decrypt-mnemonic --in <backup file>",
+	Action:    actionDecryptMnemonic,
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:     "in",
+			Usage:    "Mnemonic backup file to decrypt",
+			Required: true,
+		},
+	},
+}
+
+// actionEncryptMnemonic reads the mnemonic and prints or writes its encrypted backup
+func actionEncryptMnemonic(ctx context.Context, c *cli.Command) error {
+	setLogging(c)
+
+	params := keys.Argon2Params{
+		Time:    uint32(min(c.Uint("argon2-time"), math.MaxUint32)),
+		Memory:  uint32(min(c.Uint("argon2-memory"), math.MaxUint32/1024) * 1024),
+		Threads: uint8(min(c.Uint("argon2-threads"), math.MaxUint8)),
+	}
+	if err := params.Validate(); err != nil {
+		return cli.Exit(fmt.Sprintf("Invalid Argon2id parameters: %v", err), 1)
+	}
+
+	// the backup is always encrypted, so the environment variable applies without --encrypt
+	password, err := readPassword(c, true)
+	if err != nil {
+		return err
+	}
+	if password == "" {
+		if password, err = promptSecret("mnemonic backup password", true); err != nil {
+			return err
+		}
+	}
+
+	if err := confirmOutFile(c); err != nil {
+		return err
+	}
+
+	gen, err := getGenerator(c)
+	if err != nil {
+		return err
+	}
+	mnemonic, _, err := readMnemonic(c, gen)
+	if err != nil {
+		return err
+	}
+
+	backup, err := keys.EncryptMnemonic(mnemonic, password, params)
+	if err != nil {
+		return cli.Exit(err.Error(), 1)
+	}
+	log.Info().Str("params", params.String()).Msg("Encrypted the mnemonic. Keep the password apart from the backup.")
+
+	fmt.Print(backup)
+	if err := writeOutput(c.String("out"), backup, 0600); err != nil {
+		log.Error().Err(err).Msg("Failed to write mnemonic backup to file")
+		return err
+	}
+	return nil
+}
+
+// actionDecryptMnemonic decrypts the backup and prints or writes the mnemonic words
+func actionDecryptMnemonic(ctx context.Context, c *cli.Command) error {
+	setLogging(c)
+
+	data, err := os.ReadFile(c.String("in"))
+	if err != nil {
+		return cli.Exit(fmt.Sprintf("Failed to read the mnemonic backup: %v", err), 1)
+	}
+
+	// the backup is always encrypted, so the environment variable applies without --encrypt
+	password, err := readPassword(c, true)
+	if err != nil {
+		return err
+	}
+	if password == "" {
+		if password, err = promptSecret("mnemonic backup password", false); err != nil {
+			return err
+		}
+	}
+
+	if err := confirmOutFile(c); err != nil {
+		return err
+	}
+
+	mnemonic, err := keys.DecryptMnemonic(data, password)
+	if errors.Is(err, keys.ErrMnemonicBackupAuth) {
+		return cli.Exit("Failed to decrypt the mnemonic backup: the password is wrong or the backup was modified.", 1)
+	}
+	if err != nil {
+		return cli.Exit(fmt.Sprintf("Failed to decrypt the mnemonic backup: %v", err), 1)
+	}
+
+	fmt.Println(mnemonic.String())
+	if err := writeOutput(c.String("out"), mnemonic.String()+"\n", 0600); err != nil {
+		log.Error().Err(err).Msg("Failed to write mnemonic to file")
+		return err
+	}
+	return nil
+}
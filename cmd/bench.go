@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/goodieshq/bipkey/pkg/keys"
+	"github.com/urfave/cli/v3"
+)
+
+// BENCH_SALT and BENCH_IDENTITY are the fixed inputs of the benchmark, together with the all-zero entropy mnemonic,
+// so that every machine times the same keys
+const (
+	BENCH_SALT     = "bipkey-bench"
+	BENCH_IDENTITY = "bench"
+)
+
+// cmdBench times key derivation on this machine, e.g. before committing to RSA-8192 in a recovery ceremony
+var cmdBench = &cli.Command{
+	Name:      "bench",
+	Usage:     "Time key derivation on this machine for every supported key type, or the one selected by -ecc/-rsa",
+	UsageText: "bipkey [--algorithm <algorithm>] [--derivation <version>] [--kdf scrypt] bench [--rounds <n>] [--timeout <duration>]",
+	Action:    actionBench,
+	Flags: []cli.Flag{
+		&cli.IntFlag{
+			Name:  "rounds",
+			Usage: "Number of keys derived per key type, each at a different index since RSA timings vary with the primes",
+			Value: 3,
+			Validator: func(val int) error {
+				if val < 1 {
+					return cli.Exit("The number of rounds must be at least 1.", 1)
+				}
+				return nil
+			},
+		},
+		&cli.DurationFlag{
+			Name:  "timeout",
+			Usage: "Stop the benchmark after this duration, e.g. 10m (default: no limit)",
+		},
+	},
+}
+
+// actionBench derives keys from fixed inputs with the configured generator, so that the timings include the
+// derivation version and any scrypt stretch, and prints the minimum, average, and maximum wall-clock time per key type.
+// It stops at --timeout or on interrupt, even in the middle of a long RSA prime search.
+func actionBench(ctx context.Context, c *cli.Command) error {
+	setLogging(c)
+
+	cases, err := keyTypeCases(c)
+	if err != nil {
+		return err
+	}
+	gen, err := getGenerator(c)
+	if err != nil {
+		return err
+	}
+	mnemonic, err := gen.MnemonicFromEntropy(make([]byte, keys.MNEMONIC_ENTROPY_BITS/8))
+	if err != nil {
+		return err
+	}
+
+	if timeout := c.Duration("timeout"); timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	rounds := int(c.Int("rounds"))
+	kdf := "none"
+	if params := gen.Scrypt(); params != nil {
+		kdf = params.String()
+	}
+	fmt.Printf("Derivation %s, KDF %s, %d round(s) per key type:\n\n", gen.Derivation(), kdf, rounds)
+
+	for _, tc := range cases {
+		caps, err := keys.GetCapabilities(tc.keyType, tc.keyId)
+		if err != nil {
+			return cli.Exit(err.Error(), 1)
+		}
+
+		times, err := benchKeyType(ctx, gen, tc, mnemonic, rounds)
+		if errors.Is(err, context.DeadlineExceeded) {
+			return cli.Exit(fmt.Sprintf("Benchmark timed out after %s while deriving %s keys.", c.Duration("timeout"), caps.Algorithm), 1)
+		}
+		if errors.Is(err, context.Canceled) {
+			return cli.Exit(fmt.Sprintf("Benchmark cancelled while deriving %s keys.", caps.Algorithm), 1)
+		}
+		if err != nil {
+			return err
+		}
+
+		minimum, maximum, total := times[0], times[0], time.Duration(0)
+		for _, t := range times {
+			minimum, maximum, total = min(minimum, t), max(maximum, t), total+t
+		}
+		fmt.Printf("%-12s min %-10s avg %-10s max %s\n", caps.Algorithm, minimum.Round(time.Millisecond),
+			(total / time.Duration(len(times))).Round(time.Millisecond), maximum.Round(time.Millisecond))
+	}
+	return nil
+}
+
+// benchKeyType returns the wall-clock time of each round of deriving the key type. The v2 and v3 prime search stops
+// between candidates, but v1's rsa.GenerateKey cannot be interrupted, so each key is derived in the background and
+// abandoned if the context ends first.
+func benchKeyType(ctx context.Context, gen *keys.Generator, tc keyTypeCase, mnemonic keys.Mnemonic, rounds int) ([]time.Duration, error) {
+	times := make([]time.Duration, 0, rounds)
+	for i := range rounds {
+		label := keys.KeyLabel{Identity: BENCH_IDENTITY, Index: i}
+
+		start := time.Now()
+		done := make(chan error, 1)
+		go func() {
+			_, err := gen.GenerateLabeledKeyFromMnemonic(ctx, tc.keyType, tc.keyId, BENCH_SALT, mnemonic, label)
+			done <- err
+		}()
+
+		select {
+		case err := <-done:
+			if err != nil {
+				return nil, err
+			}
+			times = append(times, time.Since(start))
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	return times, nil
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/goodieshq/bipkey/pkg/keys"
+	"github.com/urfave/cli/v3"
+)
+
+// BRAINWALLET_WARNING is shown on every brainwallet run
+const BRAINWALLET_WARNING = `
+************************************************************************
+WARNING: BRAINWALLET MODE. THIS KEY IS WEAKER THAN A MNEMONIC KEY.
+The key is derived from a passphrase you memorized, not from a generated
+mnemonic. Human-chosen passphrases carry far less entropy than 24 random
+words, and anyone who learns the salt can guess passphrases offline at
+their leisure. Quotes, lyrics, and other known phrases are cracked
+routinely. If the passphrase is forgotten, the key is gone for good.
+Prefer restore or generate without --brainwallet for any key that matters.
+************************************************************************
+`
+
+// newBrainwalletFlag creates the restore flag selecting the passphrase-only brainwallet derivation. It is deliberately
+// not a config key, so that brainwallet mode is always chosen on the command line.
+func newBrainwalletFlag() cli.Flag {
+	return &cli.BoolFlag{
+		Name:  "brainwallet",
+		Usage: "Derive the key from a memorized passphrase (prompted) via Argon2id instead of a mnemonic. WEAKER than a mnemonic, see the README.",
+	}
+}
+
+// the flags of the mnemonic path that a brainwallet key cannot use
+var (
+	brainwalletStringConflicts = []string{"mnemonic", "entropy", "recovery-file", "mnemonic-out", "entropy-out", "bip32-path"}
+	brainwalletBoolConflicts   = []string{"derive-password", "no-bip39-seed", "dry-run"}
+)
+
+// warnBrainwallet writes the brainwallet warning straight to w, bypassing the logger, so that neither the log level
+// nor any other setting can hide it
+func warnBrainwallet(w io.Writer) {
+	fmt.Fprint(w, BRAINWALLET_WARNING)
+}
+
+// brainwalletConflict returns the error for a mnemonic path flag given with --brainwallet
+func brainwalletConflict(name string) error {
+	return cli.Exit(fmt.Sprintf("--%s cannot be combined with --brainwallet, which derives the key without a mnemonic.", name), 1)
+}
+
+// actionBrainwallet restores the brainwallet key of the prompted passphrase, see keys.GenerateKeyFromPassphrase
+func actionBrainwallet(ctx context.Context, c *cli.Command, ki *KeyInfo) error {
+	warnBrainwallet(os.Stderr)
+
+	for _, name := range brainwalletStringConflicts {
+		if c.String(name) != "" {
+			return brainwalletConflict(name)
+		}
+	}
+	for _, name := range brainwalletBoolConflicts {
+		if c.Bool(name) {
+			return brainwalletConflict(name)
+		}
+	}
+	if kdf := c.String("kdf"); kdf != "" && kdf != "none" {
+		return cli.Exit("--kdf cannot be combined with --brainwallet, which always stretches the passphrase with Argon2id.", 1)
+	}
+	if err := confirmOutFile(c); err != nil {
+		return err
+	}
+
+	passphrase, err := promptSecret("brainwallet passphrase", true)
+	if err != nil {
+		return err
+	}
+	k, err := ki.Generator.GenerateKeyFromPassphrase(ctx, ki.KeyType, ki.KeyId, ki.Salt, passphrase, ki.Label)
+	if errors.Is(err, keys.ErrBrainwalletPassphraseTooShort) {
+		return cli.Exit(fmt.Sprintf("%v. Use a long, unique passphrase that is not a known phrase.", err), 1)
+	}
+	if err != nil {
+		return err
+	}
+	return outputKey(ctx, c, ki, k)
+}
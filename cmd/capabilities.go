@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/goodieshq/bipkey/pkg/keys"
+	"github.com/urfave/cli/v3"
+)
+
+// cmdCapabilities describes what keys of the selected type can be used for
+var cmdCapabilities = &cli.Command{
+	Name:      "capabilities",
+	Usage:     "Show the operations supported by the selected key type and any caveats",
+	UsageText: "bipkey [--algorithm <algorithm>] capabilities [--json]",
+	Action:    actionCapabilities,
+}
+
+// actionCapabilities prints the capabilities of the key type selected by -ecc/-rsa
+func actionCapabilities(ctx context.Context, c *cli.Command) error {
+	setLogging(c)
+
+	keyType, keyId, err := getKeyType(c)
+	if err != nil {
+		return err
+	}
+
+	caps, err := keys.GetCapabilities(keyType, keyId)
+	if err != nil {
+		return cli.Exit(err.Error(), 1)
+	}
+
+	if c.Bool("json") {
+		data, err := json.MarshalIndent(caps, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal capabilities: %w", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	fmt.Printf("Algorithm: %s\n", caps.Algorithm)
+	fmt.Println("\nSupported Operations:")
+	for _, op := range caps.Operations {
+		fmt.Printf(" - %s\n", op)
+	}
+	if len(caps.Caveats) > 0 {
+		fmt.Println("\nCaveats:")
+		for _, caveat := range caps.Caveats {
+			fmt.Printf(" - %s\n", caveat)
+		}
+	}
+	return nil
+}
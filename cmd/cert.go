@@ -0,0 +1,220 @@
+package main
+
+import (
+	"fmt"
+	"math/big"
+	"net"
+	"time"
+
+	"github.com/goodieshq/bipkey/pkg/keys"
+	"github.com/rs/zerolog/log"
+	"github.com/urfave/cli/v3"
+)
+
+// certFlags returns the flags controlling certificate and certificate request output
+func certFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:  "cert-out",
+			Usage: "Output file for a self-signed certificate of the derived key (PEM)",
+			Value: "",
+		},
+		&cli.StringFlag{
+			Name:  "csr-out",
+			Usage: "Output file for a certificate signing request of the derived key (PEM)",
+			Value: "",
+		},
+		&cli.StringFlag{
+			Name:  "subject",
+			Usage: "Common name of the certificate/CSR subject (defaults to the first --dns name, then --comment)",
+			Value: "",
+		},
+		&cli.StringSliceFlag{
+			Name:  "dns",
+			Usage: "DNS subject alternative name for the certificate/CSR (repeatable)",
+		},
+		&cli.StringSliceFlag{
+			Name:  "ip",
+			Usage: "IP address subject alternative name for the certificate/CSR (repeatable)",
+		},
+		&cli.IntFlag{
+			Name:  "cert-days",
+			Usage: "Number of days the self-signed certificate is valid for",
+			Value: 365,
+		},
+		&cli.StringFlag{
+			Name:  "not-before",
+			Usage: "Start of the self-signed certificate validity in RFC 3339 form, e.g. 2025-01-01T00:00:00Z (default: now)",
+			Value: "",
+		},
+		&cli.StringFlag{
+			Name:  "not-after",
+			Usage: "End of the self-signed certificate validity in RFC 3339 form, replacing --cert-days",
+			Value: "",
+		},
+		&cli.StringFlag{
+			Name:  "serial",
+			Usage: "Serial number of the self-signed certificate, decimal or 0x-prefixed hex (derived from the mnemonic by default)",
+			Value: "",
+		},
+		&cli.BoolFlag{
+			Name:  "cert-ca",
+			Usage: "Mark the self-signed certificate as a CA certificate (e.g. for an offline root)",
+		},
+		&cli.BoolFlag{
+			Name:  "verify-cert",
+			Usage: "Verify the self-signed --cert-out or --step-ca certificate against itself with x509.Verify after creating it, and fail if it does not validate",
+		},
+		&cli.StringFlag{
+			Name:  "step-ca",
+			Usage: "Directory to write the key as a step-ca root to: root_ca.key (PKCS#8) and a self-signed root_ca.crt for step ca init",
+			Value: "",
+		},
+	}
+}
+
+// getCertOptions validates the certificate flags, returning nil if no certificate or CSR was requested
+func getCertOptions(c *cli.Command) (*keys.CertOptions, error) {
+	if c.String("cert-out") == "" && c.String("csr-out") == "" {
+		return nil, nil
+	}
+
+	var ips []net.IP
+	for _, val := range c.StringSlice("ip") {
+		ip := net.ParseIP(val)
+		if ip == nil {
+			return nil, cli.Exit(fmt.Sprintf("Invalid IP address %q.", val), 1)
+		}
+		ips = append(ips, ip)
+	}
+
+	dnsNames := c.StringSlice("dns")
+	commonName := c.String("subject")
+	if commonName == "" && len(dnsNames) > 0 {
+		commonName = dnsNames[0]
+	}
+	if commonName == "" {
+		commonName = c.String("comment")
+	}
+	if commonName == "" {
+		return nil, cli.Exit("A certificate subject is required: use --subject, --dns, or --comment.", 1)
+	}
+
+	opts := &keys.CertOptions{
+		CommonName:  commonName,
+		DNSNames:    dnsNames,
+		IPAddresses: ips,
+		IsCA:        c.Bool("cert-ca"),
+		Validity:    time.Duration(c.Int("cert-days")) * 24 * time.Hour,
+	}
+	if err := applyCertValidity(c, opts); err != nil {
+		return nil, err
+	}
+	return opts, nil
+}
+
+// applyCertValidity validates the --cert-days, --not-before, --not-after, and --serial flags into the options. The
+// validity of the options is kept unless --cert-days is set.
+func applyCertValidity(c *cli.Command, opts *keys.CertOptions) error {
+	if c.IsSet("cert-days") {
+		days := c.Int("cert-days")
+		if days <= 0 {
+			return cli.Exit("The certificate validity (--cert-days) must be at least one day.", 1)
+		}
+		opts.Validity = time.Duration(days) * 24 * time.Hour
+	}
+
+	notBefore, err := parseCertTime(c, "not-before")
+	if err != nil {
+		return err
+	}
+	notAfter, err := parseCertTime(c, "not-after")
+	if err != nil {
+		return err
+	}
+	if !notAfter.IsZero() && c.IsSet("cert-days") {
+		return cli.Exit("Only one of --cert-days or --not-after may be specified.", 1)
+	}
+	if !notAfter.IsZero() && !notAfter.After(notBefore) && !notBefore.IsZero() {
+		return cli.Exit("The certificate must expire (--not-after) after it becomes valid (--not-before).", 1)
+	}
+	opts.NotBefore, opts.NotAfter = notBefore, notAfter
+
+	if val := c.String("serial"); val != "" {
+		serial, ok := new(big.Int).SetString(val, 0)
+		if !ok || serial.Sign() <= 0 {
+			return cli.Exit(fmt.Sprintf("Invalid certificate serial number %q, expected a positive decimal or 0x-prefixed hex number.", val), 1)
+		}
+		opts.Serial = serial
+	}
+	return nil
+}
+
+// verifyCertificate checks the self-signed certificate with keys.VerifySelfSigned if --verify-cert is given, before it
+// is written
+func verifyCertificate(c *cli.Command, der []byte) error {
+	if !c.Bool("verify-cert") {
+		return nil
+	}
+	if err := keys.VerifySelfSigned(der); err != nil {
+		return cli.Exit(fmt.Sprintf("Certificate verification failed: %v", err), 1)
+	}
+	log.Info().Msg("Verified the self-signed certificate against itself.")
+	return nil
+}
+
+// parseCertTime parses the RFC 3339 time of the named flag, returning the zero time if it is not set
+func parseCertTime(c *cli.Command, name string) (time.Time, error) {
+	val := c.String(name)
+	if val == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, val)
+	if err != nil {
+		return time.Time{}, cli.Exit(fmt.Sprintf("Invalid --%s time %q, expected RFC 3339 form such as 2025-01-01T00:00:00Z.", name, val), 1)
+	}
+	return t, nil
+}
+
+// writeCertificates writes the requested certificate and certificate request for the key
+func writeCertificates(c *cli.Command, k *keys.Key, opts *keys.CertOptions) error {
+	if opts == nil {
+		return nil
+	}
+
+	// certificates and requests are public, so they are written world-readable
+	if path := c.String("cert-out"); path != "" {
+		der, err := k.Certificate(*opts)
+		if err != nil {
+			return err
+		}
+		if err := verifyCertificate(c, der); err != nil {
+			return err
+		}
+		data, err := exportPEM(c, keys.CertificatePEM(der))
+		if err != nil {
+			return err
+		}
+		if err := writeOutput(path, data, 0644); err != nil {
+			return err
+		}
+		log.Info().Str("file", path).Msg("Wrote self-signed certificate.")
+	}
+
+	if path := c.String("csr-out"); path != "" {
+		der, err := k.CertificateRequest(*opts)
+		if err != nil {
+			return err
+		}
+		data, err := exportPEM(c, keys.CertificateRequestPEM(der))
+		if err != nil {
+			return err
+		}
+		if err := writeOutput(path, data, 0644); err != nil {
+			return err
+		}
+		log.Info().Str("file", path).Msg("Wrote certificate signing request.")
+	}
+
+	return nil
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/goodieshq/bipkey/pkg/keys"
+	"github.com/rs/zerolog/log"
+	"github.com/urfave/cli/v3"
+)
+
+// cmdChain derives a two-tier CA from one mnemonic: a root at --index and an intermediate at the next index
+var cmdChain = &cli.Command{
+	Name:  "chain",
+	Usage: "Derive a root CA key (at --index) and an intermediate CA key (at the next index), and issue the intermediate certificate signed by the root",
+	UsageText: "bipkey [--algorithm <algorithm>] [-salt <salt value>] [-index <n>] [-out <intermediate key file>] chain " +
+		"--root-subject <name> --intermediate-subject <name> [--root-cert-out <file>] [--intermediate-cert-out <file>] [--chain-out <file>]",
+	Action: actionChain,
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:     "root-subject",
+			Usage:    "Common name of the root CA certificate",
+			Required: true,
+		},
+		&cli.StringFlag{
+			Name:     "intermediate-subject",
+			Usage:    "Common name of the intermediate CA certificate",
+			Required: true,
+		},
+		&cli.IntFlag{
+			Name:  "root-days",
+			Usage: "Number of days the root CA certificate is valid for",
+			Value: 3650,
+		},
+		&cli.IntFlag{
+			Name:  "intermediate-days",
+			Usage: "Number of days the intermediate CA certificate is valid for, at most --root-days",
+			Value: 1825,
+		},
+		&cli.StringFlag{
+			Name:  "root-cert-out",
+			Usage: "Output file for the self-signed root CA certificate (PEM)",
+			Value: "",
+		},
+		&cli.StringFlag{
+			Name:  "intermediate-cert-out",
+			Usage: "Output file for the intermediate CA certificate signed by the root (PEM)",
+			Value: "",
+		},
+		&cli.StringFlag{
+			Name:  "chain-out",
+			Usage: "Output file for the certificate chain, the intermediate followed by the root (PEM)",
+			Value: "",
+		},
+		newMnemonicFlag(),
+		newEntropyFlag(),
+	},
+}
+
+// actionChain writes the intermediate key like restore does, then the root, intermediate, and chain certificates.
+// The root key itself is never written: it is restored from the mnemonic whenever the root has to sign again.
+func actionChain(ctx context.Context, c *cli.Command) error {
+	setLogging(c)
+	ki, err := getKeyInfo(c)
+	if err != nil {
+		return err
+	}
+	if ki.Cert != nil {
+		return cli.Exit("Use --root-cert-out, --intermediate-cert-out, and --chain-out instead of --cert-out and --csr-out with chain.", 1)
+	}
+	if ki.StepCA != nil {
+		return cli.Exit("chain writes the intermediate key, use --step-ca with generate or restore to write a step-ca root.", 1)
+	}
+
+	if ki.Path != nil {
+		return cli.Exit("chain derives keys at consecutive indices, which --bip32-path does not support.", 1)
+	}
+	rootOpts, intermediateOpts, err := getChainOptions(c)
+	if err != nil {
+		return err
+	}
+	if c.String("root-cert-out") == "" && c.String("intermediate-cert-out") == "" && c.String("chain-out") == "" {
+		return cli.Exit("At least one of --root-cert-out, --intermediate-cert-out, or --chain-out is required.", 1)
+	}
+
+	if err := confirmOutFile(c); err != nil {
+		return err
+	}
+
+	mnemonic, gen, err := readMnemonic(c, ki.Generator)
+	if err != nil {
+		return err
+	}
+	ki.Generator = gen
+
+	root, err := gen.GenerateLabeledKeyFromMnemonic(ctx, ki.KeyType, ki.KeyId, ki.Salt, mnemonic, ki.Label)
+	if err != nil {
+		return err
+	}
+	// the root key is never written, but it is the most sensitive key of the ceremony
+	auditKey(c, ki.Label, root)
+	intermediateLabel := keys.KeyLabel{Identity: ki.Label.Identity, Index: ki.Label.Index + 1}
+	intermediate, err := gen.GenerateLabeledKeyFromMnemonic(ctx, ki.KeyType, ki.KeyId, ki.Salt, mnemonic, intermediateLabel)
+	if err != nil {
+		return err
+	}
+
+	// issue the certificates before the intermediate key is encrypted for output
+	rootDER, err := root.Certificate(rootOpts)
+	if err != nil {
+		return err
+	}
+	intermediateDER, err := root.IssueCertificate(rootDER, *intermediate, intermediateOpts)
+	if err != nil {
+		return err
+	}
+	log.Info().Int("index", ki.Label.Index).Str("fingerprint", root.Fingerprint()).Msg("Derived root CA key.")
+	log.Info().Int("index", intermediateLabel.Index).Str("fingerprint", intermediate.Fingerprint()).Msg("Derived intermediate CA key.")
+
+	ki.Label = intermediateLabel
+	if err := outputKey(ctx, c, ki, intermediate); err != nil {
+		return err
+	}
+
+	// certificates are public, so they are written world-readable
+	outputs := []struct {
+		flag string
+		pem  string
+		msg  string
+	}{
+		{"root-cert-out", keys.CertificatePEM(rootDER), "Wrote root CA certificate."},
+		{"intermediate-cert-out", keys.CertificatePEM(intermediateDER), "Wrote intermediate CA certificate."},
+		{"chain-out", keys.CertificateChainPEM(intermediateDER, rootDER), "Wrote certificate chain."},
+	}
+	for _, output := range outputs {
+		path := c.String(output.flag)
+		if path == "" {
+			continue
+		}
+		data, err := exportPEM(c, output.pem)
+		if err != nil {
+			return err
+		}
+		if err := writeOutput(path, data, 0644); err != nil {
+			return err
+		}
+		log.Info().Str("file", path).Msg(output.msg)
+	}
+	return nil
+}
+
+// getChainOptions validates the chain flags into the root and intermediate certificate options. The root may sign
+// one level of intermediates, and the intermediate only end-entity certificates.
+func getChainOptions(c *cli.Command) (keys.CertOptions, keys.CertOptions, error) {
+	rootDays, intermediateDays := c.Int("root-days"), c.Int("intermediate-days")
+	if rootDays <= 0 || intermediateDays <= 0 {
+		return keys.CertOptions{}, keys.CertOptions{}, cli.Exit("The certificate validity (--root-days, --intermediate-days) must be at least one day.", 1)
+	}
+	if intermediateDays > rootDays {
+		return keys.CertOptions{}, keys.CertOptions{}, cli.Exit(fmt.Sprintf("The intermediate certificate (%d days) must not outlive the root certificate (%d days).", intermediateDays, rootDays), 1)
+	}
+
+	// both certificates share a start, so that a fixed --not-before reproduces the whole chain
+	notBefore, err := parseCertTime(c, "not-before")
+	if err != nil {
+		return keys.CertOptions{}, keys.CertOptions{}, err
+	}
+	if notBefore.IsZero() {
+		notBefore = time.Now()
+	}
+
+	root := keys.CertOptions{
+		CommonName: c.String("root-subject"),
+		IsCA:       true,
+		MaxPathLen: 1,
+		NotBefore:  notBefore,
+		Validity:   time.Duration(rootDays) * 24 * time.Hour,
+	}
+	intermediate := keys.CertOptions{
+		CommonName:     c.String("intermediate-subject"),
+		IsCA:           true,
+		MaxPathLenZero: true,
+		NotBefore:      notBefore,
+		Validity:       time.Duration(intermediateDays) * 24 * time.Hour,
+	}
+	return root, intermediate, nil
+}
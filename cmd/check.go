@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/goodieshq/bipkey/pkg/keys"
+	"github.com/urfave/cli/v3"
+)
+
+// cmdCheckMnemonic verifies a mnemonic without deriving a key
+var cmdCheckMnemonic = &cli.Command{
+	Name:      "check-mnemonic",
+	Usage:     "Check that a mnemonic is a valid BIP-39 mnemonic (word list and checksum) without deriving a key",
+	UsageText: "bipkey [-strict] check-mnemonic [--mnemonic <words> | --file <path>]",
+	Action:    actionCheckMnemonic,
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:    "mnemonic",
+			Aliases: []string{"m"},
+			Usage:   "24-word mnemonic to check (first 4 letters minimum)",
+		},
+		&cli.StringFlag{
+			Name:  "file",
+			Usage: "File containing the mnemonic to check, as words or the numbered key display",
+		},
+	},
+}
+
+// actionCheckMnemonic prints VALID or INVALID for the mnemonic and exits non-zero if it is invalid
+func actionCheckMnemonic(ctx context.Context, c *cli.Command) error {
+	setLogging(c)
+
+	mnemonicString := c.String("mnemonic")
+	if path := c.String("file"); path != "" {
+		if mnemonicString != "" {
+			return cli.Exit("Only one of --mnemonic or --file may be specified.", 1)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return cli.Exit(fmt.Sprintf("Failed to read mnemonic file: %v", err), 1)
+		}
+		mnemonicString = string(data)
+	}
+
+	if strings.TrimSpace(mnemonicString) == "" {
+		var err error
+		mnemonicString, err = promptMnemonic()
+		if err != nil {
+			return err
+		}
+	}
+
+	gen, err := getGenerator(c)
+	if err != nil {
+		return err
+	}
+	if gen, err = mnemonicGenerator(c, gen, mnemonicString); err != nil {
+		return err
+	}
+
+	if err := checkMnemonic(gen, mnemonicString, c.Bool("strict")); err != nil {
+		fmt.Printf("INVALID: %v\n", err)
+		return cli.Exit("", 1)
+	}
+
+	fmt.Println("VALID")
+	return nil
+}
+
+// checkMnemonic normalizes the mnemonic and verifies its words and BIP-39 checksum against the generator's word list
+func checkMnemonic(gen *keys.Generator, mnemonicString string, strict bool) error {
+	parse := gen.ParseMnemonic
+	if strict {
+		parse = gen.ParseMnemonicStrict
+	}
+
+	mnemonic, err := parse(mnemonicString)
+	if err != nil {
+		return err
+	}
+	if _, err := gen.EntropyFromMnemonic(mnemonic); err != nil {
+		return err
+	}
+	return nil
+}
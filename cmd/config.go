@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"github.com/urfave/cli/v3"
+	"gopkg.in/yaml.v3"
+)
+
+// CONFIG_FILE_NAME is the name of the default config file in the user's bipkey config directory
+const CONFIG_FILE_NAME = "config.yaml"
+
+// configKeys lists the global flags that the config file may set defaults for
+var configKeys = []string{
+	"algorithm", "ecc", "rsa", "rsa-exponent", "rsa-exponent-fallback", "format", "pem-line-length", "derivation", "fingerprint-format", "language", "strict", "allow-repeated-words", "allow-low-entropy", "group-size", "group-separator", "columns", "kdf", "scrypt-n", "scrypt-r", "scrypt-p", "no-bip39-seed",
+	"allowed-algorithms", "log-file", "log-max-size", "log-max-backups", "salt", "salt-prompt", "salt-normalize", "salt-blocklist", "password", "password-file", "password-prompt", "prompt-timeout", "encrypt", "derive-password", "pbkdf2-prf", "compat-openssl", "record-salt",
+}
+
+// configSecretKeys lists the config keys that hold secrets, which should not be stored in plaintext
+var configSecretKeys = []string{"salt", "password"}
+
+// configSource supplies a flag default from the config file, with lower precedence than the command line
+type configSource struct {
+	path  string
+	key   string
+	value string
+}
+
+func (s *configSource) Lookup() (string, bool) { return s.value, true }
+func (s *configSource) String() string         { return fmt.Sprintf("config key %q in %s", s.key, s.path) }
+func (s *configSource) GoString() string {
+	return fmt.Sprintf("&configSource{path:%q,key:%q}", s.path, s.key)
+}
+
+// defaultConfigPath returns the path of the default config file, e.g. ~/.config/bipkey/config.yaml
+func defaultConfigPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "bipkey", CONFIG_FILE_NAME)
+}
+
+// configPathFromArgs returns the --config path given on the command line, which must be known before the flags are parsed
+func configPathFromArgs(args []string) (string, bool) {
+	return flagValueFromArgs(args, "config")
+}
+
+// flagValueFromArgs returns the value of the named flag given on the command line, before the flags are parsed
+func flagValueFromArgs(args []string, flagName string) (string, bool) {
+	for i, arg := range args {
+		if arg == "--" {
+			break
+		}
+		name, value, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if !strings.HasPrefix(arg, "-") || name != flagName {
+			continue
+		}
+		if hasValue {
+			return value, true
+		}
+		if i+1 < len(args) {
+			return args[i+1], true
+		}
+		return "", true
+	}
+	return "", false
+}
+
+// argsHaveFlag reports whether any of the named flags is given on the command line
+func argsHaveFlag(args []string, names ...string) bool {
+	for _, arg := range args {
+		if arg == "--" {
+			break
+		}
+		if !strings.HasPrefix(arg, "-") {
+			continue
+		}
+		name, _, _ := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if slices.Contains(names, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseConfig parses the config file data into flag values keyed by flag name, rejecting unknown keys
+func parseConfig(data []byte) (map[string]string, error) {
+	var raw map[string]any
+	if err := yaml.NewDecoder(bytes.NewReader(data)).Decode(&raw); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("invalid config file: %w", err)
+	}
+
+	values := make(map[string]string, len(raw))
+	for key, value := range raw {
+		if !slices.Contains(configKeys, key) {
+			return nil, fmt.Errorf("unsupported config key %q, expected one of: %s", key, strings.Join(configKeys, ", "))
+		}
+		switch value.(type) {
+		case string, bool, int:
+			values[key] = fmt.Sprint(value)
+		default:
+			return nil, fmt.Errorf("config key %q must be a string, number, or boolean", key)
+		}
+	}
+
+	given := 0
+	for _, name := range keyTypeFlags {
+		if values[name] != "" {
+			given++
+		}
+	}
+	if given > 1 {
+		return nil, fmt.Errorf("config keys \"algorithm\", \"ecc\", and \"rsa\" are mutually exclusive")
+	}
+	return values, nil
+}
+
+// loadConfig applies the config file as flag defaults for the given command line. The file is the --config path,
+// or the default path if it exists. Flags given on the command line always override the config file.
+func loadConfig(cmd *cli.Command, args []string) error {
+	path, explicit := configPathFromArgs(args)
+	if !explicit {
+		path = defaultConfigPath()
+	}
+	if path == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) && !explicit {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	values, err := parseConfig(data)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	for _, key := range configSecretKeys {
+		if values[key] != "" {
+			log.Warn().Str("file", path).Msgf("The config file contains the %s in plaintext. Prefer --%s-prompt and keep the file private.", key, key)
+		}
+	}
+
+	// a key type given on the command line or by a recovery file replaces the configured one, rather than conflicting with it
+	keyTypeGiven := argsHaveFlag(args, append(keyTypeFlags, "recovery-file")...)
+	passwordGiven := argsHaveFlag(args, "password-file")
+
+	for _, flag := range cmd.Flags {
+		name := flag.Names()[0]
+		value, ok := values[name]
+		if !ok || (slices.Contains(keyTypeFlags, name) && keyTypeGiven) {
+			continue
+		}
+		// an explicit password file, or the environment variable with --encrypt, replaces a configured password
+		if name == "password" && (passwordGiven || (os.Getenv(PASSWORD_ENV_VAR) != "" && (argsHaveFlag(args, "encrypt") || values["encrypt"] == "true"))) {
+			continue
+		}
+
+		source := cli.NewValueSourceChain(&configSource{path: path, key: name, value: value})
+		switch f := flag.(type) {
+		case *cli.StringFlag:
+			f.Sources.Append(source)
+		case *cli.BoolFlag:
+			f.Sources.Append(source)
+		case *cli.IntFlag:
+			f.Sources.Append(source)
+		case *cli.DurationFlag:
+			f.Sources.Append(source)
+		}
+		log.Debug().Str("key", name).Msg("Applied config file default.")
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package main
+
+import "testing"
+
+func TestParseConfig(t *testing.T) {
+	values, err := parseConfig([]byte("ecc: p384\nformat: sec1\nsalt-prompt: true\n"))
+	if err != nil {
+		t.Fatalf("failed to parse config: %v", err)
+	}
+	if values["ecc"] != "p384" || values["format"] != "sec1" || values["salt-prompt"] != "true" || len(values) != 3 {
+		t.Fatalf("unexpected config values: %v", values)
+	}
+
+	if values, err := parseConfig(nil); err != nil || len(values) != 0 {
+		t.Fatalf("expected an empty config to set no values, got %v (%v)", values, err)
+	}
+
+	for _, data := range []string{
+		"curve: p384\n",           // unknown key
+		"ecc: p384\nrsa: 4096\n",  // conflicting key types
+		"format: [pkcs8, sec1]\n", // not a scalar
+		"ecc: [p384\n",            // invalid YAML
+		"brainwallet: true\n",     // brainwallet mode is only chosen on the command line
+	} {
+		if _, err := parseConfig([]byte(data)); err == nil {
+			t.Fatalf("expected config %q to be rejected", data)
+		}
+	}
+}
+
+func TestConfigPathFromArgs(t *testing.T) {
+	tests := []struct {
+		args     []string
+		path     string
+		explicit bool
+	}{
+		{[]string{"bipkey", "restore"}, "", false},
+		{[]string{"bipkey", "--config", "a.yaml", "restore"}, "a.yaml", true},
+		{[]string{"bipkey", "-config=b.yaml", "restore"}, "b.yaml", true},
+		{[]string{"bipkey", "restore", "--", "--config", "c.yaml"}, "", false},
+	}
+
+	for _, tt := range tests {
+		path, explicit := configPathFromArgs(tt.args)
+		if path != tt.path || explicit != tt.explicit {
+			t.Fatalf("unexpected config path for %v: got %q (%v), want %q (%v)", tt.args, path, explicit, tt.path, tt.explicit)
+		}
+	}
+
+	if !argsHaveFlag([]string{"bipkey", "restore", "-rsa=4096"}, "ecc", "rsa") {
+		t.Fatalf("expected -rsa=4096 to be detected")
+	}
+	if argsHaveFlag([]string{"bipkey", "restore", "rsa"}, "ecc", "rsa") {
+		t.Fatalf("expected a positional argument not to be detected as a flag")
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/goodieshq/bipkey/pkg/keys"
+	"github.com/rs/zerolog/log"
+	"github.com/urfave/cli/v3"
+)
+
+// cmdConvert re-exports an existing private key file in another format
+var cmdConvert = &cli.Command{
+	Name:      "convert",
+	Usage:     "Re-export an existing PEM or OpenSSH private key in another --format, without the mnemonic",
+	UsageText: "bipkey [-format <format>] [-password <password>] [-out <file>] convert --in <key file> [--in-password <password>]",
+	Action:    actionConvert,
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:     "in",
+			Usage:    "Private key file to convert (PKCS#8, encrypted PKCS#8, SEC1, PKCS#1, or OpenSSH)",
+			Required: true,
+		},
+		&cli.StringFlag{
+			Name:  "in-password",
+			Usage: "Password of an encrypted input key, prompted for without echo if needed and not given",
+			Value: "",
+		},
+	},
+}
+
+// actionConvert loads a private key file and prints or writes it in the requested format
+func actionConvert(ctx context.Context, c *cli.Command) error {
+	setLogging(c)
+
+	compat, err := getCompatOpenSSL(c)
+	if err != nil {
+		return err
+	}
+	format, err := keys.ParseFormat(c.String("format"))
+	if err != nil {
+		return cli.Exit(err.Error(), 1)
+	}
+	password, err := getPassword(c)
+	if err != nil {
+		return err
+	}
+	if password != "" && format != keys.FormatPKCS8 && compat == "" {
+		return cli.Exit(fmt.Sprintf("Password encryption is only supported with the %s format.", keys.FormatPKCS8), 1)
+	}
+	if c.Bool("ec-params") && format != keys.FormatSEC1 {
+		return cli.Exit(fmt.Sprintf("--ec-params requires the %s format.", keys.FormatSEC1), 1)
+	}
+
+	comment, err := getComment(c)
+	if err != nil {
+		return err
+	}
+	k, err := loadInputKey(c)
+	if err != nil {
+		return err
+	}
+	if comment != "" {
+		k.SetComment(comment)
+	}
+
+	if compat != "" {
+		format = k.OpenSSLFormat(compat)
+		if password != "" {
+			format = keys.FormatPKCS8
+		}
+	}
+	if err := k.CheckFormat(format); err != nil {
+		return cli.Exit(err.Error(), 1)
+	}
+
+	if err := confirmOutFile(c); err != nil {
+		return err
+	}
+
+	if password != "" {
+		if err := encryptKey(c, k, password); err != nil {
+			return err
+		}
+	}
+
+	encoded, err := encodeKey(c, k, format)
+	if err != nil {
+		return cli.Exit(err.Error(), 1)
+	}
+
+	if fingerprint, err := k.PublicFingerprint(keys.FingerprintHex); err == nil {
+		log.Info().Str("fingerprint", fingerprint).Msg("Loaded private key.")
+	}
+
+	if c.String("out") == "" {
+		wrapped, err := exportPEM(c, encoded)
+		if err != nil {
+			return err
+		}
+		fmt.Print(wrapped)
+	} else if err := writeFile(c, encoded); err != nil {
+		log.Error().Err(err).Msg("Failed to write key to file")
+		return err
+	}
+	if err := writePublicKey(c, k); err != nil {
+		log.Error().Err(err).Msg("Failed to write public key to file")
+		return err
+	}
+	if err := writeSSHPublicKey(c, k); err != nil {
+		log.Error().Err(err).Msg("Failed to write OpenSSH public key to file")
+		return err
+	}
+	return nil
+}
+
+// loadInputKey loads the private key file given by --in, prompting for its password if it is encrypted and no
+// --in-password was given
+func loadInputKey(c *cli.Command) (*keys.Key, error) {
+	data, err := os.ReadFile(c.String("in"))
+	if err != nil {
+		return nil, cli.Exit(fmt.Sprintf("Failed to read the input key: %v", err), 1)
+	}
+
+	k, err := keys.LoadKeyFromPEM(data, c.String("in-password"))
+	if errors.Is(err, keys.ErrPasswordRequired) {
+		inPassword, perr := promptSecret("input key password", false)
+		if perr != nil {
+			return nil, perr
+		}
+		k, err = keys.LoadKeyFromPEM(data, inPassword)
+	}
+	if err != nil {
+		return nil, cli.Exit(fmt.Sprintf("Failed to load the input key: %v", err), 1)
+	}
+	return k, nil
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"runtime"
+	"runtime/debug"
+
+	"github.com/goodieshq/bipkey/pkg/keys"
+	"github.com/urfave/cli/v3"
+)
+
+// determinismModules lists the dependencies that key derivation and encoding depend on, with what each provides
+var determinismModules = []struct {
+	path    string
+	purpose string
+}{
+	{"github.com/tyler-smith/go-bip39", "BIP-39 mnemonic and seed"},
+	{"golang.org/x/crypto", "HKDF, scrypt, ChaCha20"},
+	{"github.com/youmark/pkcs8", "PKCS#8 encoding and encryption"},
+}
+
+// cmdVerifyDeterminism derives the known-answer keys and reports them with the Go and dependency versions of the build
+var cmdVerifyDeterminism = &cli.Command{
+	Name:      "verify-determinism",
+	Usage:     "Check that this build derives the canonical known-answer keys and report the Go and dependency versions it was built with",
+	UsageText: "bipkey [--algorithm <algorithm>] [--json] verify-determinism",
+	Action:    actionVerifyDeterminism,
+}
+
+// ModuleVersion is the version of a dependency compiled into the build
+type ModuleVersion struct {
+	Path    string `json:"path"`
+	Version string `json:"version"`
+	Purpose string `json:"purpose"`
+}
+
+// DeterminismResult is the known-answer check of a single algorithm and derivation version
+type DeterminismResult struct {
+	Algorithm   string `json:"algorithm"`
+	Derivation  string `json:"derivation"`
+	Pass        bool   `json:"pass"`
+	Fingerprint string `json:"fingerprint,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
+// DeterminismReport attests that a build reproduces the canonical keys, along with the versions responsible
+type DeterminismReport struct {
+	GoVersion  string              `json:"go_version"`
+	Platform   string              `json:"platform"`
+	Version    string              `json:"bipkey_version"`
+	Derivation string              `json:"derivation"`
+	Modules    []ModuleVersion     `json:"modules"`
+	Results    []DeterminismResult `json:"results"`
+	Pass       bool                `json:"pass"`
+}
+
+// buildModuleVersions returns the versions of the determinism-relevant modules from the build info, "unknown" if the
+// build info is not available
+func buildModuleVersions() []ModuleVersion {
+	versions := make(map[string]string)
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, dep := range info.Deps {
+			version := dep.Version
+			if dep.Replace != nil {
+				version = fmt.Sprintf("%s => %s %s", dep.Version, dep.Replace.Path, dep.Replace.Version)
+			}
+			versions[dep.Path] = version
+		}
+	}
+
+	modules := make([]ModuleVersion, 0, len(determinismModules))
+	for _, module := range determinismModules {
+		version := versions[module.path]
+		if version == "" {
+			version = "unknown"
+		}
+		modules = append(modules, ModuleVersion{Path: module.path, Version: version, Purpose: module.purpose})
+	}
+	return modules
+}
+
+// actionVerifyDeterminism derives the known-answer key of every selected key type and prints PASS or FAIL for each,
+// after the versions of the build. With derivation v1 in effect, the default, RSA keys are also checked against the
+// original v1 known answers, since those are the keys a default restore must reproduce. It exits non-zero if any key
+// differs from its known answer.
+func actionVerifyDeterminism(ctx context.Context, c *cli.Command) error {
+	setLogging(c)
+
+	cases, err := keyTypeCases(c)
+	if err != nil {
+		return err
+	}
+	derivation, err := keys.ParseDerivationVersion(c.String("derivation"))
+	if err != nil {
+		return cli.Exit(err.Error(), 1)
+	}
+
+	report := DeterminismReport{
+		GoVersion:  runtime.Version(),
+		Platform:   runtime.GOOS + "/" + runtime.GOARCH,
+		Version:    c.Root().Version,
+		Derivation: derivation.String(),
+		Modules:    buildModuleVersions(),
+		Pass:       true,
+	}
+	addResult := func(result DeterminismResult, err error) {
+		if err != nil {
+			result.Error = err.Error()
+			report.Pass = false
+		} else {
+			result.Pass = true
+		}
+		report.Results = append(report.Results, result)
+	}
+	for _, tc := range cases {
+		caps, err := keys.GetCapabilities(tc.keyType, tc.keyId)
+		if err != nil {
+			return cli.Exit(err.Error(), 1)
+		}
+		result := DeterminismResult{Algorithm: caps.Algorithm, Derivation: keys.SELFTEST_DERIVATION.String()}
+		result.Fingerprint, err = keys.CheckKnownAnswer(ctx, tc.keyType, tc.keyId)
+		addResult(result, err)
+
+		if derivation == keys.DerivationV1 && tc.keyType == keys.KeyTypeRSA {
+			result = DeterminismResult{Algorithm: caps.Algorithm, Derivation: keys.DerivationV1.String()}
+			result.Fingerprint, err = keys.CheckLegacyRSAKnownAnswer(ctx, tc.keyId)
+			addResult(result, err)
+		}
+	}
+
+	if c.Bool("json") {
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal determinism report: %w", err)
+		}
+		fmt.Println(string(data))
+	} else {
+		printDeterminismReport(&report)
+	}
+
+	if !report.Pass {
+		return cli.Exit("This build does not reproduce the canonical keys. Do not use it to generate or restore keys.", 1)
+	}
+	return nil
+}
+
+// printDeterminismReport prints the versions of the build followed by the result of each algorithm
+func printDeterminismReport(report *DeterminismReport) {
+	fmt.Printf("Go Version: %s (%s)\n", report.GoVersion, report.Platform)
+	fmt.Printf("bipkey Version: %s\n", report.Version)
+	fmt.Printf("Derivation: %s\n", report.Derivation)
+	fmt.Println("Modules:")
+	for _, module := range report.Modules {
+		fmt.Printf("  %-33s %-36s %s\n", module.Path, module.Version, module.Purpose)
+	}
+	fmt.Println()
+
+	passed := 0
+	for _, result := range report.Results {
+		if result.Pass {
+			passed++
+			fmt.Printf("PASS  %-12s %-3s %s\n", result.Algorithm, result.Derivation, result.Fingerprint)
+		} else {
+			fmt.Printf("FAIL  %-12s %-3s %s\n", result.Algorithm, result.Derivation, result.Error)
+		}
+	}
+	fmt.Printf("\nDeterminism verified for %d of %d known answers.\n", passed, len(report.Results))
+}
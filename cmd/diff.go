@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/goodieshq/bipkey/pkg/keys"
+	"github.com/urfave/cli/v3"
+)
+
+// cmdDiffMnemonic compares an entered mnemonic against a reference to locate transcription errors
+var cmdDiffMnemonic = &cli.Command{
+	Name:      "diff-mnemonic",
+	Usage:     "Compare a mnemonic against a reference and show the word positions that differ",
+	UsageText: "bipkey diff-mnemonic --mnemonic <words> --reference <words>",
+	Action:    actionDiffMnemonic,
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:    "mnemonic",
+			Aliases: []string{"m"},
+			Usage:   "24-word mnemonic to check, e.g. as entered during a failed restore (prompted if not given)",
+		},
+		&cli.StringFlag{
+			Name:     "reference",
+			Aliases:  []string{"r"},
+			Usage:    "24-word reference mnemonic to compare against, e.g. a second copy of the backup",
+			Required: true,
+		},
+	},
+}
+
+// actionDiffMnemonic prints every word position where the mnemonics differ and exits non-zero if any do
+func actionDiffMnemonic(ctx context.Context, c *cli.Command) error {
+	setLogging(c)
+
+	mnemonicString := c.String("mnemonic")
+	if mnemonicString == "" {
+		var err error
+		mnemonicString, err = promptMnemonic()
+		if err != nil {
+			return err
+		}
+	}
+
+	// the reference is a known good copy, so it determines the word list language
+	gen, err := getGenerator(c)
+	if err != nil {
+		return err
+	}
+	if gen, err = mnemonicGenerator(c, gen, c.String("reference")); err != nil {
+		return err
+	}
+
+	diffs, err := gen.DiffMnemonic(mnemonicString, c.String("reference"))
+	if err != nil {
+		return cli.Exit(fmt.Sprintf("Invalid mnemonic: %v", err), 1)
+	}
+
+	if len(diffs) == 0 {
+		fmt.Println("The mnemonics match.")
+		return nil
+	}
+
+	for _, diff := range diffs {
+		fmt.Printf("Word %d differs: %s (reference: %s)\n", diff.Position, describeWord(diff.Word, diff.WordKnown),
+			describeWord(diff.Reference, diff.ReferenceKnown))
+	}
+	return cli.Exit(fmt.Sprintf("%d of %d words differ.", len(diffs), keys.MNEMONIC_WORD_COUNT), 1)
+}
+
+// describeWord quotes a mnemonic word and marks words that are not in the BIP-39 word list
+func describeWord(word string, known bool) string {
+	if known {
+		return fmt.Sprintf("'%s'", word)
+	}
+	return fmt.Sprintf("'%s' (not a BIP-39 word)", word)
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/goodieshq/bipkey/pkg/keys"
+	"github.com/urfave/cli/v3"
+)
+
+// dryRun validates the remaining inputs of generate (or restore, which also reads and checks the mnemonic) and
+// prints what would be done, without deriving a key or writing any file. Every output path must be writable, and
+// existing files are conflicts unless --force is given, since a dry run never prompts.
+func dryRun(c *cli.Command, ki *KeyInfo, restore bool) error {
+	capabilities, err := keys.GetCapabilities(ki.KeyType, ki.KeyId)
+	if err != nil {
+		return cli.Exit(err.Error(), 1)
+	}
+
+	mnemonic := "a new random mnemonic"
+	if restore {
+		m, gen, err := readMnemonic(c, ki.Generator)
+		if err != nil {
+			return err
+		}
+		// restoring tolerates a bad checksum, but a runbook check should catch the likely typo
+		if _, err := gen.EntropyFromMnemonic(m); err != nil {
+			return cli.Exit(fmt.Sprintf("Invalid mnemonic: %v", err), 1)
+		}
+		mnemonic = "the given mnemonic (checksum valid)"
+	}
+
+	outputs, err := checkOutputPaths(c)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("Dry run: all inputs are valid. No key was derived and no file was written.")
+	fmt.Printf("Key Algorithm: %s\n", capabilities.Algorithm)
+	if ki.Path != nil {
+		fmt.Printf("Key Derivation: SLIP-10 (BIP-32 path %s)\n", ki.Path)
+	} else if ki.Generator.EntropySeed() {
+		fmt.Printf("Key Derivation: %s (mnemonic entropy seed, no BIP-39 seed)\n", ki.Generator.Derivation())
+	} else {
+		fmt.Printf("Key Derivation: %s\n", ki.Generator.Derivation())
+	}
+	if ki.Label.Identity != "" {
+		fmt.Printf("Key Identity: \"%s\"\n", ki.Label.Identity)
+	}
+	fmt.Printf("Key Index: %d\n", ki.Label.Index)
+	if ki.Salt == "" {
+		fmt.Println("Key Salt: (none)")
+	} else {
+		fmt.Printf("Key Salt: %d characters\n", len([]rune(ki.Salt)))
+	}
+	fmt.Printf("Mnemonic: %s\n", mnemonic)
+	formats := make([]string, 0, len(ki.Formats))
+	for _, format := range ki.Formats {
+		formats = append(formats, string(format))
+	}
+	fmt.Printf("Format: %s\n", strings.Join(formats, ", "))
+	fmt.Printf("Encrypted: %t\n", ki.Password != "" || ki.DerivePassword)
+
+	if len(outputs) == 0 {
+		fmt.Println("Output Files: (none, the key is only displayed)")
+		return nil
+	}
+	fmt.Println("Output Files:")
+	for _, output := range outputs {
+		fmt.Printf("  --%s %s\n", output.flag, output.path)
+	}
+	return nil
+}
+
+// dryRunOutput is an output file that a dry run checked
+type dryRunOutput struct {
+	flag string
+	path string
+}
+
+// checkOutputPaths checks that every requested output file can be written without clobbering another output, and
+// without overwriting an existing file unless --force is given
+func checkOutputPaths(c *cli.Command) ([]dryRunOutput, error) {
+	var outputs []dryRunOutput
+	seen := make(map[string]string)
+
+	for _, name := range outputFileFlags {
+		for _, path := range outputPaths(c, name) {
+			abs, err := filepath.Abs(path)
+			if err != nil {
+				return nil, fmt.Errorf("failed to resolve output path: %w", err)
+			}
+			if other, ok := seen[abs]; ok {
+				return nil, cli.Exit(fmt.Sprintf("--%s and --%s both write to %q.", other, name, path), 1)
+			}
+			seen[abs] = name
+
+			if err := checkWritable(path, c.Bool("force")); err != nil {
+				return nil, err
+			}
+			outputs = append(outputs, dryRunOutput{flag: name, path: path})
+		}
+	}
+	return outputs, nil
+}
+
+// checkWritable checks that the output file could be created, or overwritten with force, without modifying anything
+func checkWritable(path string, force bool) error {
+	info, err := os.Stat(path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		dir := filepath.Dir(path)
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			return cli.Exit(fmt.Sprintf("Output directory %q of %q does not exist.", dir, path), 1)
+		}
+		// probe the directory with a temporary file, since it is the directory that must allow creating the file
+		probe, err := os.CreateTemp(dir, ".bipkey-dry-run-*")
+		if err != nil {
+			return cli.Exit(fmt.Sprintf("Output file %q cannot be created in %q: %v", path, dir, errors.Unwrap(err)), 1)
+		}
+		probe.Close()
+		return os.Remove(probe.Name())
+	case err != nil:
+		return fmt.Errorf("failed to check output file: %w", err)
+	case info.IsDir():
+		return cli.Exit(fmt.Sprintf("Output path %q is a directory.", path), 1)
+	case !force:
+		return cli.Exit(fmt.Sprintf("Output file %q already exists. Use --force to overwrite it.", path), 1)
+	}
+
+	// opening without truncation leaves the existing file untouched
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		return cli.Exit(fmt.Sprintf("Output file %q cannot be overwritten: %v", path, err), 1)
+	}
+	return f.Close()
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/goodieshq/bipkey/pkg/keys"
+	"github.com/urfave/cli/v3"
+)
+
+// cmdInspectEncrypted prints how an encrypted PKCS#8 key is protected, without its password
+var cmdInspectEncrypted = &cli.Command{
+	Name:      "inspect-encrypted",
+	Usage:     "Show the KDF, its parameters, and the cipher protecting an encrypted PKCS#8 key, without the password",
+	UsageText: "bipkey inspect-encrypted --in <key file> [--json]",
+	Action:    actionInspectEncrypted,
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:     "in",
+			Usage:    "Encrypted PKCS#8 private key file (PEM \"ENCRYPTED PRIVATE KEY\")",
+			Required: true,
+		},
+	},
+}
+
+// actionInspectEncrypted prints the encryption parameters of the --in key
+func actionInspectEncrypted(ctx context.Context, c *cli.Command) error {
+	setLogging(c)
+
+	data, err := os.ReadFile(c.String("in"))
+	if err != nil {
+		return cli.Exit(fmt.Sprintf("Failed to read the input key: %v", err), 1)
+	}
+	params, err := keys.InspectEncryptedPEM(data)
+	if err != nil {
+		return cli.Exit(err.Error(), 1)
+	}
+
+	if c.Bool("json") {
+		data, err := json.MarshalIndent(params, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal encryption parameters: %w", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	fmt.Printf("Scheme: %s\n", params.Scheme)
+	switch {
+	case params.Iterations != 0:
+		fmt.Printf("KDF: %s (%s, %d iterations)\n", params.KDF, params.PRF, params.Iterations)
+	case params.ScryptN != 0:
+		fmt.Printf("KDF: %s (N=%d, r=%d, p=%d)\n", params.KDF, params.ScryptN, params.ScryptR, params.ScryptP)
+	case params.KDF != "":
+		fmt.Printf("KDF: %s\n", params.KDF)
+	}
+	if params.SaltSize != 0 {
+		fmt.Printf("Salt: %d bytes\n", params.SaltSize)
+	}
+	if params.KeyLength != 0 {
+		fmt.Printf("Key Length: %d bytes\n", params.KeyLength)
+	}
+	if params.Cipher != "" {
+		fmt.Printf("Cipher: %s\n", params.Cipher)
+	}
+	if params.Supported {
+		fmt.Println("Supported: yes")
+	} else {
+		fmt.Println("Supported: no, bipkey cannot decrypt this key")
+	}
+	return nil
+}
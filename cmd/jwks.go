@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/goodieshq/bipkey/pkg/keys"
+	"github.com/rs/zerolog/log"
+	"github.com/urfave/cli/v3"
+)
+
+// cmdJWKS emits a JSON Web Key Set of the public keys derived at consecutive indices
+var cmdJWKS = &cli.Command{
+	Name:      "jwks",
+	Usage:     "Print a JSON Web Key Set of the public keys derived at consecutive indices of an existing mnemonic",
+	UsageText: "bipkey [--algorithm <algorithm>] [-salt <salt value>] [-index <first index>] jwks --count <n>",
+	Action:    actionJWKS,
+	Flags: []cli.Flag{
+		newMnemonicFlag(),
+		newEntropyFlag(),
+		&cli.IntFlag{
+			Name:  "count",
+			Usage: "Number of keys in the set, derived at indices --index through --index + count - 1",
+			Value: 1,
+		},
+	},
+}
+
+// actionJWKS derives the public keys of a rotation set and prints them as a JWKS
+func actionJWKS(ctx context.Context, c *cli.Command) error {
+	setLogging(c)
+	ki, err := getKeyInfo(c)
+	if err != nil {
+		return err
+	}
+
+	count := int(c.Int("count"))
+	if count < 1 {
+		return cli.Exit("The key count must be at least 1.", 1)
+	}
+
+	if ki.Path != nil {
+		return cli.Exit("jwks derives keys at consecutive indices, which --bip32-path does not support.", 1)
+	}
+	if err := confirmOutFile(c); err != nil {
+		return err
+	}
+
+	mnemonic, gen, err := readMnemonic(c, ki.Generator)
+	if err != nil {
+		return err
+	}
+	ki.Generator = gen
+
+	var derived []*keys.Key
+	for i := range count {
+		label := ki.Label
+		label.Index += i
+		k, err := ki.Generator.GenerateLabeledKeyFromMnemonic(ctx, ki.KeyType, ki.KeyId, ki.Salt, mnemonic, label)
+		if err != nil {
+			return err
+		}
+		auditKey(c, label, k)
+		log.Debug().Int("index", label.Index).Msg("Derived key for the key set.")
+		derived = append(derived, k)
+	}
+
+	jwks, err := keys.NewJWKS(derived...)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(jwks, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal JWKS: %w", err)
+	}
+	fmt.Println(string(data))
+
+	// the key set only holds public keys, so it is written world-readable
+	if err := writeOutput(c.String("out"), string(data)+"\n", 0644); err != nil {
+		log.Error().Err(err).Msg("Failed to write JWKS to file")
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/goodieshq/bipkey/pkg/keys"
+	"github.com/rs/zerolog"
+	"github.com/urfave/cli/v3"
+)
+
+// LOG_FILE_PERMS are the permissions of a new log file. The log holds no secrets, but it is an audit trail.
+const LOG_FILE_PERMS = 0600
+
+// auditLog records the keys that were derived, it only writes to the log file
+var auditLog = zerolog.Nop()
+
+// rotatingFile is an append-only log file that is rotated once it would exceed maxSize bytes, keeping up to
+// maxBackups older files named <path>.1 (newest) to <path>.<maxBackups>
+type rotatingFile struct {
+	mu         sync.Mutex
+	path       string
+	maxSize    int64 // rotation threshold in bytes, 0 to never rotate
+	maxBackups int
+	file       *os.File
+	size       int64
+}
+
+// openRotatingFile opens the log file for appending, creating it if needed
+func openRotatingFile(path string, maxSize int64, maxBackups int) (*rotatingFile, error) {
+	r := &rotatingFile{path: path, maxSize: maxSize, maxBackups: maxBackups}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *rotatingFile) open() error {
+	f, err := os.OpenFile(r.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, LOG_FILE_PERMS)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("failed to stat log file: %w", err)
+	}
+	r.file, r.size = f, info.Size()
+	return nil
+}
+
+// rotate shifts the backups up by one, dropping the oldest, and starts a new log file
+func (r *rotatingFile) rotate() error {
+	if err := r.file.Close(); err != nil {
+		return fmt.Errorf("failed to close log file: %w", err)
+	}
+	if r.maxBackups > 0 {
+		for i := r.maxBackups - 1; i > 0; i-- {
+			// missing backups are expected until the log has rotated maxBackups times
+			os.Rename(fmt.Sprintf("%s.%d", r.path, i), fmt.Sprintf("%s.%d", r.path, i+1))
+		}
+		if err := os.Rename(r.path, r.path+".1"); err != nil {
+			return fmt.Errorf("failed to rotate log file: %w", err)
+		}
+	} else if err := os.Remove(r.path); err != nil {
+		return fmt.Errorf("failed to rotate log file: %w", err)
+	}
+	return r.open()
+}
+
+// Write appends a log entry, rotating first if the entry would exceed the size limit. An entry is never split.
+func (r *rotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.maxSize > 0 && r.size > 0 && r.size+int64(len(p)) > r.maxSize {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// openLogFile opens the --log-file before any command runs, so an unwritable audit log stops the run up front
+func openLogFile(ctx context.Context, c *cli.Command) (context.Context, error) {
+	path := c.String("log-file")
+	if path == "" {
+		return ctx, nil
+	}
+	maxSize, maxBackups := c.Int("log-max-size"), c.Int("log-max-backups")
+	if maxSize < 0 || maxBackups < 0 {
+		return ctx, cli.Exit("--log-max-size and --log-max-backups cannot be negative.", 1)
+	}
+
+	f, err := openRotatingFile(path, int64(maxSize)<<20, maxBackups)
+	if err != nil {
+		return ctx, cli.Exit(err.Error(), 1)
+	}
+	auditLog = zerolog.New(f).With().Timestamp().Logger()
+	return ctx, nil
+}
+
+// auditKey records a derived key in the log file by its public parameters only: the command, algorithm, label,
+// derivation, and public key fingerprint in the --fingerprint-format. Every command deriving a key calls it, whether
+// or not the key is written.
+func auditKey(c *cli.Command, label keys.KeyLabel, k *keys.Key) {
+	event := auditLog.Info().Str("command", c.Name).Int("index", label.Index)
+	// the private key fingerprint changes with every encryption, the public one identifies the key across runs
+	if format, err := keys.ParseFingerprintFormat(c.String("fingerprint-format")); err == nil {
+		if fingerprint, err := k.PublicFingerprint(format); err == nil {
+			event = event.Str("fingerprint", fingerprint)
+		}
+	}
+	if caps, err := k.Capabilities(); err == nil {
+		event = event.Str("algorithm", caps.Algorithm)
+	}
+	if label.Identity != "" {
+		event = event.Str("identity", label.Identity)
+	}
+	event = event.Str("derivation", k.DerivationName())
+	if k.Path() != nil {
+		event = event.Str("bip32_path", k.Path().String())
+	}
+	event.Msg("Derived key.")
+}
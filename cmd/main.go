@@ -3,16 +3,28 @@ package main
 import (
 	"bufio"
 	"context"
+	"crypto/rsa"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
 	"os"
 	"os/signal"
+	"slices"
+	"strconv"
 	"strings"
 	"syscall"
+	"time"
+	"unicode"
 
 	"github.com/goodieshq/bipkey/pkg/keys"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 	"github.com/urfave/cli/v3"
+	"golang.org/x/term"
 )
 
 var app *cli.Command
@@ -20,9 +32,7 @@ var Version string = "dev"
 
 // initialize logging and the CLI application with commands and flags
 func init() {
-	log.Logger = log.Output(zerolog.ConsoleWriter{
-		Out: os.Stderr,
-	}).Level(zerolog.InfoLevel)
+	log.Logger = newLogger(colorDisabled()).Level(zerolog.InfoLevel)
 
 	cli.VersionPrinter = func(c *cli.Command) {
 		fmt.Printf("%s\n", c.Version)
@@ -33,7 +43,8 @@ func init() {
 		Version:     Version,
 		Usage:       "Generate and restore RSA/ECC private keys from BIP-39 mnemonics",
 		Description: "bipkey is a tool to generate and restore deterministic RSA/ECC private keys from BIP-39 mnemonics. Used for secure key backup and recovery for offline Certificate Authorities.",
-		UsageText:   "bipkey [-ecc <curve> | -rsa <key size>] [-salt <salt value>] [-password <pkcs8 password>] [generate/restore]",
+		UsageText:   "bipkey [--algorithm <algorithm>] [-salt <salt value>] [-password <pkcs8 password>] [generate/restore]",
+		Before:      beforeRun,
 		Commands: []*cli.Command{
 			{
 				Name:   "generate",
@@ -45,14 +56,32 @@ func init() {
 				Usage:  "Restore a private key from an existing mnemonic",
 				Action: actionRestore,
 				Flags: []cli.Flag{
-					&cli.StringFlag{
-						Name:    "mnemonic",
-						Aliases: []string{"m"},
-						Usage:   "Existing 24-word mnemonic to restore the key from (first 4 letters minimum)",
-						Value:   "",
-					},
+					newMnemonicFlag(),
+					newEntropyFlag(),
+					newBrainwalletFlag(),
 				},
 			},
+			cmdWizard,
+			cmdDeriveSecret,
+			cmdEncryptMnemonic,
+			cmdDecryptMnemonic,
+			cmdCapabilities,
+			cmdSpec,
+			cmdJWKS,
+			cmdSaltMigration,
+			cmdPin,
+			cmdStream,
+			cmdConvert,
+			cmdInspectEncrypted,
+			cmdMatch,
+			cmdChain,
+			cmdRecord,
+			cmdCheckMnemonic,
+			cmdAnalyzeMnemonic,
+			cmdDiffMnemonic,
+			cmdSelfTest,
+			cmdVerifyDeterminism,
+			cmdBench,
 		},
 		Flags: []cli.Flag{
 			&cli.BoolFlag{
@@ -60,6 +89,30 @@ func init() {
 				Usage:   "Enable verbose logging output",
 				Aliases: []string{"v"},
 			},
+			&cli.BoolFlag{
+				Name:  "no-color",
+				Usage: "Disable colorized logging output (also honors the NO_COLOR environment variable)",
+			},
+			&cli.StringFlag{
+				Name:  "log-file",
+				Usage: "Also write JSON logs to this file as an audit trail of the derived keys (never includes secrets)",
+				Value: "",
+			},
+			&cli.IntFlag{
+				Name:  "log-max-size",
+				Usage: "Rotate the log file once it would exceed this size in MiB (0 disables rotation)",
+				Value: 0,
+			},
+			&cli.IntFlag{
+				Name:  "log-max-backups",
+				Usage: "Number of rotated log files to keep as <log-file>.1 to <log-file>.N",
+				Value: 3,
+			},
+			&cli.StringFlag{
+				Name:  "config",
+				Usage: "Config file setting defaults for global flags (default: ~/.config/bipkey/config.yaml, if it exists)",
+				Value: "",
+			},
 			&cli.StringFlag{
 				Name:    "salt",
 				Aliases: []string{"s"},
@@ -75,9 +128,41 @@ func init() {
 					return nil
 				},
 			},
+			&cli.BoolFlag{
+				Name:  "salt-prompt",
+				Usage: "Prompt for the salt without echoing it (entered twice) when --salt is not given",
+			},
+			&cli.StringFlag{
+				Name:  "salt-normalize",
+				Usage: "Normalize the salt before derivation: nfkd (Unicode NFKD), trim (strip surrounding whitespace), both as \"nfkd,trim\", or none to use it as entered. Changes the derived key, restore with the same setting.",
+				Value: keys.SALT_NORMALIZATION_NONE,
+				Validator: func(val string) error {
+					if _, err := keys.ParseSaltNormalization(val); err != nil {
+						return cli.Exit(err.Error(), 1)
+					}
+					return nil
+				},
+			},
+			&cli.StringFlag{
+				Name:  "salt-blocklist",
+				Usage: "File of banned weak salts, one per line; generation is refused if the salt matches an entry (case-insensitive)",
+				Value: "",
+			},
+			&cli.StringFlag{
+				Name:  "algorithm",
+				Usage: "Generate a private key of the specified algorithm (e.g. ecc:p256, ecc:p384, ed25519, rsa:2048, rsa:4096)",
+				Value: "",
+				Validator: func(val string) error {
+					if _, _, err := keys.ParseAlgorithm(val); err != nil {
+						fmt.Printf("%s\n", keys.SupportedAlgorithms())
+						return cli.Exit(err.Error(), 1)
+					}
+					return nil
+				},
+			},
 			&cli.StringFlag{
 				Name:  "ecc",
-				Usage: "Generate an ECC private key with the specified curve (e.g. p256, p384, p521, ed25519)",
+				Usage: "Generate an ECC private key with the specified curve (e.g. p256, p384, p521, ed25519). Deprecated alias of --algorithm ecc:<curve>",
 				Value: "",
 				Validator: func(val string) error {
 					id, err := keys.ParseECCCurve(val)
@@ -105,7 +190,7 @@ func init() {
 			},
 			&cli.StringFlag{
 				Name:  "rsa",
-				Usage: "Generate an RSA private key with the specified bit size (2048, 3072, 4096)",
+				Usage: "Generate an RSA private key with the specified bit size (2048, 3072, 4096). Deprecated alias of --algorithm rsa:<size>",
 				Value: "",
 				Validator: func(val string) error {
 					id, err := keys.ParseRSAKeyID(val)
@@ -133,17 +218,318 @@ func init() {
 			&cli.StringFlag{
 				Name:    "out",
 				Aliases: []string{"o"},
-				Usage:   "Output file to save the generated key in PEM format.",
+				Usage:   "Output file to save the generated key in PEM format, or a template such as \"key.{ext}\" with one file per --format",
 				Value:   "",
 			},
+			&cli.StringFlag{
+				Name:  "mnemonic-out",
+				Usage: "Output file to save the mnemonic words, grouped per --group-size, e.g. to print a backup card (secret, owner-only)",
+				Value: "",
+			},
+			&cli.IntFlag{
+				Name:  "group-size",
+				Usage: "Show the plain mnemonic in groups of this many words, e.g. 4 for a backup card (0 shows all words on one line)",
+				Value: 0,
+				Validator: func(n int) error {
+					if n < 0 {
+						return cli.Exit("The mnemonic group size must not be negative.", 1)
+					}
+					return nil
+				},
+			},
+			&cli.StringFlag{
+				Name:  "group-separator",
+				Usage: "Separator between mnemonic word groups, with \\n and \\t escapes, e.g. \" | \"",
+				Value: "\\n",
+			},
+			&cli.IntFlag{
+				Name:  "columns",
+				Usage: "Number of columns of the numbered mnemonic display (0 fits as many as the terminal width allows, 6 if it is unknown)",
+				Value: 0,
+				Validator: func(n int) error {
+					if n < 0 {
+						return cli.Exit("The mnemonic display columns must not be negative.", 1)
+					}
+					return nil
+				},
+			},
+			&cli.StringFlag{
+				Name:  "entropy-out",
+				Usage: "Output file to save the 32-byte mnemonic entropy as hex, a compact backup of the mnemonic (secret, owner-only)",
+				Value: "",
+			},
+			&cli.StringFlag{
+				Name:  "pub-out",
+				Usage: "Output file to save the public key in PEM format (SubjectPublicKeyInfo, world-readable)",
+				Value: "",
+			},
+			&cli.StringFlag{
+				Name:  "pub-raw-out",
+				Usage: "Output file to save the raw public key as hex (uncompressed EC point, Ed25519 public key, or RSA modulus, world-readable)",
+				Value: "",
+			},
+			&cli.BoolFlag{
+				Name:  "pub-compressed",
+				Usage: "Write the --pub-raw-out EC point in compressed SEC1 form (0x02/0x03 || X), NIST curves only",
+			},
+			&cli.StringFlag{
+				Name:  "ssh-pub-out",
+				Usage: "Output file to save the OpenSSH public key in authorized_keys form, ending in the --comment (world-readable)",
+				Value: "",
+			},
+			&cli.StringFlag{
+				Name:  "metadata-out",
+				Usage: "Output file to save the derivation parameters as JSON (key type, size, salt, KDF, index), everything but the mnemonic. Exposes the salt.",
+				Value: "",
+			},
+			&cli.StringFlag{
+				Name:  "record-out",
+				Usage: "Output file to save a JSON inventory record of the key (type, size, label, fingerprint, creation), free of secrets and, without --record-salt, of the salt",
+				Value: "",
+			},
+			&cli.BoolFlag{
+				Name:  "record-salt",
+				Usage: "Include the salt in the --record-out inventory record, if the inventory may hold it",
+			},
+			&cli.StringFlag{
+				Name:  "tink-out",
+				Usage: "Output file to save the key as a cleartext Tink JSON keyset, e.g. ECDSA_P256 or ED25519 parameters (secret, owner-only)",
+				Value: "",
+			},
+			&cli.StringFlag{
+				Name:  "format",
+				Usage: "Private key output format (pkcs8, sec1, pkcs1, openssh), or a comma-separated list written to an --out template such as \"key.{ext}\"",
+				Value: "pkcs8",
+				Validator: func(val string) error {
+					if _, err := keys.ParseFormats(val); err != nil {
+						fmt.Printf("%s\n", keys.SupportedFormats())
+						return cli.Exit(err.Error(), 1)
+					}
+					return nil
+				},
+			},
+			&cli.BoolFlag{
+				Name:  "ec-params",
+				Usage: "Precede the SEC1 private key file with an \"EC PARAMETERS\" block naming the curve, for legacy openssl ec workflows (--format sec1 only)",
+			},
+			&cli.IntFlag{
+				Name:  "pem-line-length",
+				Usage: "Width of the base64 lines of exported PEM files, for strict parsers. 0 writes each block on a single line.",
+				Value: keys.PEM_LINE_LENGTH,
+				Validator: func(val int) error {
+					if val < 0 {
+						return cli.Exit("--pem-line-length must not be negative", 1)
+					}
+					return nil
+				},
+			},
+			&cli.StringFlag{
+				Name:  "identity",
+				Usage: "Derive the keys of a named identity (e.g. \"root-ca\"), giving each algorithm a coordinated key from one mnemonic",
+				Value: "",
+			},
+			&cli.IntFlag{
+				Name:  "index",
+				Usage: "Derive the independent key at this index (e.g. for a rotation set). Index 0 is the original key.",
+				Value: 0,
+			},
+			&cli.StringFlag{
+				Name:  "comment",
+				Usage: "Comment embedded in the OpenSSH key and --ssh-pub-out, and the certificate subject fallback (default: \"bipkey:<identity>:<index>\" from the key label)",
+				Value: "",
+			},
+			&cli.IntFlag{
+				Name:  "max-rsa-retries",
+				Usage: "Maximum prime candidates drawn per RSA prime before giving up, guards against a degenerate entropy source",
+				Value: keys.DEFAULT_MAX_PRIME_CANDIDATES,
+			},
+			&cli.IntFlag{
+				Name:  "stream-buffer-size",
+				Usage: fmt.Sprintf("Bytes of ChaCha20 keystream produced per call during derivation (1 to %d), a performance knob for large RSA keys that does not change the keys", keys.MAX_STREAM_BUFFER_SIZE),
+				Value: keys.DEFAULT_STREAM_BUFFER_SIZE,
+			},
+			&cli.IntFlag{
+				Name:  "rsa-exponent",
+				Usage: "Public exponent of derived RSA keys (derivation v2 and later). Keys must be restored with the same exponent settings.",
+				Value: keys.RSA_PUBLIC_EXPONENT,
+			},
+			&cli.BoolFlag{
+				Name:  "rsa-exponent-fallback",
+				Usage: "Use the next larger exponent of 3, 5, 17, 257, 65537 that is invertible when --rsa-exponent is not coprime with the primes, instead of drawing a new prime",
+			},
+			&cli.StringFlag{
+				Name:  "language",
+				Usage: "BIP-39 word list language (english, spanish, french, italian, czech, japanese, korean, chinese-simplified, chinese-traditional), or auto to detect it from the mnemonic (new mnemonics are english)",
+				Value: LANGUAGE_AUTO,
+			},
+			&cli.BoolFlag{
+				Name:  "strict",
+				Usage: "Require the mnemonic as exact, full, lowercase BIP-39 words with a valid checksum (no 4-letter prefixes)",
+			},
+			&cli.BoolFlag{
+				Name:  "allow-repeated-words",
+				Usage: "Do not warn about, or ask to confirm, words the mnemonic repeats. BIP-39 allows repeated words.",
+			},
+			&cli.BoolFlag{
+				Name:  "allow-low-entropy",
+				Usage: "Do not warn about low-entropy patterns in a restored mnemonic, such as few distinct words or words in word list order",
+			},
+			&cli.StringFlag{
+				Name:  "derivation",
+				Usage: "Key derivation version (v1, v2, v3). Keys must be restored with the version they were generated with.",
+				Value: keys.DEFAULT_DERIVATION.String(),
+				Validator: func(val string) error {
+					if _, err := keys.ParseDerivationVersion(val); err != nil {
+						fmt.Printf("%s\n", keys.SupportedDerivations())
+						return cli.Exit(err.Error(), 1)
+					}
+					return nil
+				},
+			},
+			&cli.StringFlag{
+				Name:  "kdf",
+				Usage: "Seed KDF applied to the BIP-39 seed before HKDF (none, scrypt). Keys must be restored with the same KDF and parameters.",
+				Value: "none",
+			},
+			&cli.IntFlag{
+				Name:  "scrypt-n",
+				Usage: "scrypt CPU/memory cost N for --kdf scrypt, a power of two",
+				Value: keys.DEFAULT_SCRYPT_N,
+			},
+			&cli.IntFlag{
+				Name:  "scrypt-r",
+				Usage: "scrypt block size r for --kdf scrypt",
+				Value: keys.DEFAULT_SCRYPT_R,
+			},
+			&cli.IntFlag{
+				Name:  "scrypt-p",
+				Usage: "scrypt parallelization p for --kdf scrypt",
+				Value: keys.DEFAULT_SCRYPT_P,
+			},
+			&cli.BoolFlag{
+				Name:  "no-bip39-seed",
+				Usage: "Use the 32-byte mnemonic entropy as the HKDF secret instead of the BIP-39 seed (skips PBKDF2), for interop with other KDFs. Derives different keys, restore with the same flag.",
+			},
+			&cli.StringFlag{
+				Name:  "bip32-path",
+				Usage: "Derive the key along this BIP-32 path with SLIP-10, e.g. \"m/0'/1'/2'\", instead of bipkey's HKDF scheme. Only P-256 and Ed25519 are supported, not secp256k1 wallet keys. The salt is the BIP-39 passphrase.",
+				Value: "",
+			},
+			&cli.StringFlag{
+				Name:  "allowed-algorithms",
+				Usage: "Comma-separated algorithm policy, e.g. \"p384,p521,rsa-4096\" or \"ecc\"; other key types and sizes are rejected (default: all)",
+				Value: "",
+			},
+			&cli.StringFlag{
+				Name:  "recovery-file",
+				Usage: "Recovery record (YAML or JSON, see record) supplying the key type, derivation parameters, salt, index, and mnemonic to restore",
+				Value: "",
+			},
+			&cli.BoolFlag{
+				Name:  "dry-run",
+				Usage: "Validate the flags, salt policy, mnemonic (when restoring), and output paths, report the plan, and exit without deriving or writing anything",
+			},
+			&cli.BoolFlag{
+				Name:    "force",
+				Aliases: []string{"f"},
+				Usage:   "Overwrite the output file without prompting if it already exists",
+			},
+			&cli.StringFlag{
+				Name:  "expect-fingerprint",
+				Usage: "Expected SHA-256 public key fingerprint. Exits non-zero if the derived key does not match.",
+				Value: "",
+			},
+			&cli.StringFlag{
+				Name:  "fingerprint-format",
+				Usage: "Format of the expected fingerprint (hex, colon, base64)",
+				Value: "hex",
+				Validator: func(val string) error {
+					if _, err := keys.ParseFingerprintFormat(val); err != nil {
+						fmt.Printf("%s\n", keys.SupportedFingerprintFormats())
+						return cli.Exit(err.Error(), 1)
+					}
+					return nil
+				},
+			},
 			&cli.StringFlag{
 				Name:    "password",
 				Aliases: []string{"p"},
-				Usage:   "Optional password to encrypt the private key. Encryption is not deterministic, but the underlying key is.",
+				Usage:   "Optional password to encrypt the private key (or set " + PASSWORD_ENV_VAR + " with --encrypt). Encryption is not deterministic, but the underlying key is.",
 				Value:   "",
 			},
+			&cli.StringFlag{
+				Name:  "password-file",
+				Usage: "File containing the password to encrypt the private key, e.g. a CI secret. Trailing newlines are ignored.",
+				Value: "",
+			},
+			&cli.BoolFlag{
+				Name:  "password-prompt",
+				Usage: "Prompt for the encryption password without echoing it (entered twice) when no password is given",
+			},
+			&cli.DurationFlag{
+				Name:  "prompt-timeout",
+				Usage: "Give up on a prompt, confirmation, or piped mnemonic that receives no input within this time (0 waits indefinitely)",
+				Value: DEFAULT_PROMPT_TIMEOUT,
+				Validator: func(val time.Duration) error {
+					if val < 0 {
+						return cli.Exit("--prompt-timeout cannot be negative.", 1)
+					}
+					return nil
+				},
+			},
+			&cli.BoolFlag{
+				Name:  "encrypt",
+				Usage: "Require password encryption of the private key, failing if no password is given by --password, --password-file, " + PASSWORD_ENV_VAR + ", or --password-prompt",
+			},
+			&cli.BoolFlag{
+				Name:  "derive-password",
+				Usage: "Encrypt the private key under a password derived from the mnemonic and salt (shown by derive-secret --pem-password), so that the mnemonic alone restores and decrypts it. It adds no secrecy beyond the mnemonic.",
+			},
+			&cli.BoolFlag{
+				Name:  "split-password",
+				Usage: "Encrypt the --out key under a random password that is shown once to a second operator (or written to --password-out), never alongside the key",
+			},
+			&cli.StringFlag{
+				Name:  "password-out",
+				Usage: "Output file to save the random password of --split-password, for a different custodian than the key file (secret, owner-only). Implies --split-password.",
+				Value: "",
+			},
+			&cli.StringFlag{
+				Name:  "pbkdf2-prf",
+				Usage: "PBKDF2 PRF deriving the PKCS#8 encryption key from the password (sha256, sha512), to match what the decrypting tool supports",
+				Value: string(keys.PBKDF2PRFSHA256),
+				Validator: func(val string) error {
+					if _, err := keys.ParsePBKDF2PRF(val); err != nil {
+						fmt.Printf("%s\n", keys.SupportedPBKDF2PRFs())
+						return cli.Exit(err.Error(), 1)
+					}
+					return nil
+				},
+			},
+			&cli.StringFlag{
+				Name:  "compat-openssl",
+				Usage: "Write the key as the given OpenSSL version (3.0, 1.1.1) does by default: its format and PKCS#8 encryption (PBKDF2-HMAC-SHA256, 2048 iterations, AES-256-CBC). Replaces --format and --pbkdf2-prf.",
+				Value: "",
+				Validator: func(val string) error {
+					if _, err := keys.ParseOpenSSLVersion(val); err != nil {
+						fmt.Printf("%s\n", keys.SupportedOpenSSLVersions())
+						return cli.Exit(err.Error(), 1)
+					}
+					return nil
+				},
+			},
+			&cli.BoolFlag{
+				Name:  "json",
+				Usage: "Print the key information as JSON instead of text",
+			},
+			&cli.BoolFlag{
+				Name:  "include-private",
+				Usage: "Include the mnemonic, its word indices, and the private key in the JSON output",
+			},
 		},
 	}
+	app.Flags = append(app.Flags, certFlags()...)
+	app.Flags = append(app.Flags, vaultFlags()...)
 }
 
 func main() {
@@ -151,199 +537,1217 @@ func main() {
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
 	defer stop()
 
+	// the config file supplies flag defaults, so it is applied before the flags are parsed
+	if err := loadConfig(app, os.Args); err != nil {
+		log.Error().Err(err).Msg("Failed to load the config file")
+		os.Exit(1)
+	}
+	if err := loadRecoveryFile(app, os.Args); err != nil {
+		log.Error().Err(err).Msg("Failed to load the recovery file")
+		os.Exit(1)
+	}
+
 	if err := app.Run(ctx, os.Args); err != nil {
-		if err == context.Canceled {
+		if errors.Is(err, context.Canceled) {
 			return
 		}
-		// log.Fatal().Err(err).Msg("Application error")
+		// the error has been logged or printed where it occurred, cli.Exit errors have already exited
+		os.Exit(1)
 	}
 }
 
-func setLogging(c *cli.Command) {
-	if c.Bool("verbose") {
-		log.Logger = log.Logger.Level(zerolog.DebugLevel)
-	}
+// beforeRun applies the global settings every command shares before it runs
+func beforeRun(ctx context.Context, c *cli.Command) (context.Context, error) {
+	promptTimeout = c.Duration("prompt-timeout")
+	return openLogFile(ctx, c)
 }
 
-// writeFile writes the provided data to the specified output file
-func writeFile(c *cli.Command, data string) error {
-	// If no output file is specified, return early
-	outFile := c.String("out")
-	if outFile == "" {
-		return nil
-	}
-
-	// Create the output file, ensuring any existing file is overwritten
-	f, err := os.Create(outFile)
-	if err != nil {
-		return fmt.Errorf("failed to create output file: %w", err)
-	}
-	defer f.Close()
-
-	// Write the data to the file
-	_, err = f.WriteString(data)
-	if err != nil {
-		return fmt.Errorf("failed to write to output file: %w", err)
+// colorDisabled reports whether log output should be left uncolored, either because NO_COLOR is set to a non-empty value
+// or stderr is not a terminal
+func colorDisabled() bool {
+	if os.Getenv("NO_COLOR") != "" {
+		return true
 	}
-
-	return nil
+	return !term.IsTerminal(int(os.Stderr.Fd()))
 }
 
-type KeyInfo struct {
-	KeyType  keys.KeyType
-	KeyId    int
-	Salt     string
-	Password string
+// newLogger creates a console logger writing to stderr. The console log may quote the input, e.g. an invalid mnemonic
+// word, so it is never copied to the --log-file, which only receives the auditLog entries.
+func newLogger(noColor bool) zerolog.Logger {
+	return log.Output(zerolog.ConsoleWriter{
+		Out:     os.Stderr,
+		NoColor: noColor,
+	})
 }
 
-// getKeyInfo retrieves the key type, size, and salt from the command flags
-func getKeyInfo(c *cli.Command) (*KeyInfo, error) {
-	eccOpt := c.String("ecc")
-	rsaOpt := c.String("rsa")
-	salt := c.String("salt")
-	password := c.String("password")
+func setLogging(c *cli.Command) {
+	level := zerolog.InfoLevel
+	if c.Bool("verbose") {
+		level = zerolog.DebugLevel
+	}
 
-	// key info defaults
-	keyType := keys.KeyTypeNone
-	keyId := 0
+	log.Logger = newLogger(c.Bool("no-color") || colorDisabled()).Level(level)
+}
 
-	// RSA or ECC must be specified
-	if eccOpt == "" && rsaOpt == "" {
-		return nil, cli.Exit("At least one of -ecc or -rsa flags must be specified.", 1)
-	}
+// outputFileFlags lists the flags naming files that bipkey writes
+var outputFileFlags = []string{"out", "mnemonic-out", "entropy-out", "pub-out", "pub-raw-out", "ssh-pub-out", "metadata-out", "record-out", "tink-out", "password-out", "cert-out", "csr-out", "vault-out", "root-cert-out", "intermediate-cert-out", "chain-out", "recovery-out", "step-ca"}
 
-	// both ECC and RSA cannot be specified
-	if eccOpt != "" && rsaOpt != "" {
-		return nil, cli.Exit("Only one of -ecc or -rsa flags may be specified.", 1)
+// confirmOutFile ensures existing output files are only overwritten with the user's consent
+func confirmOutFile(c *cli.Command) error {
+	if c.Bool("force") {
+		return nil
 	}
 
-	if eccOpt != "" {
-		// use ECC key type
-		keyType = keys.KeyTypeECC
-		eccId, err := keys.ParseECCCurve(eccOpt)
-		if err != nil {
-			return nil, cli.Exit(err.Error(), 1)
+	for _, name := range outputFileFlags {
+		for _, path := range outputPaths(c, name) {
+			if err := confirmOverwrite(path); err != nil {
+				return err
+			}
 		}
-		keyId = int(eccId)
 	}
+	return nil
+}
 
-	if rsaOpt != "" {
-		// use RSA key type
-		keyType = keys.KeyTypeRSA
-		rsaId, err := keys.ParseRSAKeyID(rsaOpt)
-		if err != nil {
-			return nil, cli.Exit(err.Error(), 1)
-		}
-		keyId = int(rsaId)
+// outputPaths returns the files written for the output flag. An --out template names one file per --format.
+func outputPaths(c *cli.Command, name string) []string {
+	if name == "step-ca" {
+		return stepCAPaths(c)
 	}
-
-	// validate key type and size
-	if keyType == keys.KeyTypeNone {
-		return nil, cli.Exit("Invalid key type specified.", 1)
+	path := c.String(name)
+	if path == "" {
+		return nil
 	}
-
-	// salt is not required but is recommended
-	if len(salt) == 0 {
-		log.Warn().Msg("Salt value is not provided. It's recommended to use a salt value for better security.")
+	formats, err := keys.ParseFormats(c.String("format"))
+	if name != "out" || !isOutTemplate(path) || err != nil {
+		return []string{path}
 	}
 
-	return &KeyInfo{
-		KeyType:  keyType,
-		KeyId:    keyId,
-		Salt:     salt,
-		Password: password,
-	}, nil
+	var paths []string
+	for _, format := range formats {
+		paths = append(paths, keyOutPath(path, format))
+	}
+	return paths
 }
 
-// actionGenerate generates a new private key and mnemonic based on the provided command flags
-func actionGenerate(ctx context.Context, c *cli.Command) error {
-	setLogging(c)
-	ki, err := getKeyInfo(c)
-	if err != nil {
-		return err
+// confirmOverwrite asks before overwriting the given output file, if it exists
+func confirmOverwrite(outFile string) error {
+	if outFile == "" {
+		return nil
 	}
 
-	k, err := keys.GenerateKey(ctx, ki.KeyType, ki.KeyId, ki.Salt)
+	info, err := os.Stat(outFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
 	if err != nil {
-		log.Error().Err(err).Msg("Failed to generate key")
-		return err
+		return fmt.Errorf("failed to check output file: %w", err)
 	}
-
-	if ki.Password != "" {
-		if err := k.Encrypt(ki.Password); err != nil {
-			log.Error().Err(err).Msg("Failed to encrypt the private key")
-			return err
-		}
-		log.Debug().Msg("Encrypted the private key with the provided password.")
+	if info.IsDir() {
+		return cli.Exit(fmt.Sprintf("Output path %q is a directory.", outFile), 1)
 	}
 
-	k.Display()
-	if err := writeFile(c, k.PEM()); err != nil {
-		log.Error().Err(err).Msg("Failed to write key to file")
+	// without a terminal there is nobody to ask, so refuse rather than overwrite
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return cli.Exit(fmt.Sprintf("Output file %q already exists. Use --force to overwrite it.", outFile), 1)
 	}
 
-	return nil
-}
-
-// promptMnemonic prompts the user to enter their 24-word mnemonic recovery key
-func promptMnemonic() (string, error) {
-	fmt.Println("Please enter your 24-word mnemonic recovery key in order (separated by spaces):")
-	var mnemonicString string
-
-	reader := bufio.NewReader(os.Stdin)
-	line, err := reader.ReadString('\n')
+	fmt.Printf("Output file %q already exists. Overwrite? [y/N]: ", outFile)
+	yes, err := readConfirmation()
 	if err != nil {
-		return "", fmt.Errorf("failed to read mnemonic input: %w", err)
+		return err
 	}
-	fmt.Println()
-
-	mnemonicString = strings.TrimSpace(line)
-	return mnemonicString, nil
+	if !yes {
+		return cli.Exit("Aborted: output file was not overwritten.", 1)
+	}
+	return nil
 }
 
-// actionRestore restores a private key from an existing mnemonic/salt
-func actionRestore(ctx context.Context, c *cli.Command) error {
-	setLogging(c)
-	ki, err := getKeyInfo(c)
+// writeFile writes the provided data to the --out file, readable only by the owner
+func writeFile(c *cli.Command, data string) error {
+	data, err := exportPEM(c, data)
 	if err != nil {
 		return err
 	}
+	return writeOutput(c.String("out"), data, 0600)
+}
 
-	var mnemonic keys.Mnemonic
+// OUT_FORMAT_PLACEHOLDER and OUT_EXT_PLACEHOLDER in an --out template are replaced with the name and file extension
+// of each output format, e.g. "key.{ext}" writes key.pem and key.ossh
+const (
+	OUT_FORMAT_PLACEHOLDER = "{format}"
+	OUT_EXT_PLACEHOLDER    = "{ext}"
+)
+
+// isOutTemplate reports whether the --out path contains a format placeholder
+func isOutTemplate(path string) bool {
+	return strings.Contains(path, OUT_FORMAT_PLACEHOLDER) || strings.Contains(path, OUT_EXT_PLACEHOLDER)
+}
+
+// keyOutPath returns the --out path of the key in the given format
+func keyOutPath(template string, format keys.Format) string {
+	return strings.NewReplacer(OUT_FORMAT_PLACEHOLDER, string(format), OUT_EXT_PLACEHOLDER, format.Extension()).Replace(template)
+}
+
+// writeKeyFiles writes the private key to the --out file in every requested format, each to its own file
+func writeKeyFiles(c *cli.Command, ki *KeyInfo, k *keys.Key) error {
+	template := c.String("out")
+	if template == "" {
+		return nil
+	}
+	for _, format := range ki.Formats {
+		encoded, err := encodeKey(c, k, format)
+		if err != nil {
+			return err
+		}
+		data, err := exportPEM(c, encoded)
+		if err != nil {
+			return err
+		}
+		if err := writeOutput(keyOutPath(template, format), data, 0600); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// encryptKey encrypts the private key with the password as PKCS#8, using PBKDF2 with the --pbkdf2-prf PRF
+func encryptKey(c *cli.Command, k *keys.Key, password string) error {
+	if c.String("compat-openssl") != "" {
+		log.Info().Int("iterations", keys.OPENSSL_PBKDF2_ITERATIONS).Msg("Encrypting the private key with the PBKDF2 iteration count of OpenSSL, lower than the bipkey default.")
+		return k.EncryptWithOpts(password, keys.OpenSSLPKCS8Opts())
+	}
+	prf, err := keys.ParsePBKDF2PRF(c.String("pbkdf2-prf"))
+	if err != nil {
+		return cli.Exit(err.Error(), 1)
+	}
+	opts, err := keys.PKCS8Opts(prf)
+	if err != nil {
+		return cli.Exit(err.Error(), 1)
+	}
+	return k.EncryptWithOpts(password, opts)
+}
+
+// getCompatOpenSSL returns the --compat-openssl version, or an empty version if it is not given. The flags choosing
+// an encoding that the profile selects cannot be combined with it.
+func getCompatOpenSSL(c *cli.Command) (keys.OpenSSLVersion, error) {
+	val := c.String("compat-openssl")
+	if val == "" {
+		return "", nil
+	}
+	version, err := keys.ParseOpenSSLVersion(val)
+	if err != nil {
+		return "", cli.Exit(err.Error(), 1)
+	}
+	for _, name := range []string{"format", "pbkdf2-prf", "pem-line-length", "ec-params"} {
+		if c.IsSet(name) {
+			return "", cli.Exit(fmt.Sprintf("--%s cannot be combined with --compat-openssl, which selects the OpenSSL %s encoding.", name, version), 1)
+		}
+	}
+	return version, nil
+}
+
+// encodeKey returns the PEM encoding of the private key in the format, preceded by the curve's EC PARAMETERS block
+// for a SEC1 key with --ec-params
+func encodeKey(c *cli.Command, k *keys.Key, format keys.Format) (string, error) {
+	if c.Bool("ec-params") && format == keys.FormatSEC1 {
+		return k.EncodeWithECParameters(format)
+	}
+	return k.Encode(format)
+}
+
+// exportPEM wraps the PEM blocks of exported data at the --pem-line-length width
+func exportPEM(c *cli.Command, data string) (string, error) {
+	// raw and hex payloads have no PEM block to rewrap, they are written as they are
+	if block, _ := pem.Decode([]byte(data)); block == nil {
+		return data, nil
+	}
+	return keys.WrapPEM(data, int(c.Int("pem-line-length")))
+}
+
+// writeMnemonic writes the mnemonic words in the generator's word groups to the --mnemonic-out file, if provided.
+// It is the secret itself, so it is owner-only.
+func writeMnemonic(c *cli.Command, gen *keys.Generator, mnemonic keys.Mnemonic) error {
+	path := c.String("mnemonic-out")
+	if path == "" {
+		return nil
+	}
+
+	log.Warn().Str("file", path).Msg("The mnemonic file holds the key in plaintext. Print or copy it and then delete it.")
+	return writeOutput(path, gen.FormatMnemonicGroups(mnemonic)+"\n", 0600)
+}
+
+// unescapeSeparator interprets the \n, \t, and \\ escapes of a separator given on the command line
+func unescapeSeparator(val string) (string, error) {
+	var builder strings.Builder
+	for i := 0; i < len(val); i++ {
+		if val[i] != '\\' {
+			builder.WriteByte(val[i])
+			continue
+		}
+		if i++; i == len(val) {
+			return "", fmt.Errorf("trailing backslash in %q", val)
+		}
+		switch val[i] {
+		case 'n':
+			builder.WriteByte('\n')
+		case 't':
+			builder.WriteByte('\t')
+		case '\\':
+			builder.WriteByte('\\')
+		default:
+			return "", fmt.Errorf("unsupported escape \\%c in %q", val[i], val)
+		}
+	}
+	return builder.String(), nil
+}
+
+// writeEntropy writes the mnemonic entropy as hex to the --entropy-out file, if provided. It is as secret as the mnemonic.
+func writeEntropy(c *cli.Command, gen *keys.Generator, mnemonic keys.Mnemonic) error {
+	path := c.String("entropy-out")
+	if path == "" {
+		return nil
+	}
+
+	entropy, err := gen.EntropyFromMnemonic(mnemonic)
+	if err != nil {
+		return err
+	}
+	return writeOutput(path, hex.EncodeToString(entropy)+"\n", 0600)
+}
+
+// writePublicKey writes the public key to the --pub-out file, if provided. It is not secret, so it is world-readable.
+func writePublicKey(c *cli.Command, k *keys.Key) error {
+	path := c.String("pub-out")
+	if path == "" {
+		return nil
+	}
+
+	pub, err := k.PublicKeyPEM()
+	if err != nil {
+		return err
+	}
+	if pub, err = exportPEM(c, pub); err != nil {
+		return err
+	}
+	return writeOutput(path, pub, 0644)
+}
+
+// writeSSHPublicKey writes the OpenSSH public key to the --ssh-pub-out file, if provided, in authorized_keys form
+func writeSSHPublicKey(c *cli.Command, k *keys.Key) error {
+	path := c.String("ssh-pub-out")
+	if path == "" {
+		return nil
+	}
+
+	line, err := k.AuthorizedKey()
+	if err != nil {
+		return err
+	}
+	return writeOutput(path, line, 0644)
+}
+
+// writeRawPublicKey writes the raw public key as hex to the --pub-raw-out file, if provided, with the EC point
+// compressed if --pub-compressed is given
+func writeRawPublicKey(c *cli.Command, k *keys.Key) error {
+	path := c.String("pub-raw-out")
+	if path == "" {
+		return nil
+	}
+
+	publicKeyBytes := k.PublicKeyBytes
+	if c.Bool("pub-compressed") {
+		publicKeyBytes = k.PublicKeyBytesCompressed
+	}
+	raw, err := publicKeyBytes()
+	if err != nil {
+		return err
+	}
+	return writeOutput(path, hex.EncodeToString(raw)+"\n", 0644)
+}
+
+// writeMetadata writes the derivation parameters of the key as JSON to the --metadata-out file, if provided. The salt
+// is not a secret on its own, but it removes one factor an attacker holding the mnemonic would need, so it is owner-only.
+func writeMetadata(c *cli.Command, ki *KeyInfo, k *keys.Key) error {
+	path := c.String("metadata-out")
+	if path == "" {
+		return nil
+	}
+
+	metadata, err := k.Metadata(ki.Format)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(metadata, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal key metadata: %w", err)
+	}
+
+	log.Warn().Str("file", path).Msg("The metadata file contains the salt. Anyone holding it and the mnemonic can restore the key, so store it apart from the mnemonic.")
+	return writeOutput(path, string(data)+"\n", 0600)
+}
+
+// writeRecord writes the inventory record of the key as JSON to the --record-out file, if provided. It holds no secret
+// material, so it is world-readable unless --record-salt adds the salt.
+func writeRecord(c *cli.Command, k *keys.Key) error {
+	path := c.String("record-out")
+	if path == "" {
+		return nil
+	}
+
+	format, err := keys.ParseFingerprintFormat(c.String("fingerprint-format"))
+	if err != nil {
+		return cli.Exit(err.Error(), 1)
+	}
+	includeSalt := c.Bool("record-salt")
+	record, err := k.Record(format, includeSalt)
+	if err != nil {
+		return err
+	}
+	record.Tool = "bipkey " + Version
+	record.Command = c.Name
+	data, err := json.MarshalIndent(record, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal key record: %w", err)
+	}
+
+	if includeSalt {
+		log.Warn().Str("file", path).Msg("The key record contains the salt. Anyone holding it and the mnemonic can restore the key, so keep the inventory apart from the mnemonic.")
+		return writeOutput(path, string(data)+"\n", 0600)
+	}
+	return writeOutput(path, string(data)+"\n", 0644)
+}
+
+// writeOutput writes the provided data to the specified output file with the given permissions
+func writeOutput(outFile string, data string, perm os.FileMode) error {
+	// If no output file is specified, return early
+	if outFile == "" {
+		return nil
+	}
+
+	// Create the output file, overwriting any file confirmed by confirmOutFile
+	f, err := os.OpenFile(outFile, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return fmt.Errorf("failed to create output file: %w", err)
+	}
+
+	// an overwritten file keeps its previous mode, so set it explicitly
+	if err := f.Chmod(perm); err != nil {
+		log.Debug().Err(err).Msg("Failed to set output file permissions.")
+	}
+
+	// Write the data to the file
+	_, err = f.WriteString(data)
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write to output file: %w", err)
+	}
+
+	// some filesystems only report a failed write when the file is closed
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write to output file: %w", err)
+	}
+	return nil
+}
+
+type KeyInfo struct {
+	KeyType        keys.KeyType
+	KeyId          int
+	Salt           string
+	Password       string
+	DerivePassword bool          // encrypt under the password derived from the mnemonic, see --derive-password
+	Format         keys.Format   // format of the displayed key, the first of Formats
+	Formats        []keys.Format // formats written to the --out file or template
+	Cert           *keys.CertOptions
+	StepCA         *keys.CertOptions
+	VaultWrapping  *rsa.PublicKey // parsed --vault-wrapping-key, nil if the --vault-out key is not wrapped
+	Comment        string         // --comment embedded in the formats that carry one, empty for the label's default
+
+	Generator *keys.Generator
+	Label     keys.KeyLabel
+	Path      keys.DerivationPath // BIP-32 path of a SLIP-10 key, nil for the HKDF scheme
+}
+
+// keyTypeFlags are the mutually exclusive flags selecting the key type. -ecc and -rsa are deprecated aliases of --algorithm.
+var keyTypeFlags = []string{"algorithm", "ecc", "rsa"}
+
+// keyTypeGiven reports whether any of the key type flags is set
+func keyTypeGiven(c *cli.Command) bool {
+	for _, name := range keyTypeFlags {
+		if c.String(name) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// getKeyType parses the mutually exclusive --algorithm/-ecc/-rsa flags into the key type and id
+func getKeyType(c *cli.Command) (keys.KeyType, int, error) {
+	algorithm := c.String("algorithm")
+	given := 0
+	for _, name := range keyTypeFlags {
+		if c.String(name) != "" {
+			given++
+		}
+	}
+
+	// one key type must be specified
+	if given == 0 {
+		return keys.KeyTypeNone, 0, cli.Exit("One of --algorithm, -ecc, or -rsa flags must be specified.", 1)
+	}
+	if given > 1 {
+		return keys.KeyTypeNone, 0, cli.Exit("Only one of --algorithm, -ecc, or -rsa flags may be specified.", 1)
+	}
+
+	// the deprecated flags name the curve or size of their key type
+	if eccOpt := c.String("ecc"); eccOpt != "" {
+		algorithm = "ecc:" + eccOpt
+		log.Warn().Msgf("-ecc is deprecated and will be removed. Use --algorithm %s instead.", algorithm)
+	} else if rsaOpt := c.String("rsa"); rsaOpt != "" {
+		algorithm = "rsa:" + rsaOpt
+		log.Warn().Msgf("-rsa is deprecated and will be removed. Use --algorithm %s instead.", algorithm)
+	}
+
+	keyType, keyId, err := keys.ParseAlgorithm(algorithm)
+	if err != nil {
+		return keys.KeyTypeNone, 0, cli.Exit(err.Error(), 1)
+	}
+	return keyType, keyId, nil
+}
+
+// checkAlgorithmPolicy rejects the key type and id if the --allowed-algorithms policy does not allow it
+func checkAlgorithmPolicy(c *cli.Command, keyType keys.KeyType, keyId int) error {
+	// an explicit but empty policy fails closed instead of allowing everything
+	if c.IsSet("allowed-algorithms") && c.String("allowed-algorithms") == "" {
+		return cli.Exit("The --allowed-algorithms policy names no algorithms. Omit it to allow every algorithm.", 1)
+	}
+	policy, err := keys.ParseAlgorithmPolicy(c.String("allowed-algorithms"))
+	if err != nil {
+		return cli.Exit(err.Error(), 1)
+	}
+	if policy.Allows(keyType, keyId) {
+		return nil
+	}
+
+	algorithm := string(keyType)
+	if caps, err := keys.GetCapabilities(keyType, keyId); err == nil {
+		algorithm = caps.Algorithm
+	}
+	return cli.Exit(fmt.Sprintf("%s keys are not allowed by the algorithm policy (allowed: %s).", algorithm, policy), 1)
+}
+
+// getKeyInfo retrieves the key type, size, and salt from the command flags
+func getKeyInfo(c *cli.Command) (*KeyInfo, error) {
+	keyType, keyId, err := getKeyType(c)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkAlgorithmPolicy(c, keyType, keyId); err != nil {
+		return nil, err
+	}
+
+	salt, err := getSalt(c)
+	if err != nil {
+		return nil, err
+	}
+	password, derivePassword, err := getKeyPassword(c)
+	if err != nil {
+		return nil, err
+	}
+
+	// validate the output formats against the key type before spending time on generation
+	compat, err := getCompatOpenSSL(c)
+	if err != nil {
+		return nil, err
+	}
+	formats, err := keys.ParseFormats(c.String("format"))
+	if err != nil {
+		return nil, cli.Exit(err.Error(), 1)
+	}
+	if compat != "" {
+		formats = []keys.Format{compat.Format(keyType, keyId, password != "" || derivePassword)}
+	}
+	for _, format := range formats {
+		if err := keys.CheckFormat(format, keyType, keyId); err != nil {
+			return nil, cli.Exit(err.Error(), 1)
+		}
+		if (password != "" || derivePassword) && format != keys.FormatPKCS8 {
+			return nil, cli.Exit(fmt.Sprintf("Password encryption is only supported with the %s format.", keys.FormatPKCS8), 1)
+		}
+	}
+	if c.Bool("ec-params") && !slices.Contains(formats, keys.FormatSEC1) {
+		return nil, cli.Exit(fmt.Sprintf("--ec-params requires the %s format.", keys.FormatSEC1), 1)
+	}
+	if len(formats) > 1 && !isOutTemplate(c.String("out")) {
+		return nil, cli.Exit(fmt.Sprintf("Several formats require an --out template with %s or %s, e.g. --out \"key.%s\".", OUT_FORMAT_PLACEHOLDER, OUT_EXT_PLACEHOLDER, OUT_EXT_PLACEHOLDER), 1)
+	}
+	if c.Bool("pub-compressed") {
+		if c.String("pub-raw-out") == "" {
+			return nil, cli.Exit("--pub-compressed requires --pub-raw-out.", 1)
+		}
+		if keyType != keys.KeyTypeECC || keys.ECCCurveID(keyId) == keys.ECCCurveEd25519 {
+			return nil, cli.Exit("Compressed public keys (--pub-compressed) are only supported for the NIST curves P-256, P-384, and P-521.", 1)
+		}
+	}
+	vaultWrapping, err := getVaultWrappingKey(c, keyType, keyId)
+	if err != nil {
+		return nil, err
+	}
+
+	gen, err := getGenerator(c)
+	if err != nil {
+		return nil, err
+	}
+	if gen.Derivation() == keys.DerivationV1 && keyType == keys.KeyTypeRSA {
+		log.Warn().Msg("Derivation v1 RSA keys depend on the Go version bipkey was built with and may not restore identically.")
+		// the default stays v1 so that existing keys restore unchanged, new keys should not depend on the Go version
+		if (c.Name == "generate" || c.Name == "record") && !c.IsSet("derivation") {
+			log.Warn().Msg("NEW RSA KEYS SHOULD USE --derivation v2. v1 is only the default so that existing keys keep restoring with default flags. Generate with --derivation v2 and restore with the same flag.")
+		}
+	}
+	label := keys.KeyLabel{Identity: c.String("identity"), Index: int(c.Int("index"))}
+	if label.Index < 0 {
+		return nil, cli.Exit("The key index must not be negative.", 1)
+	}
+	if strings.ContainsAny(label.Identity, ";=") {
+		return nil, cli.Exit("The key identity must not contain ';' or '='.", 1)
+	}
+
+	path, err := getDerivationPath(c, keyType, keyId, gen, label)
+	if err != nil {
+		return nil, err
+	}
+	comment, err := getComment(c)
+	if err != nil {
+		return nil, err
+	}
+
+	// validate certificate options up front as well
+	certOpts, err := getCertOptions(c)
+	if err != nil {
+		return nil, err
+	}
+	stepCAOpts, err := getStepCAOptions(c)
+	if err != nil {
+		return nil, err
+	}
+	// without a self-signed certificate there is nothing to verify, which must not pass silently
+	if c.Bool("verify-cert") && c.String("cert-out") == "" && stepCAOpts == nil {
+		return nil, cli.Exit("--verify-cert requires --cert-out or --step-ca.", 1)
+	}
+
+	// salt is not required but is recommended
+	if len(salt) == 0 {
+		log.Warn().Msg("Salt value is not provided. It's recommended to use a salt value for better security.")
+	}
+
+	return &KeyInfo{
+		KeyType:        keyType,
+		KeyId:          keyId,
+		Salt:           salt,
+		Password:       password,
+		DerivePassword: derivePassword,
+		Format:         formats[0],
+		Formats:        formats,
+		Cert:           certOpts,
+		StepCA:         stepCAOpts,
+		VaultWrapping:  vaultWrapping,
+		Comment:        comment,
+
+		Generator: gen,
+		Label:     label,
+		Path:      path,
+	}, nil
+}
+
+// getComment returns the --comment, which must fit on the single line of an authorized_keys entry
+func getComment(c *cli.Command) (string, error) {
+	comment := c.String("comment")
+	if strings.ContainsFunc(comment, unicode.IsControl) {
+		return "", cli.Exit("The key comment must not contain control characters.", 1)
+	}
+	return comment, nil
+}
+
+// getKeyPassword returns the password to encrypt the private key with, or whether the password is derived from the
+// mnemonic with --derive-password instead, which excludes every other password source. A split export generates it.
+func getKeyPassword(c *cli.Command) (string, bool, error) {
+	if splitPasswordGiven(c) {
+		password, err := getSplitPassword(c)
+		return password, false, err
+	}
+	if !c.Bool("derive-password") {
+		password, err := getPassword(c)
+		return password, false, err
+	}
+	if c.String("password") != "" || c.String("password-file") != "" || envPasswordGiven(c) || c.Bool("password-prompt") {
+		return "", false, cli.Exit("--derive-password cannot be combined with another password source.", 1)
+	}
+	return "", true, nil
+}
+
+// getDerivationPath parses the --bip32-path and checks that it fits the key type and the other derivation options
+func getDerivationPath(c *cli.Command, keyType keys.KeyType, keyId int, gen *keys.Generator, label keys.KeyLabel) (keys.DerivationPath, error) {
+	if c.String("bip32-path") == "" {
+		return nil, nil
+	}
+	path, err := keys.ParseDerivationPath(c.String("bip32-path"))
+	if err != nil {
+		return nil, cli.Exit(err.Error(), 1)
+	}
+	if err := keys.CheckDerivationPath(path, keyType, keyId); err != nil {
+		return nil, cli.Exit(err.Error(), 1)
+	}
+	// the path selects the key, and SLIP-10 uses the plain BIP-39 seed
+	if !label.IsZero() {
+		return nil, cli.Exit("--bip32-path cannot be combined with --identity or --index, the path selects the key.", 1)
+	}
+	if gen.Scrypt() != nil {
+		return nil, cli.Exit("--bip32-path uses the plain BIP-39 seed and cannot be combined with --kdf scrypt.", 1)
+	}
+	if gen.EntropySeed() {
+		return nil, cli.Exit("--bip32-path uses the plain BIP-39 seed and cannot be combined with --no-bip39-seed.", 1)
+	}
+	return path, nil
+}
+
+// deriveKey derives the key selected by the key info from the mnemonic, along the --bip32-path if one is given
+func deriveKey(ctx context.Context, ki *KeyInfo, mnemonic keys.Mnemonic) (*keys.Key, error) {
+	if ki.Path != nil {
+		return ki.Generator.GeneratePathKeyFromMnemonic(ctx, ki.KeyType, ki.KeyId, ki.Salt, mnemonic, ki.Path)
+	}
+	return ki.Generator.GenerateLabeledKeyFromMnemonic(ctx, ki.KeyType, ki.KeyId, ki.Salt, mnemonic, ki.Label)
+}
+
+// terminalWidth returns the width of the terminal on stdout that the mnemonic is displayed on, or 0 if stdout is not
+// a terminal
+func terminalWidth() int {
+	width, _, err := term.GetSize(int(os.Stdout.Fd()))
+	if err != nil {
+		return 0
+	}
+	return width
+}
+
+// getGenerator creates the key generator configured by the derivation, RSA retry, and seed KDF flags
+func getGenerator(c *cli.Command) (*keys.Generator, error) {
+	derivation, err := keys.ParseDerivationVersion(c.String("derivation"))
+	if err != nil {
+		return nil, cli.Exit(err.Error(), 1)
+	}
+	maxRetries := int(c.Int("max-rsa-retries"))
+	if maxRetries < 1 {
+		return nil, cli.Exit("The RSA prime candidate limit (--max-rsa-retries) must be at least 1.", 1)
+	}
+	streamBuf := int(c.Int("stream-buffer-size"))
+	if streamBuf < 1 || streamBuf > keys.MAX_STREAM_BUFFER_SIZE {
+		return nil, cli.Exit(fmt.Sprintf("The stream buffer size (--stream-buffer-size) must be between 1 and %d bytes.", keys.MAX_STREAM_BUFFER_SIZE), 1)
+	}
+
+	groupSep, err := unescapeSeparator(c.String("group-separator"))
+	if err != nil {
+		return nil, cli.Exit(fmt.Sprintf("Invalid --group-separator: %v", err), 1)
+	}
+
+	cfg := keys.Config{
+		Columns:            int(c.Int("columns")),
+		Width:              terminalWidth(),
+		GroupSize:          int(c.Int("group-size")),
+		GroupSeparator:     groupSep,
+		Derivation:         derivation,
+		MaxPrimeCandidates: maxRetries,
+		RSAExponent:        keys.RSAExponent{E: int(c.Int("rsa-exponent")), Fallback: c.Bool("rsa-exponent-fallback")},
+		EntropySeed:        c.Bool("no-bip39-seed"),
+		StreamBufferSize:   streamBuf,
+	}
+	if language := c.String("language"); !strings.EqualFold(language, LANGUAGE_AUTO) {
+		lang, err := keys.ParseLanguage(language)
+		if err != nil {
+			fmt.Printf("%s\n", keys.SupportedLanguages())
+			return nil, cli.Exit(err.Error(), 1)
+		}
+		if cfg.WordList, err = lang.WordList(); err != nil {
+			return nil, cli.Exit(err.Error(), 1)
+		}
+	}
+	switch strings.ToLower(c.String("kdf")) {
+	case "", "none":
+	case "scrypt":
+		cfg.Scrypt = &keys.ScryptParams{N: int(c.Int("scrypt-n")), R: int(c.Int("scrypt-r")), P: int(c.Int("scrypt-p"))}
+	default:
+		fmt.Printf("%s\n", keys.SupportedKDFs())
+		return nil, cli.Exit(fmt.Sprintf("Unsupported seed KDF %q.", c.String("kdf")), 1)
+	}
+
+	gen, err := keys.NewGenerator(cfg)
+	if err != nil {
+		return nil, cli.Exit(err.Error(), 1)
+	}
+	return gen, nil
+}
+
+// actionGenerate generates a new private key and mnemonic based on the provided command flags
+func actionGenerate(ctx context.Context, c *cli.Command) error {
+	ki, k, err := generateKey(ctx, c)
+	if err != nil || k == nil {
+		return err
+	}
+	return outputKey(ctx, c, ki, k)
+}
+
+// generateKey validates the flags and generates a new key and mnemonic, for generate and record. With --dry-run it
+// reports the plan instead and returns no key.
+func generateKey(ctx context.Context, c *cli.Command) (*KeyInfo, *keys.Key, error) {
+	setLogging(c)
+	if c.String("recovery-file") != "" {
+		return nil, nil, cli.Exit("A recovery file restores an existing key, it cannot be used to generate a new one.", 1)
+	}
+	ki, err := getKeyInfo(c)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// a new mnemonic that is never shown could not be used to restore the key
+	if c.Bool("json") && !c.Bool("include-private") {
+		return nil, nil, cli.Exit("Generating a key with --json requires --include-private, otherwise the new mnemonic would be lost.", 1)
+	}
+
+	// a banned salt only stops new keys; existing keys must stay restorable
+	if err := checkSaltBlocklist(c.String("salt-blocklist"), ki.Salt); err != nil {
+		return nil, nil, err
+	}
+	if c.Bool("dry-run") {
+		return nil, nil, dryRun(c, ki, false)
+	}
+
+	if err := confirmOutFile(c); err != nil {
+		return nil, nil, err
+	}
+
+	mnemonic, err := ki.Generator.GenerateMnemonic(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to generate mnemonic")
+		return nil, nil, err
+	}
+
+	k, err := deriveKey(ctx, ki, *mnemonic)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to generate key")
+		return nil, nil, err
+	}
+	return ki, k, nil
+}
+
+// newMnemonicFlag creates the --mnemonic flag shared by commands that take an existing mnemonic
+func newMnemonicFlag() cli.Flag {
+	return &cli.StringFlag{
+		Name:    "mnemonic",
+		Aliases: []string{"m"},
+		Usage:   "Existing 24-word mnemonic to restore the key from (first 4 letters minimum)",
+		Value:   "",
+	}
+}
+
+// newEntropyFlag creates the flag restoring the mnemonic from its hex-encoded entropy
+func newEntropyFlag() cli.Flag {
+	return &cli.StringFlag{
+		Name:  "entropy",
+		Usage: "Existing 32-byte mnemonic entropy (64 hex characters, e.g. from --entropy-out) to restore the key from",
+		Value: "",
+	}
+}
+
+// readMnemonic reads the mnemonic from the --mnemonic or --entropy flag, or prompts for it if neither is provided.
+// It returns the mnemonic along with the generator for its word list language, see mnemonicGenerator.
+func readMnemonic(c *cli.Command, gen *keys.Generator) (keys.Mnemonic, *keys.Generator, error) {
+	if entropyHex := strings.TrimSpace(c.String("entropy")); entropyHex != "" {
+		if c.String("mnemonic") != "" {
+			return keys.Mnemonic{}, nil, cli.Exit("Only one of --mnemonic or --entropy may be specified.", 1)
+		}
+		entropy, err := hex.DecodeString(entropyHex)
+		if err != nil {
+			return keys.Mnemonic{}, nil, cli.Exit(fmt.Sprintf("Invalid entropy: %v", err), 1)
+		}
+		mnemonic, err := gen.MnemonicFromEntropy(entropy)
+		if err != nil {
+			return keys.Mnemonic{}, nil, cli.Exit(fmt.Sprintf("Invalid entropy: %v", err), 1)
+		}
+		adviseLowEntropy(c, gen, mnemonic)
+		return mnemonic, gen, nil
+	}
 
 	mnemonicString := c.String("mnemonic")
+	typed := false
 	if mnemonicString == "" {
+		var err error
 		mnemonicString, err = promptMnemonic()
 		if err != nil {
-			return err
+			return keys.Mnemonic{}, nil, err
 		}
+		typed = term.IsTerminal(int(os.Stdin.Fd()))
+	}
 
+	gen, err := mnemonicGenerator(c, gen, mnemonicString)
+	if err != nil {
+		return keys.Mnemonic{}, nil, err
 	}
 
-	mnemonicWords := strings.Split(mnemonicString, " ")
-	if len(mnemonicWords) != keys.MNEMONIC_WORD_COUNT {
-		return cli.Exit(fmt.Sprintf("Invalid mnemonic: expected %d words, got %d", keys.MNEMONIC_WORD_COUNT, len(mnemonicWords)), 1)
+	parse := gen.ParseMnemonic
+	if c.Bool("strict") {
+		parse = gen.ParseMnemonicStrict
 	}
 
-	copy(mnemonic[:], mnemonicWords)
+	mnemonic, err := parse(mnemonicString)
+	if err != nil {
+		return keys.Mnemonic{}, nil, cli.Exit(fmt.Sprintf("Invalid mnemonic: %v", err), 1)
+	}
+	adviseLowEntropy(c, gen, mnemonic)
+	if err := adviseRepeatedWords(c, mnemonic, typed); err != nil {
+		return keys.Mnemonic{}, nil, err
+	}
+	return mnemonic, gen, nil
+}
 
-	k, err := keys.GenerateKeyFromMnemonic(ctx, ki.KeyType, ki.KeyId, ki.Salt, mnemonic)
+// adviseRepeatedWords warns about every word the mnemonic repeats. Repeated words are valid BIP-39, but writing a
+// word twice is a common transcription mistake, so a mnemonic typed at the terminal is only used once the operator
+// confirms the repetition. --allow-repeated-words turns the advisory off.
+func adviseRepeatedWords(c *cli.Command, m keys.Mnemonic, confirm bool) error {
+	repeated := m.RepeatedWords()
+	if len(repeated) == 0 || c.Bool("allow-repeated-words") {
+		return nil
+	}
+	for _, word := range repeated {
+		log.Warn().Msg(repeatedWordMessage(word) + " BIP-39 allows repeated words, but please double-check them against the backup.")
+	}
+	if !confirm {
+		return nil
+	}
+
+	fmt.Print("Continue with the repeated words? [y/N]: ")
+	yes, err := readConfirmation()
 	if err != nil {
 		return err
 	}
+	if !yes {
+		return cli.Exit("Aborted: check the repeated words, or use --allow-repeated-words if they are correct.", 1)
+	}
+	return nil
+}
+
+// repeatedWordMessage describes the positions of a repeated word, e.g. `Words 3 and 17 are the same word "dance".`
+func repeatedWordMessage(word keys.RepeatedWord) string {
+	positions := make([]string, len(word.Positions))
+	for i, position := range word.Positions {
+		positions[i] = strconv.Itoa(position)
+	}
+	last := len(positions) - 1
+	return fmt.Sprintf("Words %s and %s are the same word %q.", strings.Join(positions[:last], ", "), positions[last], word.Word)
+}
+
+// LANGUAGE_AUTO is the --language value that detects the word list language from the mnemonic
+const LANGUAGE_AUTO = "auto"
+
+// mnemonicGenerator returns the generator for the word list language of the mnemonic. With --language auto the
+// language is detected from the words; if no word list matches, the generator is returned unchanged so that parsing
+// reports the offending word.
+func mnemonicGenerator(c *cli.Command, gen *keys.Generator, mnemonicString string) (*keys.Generator, error) {
+	if !strings.EqualFold(c.String("language"), LANGUAGE_AUTO) {
+		return gen, nil
+	}
+
+	lang, err := keys.DetectLanguage(mnemonicString)
+	if errors.Is(err, keys.ErrAmbiguousLanguage) {
+		return nil, cli.Exit(fmt.Sprintf("Unable to detect the mnemonic language (%v). Specify it with --language.", err), 1)
+	}
+	if err != nil || lang == keys.LanguageEnglish {
+		return gen, nil
+	}
+
+	words, err := lang.WordList()
+	if err != nil {
+		return nil, err
+	}
+	log.Info().Str("language", string(lang)).Msg("Detected mnemonic language.")
+	return gen.WithWordList(words)
+}
+
+// MNEMONIC_PROMPT_ATTEMPTS is the number of times an empty mnemonic entry is re-prompted
+const MNEMONIC_PROMPT_ATTEMPTS = 3
+
+// promptMnemonic prompts the user to enter their 24-word mnemonic recovery key on stdin. When stdin is not a terminal,
+// e.g. `echo "$MNEMONIC" | bipkey restore`, the mnemonic is read without any prompt so that piped output stays clean.
+// Either way, the mnemonic must arrive within the --prompt-timeout.
+func promptMnemonic() (string, error) {
+	return readWithTimeout("mnemonic", func() (string, error) {
+		if !term.IsTerminal(int(os.Stdin.Fd())) {
+			return readMnemonicFrom(os.Stdin)
+		}
+		return promptMnemonicFrom(os.Stdin, os.Stdout)
+	})
+}
 
-	if ki.Password != "" {
-		if err := k.Encrypt(ki.Password); err != nil {
+// readMnemonicFrom reads a piped mnemonic from r without prompting. Words are collected line by line, so the mnemonic
+// may be split across lines or use the numbered key display, until all words have arrived, an empty line follows
+// the words, or the input ends. A stream that is left open after the mnemonic is therefore never waited on.
+func readMnemonicFrom(r io.Reader) (string, error) {
+	scanner := bufio.NewScanner(r)
+	var fields []string
+	words := 0
+	for words < keys.MNEMONIC_WORD_COUNT && scanner.Scan() {
+		line := strings.Fields(scanner.Text())
+		if len(line) == 0 {
+			if words > 0 {
+				break
+			}
+			continue
+		}
+		for _, field := range line {
+			// a bare position prefix such as "01:" is not a word
+			if strings.TrimLeft(field, "0123456789") != ":" {
+				words++
+			}
+		}
+		fields = append(fields, line...)
+	}
+	if err := scanner.Err(); err != nil {
+		return "", fmt.Errorf("failed to read mnemonic input: %w", err)
+	}
+	if words == 0 {
+		return "", cli.Exit("No mnemonic received on stdin (end of input).", 1)
+	}
+	return strings.Join(fields, " "), nil
+}
+
+// promptMnemonicFrom prompts for the mnemonic on out and reads it from r, re-prompting when an empty line is entered.
+// Any reader can drive it, e.g. a strings.Reader in tests or a pipe from another front-end.
+func promptMnemonicFrom(r io.Reader, out io.Writer) (string, error) {
+	reader, ok := r.(*bufio.Reader)
+	if !ok {
+		reader = bufio.NewReader(r)
+	}
+
+	for attempt := 1; attempt <= MNEMONIC_PROMPT_ATTEMPTS; attempt++ {
+		fmt.Fprintln(out, "Please enter your 24-word mnemonic recovery key in order (separated by spaces):")
+
+		line, err := reader.ReadString('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return "", fmt.Errorf("failed to read mnemonic input: %w", err)
+		}
+
+		// a final line without a newline is still accepted
+		if line = strings.TrimSpace(line); line != "" {
+			if strings.HasPrefix(line, "01:") || strings.HasPrefix(line, "1:") {
+				line = readNumberedLines(reader, line)
+			}
+			fmt.Fprintln(out)
+			return line, nil
+		}
+
+		// Ctrl-D or a closed pipe, nothing more will arrive
+		if errors.Is(err, io.EOF) {
+			fmt.Fprintln(out)
+			return "", cli.Exit("No mnemonic received (end of input).", 1)
+		}
+
+		fmt.Fprintln(out, "No input received.")
+	}
+
+	return "", cli.Exit(fmt.Sprintf("No mnemonic received after %d attempts.", MNEMONIC_PROMPT_ATTEMPTS), 1)
+}
+
+// readNumberedLines continues a mnemonic pasted from the numbered, multi-line key display, reading lines until the
+// last word number appears, an empty line is entered, or the input ends
+func readNumberedLines(reader *bufio.Reader, first string) string {
+	lines := []string{first}
+	last := fmt.Sprintf("%d:", keys.MNEMONIC_WORD_COUNT)
+	for !strings.Contains(" "+strings.Join(lines, " "), " "+last) {
+		line, err := reader.ReadString('\n')
+		if line = strings.TrimSpace(line); line == "" {
+			break
+		}
+		lines = append(lines, line)
+		if err != nil {
+			break
+		}
+	}
+	return strings.Join(lines, " ")
+}
+
+// actionRestore restores a private key from an existing mnemonic/salt
+func actionRestore(ctx context.Context, c *cli.Command) error {
+	setLogging(c)
+	ki, err := getKeyInfo(c)
+	if err != nil {
+		return err
+	}
+	// a brainwallet key has no mnemonic, so it leaves the mnemonic path right away
+	if c.Bool("brainwallet") {
+		return actionBrainwallet(ctx, c, ki)
+	}
+	if c.Bool("dry-run") {
+		return dryRun(c, ki, true)
+	}
+
+	if err := confirmOutFile(c); err != nil {
+		return err
+	}
+
+	mnemonic, gen, err := readMnemonic(c, ki.Generator)
+	if err != nil {
+		return err
+	}
+	ki.Generator = gen
+
+	k, err := deriveKey(ctx, ki, mnemonic)
+	if err != nil {
+		return err
+	}
+
+	return outputKey(ctx, c, ki, k)
+}
+
+// outputKey encrypts, displays, and writes the derived key, then checks it against any expected fingerprint
+func outputKey(ctx context.Context, c *cli.Command, ki *KeyInfo, k *keys.Key) error {
+	k.SetComment(ki.Comment)
+
+	password := ki.Password
+	if ki.DerivePassword {
+		var err error
+		if password, err = ki.Generator.DerivePEMPassword(ctx, ki.Salt, k.Mnemonic()); err != nil {
+			log.Error().Err(err).Msg("Failed to derive the private key password")
+			return err
+		}
+		log.Info().Msg("Encrypting the private key under the password derived from the mnemonic. It adds no secrecy beyond the mnemonic.")
+	}
+	if password != "" {
+		if err := encryptKey(c, k, password); err != nil {
 			log.Error().Err(err).Msg("Failed to encrypt the private key")
 			return err
 		}
 		log.Debug().Msg("Encrypted the private key with the provided password.")
 	}
 
-	k.Display()
-	if err := writeFile(c, k.PEM()); err != nil {
+	if _, err := k.Encode(ki.Format); err != nil {
+		log.Error().Err(err).Msg("Failed to encode the private key")
+		return err
+	}
+	// a mismatched key must not be shown or overwrite any output file, even with --force
+	if err := checkFingerprint(c, k); err != nil {
+		return err
+	}
+
+	auditKey(c, ki.Label, k)
+
+	if c.Bool("json") {
+		if err := displayJSON(c, ki, k); err != nil {
+			return err
+		}
+	} else if err := k.DisplayFormat(ki.Format); err != nil {
+		return err
+	}
+	if err := writeKeyFiles(c, ki, k); err != nil {
 		log.Error().Err(err).Msg("Failed to write key to file")
+		return err
+	}
+	if err := writeSplitPassword(c, password); err != nil {
+		log.Error().Err(err).Msg("Failed to hand over the split key password")
+		return err
+	}
+	if err := writeMnemonic(c, ki.Generator, k.Mnemonic()); err != nil {
+		log.Error().Err(err).Msg("Failed to write mnemonic to file")
+		return err
+	}
+	if err := writeEntropy(c, ki.Generator, k.Mnemonic()); err != nil {
+		log.Error().Err(err).Msg("Failed to write mnemonic entropy to file")
+		return err
+	}
+	if err := writePublicKey(c, k); err != nil {
+		log.Error().Err(err).Msg("Failed to write public key to file")
+		return err
+	}
+	if err := writeRawPublicKey(c, k); err != nil {
+		log.Error().Err(err).Msg("Failed to write raw public key to file")
+		return err
+	}
+	if err := writeSSHPublicKey(c, k); err != nil {
+		log.Error().Err(err).Msg("Failed to write OpenSSH public key to file")
+		return err
+	}
+	if err := writeMetadata(c, ki, k); err != nil {
+		log.Error().Err(err).Msg("Failed to write key metadata to file")
+		return err
+	}
+	if err := writeRecord(c, k); err != nil {
+		log.Error().Err(err).Msg("Failed to write key record to file")
+		return err
+	}
+	if err := writeCertificates(c, k, ki.Cert); err != nil {
+		log.Error().Err(err).Msg("Failed to write certificate")
+		return err
+	}
+	if err := writeStepCA(c, k, ki.StepCA); err != nil {
+		log.Error().Err(err).Msg("Failed to write step-ca root")
+		return err
+	}
+	if err := writeVault(c, k, ki.VaultWrapping); err != nil {
+		log.Error().Err(err).Msg("Failed to write Vault import key")
+		return err
+	}
+	if err := writeTink(c, k); err != nil {
+		log.Error().Err(err).Msg("Failed to write Tink keyset")
+		return err
 	}
 
 	return nil
 }
+
+// checkFingerprint compares the derived public key against the --expect-fingerprint value, if provided
+func checkFingerprint(c *cli.Command, k *keys.Key) error {
+	expected := c.String("expect-fingerprint")
+	if expected == "" {
+		return nil
+	}
+
+	format, err := keys.ParseFingerprintFormat(c.String("fingerprint-format"))
+	if err != nil {
+		return cli.Exit(err.Error(), 1)
+	}
+
+	actual, err := k.PublicFingerprint(format)
+	if err != nil {
+		return fmt.Errorf("failed to compute public key fingerprint: %w", err)
+	}
+
+	ok, err := k.MatchFingerprint(expected, format)
+	if err != nil {
+		return fmt.Errorf("failed to compare public key fingerprint: %w", err)
+	}
+
+	if !ok && !c.Bool("json") {
+		fmt.Printf("Fingerprint Check: FAIL\n  expected: %s\n  actual:   %s\n", strings.TrimSpace(expected), actual)
+	}
+	if !ok {
+		return cli.Exit("The derived key does not match the expected fingerprint.", 1)
+	}
+
+	// keep the JSON output parseable, a mismatch is still reported through the exit status
+	if !c.Bool("json") {
+		fmt.Printf("Fingerprint Check: OK (%s)\n", actual)
+	}
+	return nil
+}
+
+// displayJSON prints the key report as JSON, only including secret material when --include-private is given
+func displayJSON(c *cli.Command, ki *KeyInfo, k *keys.Key) error {
+	report, err := k.Report(ki.Format, c.Bool("include-private"))
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to build the key report")
+		return err
+	}
+
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal key report: %w", err)
+	}
+	fmt.Println(string(data))
+	return nil
+}
@@ -0,0 +1,1336 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/goodieshq/bipkey/pkg/keys"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/urfave/cli/v3"
+)
+
+const testMnemonic = "away mistake dance place sword title nurse diary skin soon figure sense force seat inform hedgehog debate around tortoise detail uncle situate draft wait"
+
+func TestPromptMnemonicFromRetriesEmptyInput(t *testing.T) {
+	var out strings.Builder
+	line, err := promptMnemonicFrom(strings.NewReader("\n   \n"+testMnemonic+"\n"), &out)
+	if err != nil {
+		t.Fatalf("failed to read mnemonic: %v", err)
+	}
+	if line != testMnemonic {
+		t.Fatalf("unexpected mnemonic: %q", line)
+	}
+	if strings.Count(out.String(), "No input received.") != 2 {
+		t.Fatalf("expected two empty input notices, got:\n%s", out.String())
+	}
+}
+
+func TestPromptMnemonicFromGivesUp(t *testing.T) {
+	tests := map[string]string{
+		"eof":       "",
+		"eof after": "\n",
+		"all empty": strings.Repeat("\n", MNEMONIC_PROMPT_ATTEMPTS+1),
+	}
+	for name, input := range tests {
+		if _, err := promptMnemonicFrom(strings.NewReader(input), io.Discard); err == nil {
+			t.Fatalf("%s: expected an error without a mnemonic", name)
+		}
+	}
+
+	// input ending without a newline is accepted
+	line, err := promptMnemonicFrom(strings.NewReader(testMnemonic), io.Discard)
+	if err != nil || line != testMnemonic {
+		t.Fatalf("expected the final unterminated line to be accepted: %q, %v", line, err)
+	}
+}
+
+func TestPromptMnemonicFromReader(t *testing.T) {
+	var out strings.Builder
+	line, err := promptMnemonicFrom(strings.NewReader("  "+testMnemonic+"  \n"), &out)
+	if err != nil {
+		t.Fatalf("failed to read mnemonic: %v", err)
+	}
+	if line != testMnemonic {
+		t.Fatalf("unexpected mnemonic: %q", line)
+	}
+	if !strings.HasPrefix(out.String(), "Please enter your 24-word mnemonic") {
+		t.Fatalf("expected the prompt to be written to the output, got %q", out.String())
+	}
+}
+
+func TestPromptMnemonicFromNumberedDisplay(t *testing.T) {
+	// the numbered display spans several lines, which are read until the last word number
+	words := strings.Fields(testMnemonic)
+	var lines []string
+	for row := 0; row < len(words); row += 6 {
+		var line []string
+		for i := row; i < row+6; i++ {
+			line = append(line, fmt.Sprintf("%02d: %-9s", i+1, words[i]))
+		}
+		lines = append(lines, strings.Join(line, ""))
+	}
+	input := strings.Join(lines, "\n") + "\nnext line\n"
+
+	line, err := promptMnemonicFrom(strings.NewReader(input), io.Discard)
+	if err != nil {
+		t.Fatalf("failed to read mnemonic: %v", err)
+	}
+	m, err := keys.ParseMnemonic(line)
+	if err != nil {
+		t.Fatalf("failed to parse the numbered mnemonic %q: %v", line, err)
+	}
+	if m.String() != testMnemonic {
+		t.Fatalf("unexpected mnemonic: %q", m.String())
+	}
+}
+
+func TestPromptMnemonicPipedStdin(t *testing.T) {
+	// a pipe is not a terminal, so the mnemonic is read without printing a prompt
+	stdinR, stdinW, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("failed to create stdin pipe: %v", err)
+	}
+	stdout, err := os.Create(filepath.Join(t.TempDir(), "stdout"))
+	if err != nil {
+		t.Fatalf("failed to create stdout file: %v", err)
+	}
+	defer stdout.Close()
+
+	oldStdin, oldStdout := os.Stdin, os.Stdout
+	os.Stdin, os.Stdout = stdinR, stdout
+	defer func() { os.Stdin, os.Stdout = oldStdin, oldStdout }()
+
+	// the words may be split across lines, and the pipe is left open after the mnemonic
+	words := strings.Fields(testMnemonic)
+	fmt.Fprintf(stdinW, "\n  %s\n\t%s  \n", strings.Join(words[:10], "  "), strings.Join(words[10:], " "))
+	defer stdinW.Close()
+
+	line, err := promptMnemonic()
+	if err != nil {
+		t.Fatalf("failed to read piped mnemonic: %v", err)
+	}
+	if line != testMnemonic {
+		t.Fatalf("unexpected mnemonic: %q", line)
+	}
+	if info, err := stdout.Stat(); err != nil || info.Size() != 0 {
+		t.Fatalf("expected nothing to be written to stdout when stdin is piped")
+	}
+}
+
+func TestPromptSecretWithoutTerminal(t *testing.T) {
+	stdinR, stdinW, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("failed to create stdin pipe: %v", err)
+	}
+	defer stdinW.Close()
+	defer stdinR.Close()
+
+	oldStdin, oldTTY := os.Stdin, ttyPath
+	os.Stdin, ttyPath = stdinR, filepath.Join(t.TempDir(), "no-tty")
+	defer func() { os.Stdin, ttyPath = oldStdin, oldTTY }()
+
+	// with stdin piped and no controlling terminal, the error names the non-interactive source
+	_, err = promptSecret("salt", true)
+	if err == nil || !strings.Contains(err.Error(), "no terminal is available") || !strings.Contains(err.Error(), "--salt") {
+		t.Fatalf("expected a clear error without a terminal, got: %v", err)
+	}
+}
+
+func TestReadSecretVisibleFallback(t *testing.T) {
+	tests := []struct {
+		input string
+		value string
+	}{
+		{"my salt\nmy salt\n", "my salt"},
+		{"my salt\r\nmy salt", "my salt"},
+		{"my salt\nother salt\n", ""},
+		{"\n", ""},
+		{"", ""},
+	}
+	for _, tc := range tests {
+		r, w, err := os.Pipe()
+		if err != nil {
+			t.Fatalf("failed to create pipe: %v", err)
+		}
+		io.WriteString(w, tc.input)
+		w.Close()
+
+		// a pipe cannot be switched to hidden input, so the secret is read as visible lines
+		value, err := readSecret(r, "salt", true)
+		r.Close()
+		if tc.value == "" && err == nil {
+			t.Fatalf("expected input %q to be rejected, got %q", tc.input, value)
+		}
+		if tc.value != "" && (err != nil || value != tc.value) {
+			t.Fatalf("unexpected secret for input %q: %q, %v", tc.input, value, err)
+		}
+	}
+}
+
+func TestReadWithTimeout(t *testing.T) {
+	oldTimeout := promptTimeout
+	defer func() { promptTimeout = oldTimeout }()
+
+	block := make(chan struct{})
+	defer close(block)
+	promptTimeout = 10 * time.Millisecond
+	_, err := readWithTimeout("salt", func() (string, error) {
+		<-block
+		return "", nil
+	})
+	if err == nil || !strings.Contains(err.Error(), "Timed out after 10ms waiting for the salt") {
+		t.Fatalf("expected the read to time out, got: %v", err)
+	}
+
+	promptTimeout = 0
+	value, err := readWithTimeout("salt", func() (string, error) { return "value", nil })
+	if err != nil || value != "value" {
+		t.Fatalf("unexpected result without a timeout: %q, %v", value, err)
+	}
+}
+
+func TestReadMnemonicFrom(t *testing.T) {
+	words := strings.Fields(testMnemonic)
+	var numbered []string
+	for i, word := range words {
+		numbered = append(numbered, fmt.Sprintf("%02d: %s", i+1, word))
+	}
+
+	tests := map[string]string{
+		"one line":     testMnemonic + "\n",
+		"unterminated": testMnemonic,
+		"empty line":   strings.Join(words[:12], " ") + "\n" + strings.Join(words[12:], " ") + "\n\nnot a word\n",
+		"numbered":     strings.Join(numbered[:12], " ") + "\n" + strings.Join(numbered[12:], " ") + "\nnext line\n",
+	}
+	for name, input := range tests {
+		line, err := readMnemonicFrom(strings.NewReader(input))
+		if err != nil {
+			t.Fatalf("%s: failed to read mnemonic: %v", name, err)
+		}
+		m, err := keys.ParseMnemonic(line)
+		if err != nil {
+			t.Fatalf("%s: failed to parse mnemonic %q: %v", name, line, err)
+		}
+		if m.String() != testMnemonic {
+			t.Fatalf("%s: unexpected mnemonic: %q", name, m.String())
+		}
+	}
+
+	if _, err := readMnemonicFrom(strings.NewReader("\n  \n")); err == nil {
+		t.Fatalf("expected an error without a mnemonic")
+	}
+}
+
+func TestCheckMnemonic(t *testing.T) {
+	words := strings.Fields(testMnemonic)
+	short := keys.MustParseMnemonic(testMnemonic)
+	short = short.Short()
+
+	tests := []struct {
+		name   string
+		input  string
+		strict bool
+		ok     bool
+	}{
+		{"valid", testMnemonic, false, true},
+		{"prefixes", short.String(), false, true},
+		{"prefixes strict", short.String(), true, false},
+		{"checksum", strings.Join(append(append([]string{}, words[:23]...), "zoo"), " "), false, false},
+		{"unknown word", strings.Join(append([]string{"xyzzy"}, words[1:]...), " "), false, false},
+		{"23 words", strings.Join(words[:23], " "), false, false},
+	}
+	for _, test := range tests {
+		if err := checkMnemonic(keys.DefaultGenerator(), test.input, test.strict); (err == nil) != test.ok {
+			t.Fatalf("%s: expected ok=%v, got %v", test.name, test.ok, err)
+		}
+	}
+}
+
+func TestGetPassword(t *testing.T) {
+	dir := t.TempDir()
+	passwordFile := filepath.Join(dir, "password")
+	if err := os.WriteFile(passwordFile, []byte("from-file\r\n"), 0600); err != nil {
+		t.Fatalf("failed to write password file: %v", err)
+	}
+	emptyFile := filepath.Join(dir, "empty")
+	if err := os.WriteFile(emptyFile, []byte("\n"), 0600); err != nil {
+		t.Fatalf("failed to write password file: %v", err)
+	}
+
+	run := func(env string, args ...string) (string, bool, error) {
+		t.Setenv(PASSWORD_ENV_VAR, env)
+		var password string
+		var derived bool
+		cmd := &cli.Command{
+			Name: "bipkey",
+			// report cli.Exit errors instead of exiting the test binary
+			ExitErrHandler: func(context.Context, *cli.Command, error) {},
+			Flags: []cli.Flag{
+				&cli.StringFlag{Name: "password"},
+				&cli.StringFlag{Name: "password-file"},
+				&cli.BoolFlag{Name: "password-prompt"},
+				&cli.BoolFlag{Name: "encrypt"},
+				&cli.BoolFlag{Name: "derive-password"},
+			},
+			Action: func(ctx context.Context, c *cli.Command) error {
+				var err error
+				password, derived, err = getKeyPassword(c)
+				return err
+			},
+		}
+		err := cmd.Run(t.Context(), append([]string{"bipkey"}, args...))
+		return password, derived, err
+	}
+
+	tests := []struct {
+		name     string
+		env      string
+		args     []string
+		password string
+		ok       bool
+	}{
+		{"none", "", nil, "", true},
+		{"flag", "from-env", []string{"--password", "from-flag"}, "from-flag", true},
+		{"file", "from-env", []string{"--password-file", passwordFile}, "from-file", true},
+		// a variable left in the environment must not encrypt a key on its own
+		{"env without encrypt", "from-env", nil, "", true},
+		{"encrypt env", "from-env", []string{"--encrypt"}, "from-env", true},
+		{"encrypt missing", "", []string{"--encrypt"}, "", false},
+		{"flag and file", "", []string{"--password", "x", "--password-file", passwordFile}, "", false},
+		{"empty file", "", []string{"--password-file", emptyFile}, "", false},
+		{"missing file", "", []string{"--password-file", filepath.Join(dir, "missing")}, "", false},
+		{"derived encrypt", "", []string{"--derive-password", "--encrypt"}, "", true},
+		{"derived and flag", "", []string{"--derive-password", "--password", "x"}, "", false},
+		{"derived and env", "from-env", []string{"--derive-password", "--encrypt"}, "", false},
+		{"derived ignores env", "from-env", []string{"--derive-password"}, "", true},
+	}
+	for _, test := range tests {
+		password, derived, err := run(test.env, test.args...)
+		if (err == nil) != test.ok || password != test.password {
+			t.Fatalf("%s: got %q (%v), want %q (ok=%v)", test.name, password, err, test.password, test.ok)
+		}
+		if wantDerived := test.ok && slices.Contains(test.args, "--derive-password"); derived != wantDerived {
+			t.Fatalf("%s: expected derived=%v, got %v", test.name, wantDerived, derived)
+		}
+	}
+}
+
+func TestMatchKeyFile(t *testing.T) {
+	mnemonic := keys.MustParseMnemonic(testMnemonic)
+	derived, err := keys.GenerateKeyFromMnemonic(t.Context(), keys.KeyTypeECC, int(keys.ECCCurveP256), "match-salt", mnemonic)
+	if err != nil {
+		t.Fatalf("failed to derive key: %v", err)
+	}
+	other, err := keys.GenerateKeyFromMnemonic(t.Context(), keys.KeyTypeECC, int(keys.ECCCurveP256), "other-salt", mnemonic)
+	if err != nil {
+		t.Fatalf("failed to derive key: %v", err)
+	}
+
+	// the key file is stored encrypted, as it typically is in a backup
+	stored, err := keys.GenerateKeyFromMnemonic(t.Context(), keys.KeyTypeECC, int(keys.ECCCurveP256), "match-salt", mnemonic)
+	if err != nil {
+		t.Fatalf("failed to derive key: %v", err)
+	}
+	if err := stored.Encrypt("match-password"); err != nil {
+		t.Fatalf("failed to encrypt key: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "key.pem")
+	if err := os.WriteFile(path, []byte(stored.PEM()), 0600); err != nil {
+		t.Fatalf("failed to write key file: %v", err)
+	}
+
+	load := func(password string) (*keys.Key, error) {
+		var loaded *keys.Key
+		cmd := &cli.Command{
+			Name:           "match",
+			ExitErrHandler: func(context.Context, *cli.Command, error) {},
+			Flags:          []cli.Flag{&cli.StringFlag{Name: "in"}, &cli.StringFlag{Name: "in-password"}},
+			Action: func(ctx context.Context, c *cli.Command) error {
+				var err error
+				loaded, err = loadInputKey(c)
+				return err
+			},
+		}
+		err := cmd.Run(t.Context(), []string{"match", "--in", path, "--in-password", password})
+		return loaded, err
+	}
+
+	if _, err := load("wrong-password"); err == nil {
+		t.Fatalf("expected the key file to fail to load with a wrong password")
+	}
+	loaded, err := load("match-password")
+	if err != nil {
+		t.Fatalf("failed to load key file: %v", err)
+	}
+
+	match, derivedFingerprint, loadedFingerprint, err := compareKeys(derived, loaded, keys.FingerprintHex)
+	if err != nil || !match || derivedFingerprint != loadedFingerprint {
+		t.Fatalf("expected the derived key to match the key file: %s != %s (%v)", derivedFingerprint, loadedFingerprint, err)
+	}
+	if match, _, _, err := compareKeys(other, loaded, keys.FingerprintHex); err != nil || match {
+		t.Fatalf("expected a key derived with another salt not to match the key file (%v)", err)
+	}
+}
+
+func TestUnescapeSeparator(t *testing.T) {
+	tests := map[string]string{
+		`\n`:    "\n",
+		` | `:   " | ",
+		`\t-\\`: "\t-\\",
+	}
+	for val, expected := range tests {
+		if sep, err := unescapeSeparator(val); err != nil || sep != expected {
+			t.Fatalf("unescapeSeparator(%q): got %q (%v), want %q", val, sep, err, expected)
+		}
+	}
+	for _, val := range []string{`\`, `\x`} {
+		if _, err := unescapeSeparator(val); err == nil {
+			t.Fatalf("expected %q to be rejected", val)
+		}
+	}
+}
+
+func TestCheckSaltBlocklist(t *testing.T) {
+	if err := checkSaltBlocklist("", "salt"); err != nil {
+		t.Fatalf("expected no blocklist to accept any salt: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "blocklist.txt")
+	if err := os.WriteFile(path, []byte("# banned salts\nsalt\n\n  Password  \r\nchangeme\n"), 0644); err != nil {
+		t.Fatalf("failed to write blocklist: %v", err)
+	}
+	for _, salt := range []string{"salt", "SALT", "password", "ChangeMe"} {
+		if err := checkSaltBlocklist(path, salt); err == nil {
+			t.Fatalf("expected salt %q to be rejected", salt)
+		}
+	}
+	for _, salt := range []string{"MyExampleSalt", "# banned salts", "salty"} {
+		if err := checkSaltBlocklist(path, salt); err != nil {
+			t.Fatalf("expected salt %q to be accepted: %v", salt, err)
+		}
+	}
+
+	if err := checkSaltBlocklist(filepath.Join(t.TempDir(), "missing.txt"), "salt"); err == nil {
+		t.Fatalf("expected a missing blocklist file to be an error")
+	}
+}
+
+func TestCheckWritable(t *testing.T) {
+	dir := t.TempDir()
+	existing := filepath.Join(dir, "existing.pem")
+	if err := os.WriteFile(existing, []byte("keep"), 0600); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	if err := checkWritable(filepath.Join(dir, "new.pem"), false); err != nil {
+		t.Fatalf("expected a new file to be writable: %v", err)
+	}
+	if err := checkWritable(existing, false); err == nil {
+		t.Fatalf("expected an existing file to be a conflict without --force")
+	}
+	if err := checkWritable(existing, true); err != nil {
+		t.Fatalf("expected an existing file to be writable with --force: %v", err)
+	}
+	for _, path := range []string{dir, filepath.Join(dir, "missing", "new.pem")} {
+		if err := checkWritable(path, true); err == nil {
+			t.Fatalf("expected %q to be rejected", path)
+		}
+	}
+
+	// the checks must leave no trace behind
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("failed to read directory: %v", err)
+	}
+	if data, _ := os.ReadFile(existing); len(entries) != 1 || string(data) != "keep" {
+		t.Fatalf("dry run checks modified the directory: %d entries, existing file %q", len(entries), data)
+	}
+}
+
+func TestRecoveryFlagValues(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "salt.txt"), []byte("MyExampleSalt\n"), 0600); err != nil {
+		t.Fatalf("failed to write salt file: %v", err)
+	}
+
+	record, err := keys.ParseRecoveryRecord([]byte("type: RSA\nsize: 3072\nderivation: v2\nrsa_exponent: {e: 3, fallback: true}\nindex: 4\nsalt_file: salt.txt\nmnemonic: " + testMnemonic + "\n"))
+	if err != nil {
+		t.Fatalf("failed to parse recovery record: %v", err)
+	}
+	values, err := recoveryFlagValues(record, dir)
+	if err != nil {
+		t.Fatalf("failed to get recovery flag values: %v", err)
+	}
+
+	expected := map[string]string{
+		"algorithm":             "rsa:3072",
+		"derivation":            "v2",
+		"kdf":                   "none",
+		"no-bip39-seed":         "false",
+		"rsa-exponent":          "3",
+		"rsa-exponent-fallback": "true",
+		"index":                 "4",
+		"identity":              "",
+		"salt":                  "MyExampleSalt",
+		"language":              "english",
+		"mnemonic":              testMnemonic,
+	}
+	if len(values) != len(expected) {
+		t.Fatalf("unexpected recovery flag values: %v", values)
+	}
+	for name, value := range expected {
+		if values[name] != value {
+			t.Fatalf("unexpected --%s value %q, want %q", name, values[name], value)
+		}
+	}
+
+	// a missing salt file is an error rather than an unsalted restore
+	record.SaltFile = "missing.txt"
+	if _, err := recoveryFlagValues(record, dir); err == nil {
+		t.Fatalf("expected a missing salt file to be an error")
+	}
+}
+
+func TestRecoveryFileNotDeprecated(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "recovery.yaml")
+	if err := os.WriteFile(path, []byte("type: ECC\ncurve: P-384\nmnemonic: "+testMnemonic+"\n"), 0600); err != nil {
+		t.Fatalf("failed to write recovery file: %v", err)
+	}
+
+	var buf bytes.Buffer
+	defer func(l zerolog.Logger) { log.Logger = l }(log.Logger)
+	log.Logger = zerolog.New(&buf)
+
+	cmd := &cli.Command{
+		Name: "bipkey",
+		Flags: []cli.Flag{
+			&cli.StringFlag{Name: "recovery-file"},
+			&cli.StringFlag{Name: "algorithm"},
+			&cli.StringFlag{Name: "ecc"},
+			&cli.StringFlag{Name: "rsa"},
+		},
+		Action: func(_ context.Context, c *cli.Command) error {
+			keyType, keyId, err := getKeyType(c)
+			if err != nil || keyType != keys.KeyTypeECC || keyId != int(keys.ECCCurveP384) {
+				t.Fatalf("unexpected key type %s %d from the recovery file: %v", keyType, keyId, err)
+			}
+			return nil
+		},
+	}
+	args := []string{"bipkey", "--recovery-file", path}
+	if err := loadRecoveryFile(cmd, args); err != nil {
+		t.Fatalf("failed to load recovery file: %v", err)
+	}
+	if err := cmd.Run(t.Context(), args); err != nil {
+		t.Fatalf("failed to run command: %v", err)
+	}
+	// the operator never gave the deprecated flags, so a restore must not warn about them
+	if strings.Contains(buf.String(), "deprecated") {
+		t.Fatalf("expected no deprecation warning for a recovery file, got: %s", buf.String())
+	}
+}
+
+func TestBenchKeyType(t *testing.T) {
+	gen, err := keys.NewGenerator(keys.Config{})
+	if err != nil {
+		t.Fatalf("failed to create generator: %v", err)
+	}
+	mnemonic := keys.MustParseMnemonic(testMnemonic)
+
+	times, err := benchKeyType(t.Context(), gen, keyTypeCase{keys.KeyTypeECC, int(keys.ECCCurveP256)}, mnemonic, 3)
+	if err != nil {
+		t.Fatalf("failed to benchmark: %v", err)
+	}
+	if len(times) != 3 {
+		t.Fatalf("expected 3 timings, got %d", len(times))
+	}
+
+	// a cancelled benchmark stops without waiting for the derivation
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+	if _, err := benchKeyType(ctx, gen, keyTypeCase{keys.KeyTypeRSA, int(keys.RSAKey8192)}, mnemonic, 1); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected a cancelled benchmark to stop, got %v", err)
+	}
+}
+
+func TestCheckAlgorithmPolicy(t *testing.T) {
+	tests := []struct {
+		args    []string
+		allowed bool
+	}{
+		{[]string{"test", "--rsa", "2048"}, true},
+		{[]string{"test", "--rsa", "2048", "--allowed-algorithms", "p384,rsa-4096"}, false},
+		{[]string{"test", "--ecc", "p384", "--allowed-algorithms", "p384,rsa-4096"}, true},
+		{[]string{"test", "--rsa", "4096", "--allowed-algorithms", "p384,rsa-4096"}, true},
+		{[]string{"test", "--ecc", "ed25519", "--allowed-algorithms", "rsa"}, false},
+		{[]string{"test", "--ecc", "ed25519", "--allowed-algorithms", "ecc"}, true},
+		{[]string{"test", "--ecc", "ed25519", "--allowed-algorithms", " , "}, false},
+		{[]string{"test", "--ecc", "ed25519", "--allowed-algorithms", ""}, false},
+	}
+	for _, tc := range tests {
+		cmd := &cli.Command{
+			Flags: []cli.Flag{
+				&cli.StringFlag{Name: "ecc"},
+				&cli.StringFlag{Name: "rsa"},
+				&cli.StringFlag{Name: "allowed-algorithms"},
+			},
+			ExitErrHandler: func(context.Context, *cli.Command, error) {},
+			Action: func(_ context.Context, c *cli.Command) error {
+				keyType, keyId, err := getKeyType(c)
+				if err != nil {
+					return err
+				}
+				return checkAlgorithmPolicy(c, keyType, keyId)
+			},
+		}
+		err := cmd.Run(context.Background(), tc.args)
+		if tc.allowed && err != nil {
+			t.Fatalf("expected %v to be allowed: %v", tc.args, err)
+		}
+		if !tc.allowed && (err == nil || !strings.Contains(err.Error(), "not allowed") && !strings.Contains(err.Error(), "names no algorithms")) {
+			t.Fatalf("expected %v to be rejected, got: %v", tc.args, err)
+		}
+	}
+}
+
+func TestGetKeyType(t *testing.T) {
+	tests := []struct {
+		args    []string
+		keyType keys.KeyType
+		keyId   int
+	}{
+		{[]string{"test", "--algorithm", "rsa:4096"}, keys.KeyTypeRSA, int(keys.RSAKey4096)},
+		{[]string{"test", "--algorithm", "ecc:p256"}, keys.KeyTypeECC, int(keys.ECCCurveP256)},
+		{[]string{"test", "--algorithm", "ed25519"}, keys.KeyTypeECC, int(keys.ECCCurveEd25519)},
+		{[]string{"test", "--algorithm", "ecc-p-384"}, keys.KeyTypeECC, int(keys.ECCCurveP384)},
+		{[]string{"test", "--ecc", "p521"}, keys.KeyTypeECC, int(keys.ECCCurveP521)},
+		{[]string{"test", "--rsa", "2048"}, keys.KeyTypeRSA, int(keys.RSAKey2048)},
+		{[]string{"test"}, keys.KeyTypeNone, 0},
+		{[]string{"test", "--algorithm", "rsa:1024"}, keys.KeyTypeNone, 0},
+		{[]string{"test", "--algorithm", "rsa:4096", "--ecc", "p256"}, keys.KeyTypeNone, 0},
+		{[]string{"test", "--ecc", "p256", "--rsa", "2048"}, keys.KeyTypeNone, 0},
+	}
+
+	// the deprecated flags log a warning naming --algorithm
+	var buf bytes.Buffer
+	defer func(l zerolog.Logger) { log.Logger = l }(log.Logger)
+	log.Logger = zerolog.New(&buf)
+
+	for _, tc := range tests {
+		buf.Reset()
+		cmd := &cli.Command{
+			Flags: []cli.Flag{
+				&cli.StringFlag{Name: "algorithm"},
+				&cli.StringFlag{Name: "ecc"},
+				&cli.StringFlag{Name: "rsa"},
+			},
+			ExitErrHandler: func(context.Context, *cli.Command, error) {},
+			Action: func(_ context.Context, c *cli.Command) error {
+				keyType, keyId, err := getKeyType(c)
+				if tc.keyType == keys.KeyTypeNone {
+					if err == nil {
+						t.Fatalf("expected %v to be rejected", tc.args)
+					}
+					return nil
+				}
+				if err != nil {
+					t.Fatalf("failed to get key type for %v: %v", tc.args, err)
+				}
+				if keyType != tc.keyType || keyId != tc.keyId {
+					t.Fatalf("%v selected %s key %d, expected %s key %d", tc.args, keyType, keyId, tc.keyType, tc.keyId)
+				}
+				deprecated := slices.Contains(tc.args, "--ecc") || slices.Contains(tc.args, "--rsa")
+				if strings.Contains(buf.String(), "Use --algorithm") != deprecated {
+					t.Fatalf("%v: expected a deprecation warning only for -ecc and -rsa, got: %s", tc.args, buf.String())
+				}
+				return nil
+			},
+		}
+		if err := cmd.Run(context.Background(), tc.args); err != nil {
+			t.Fatalf("failed to run %v: %v", tc.args, err)
+		}
+	}
+}
+
+func TestSaltMigration(t *testing.T) {
+	mnemonic, err := keys.ParseMnemonic(testMnemonic)
+	if err != nil {
+		t.Fatalf("failed to parse mnemonic: %v", err)
+	}
+	gen := keys.DefaultGenerator()
+	ki := &KeyInfo{
+		KeyType:   keys.KeyTypeECC,
+		KeyId:     int(keys.ECCCurveEd25519),
+		Salt:      "old-salt",
+		Generator: gen,
+		Label:     keys.KeyLabel{Identity: "root-ca", Index: 2},
+	}
+
+	plan, err := saltMigration(t.Context(), &cli.Command{Name: "salt-migration"}, ki, mnemonic, "new-salt", 3, keys.FingerprintHex)
+	if err != nil {
+		t.Fatalf("failed to build salt migration plan: %v", err)
+	}
+	if plan.Algorithm != "Ed25519" || plan.Identity != "root-ca" || len(plan.Keys) != 3 {
+		t.Fatalf("unexpected salt migration plan: %+v", plan)
+	}
+
+	// every row matches the keys derived on their own, and no fingerprint repeats across salts or indices
+	seen := make(map[string]bool)
+	for i, row := range plan.Keys {
+		if row.Index != 2+i {
+			t.Fatalf("unexpected index %d in row %d", row.Index, i)
+		}
+		for salt, fingerprint := range map[string]string{"old-salt": row.OldFingerprint, "new-salt": row.NewFingerprint} {
+			k, err := gen.GenerateLabeledKeyFromMnemonic(t.Context(), ki.KeyType, ki.KeyId, salt, mnemonic, keys.KeyLabel{Identity: "root-ca", Index: row.Index})
+			if err != nil {
+				t.Fatalf("failed to derive key: %v", err)
+			}
+			if expected, _ := k.PublicFingerprint(keys.FingerprintHex); fingerprint != expected {
+				t.Fatalf("index %d %s fingerprint %s, expected %s", row.Index, salt, fingerprint, expected)
+			}
+			if seen[fingerprint] {
+				t.Fatalf("fingerprint %s appears twice in the plan", fingerprint)
+			}
+			seen[fingerprint] = true
+		}
+	}
+
+	table := plan.String()
+	if !strings.Contains(table, "Identity: root-ca") || !strings.Contains(table, fmt.Sprintf("4      %s %s", plan.Keys[2].OldFingerprint, plan.Keys[2].NewFingerprint)) {
+		t.Fatalf("unexpected salt migration table:\n%s", table)
+	}
+}
+
+func TestRotatingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	r, err := openRotatingFile(path, 64, 2)
+	if err != nil {
+		t.Fatalf("failed to open log file: %v", err)
+	}
+	entry := []byte(strings.Repeat("x", 39) + "\n")
+	for range 4 {
+		if _, err := r.Write(entry); err != nil {
+			t.Fatalf("failed to write log entry: %v", err)
+		}
+	}
+
+	// every entry exceeds the remaining space, so each one starts a new file and only two backups are kept
+	for _, name := range []string{path, path + ".1", path + ".2"} {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatalf("failed to read %s: %v", name, err)
+		}
+		if !bytes.Equal(data, entry) {
+			t.Fatalf("expected %s to hold a single entry, got %q", name, data)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); err == nil {
+		t.Fatalf("expected the oldest log file to be dropped")
+	}
+}
+
+func TestAuditKeyOmitsSecrets(t *testing.T) {
+	const salt = "audit-log-salt"
+	gen, err := keys.NewGenerator(keys.Config{})
+	if err != nil {
+		t.Fatalf("failed to create generator: %v", err)
+	}
+	mnemonic := keys.MustParseMnemonic(testMnemonic)
+	k, err := gen.GenerateKeyFromMnemonic(t.Context(), keys.KeyTypeECC, int(keys.ECCCurveP256), salt, mnemonic)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	fingerprint, err := k.PublicFingerprint(keys.FingerprintColon)
+	if err != nil {
+		t.Fatalf("failed to compute fingerprint: %v", err)
+	}
+
+	defer func(l zerolog.Logger) { auditLog = l }(auditLog)
+	audit := func(k *keys.Key) map[string]any {
+		var buf bytes.Buffer
+		auditLog = zerolog.New(&buf)
+		cmd := &cli.Command{
+			Name:           "restore",
+			ExitErrHandler: func(context.Context, *cli.Command, error) {},
+			Flags:          []cli.Flag{&cli.StringFlag{Name: "fingerprint-format"}},
+			Action: func(ctx context.Context, c *cli.Command) error {
+				auditKey(c, keys.KeyLabel{}, k)
+				return nil
+			},
+		}
+		if err := cmd.Run(t.Context(), []string{"restore", "--fingerprint-format", "colon"}); err != nil {
+			t.Fatalf("failed to run command: %v", err)
+		}
+
+		out := buf.String()
+		for _, want := range []string{`"command":"restore"`, `"algorithm":"ECDSA P-256"`, fingerprint} {
+			if !strings.Contains(out, want) {
+				t.Fatalf("expected the audit entry to contain %s, got: %s", want, out)
+			}
+		}
+		for _, secret := range append(strings.Fields(testMnemonic), salt) {
+			if strings.Contains(out, `"`+secret) || strings.Contains(out, " "+secret) {
+				t.Fatalf("audit entry contains the secret %q: %s", secret, out)
+			}
+		}
+		var entry map[string]any
+		if err := json.Unmarshal([]byte(out), &entry); err != nil {
+			t.Fatalf("failed to parse the audit entry: %v", err)
+		}
+		return entry
+	}
+
+	// every encryption of the same key has a new salt and IV, the logged fingerprint must not change with them
+	var logged []any
+	for range 2 {
+		encrypted := k.Clone()
+		if err := encrypted.Encrypt("audit-password"); err != nil {
+			t.Fatalf("failed to encrypt key: %v", err)
+		}
+		logged = append(logged, audit(encrypted)["fingerprint"])
+	}
+	if logged[0] != fingerprint || logged[1] != fingerprint {
+		t.Fatalf("expected both encrypted runs to log %s, got %v", fingerprint, logged)
+	}
+}
+
+func TestOutputPaths(t *testing.T) {
+	tests := []struct {
+		args  []string
+		paths []string
+	}{
+		{[]string{"test", "--out", "key.pem"}, []string{"key.pem"}},
+		{[]string{"test", "--out", "key.{ext}", "--format", "pkcs8,sec1,openssh"}, []string{"key.pem", "key.sec1.pem", "key.ossh"}},
+		{[]string{"test", "--out", "key-{format}.txt", "--format", "ssh,pkcs1"}, []string{"key-openssh.txt", "key-pkcs1.txt"}},
+		{[]string{"test"}, nil},
+	}
+	for _, tc := range tests {
+		var paths []string
+		cmd := &cli.Command{
+			Flags: []cli.Flag{
+				&cli.StringFlag{Name: "out"},
+				&cli.StringFlag{Name: "format", Value: "pkcs8"},
+			},
+			Action: func(_ context.Context, c *cli.Command) error {
+				paths = outputPaths(c, "out")
+				return nil
+			},
+		}
+		if err := cmd.Run(context.Background(), tc.args); err != nil {
+			t.Fatalf("failed to run %v: %v", tc.args, err)
+		}
+		if !slices.Equal(paths, tc.paths) {
+			t.Fatalf("%v: expected %v, got %v", tc.args, tc.paths, paths)
+		}
+	}
+}
+
+func TestWizardPrompts(t *testing.T) {
+	w := newWizard(strings.NewReader("2\nsecp384r1\n\nmaybe\nyes\n"), io.Discard)
+	if answer, err := w.choose("Which key type?", []string{"ECC", "RSA"}, "ECC"); err != nil || answer != "RSA" {
+		t.Fatalf("expected option 2 to choose RSA, got %q, %v", answer, err)
+	}
+	if answer, err := w.choose("Which curve?", []string{"P-256", "P-384"}, "P-256"); err != nil || answer != "secp384r1" {
+		t.Fatalf("expected an answer that is not an option number to be returned as entered, got %q, %v", answer, err)
+	}
+	if ok, err := w.confirm("Use a salt?", true); err != nil || !ok {
+		t.Fatalf("expected an empty answer to take the default, got %v, %v", ok, err)
+	}
+	if ok, err := w.confirm("Proceed?", false); err != nil || !ok {
+		t.Fatalf("expected an invalid answer to be asked again, got %v, %v", ok, err)
+	}
+	if _, err := w.confirm("Proceed?", false); err == nil {
+		t.Fatalf("expected an error at the end of input instead of the default")
+	}
+}
+
+func TestWizardSetFlagValidates(t *testing.T) {
+	cmd := &cli.Command{
+		Name: "bipkey",
+		Flags: []cli.Flag{
+			&cli.StringFlag{Name: "ecc", Validator: func(val string) error {
+				_, err := keys.ParseECCCurve(val)
+				return err
+			}},
+		},
+		Action: func(_ context.Context, c *cli.Command) error {
+			w := newWizard(strings.NewReader("p999\np384\n"), io.Discard)
+			if err := w.setFlag(c, "ecc", func() (string, error) { return w.ask("Which curve?", "") }); err != nil {
+				return err
+			}
+			if c.String("ecc") != "p384" {
+				t.Fatalf("expected the invalid curve to be asked again, got %q", c.String("ecc"))
+			}
+
+			w = newWizard(strings.NewReader(strings.Repeat("p999\n", WIZARD_ATTEMPTS)), io.Discard)
+			if err := w.setFlag(c, "ecc", func() (string, error) { return w.ask("Which curve?", "") }); err == nil {
+				t.Fatalf("expected an error after %d invalid answers", WIZARD_ATTEMPTS)
+			}
+			return nil
+		},
+	}
+	if err := cmd.Run(t.Context(), []string{"bipkey"}); err != nil {
+		t.Fatalf("failed to run command: %v", err)
+	}
+}
+
+func TestGetSaltNormalization(t *testing.T) {
+	tests := []struct {
+		args     []string
+		expected string
+	}{
+		// by default the salt is used exactly as entered, so existing keys keep restoring
+		{[]string{"--salt", " caf\u00e9 salt "}, " caf\u00e9 salt "},
+		{[]string{"--salt", " caf\u00e9 salt ", "--salt-normalize", "none"}, " caf\u00e9 salt "},
+		{[]string{"--salt", " caf\u00e9 salt ", "--salt-normalize", "trim"}, "caf\u00e9 salt"},
+		{[]string{"--salt", " caf\u00e9 salt ", "--salt-normalize", "nfkd,trim"}, "cafe\u0301 salt"},
+	}
+	for _, tt := range tests {
+		var salt string
+		cmd := &cli.Command{
+			Name: "bipkey",
+			Flags: []cli.Flag{
+				&cli.StringFlag{Name: "salt"},
+				&cli.BoolFlag{Name: "salt-prompt"},
+				&cli.StringFlag{Name: "salt-normalize", Value: keys.SALT_NORMALIZATION_NONE},
+				&cli.StringFlag{Name: "recovery-file"},
+			},
+			Action: func(_ context.Context, c *cli.Command) error {
+				var err error
+				salt, err = getSalt(c)
+				return err
+			},
+		}
+		if err := cmd.Run(t.Context(), append([]string{"bipkey"}, tt.args...)); err != nil {
+			t.Fatalf("%v: failed to get salt: %v", tt.args, err)
+		}
+		if salt != tt.expected {
+			t.Fatalf("%v: expected salt %q, got %q", tt.args, tt.expected, salt)
+		}
+	}
+}
+
+func TestRepeatedWordMessage(t *testing.T) {
+	tests := []struct {
+		word     keys.RepeatedWord
+		expected string
+	}{
+		{keys.RepeatedWord{Word: "dance", Positions: []int{3, 17}}, `Words 3 and 17 are the same word "dance".`},
+		{keys.RepeatedWord{Word: "sword", Positions: []int{3, 5, 21}}, `Words 3, 5 and 21 are the same word "sword".`},
+	}
+	for _, tt := range tests {
+		if message := repeatedWordMessage(tt.word); message != tt.expected {
+			t.Fatalf("expected %q, got %q", tt.expected, message)
+		}
+	}
+}
+
+func TestBrainwalletConflicts(t *testing.T) {
+	var buf bytes.Buffer
+	warnBrainwallet(&buf)
+	if !strings.Contains(buf.String(), "WARNING: BRAINWALLET MODE") {
+		t.Fatalf("expected the brainwallet warning, got: %q", buf.String())
+	}
+
+	flags := []cli.Flag{newBrainwalletFlag(), &cli.StringFlag{Name: "kdf"}}
+	for _, name := range brainwalletStringConflicts {
+		flags = append(flags, &cli.StringFlag{Name: name})
+	}
+	for _, name := range brainwalletBoolConflicts {
+		flags = append(flags, &cli.BoolFlag{Name: name})
+	}
+
+	for _, args := range [][]string{
+		{"--mnemonic", testMnemonic},
+		{"--entropy", "00"},
+		{"--bip32-path", "m/0'"},
+		{"--derive-password"},
+		{"--dry-run"},
+		{"--kdf", "argon2id"},
+	} {
+		cmd := &cli.Command{
+			Name:           "restore",
+			ExitErrHandler: func(context.Context, *cli.Command, error) {},
+			Flags:          flags,
+			Action: func(ctx context.Context, c *cli.Command) error {
+				return actionBrainwallet(ctx, c, &KeyInfo{})
+			},
+		}
+		// every conflict is rejected before the passphrase is prompted for
+		err := cmd.Run(t.Context(), append([]string{"restore", "--brainwallet"}, args...))
+		if err == nil || !strings.Contains(err.Error(), "--brainwallet") {
+			t.Fatalf("expected %v to be rejected with --brainwallet, got: %v", args, err)
+		}
+	}
+}
+
+func TestGetComment(t *testing.T) {
+	run := func(args ...string) (string, *keys.CertOptions, error) {
+		var comment string
+		var certOpts *keys.CertOptions
+		cmd := &cli.Command{
+			Name:           "restore",
+			ExitErrHandler: func(context.Context, *cli.Command, error) {},
+			Flags:          append(certFlags(), &cli.StringFlag{Name: "comment"}),
+			Action: func(ctx context.Context, c *cli.Command) error {
+				var err error
+				if comment, err = getComment(c); err != nil {
+					return err
+				}
+				certOpts, err = getCertOptions(c)
+				return err
+			},
+		}
+		err := cmd.Run(t.Context(), append([]string{"restore"}, args...))
+		return comment, certOpts, err
+	}
+
+	if _, _, err := run("--comment", "ops\nroot"); err == nil {
+		t.Fatalf("expected a comment with a control character to be rejected")
+	}
+	if _, _, err := run("--cert-out", "cert.pem"); err == nil {
+		t.Fatalf("expected a certificate without a subject to be rejected")
+	}
+
+	// the label is the certificate subject fallback, after --subject and --dns
+	comment, certOpts, err := run("--comment", "ops@example.com", "--cert-out", "cert.pem")
+	if err != nil || comment != "ops@example.com" || certOpts.CommonName != "ops@example.com" {
+		t.Fatalf("expected the comment to be the certificate subject, got %q (%v)", comment, err)
+	}
+	if _, certOpts, err := run("--comment", "ops@example.com", "--dns", "ca.example.com", "--cert-out", "cert.pem"); err != nil || certOpts.CommonName != "ca.example.com" {
+		t.Fatalf("expected --dns to take precedence over the comment, got %v", err)
+	}
+}
+
+func TestGetCompatOpenSSL(t *testing.T) {
+	run := func(args ...string) (keys.OpenSSLVersion, error) {
+		var version keys.OpenSSLVersion
+		cmd := &cli.Command{
+			Name:           "restore",
+			ExitErrHandler: func(context.Context, *cli.Command, error) {},
+			Flags: []cli.Flag{
+				&cli.StringFlag{Name: "compat-openssl"},
+				&cli.StringFlag{Name: "format", Value: "pkcs8"},
+				&cli.StringFlag{Name: "pbkdf2-prf", Value: "sha256"},
+				&cli.IntFlag{Name: "pem-line-length", Value: keys.PEM_LINE_LENGTH},
+				&cli.BoolFlag{Name: "ec-params"},
+			},
+			Action: func(ctx context.Context, c *cli.Command) error {
+				var err error
+				version, err = getCompatOpenSSL(c)
+				return err
+			},
+		}
+		err := cmd.Run(t.Context(), append([]string{"restore"}, args...))
+		return version, err
+	}
+
+	if version, err := run(); err != nil || version != "" {
+		t.Fatalf("expected no OpenSSL profile without --compat-openssl, got %q (%v)", version, err)
+	}
+	if version, err := run("--compat-openssl", "3.2"); err != nil || version != keys.OpenSSL30 {
+		t.Fatalf("expected the OpenSSL 3.0 profile, got %q (%v)", version, err)
+	}
+	// the profile selects the encoding, so the flags choosing one conflict with it
+	for _, args := range [][]string{{"--format", "sec1"}, {"--pbkdf2-prf", "sha512"}, {"--pem-line-length", "76"}, {"--ec-params"}} {
+		if _, err := run(append([]string{"--compat-openssl", "1.1.1"}, args...)...); err == nil {
+			t.Fatalf("expected %v to be rejected with --compat-openssl", args)
+		}
+	}
+}
+
+func TestSplitPassword(t *testing.T) {
+	dir := t.TempDir()
+	key, err := keys.GenerateKeyFromMnemonic(t.Context(), keys.KeyTypeECC, int(keys.ECCCurveP256), "split", keys.MustParseMnemonic(testMnemonic))
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	run := func(args ...string) error {
+		cmd := &cli.Command{
+			Name:           "restore",
+			ExitErrHandler: func(context.Context, *cli.Command, error) {},
+			Flags: []cli.Flag{
+				&cli.StringFlag{Name: "out"},
+				&cli.StringFlag{Name: "format", Value: "pkcs8"},
+				&cli.StringFlag{Name: "password"},
+				&cli.StringFlag{Name: "password-file"},
+				&cli.BoolFlag{Name: "password-prompt"},
+				&cli.BoolFlag{Name: "derive-password"},
+				&cli.BoolFlag{Name: "split-password"},
+				&cli.StringFlag{Name: "password-out"},
+			},
+			Action: func(ctx context.Context, c *cli.Command) error {
+				password, err := getSplitPassword(c)
+				if err != nil {
+					return err
+				}
+				k := key.Clone()
+				if err := k.Encrypt(password); err != nil {
+					return err
+				}
+				if err := writeOutput(c.String("out"), k.PEM(), 0600); err != nil {
+					return err
+				}
+				return writeSplitPassword(c, password)
+			},
+		}
+		return cmd.Run(t.Context(), append([]string{"restore"}, args...))
+	}
+
+	read := func(path string) string {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("failed to read %s: %v", path, err)
+		}
+		return string(data)
+	}
+
+	// each export has its own password, which only the password file holds
+	var passwords []string
+	for _, name := range []string{"a", "b"} {
+		keyPath, passwordPath := filepath.Join(dir, name+".pem"), filepath.Join(dir, "custodian", name+".txt")
+		if err := os.MkdirAll(filepath.Dir(passwordPath), 0700); err != nil {
+			t.Fatalf("failed to create directory: %v", err)
+		}
+		if err := run("--out", keyPath, "--password-out", passwordPath); err != nil {
+			t.Fatalf("failed to split the key password: %v", err)
+		}
+		password := strings.TrimSpace(read(passwordPath))
+		if strings.Contains(read(keyPath), password) {
+			t.Fatalf("expected the key file not to contain its password")
+		}
+		loaded, err := keys.LoadKeyFromPEM([]byte(read(keyPath)), password)
+		if err != nil || loaded.Fingerprint() != key.Fingerprint() {
+			t.Fatalf("expected the password file to decrypt the key file: %v", err)
+		}
+		passwords = append(passwords, password)
+	}
+	if passwords[0] == passwords[1] {
+		t.Fatalf("expected every split export to generate a new password")
+	}
+
+	path := filepath.Join(dir, "same.pem")
+	for _, args := range [][]string{
+		{"--password-out", path},                                            // no key file
+		{"--out", path, "--password-out", path},                             // the same file
+		{"--out", path, "--password-out", path + ".txt", "--password", "x"}, // another password source
+		{"--out", path, "--password-out", path + ".txt", "--derive-password"},
+	} {
+		if err := run(args...); err == nil {
+			t.Fatalf("expected %v to be rejected", args)
+		}
+	}
+
+	if !sameDirectory("a/key.pem", "./a/pw.txt") || sameDirectory("a/key.pem", "b/pw.txt") {
+		t.Fatalf("unexpected same directory detection")
+	}
+}
+
+func TestGetStepCAOptions(t *testing.T) {
+	run := func(args ...string) (*keys.CertOptions, []string, error) {
+		var opts *keys.CertOptions
+		var paths []string
+		cmd := &cli.Command{
+			Name:           "restore",
+			ExitErrHandler: func(context.Context, *cli.Command, error) {},
+			Flags:          append(certFlags(), &cli.StringFlag{Name: "comment"}),
+			Action: func(ctx context.Context, c *cli.Command) error {
+				var err error
+				opts, err = getStepCAOptions(c)
+				paths = outputPaths(c, "step-ca")
+				return err
+			},
+		}
+		err := cmd.Run(t.Context(), append([]string{"restore"}, args...))
+		return opts, paths, err
+	}
+
+	if opts, _, err := run(); err != nil || opts != nil {
+		t.Fatalf("expected no step-ca root without --step-ca, got %v (%v)", opts, err)
+	}
+	if _, _, err := run("--step-ca", "ca"); err == nil {
+		t.Fatalf("expected a step-ca root without a subject to be rejected")
+	}
+	if _, _, err := run("--step-ca", "ca", "--subject", "Root", "--dns", "ca.example.com"); err == nil {
+		t.Fatalf("expected --dns to be rejected with --step-ca")
+	}
+
+	opts, paths, err := run("--step-ca", "ca", "--comment", "Example Root CA")
+	if err != nil || opts.CommonName != "Example Root CA" || !opts.IsCA || opts.Validity != keys.STEP_CA_ROOT_VALIDITY {
+		t.Fatalf("unexpected step-ca root options %+v (%v)", opts, err)
+	}
+	if !slices.Equal(paths, []string{filepath.Join("ca", "root_ca.key"), filepath.Join("ca", "root_ca.crt")}) {
+		t.Fatalf("unexpected step-ca output files %v", paths)
+	}
+	if opts, _, err := run("--step-ca", "ca", "--subject", "Root", "--cert-days", "7300"); err != nil || opts.Validity != 7300*24*time.Hour {
+		t.Fatalf("expected --cert-days to set the root validity, got %+v (%v)", opts, err)
+	}
+}
+
+func TestFingerprintCheckedBeforeOutput(t *testing.T) {
+	key, err := keys.GenerateKeyFromMnemonic(t.Context(), keys.KeyTypeECC, int(keys.ECCCurveP256), "check", keys.MustParseMnemonic(testMnemonic))
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "key.pem")
+	if err := os.WriteFile(path, []byte("existing"), 0600); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	cmd := &cli.Command{
+		Name:           "restore",
+		ExitErrHandler: func(context.Context, *cli.Command, error) {},
+		Flags: []cli.Flag{
+			&cli.StringFlag{Name: "out"},
+			&cli.BoolFlag{Name: "force"},
+			&cli.BoolFlag{Name: "json"},
+			&cli.StringFlag{Name: "expect-fingerprint"},
+			&cli.StringFlag{Name: "fingerprint-format", Value: "hex"},
+		},
+		Action: func(ctx context.Context, c *cli.Command) error {
+			ki := &KeyInfo{Format: keys.FormatPKCS8, Formats: []keys.Format{keys.FormatPKCS8}}
+			return outputKey(ctx, c, ki, key.Clone())
+		},
+	}
+	err = cmd.Run(t.Context(), []string{"restore", "--json", "--force", "--out", path, "--expect-fingerprint", strings.Repeat("0", 64)})
+	if err == nil {
+		t.Fatalf("expected a fingerprint mismatch to fail")
+	}
+	if data, err := os.ReadFile(path); err != nil || string(data) != "existing" {
+		t.Fatalf("expected a mismatched key not to overwrite the output file, got %q (%v)", data, err)
+	}
+}
+
+func TestExportPEM(t *testing.T) {
+	key, err := keys.GenerateKeyFromMnemonic(t.Context(), keys.KeyTypeECC, int(keys.ECCCurveP256), "export", keys.MustParseMnemonic(testMnemonic))
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	run := func(data string) (string, error) {
+		var out string
+		cmd := &cli.Command{
+			Name:           "restore",
+			ExitErrHandler: func(context.Context, *cli.Command, error) {},
+			Flags:          []cli.Flag{&cli.IntFlag{Name: "pem-line-length"}},
+			Action: func(ctx context.Context, c *cli.Command) error {
+				var err error
+				out, err = exportPEM(c, data)
+				return err
+			},
+		}
+		err := cmd.Run(t.Context(), []string{"restore", "--pem-line-length", "76"})
+		return out, err
+	}
+
+	// secrets and streams are hex or raw bytes, not PEM
+	for _, data := range []string{"9b1dad05dcbb1097be006641dd6d4e72\n", "\x00\x01raw"} {
+		if out, err := run(data); err != nil || out != data {
+			t.Fatalf("expected %q to be exported unchanged, got %q (%v)", data, out, err)
+		}
+	}
+	if out, err := run(key.PEM()); err != nil || out == key.PEM() {
+		t.Fatalf("expected the PEM key to be rewrapped, got %q (%v)", out, err)
+	}
+}
+
+func TestLogFileOmitsMnemonic(t *testing.T) {
+	// every word but the last is the same, so restore warns about the repeated word
+	words := append(slices.Repeat([]string{"abandon"}, 23), "art")
+	path := filepath.Join(t.TempDir(), "audit.log")
+
+	defer func(l zerolog.Logger) { auditLog = l }(auditLog)
+	defer func(l zerolog.Logger) { log.Logger = l }(log.Logger)
+	if err := app.Run(t.Context(), []string{"bipkey", "--algorithm", "p256", "--salt", "audit-log-salt", "--log-file", path, "restore", "--mnemonic", strings.Join(words, " ")}); err != nil {
+		t.Fatalf("failed to restore the key: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read the log file: %v", err)
+	}
+	if !strings.Contains(string(data), `"message":"Derived key."`) {
+		t.Fatalf("expected the log file to record the derived key, got: %s", data)
+	}
+	for _, secret := range []string{"abandon", "art", "audit-log-salt"} {
+		if strings.Contains(string(data), secret) {
+			t.Fatalf("log file contains the secret %q: %s", secret, data)
+		}
+	}
+}
+
+func TestVaultCheckedBeforeGeneration(t *testing.T) {
+	dir := t.TempDir()
+	out := filepath.Join(dir, "k.pem")
+	defer func(h cli.ExitErrHandlerFunc) { app.ExitErrHandler = h }(app.ExitErrHandler)
+	app.ExitErrHandler = func(context.Context, *cli.Command, error) {}
+
+	tests := [][]string{
+		{"--algorithm", "p256", "--vault-wrapping-key", filepath.Join(dir, "wrapping.pem")},
+		{"--algorithm", "p256", "--vault-out", filepath.Join(dir, "k.vault"), "--vault-wrapping-key", filepath.Join(dir, "missing.pem")},
+		{"--algorithm", "rsa:8192", "--vault-out", filepath.Join(dir, "k.vault")},
+	}
+	for _, args := range tests {
+		args = append(append([]string{"bipkey"}, args...), "--salt", "vault-salt", "--out", out, "restore", "--mnemonic", testMnemonic)
+		if err := app.Run(t.Context(), args); err == nil {
+			t.Fatalf("expected %v to be rejected", args)
+		}
+		if _, err := os.Stat(out); !errors.Is(err, os.ErrNotExist) {
+			t.Fatalf("expected %v to be rejected before the key is written, got %v", args, err)
+		}
+	}
+}
+
+func TestLogFileRecordsKeySet(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+
+	defer func(l zerolog.Logger) { auditLog = l }(auditLog)
+	defer func(l zerolog.Logger) { log.Logger = l }(log.Logger)
+	// the key set is printed to stdout, which the test does not need
+	devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatalf("failed to open %s: %v", os.DevNull, err)
+	}
+	defer devNull.Close()
+	defer func(f *os.File) { os.Stdout = f }(os.Stdout)
+	os.Stdout = devNull
+	if err := app.Run(t.Context(), []string{"bipkey", "--algorithm", "p256", "--salt", "audit-log-salt", "--log-file", path, "jwks", "--count", "3", "--mnemonic", testMnemonic}); err != nil {
+		t.Fatalf("failed to derive the key set: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read the log file: %v", err)
+	}
+	if n := strings.Count(string(data), `"message":"Derived key."`); n != 3 {
+		t.Fatalf("expected the log file to record the 3 keys of the set, got %d: %s", n, data)
+	}
+}
+
+func TestVerifyCertRequiresCertificate(t *testing.T) {
+	dir := t.TempDir()
+	defer func(h cli.ExitErrHandlerFunc) { app.ExitErrHandler = h }(app.ExitErrHandler)
+	app.ExitErrHandler = func(context.Context, *cli.Command, error) {}
+
+	for _, args := range [][]string{
+		{"--verify-cert"},
+		{"--verify-cert", "--csr-out", filepath.Join(dir, "k.csr"), "--subject", "example.com"},
+	} {
+		args = append(append([]string{"bipkey", "--algorithm", "p256", "--salt", "verify-cert-salt"}, args...), "--out", filepath.Join(dir, "k.pem"), "restore", "--mnemonic", testMnemonic)
+		if err := app.Run(t.Context(), args); err == nil || !strings.Contains(err.Error(), "--verify-cert requires") {
+			t.Fatalf("expected %v to be rejected, got %v", args, err)
+		}
+	}
+}
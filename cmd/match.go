@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/goodieshq/bipkey/pkg/keys"
+	"github.com/urfave/cli/v3"
+)
+
+// cmdMatch restores a key from its mnemonic and compares it against an existing private key file
+var cmdMatch = &cli.Command{
+	Name:      "match",
+	Usage:     "Restore the key from a mnemonic and check that it matches an existing private key file, e.g. during a DR test",
+	UsageText: "bipkey [--algorithm <algorithm>] [-salt <salt value>] match --in <key file> [--in-password <password>]",
+	Action:    actionMatch,
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:     "in",
+			Usage:    "Private key file to compare against (PKCS#8, encrypted PKCS#8, SEC1, PKCS#1, or OpenSSH)",
+			Required: true,
+		},
+		&cli.StringFlag{
+			Name:  "in-password",
+			Usage: "Password of an encrypted input key, prompted for without echo if needed and not given",
+			Value: "",
+		},
+		newMnemonicFlag(),
+		newEntropyFlag(),
+	},
+}
+
+// actionMatch prints MATCH or MISMATCH with both public key fingerprints and exits non-zero on a mismatch
+func actionMatch(ctx context.Context, c *cli.Command) error {
+	setLogging(c)
+	ki, err := getKeyInfo(c)
+	if err != nil {
+		return err
+	}
+
+	format, err := keys.ParseFingerprintFormat(c.String("fingerprint-format"))
+	if err != nil {
+		return cli.Exit(err.Error(), 1)
+	}
+
+	// load the file first, so that a wrong path or password fails before the mnemonic is entered
+	loaded, err := loadInputKey(c)
+	if err != nil {
+		return err
+	}
+
+	mnemonic, gen, err := readMnemonic(c, ki.Generator)
+	if err != nil {
+		return err
+	}
+
+	ki.Generator = gen
+	derived, err := deriveKey(ctx, ki, mnemonic)
+	if err != nil {
+		return err
+	}
+	auditKey(c, ki.Label, derived)
+
+	match, derivedFingerprint, loadedFingerprint, err := compareKeys(derived, loaded, format)
+	if err != nil {
+		return err
+	}
+
+	if match {
+		fmt.Println("MATCH")
+	} else {
+		fmt.Println("MISMATCH")
+	}
+	fmt.Printf("  Derived key: %s\n", derivedFingerprint)
+	fmt.Printf("  Key file:    %s\n", loadedFingerprint)
+
+	if !match {
+		return cli.Exit("", 1)
+	}
+	return nil
+}
+
+// compareKeys reports whether the keys have the same public key, along with the public key fingerprint of each
+func compareKeys(derived, loaded *keys.Key, format keys.FingerprintFormat) (bool, string, string, error) {
+	derivedFingerprint, err := derived.PublicFingerprint(format)
+	if err != nil {
+		return false, "", "", fmt.Errorf("failed to compute the derived key fingerprint: %w", err)
+	}
+	loadedFingerprint, err := loaded.PublicFingerprint(format)
+	if err != nil {
+		return false, "", "", fmt.Errorf("failed to compute the key file fingerprint: %w", err)
+	}
+	return derivedFingerprint == loadedFingerprint, derivedFingerprint, loadedFingerprint, nil
+}
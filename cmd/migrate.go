@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/goodieshq/bipkey/pkg/keys"
+	"github.com/rs/zerolog/log"
+	"github.com/urfave/cli/v3"
+)
+
+// cmdSaltMigration derives the keys of an index range under an old and a new salt and lists both fingerprints
+var cmdSaltMigration = &cli.Command{
+	Name:      "salt-migration",
+	Usage:     "Print the public key fingerprints of consecutive indices under the current --salt and a new salt, as a salt migration plan",
+	UsageText: "bipkey [--algorithm <algorithm>] [-salt <old salt>] [-index <first index>] [-out <file>] salt-migration --new-salt <new salt> --count <n> [--json]",
+	Action:    actionSaltMigration,
+	Flags: []cli.Flag{
+		newMnemonicFlag(),
+		newEntropyFlag(),
+		&cli.StringFlag{
+			Name:  "new-salt",
+			Usage: "Salt the keys are migrated to, normalized by --salt-normalize like --salt",
+		},
+		&cli.BoolFlag{
+			Name:  "new-salt-prompt",
+			Usage: "Prompt for the new salt without echoing it (entered twice) when --new-salt is not given",
+		},
+		&cli.IntFlag{
+			Name:  "count",
+			Usage: "Number of keys in the plan, derived at indices --index through --index + count - 1",
+			Value: 1,
+		},
+	},
+}
+
+// saltMigrationRow is the old-salt and new-salt public key fingerprint of the key at an index
+type saltMigrationRow struct {
+	Index          int    `json:"index"`
+	OldFingerprint string `json:"old_fingerprint"`
+	NewFingerprint string `json:"new_fingerprint"`
+}
+
+// saltMigrationPlan lists the fingerprints of every key a salt migration replaces. It holds no salt, so the plan
+// can be reviewed and archived as an audit record.
+type saltMigrationPlan struct {
+	Algorithm         string             `json:"algorithm"`
+	Identity          string             `json:"identity,omitempty"`
+	FingerprintFormat string             `json:"fingerprint_format"`
+	Keys              []saltMigrationRow `json:"keys"`
+}
+
+// actionSaltMigration derives the key range under both salts and prints the migration plan as a table or JSON
+func actionSaltMigration(ctx context.Context, c *cli.Command) error {
+	setLogging(c)
+	ki, err := getKeyInfo(c)
+	if err != nil {
+		return err
+	}
+
+	count := int(c.Int("count"))
+	if count < 1 {
+		return cli.Exit("The key count must be at least 1.", 1)
+	}
+	if ki.Path != nil {
+		return cli.Exit("salt-migration derives keys at consecutive indices, which --bip32-path does not support.", 1)
+	}
+	format, err := keys.ParseFingerprintFormat(c.String("fingerprint-format"))
+	if err != nil {
+		return cli.Exit(err.Error(), 1)
+	}
+
+	newSalt := c.String("new-salt")
+	if newSalt == "" && c.Bool("new-salt-prompt") {
+		if newSalt, err = promptSecret("new salt", true); err != nil {
+			return err
+		}
+	}
+	if newSalt == "" {
+		return cli.Exit("The new salt is required: use --new-salt or --new-salt-prompt.", 1)
+	}
+	if newSalt, err = normalizeSalt(c, newSalt); err != nil {
+		return err
+	}
+	if newSalt == ki.Salt {
+		return cli.Exit("The new salt is the same as the old salt.", 1)
+	}
+	if err := checkSaltBlocklist(c.String("salt-blocklist"), newSalt); err != nil {
+		return err
+	}
+	if err := confirmOutFile(c); err != nil {
+		return err
+	}
+
+	mnemonic, gen, err := readMnemonic(c, ki.Generator)
+	if err != nil {
+		return err
+	}
+	ki.Generator = gen
+
+	plan, err := saltMigration(ctx, c, ki, mnemonic, newSalt, count, format)
+	if err != nil {
+		return err
+	}
+
+	var output string
+	if c.Bool("json") {
+		data, err := json.MarshalIndent(plan, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal salt migration plan: %w", err)
+		}
+		output = string(data) + "\n"
+	} else {
+		output = plan.String()
+	}
+	fmt.Print(output)
+
+	// the plan only holds public key fingerprints, so it is written world-readable
+	if err := writeOutput(c.String("out"), output, 0644); err != nil {
+		log.Error().Err(err).Msg("Failed to write salt migration plan to file")
+		return err
+	}
+	return nil
+}
+
+// saltMigration derives the keys at count consecutive indices from the key info's label under the old salt of the key
+// info and the new salt, records each in the audit log, and returns their fingerprints
+func saltMigration(ctx context.Context, c *cli.Command, ki *KeyInfo, mnemonic keys.Mnemonic, newSalt string, count int, format keys.FingerprintFormat) (*saltMigrationPlan, error) {
+	caps, err := keys.GetCapabilities(ki.KeyType, ki.KeyId)
+	if err != nil {
+		return nil, err
+	}
+	plan := &saltMigrationPlan{Algorithm: caps.Algorithm, Identity: ki.Label.Identity, FingerprintFormat: string(format)}
+
+	for i := range count {
+		label := ki.Label
+		label.Index += i
+		row := saltMigrationRow{Index: label.Index}
+		for _, side := range []struct {
+			salt        string
+			fingerprint *string
+		}{{ki.Salt, &row.OldFingerprint}, {newSalt, &row.NewFingerprint}} {
+			k, err := ki.Generator.GenerateLabeledKeyFromMnemonic(ctx, ki.KeyType, ki.KeyId, side.salt, mnemonic, label)
+			if err != nil {
+				return nil, err
+			}
+			auditKey(c, label, k)
+			if *side.fingerprint, err = k.PublicFingerprint(format); err != nil {
+				return nil, err
+			}
+		}
+		log.Debug().Int("index", label.Index).Msg("Derived the old and new salt keys for the migration plan.")
+		plan.Keys = append(plan.Keys, row)
+	}
+	return plan, nil
+}
+
+// String formats the plan as a table of index, old fingerprint, and new fingerprint
+func (p *saltMigrationPlan) String() string {
+	var builder strings.Builder
+	builder.WriteString(fmt.Sprintf("Algorithm: %s\n", p.Algorithm))
+	if p.Identity != "" {
+		builder.WriteString(fmt.Sprintf("Identity: %s\n", p.Identity))
+	}
+
+	width := len("Old Fingerprint")
+	for _, row := range p.Keys {
+		width = max(width, len(row.OldFingerprint))
+	}
+	builder.WriteString(fmt.Sprintf("\n%-6s %-*s %s\n", "Index", width, "Old Fingerprint", "New Fingerprint"))
+	for _, row := range p.Keys {
+		builder.WriteString(fmt.Sprintf("%-6d %-*s %s\n", row.Index, width, row.OldFingerprint, row.NewFingerprint))
+	}
+	return builder.String()
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/rs/zerolog/log"
+	"github.com/urfave/cli/v3"
+)
+
+// cmdPin prints the SubjectPublicKeyInfo of a derived key and its SHA-256 pin
+var cmdPin = &cli.Command{
+	Name:      "pin",
+	Usage:     "Print the base64 DER SubjectPublicKeyInfo of the key derived from an existing mnemonic and its SHA-256 pin",
+	UsageText: "bipkey [--algorithm <algorithm>] [-salt <salt value>] [-out <file>] pin [--json]",
+	Action:    actionPin,
+	Flags: []cli.Flag{
+		newMnemonicFlag(),
+		newEntropyFlag(),
+	},
+}
+
+// actionPin derives the key and prints its SPKI pin, so that pins can be deployed before the key itself
+func actionPin(ctx context.Context, c *cli.Command) error {
+	setLogging(c)
+	ki, err := getKeyInfo(c)
+	if err != nil {
+		return err
+	}
+	if err := confirmOutFile(c); err != nil {
+		return err
+	}
+
+	mnemonic, gen, err := readMnemonic(c, ki.Generator)
+	if err != nil {
+		return err
+	}
+	ki.Generator = gen
+	k, err := deriveKey(ctx, ki, mnemonic)
+	if err != nil {
+		return err
+	}
+	auditKey(c, ki.Label, k)
+
+	pin, err := k.SPKIPin()
+	if err != nil {
+		return err
+	}
+
+	var output string
+	if c.Bool("json") {
+		data, err := json.MarshalIndent(pin, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal pin: %w", err)
+		}
+		output = string(data) + "\n"
+	} else {
+		output = fmt.Sprintf("SPKI: %s\npin-sha256: %s\n", pin.SPKI, pin.SHA256)
+	}
+	fmt.Print(output)
+
+	// the pin only describes the public key, so it is written world-readable
+	if err := writeOutput(c.String("out"), output, 0644); err != nil {
+		log.Error().Err(err).Msg("Failed to write pin to file")
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/goodieshq/bipkey/pkg/keys"
+	"github.com/rs/zerolog/log"
+	"github.com/urfave/cli/v3"
+	"golang.org/x/term"
+)
+
+// DEFAULT_PROMPT_TIMEOUT is how long an interactive prompt waits for input by default
+const DEFAULT_PROMPT_TIMEOUT = 10 * time.Minute
+
+// promptTimeout is the --prompt-timeout applied to interactive input, zero to wait indefinitely
+var promptTimeout = DEFAULT_PROMPT_TIMEOUT
+
+// ttyPath is the controlling terminal, which secrets are read from when stdin is piped
+var ttyPath = "/dev/tty"
+
+// secretSources names the non-interactive sources of each prompted secret, for the error when no terminal is available
+var secretSources = map[string]string{
+	"salt":                     "--salt",
+	"password":                 "--password-file or BIPKEY_PASSWORD",
+	"mnemonic backup password": "--password-file or BIPKEY_PASSWORD",
+	"input key password":       "--in-password",
+	"new salt":                 "--new-salt",
+}
+
+// readWithTimeout runs read, which blocks on input, and gives up after the --prompt-timeout so that a misconfigured
+// terminal or an abandoned pipe cannot hang a run indefinitely. A blocked read cannot be interrupted, so it is left
+// running until bipkey exits.
+func readWithTimeout[T any](label string, read func() (T, error)) (T, error) {
+	if promptTimeout <= 0 {
+		return read()
+	}
+
+	type result struct {
+		value T
+		err   error
+	}
+	done := make(chan result, 1)
+	go func() {
+		value, err := read()
+		done <- result{value, err}
+	}()
+
+	select {
+	case r := <-done:
+		return r.value, r.err
+	case <-time.After(promptTimeout):
+		var zero T
+		return zero, cli.Exit(fmt.Sprintf("Timed out after %s waiting for the %s. Use --prompt-timeout to wait longer.", promptTimeout, label), 1)
+	}
+}
+
+// promptSecret reads a value from the terminal without echoing it. With confirm set, the value must be entered
+// twice, since a mistyped salt silently derives a different key and a mistyped password locks the key file. When
+// stdin is piped, e.g. with the mnemonic, the value is read from the controlling terminal instead.
+func promptSecret(label string, confirm bool) (string, error) {
+	tty := os.Stdin
+	if !term.IsTerminal(int(tty.Fd())) {
+		f, err := os.OpenFile(ttyPath, os.O_RDWR, 0)
+		if err != nil {
+			msg := fmt.Sprintf("Cannot prompt for the %s: stdin is not a terminal and no terminal is available (%v).", label, err)
+			if source, ok := secretSources[label]; ok {
+				msg += fmt.Sprintf(" Give it with %s instead.", source)
+			}
+			return "", cli.Exit(msg, 1)
+		}
+		defer f.Close()
+		tty = f
+	}
+	return readSecret(tty, label, confirm)
+}
+
+// readSecret prompts for the secret and reads it from the terminal f with echo disabled. If the terminal cannot be
+// switched to hidden input, e.g. a misconfigured or emulated terminal, the secret is read visibly with a warning.
+func readSecret(f *os.File, label string, confirm bool) (string, error) {
+	fd := int(f.Fd())
+	read := func() (string, error) {
+		value, err := term.ReadPassword(fd)
+		return string(value), err
+	}
+	state, err := term.GetState(fd)
+	if err != nil {
+		log.Warn().Err(err).Msgf("Cannot hide input on this terminal. The %s will be visible as it is typed.", label)
+		reader := bufio.NewReader(f)
+		read = func() (string, error) {
+			line, err := reader.ReadString('\n')
+			if err != nil && !(errors.Is(err, io.EOF) && line != "") {
+				return "", err
+			}
+			return strings.TrimRight(line, "\r\n"), nil
+		}
+	}
+	readLine := func(what string) (string, error) {
+		value, err := readWithTimeout(what, read)
+		// the timed out read still holds the terminal with echo disabled
+		if err != nil && state != nil {
+			term.Restore(fd, state)
+		}
+		return value, err
+	}
+
+	// prompts go to stderr so they never mix with key output on stdout
+	fmt.Fprintf(os.Stderr, "Enter %s: ", label)
+	value, err := readLine(label)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", promptError(err, fmt.Sprintf("failed to read %s", label))
+	}
+	if len(value) == 0 {
+		return "", cli.Exit(fmt.Sprintf("No %s was entered.", label), 1)
+	}
+
+	if confirm {
+		fmt.Fprintf(os.Stderr, "Confirm %s: ", label)
+		again, err := readLine(label + " confirmation")
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return "", promptError(err, fmt.Sprintf("failed to read %s confirmation", label))
+		}
+		if again != value {
+			return "", cli.Exit(fmt.Sprintf("The %s entries do not match.", label), 1)
+		}
+	}
+
+	return value, nil
+}
+
+// readConfirmation reads a yes or no answer from stdin within the --prompt-timeout, anything but "y" or "yes" being no
+func readConfirmation() (bool, error) {
+	line, err := readWithTimeout("confirmation", func() (string, error) {
+		return bufio.NewReader(os.Stdin).ReadString('\n')
+	})
+	if err != nil && !errors.Is(err, io.EOF) {
+		return false, promptError(err, "failed to read confirmation")
+	}
+	switch strings.ToLower(strings.TrimSpace(line)) {
+	case "y", "yes":
+		return true, nil
+	}
+	return false, nil
+}
+
+// promptError wraps a failed read, passing a timeout's exit error through unchanged
+func promptError(err error, msg string) error {
+	var exitErr cli.ExitCoder
+	if errors.As(err, &exitErr) {
+		return err
+	}
+	return fmt.Errorf("%s: %w", msg, err)
+}
+
+// getSalt returns the --salt value, prompting for it without echo when --salt-prompt is given and no salt is set. The
+// salt is normalized as selected by --salt-normalize.
+func getSalt(c *cli.Command) (string, error) {
+	salt := c.String("salt")
+	if salt == "" && c.Bool("salt-prompt") {
+		var err error
+		if salt, err = promptSecret("salt", true); err != nil {
+			return "", err
+		}
+	}
+	return normalizeSalt(c, salt)
+}
+
+// normalizeSalt applies --salt-normalize to the salt and warns about whitespace and non-ASCII characters left in it,
+// which could be entered differently at a later restore. The salt itself is never logged. A recovery record holds
+// the salt exactly as it was used for derivation, so it is not normalized again.
+func normalizeSalt(c *cli.Command, salt string) (string, error) {
+	normalization, err := keys.ParseSaltNormalization(c.String("salt-normalize"))
+	if err != nil {
+		return "", cli.Exit(err.Error(), 1)
+	}
+	if c.String("recovery-file") != "" {
+		normalization = keys.SaltNormalization{}
+	}
+
+	normalized := normalization.Apply(salt)
+	if normalized != salt {
+		log.Info().Str("normalization", normalization.String()).Msg("Normalized the salt. The key differs from the key of the salt as entered, restore it with the same --salt-normalize.")
+	}
+
+	issues := keys.CheckSalt(normalized)
+	if issues.OuterWhitespace {
+		log.Warn().Msg("The salt has leading or trailing whitespace, which is part of the key derivation and easily lost when the salt is written down. Consider --salt-normalize trim.")
+	}
+	if issues.NonASCII && !normalization.NFKD {
+		msg := "The salt contains non-ASCII characters, which another keyboard or system may enter as different bytes."
+		if issues.NotNFKD {
+			msg += " Its composed characters could also be entered decomposed. Consider --salt-normalize nfkd."
+		}
+		log.Warn().Msg(msg)
+	}
+	return normalized, nil
+}
+
+// checkSaltBlocklist rejects the salt if it matches an entry of the blocklist file, ignoring case. Blank lines and
+// lines starting with '#' are skipped, and an empty path disables the check.
+func checkSaltBlocklist(path string, salt string) error {
+	if path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cli.Exit(fmt.Sprintf("Failed to read the salt blocklist: %v", err), 1)
+	}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		entry := strings.TrimSpace(line)
+		if entry == "" || strings.HasPrefix(entry, "#") {
+			continue
+		}
+		// the salt itself is not echoed, since it may be entered at a prompt
+		if strings.EqualFold(entry, strings.TrimSpace(salt)) {
+			return cli.Exit(fmt.Sprintf("The salt is listed in the weak-salt blocklist %s. Choose a different salt.", path), 1)
+		}
+	}
+	return nil
+}
+
+// PASSWORD_ENV_VAR is the environment variable supplying the encryption password for unattended runs
+const PASSWORD_ENV_VAR = "BIPKEY_PASSWORD"
+
+// envPasswordGiven reports whether BIPKEY_PASSWORD supplies the key password. It is only consulted with --encrypt, so
+// that a variable left in the environment never encrypts a key on its own.
+func envPasswordGiven(c *cli.Command) bool {
+	return c.Bool("encrypt") && os.Getenv(PASSWORD_ENV_VAR) != ""
+}
+
+// getPassword returns the encryption password from the first available source: --password, --password-file, the
+// BIPKEY_PASSWORD environment variable (with --encrypt only), or a prompt without echo when --password-prompt is
+// given. With --encrypt, a missing password is an error instead of writing an unencrypted key.
+func getPassword(c *cli.Command) (string, error) {
+	return readPassword(c, c.Bool("encrypt"))
+}
+
+// readPassword returns the password like getPassword, consulting BIPKEY_PASSWORD only when useEnv is set
+func readPassword(c *cli.Command, useEnv bool) (string, error) {
+	password := c.String("password")
+
+	if path := c.String("password-file"); path != "" {
+		if password != "" {
+			return "", cli.Exit("Only one of --password or --password-file may be specified.", 1)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", cli.Exit(fmt.Sprintf("Failed to read the password file: %v", err), 1)
+		}
+		// a trailing newline is part of the file, not the password
+		if password = strings.TrimRight(string(data), "\r\n"); password == "" {
+			return "", cli.Exit(fmt.Sprintf("The password file %s is empty.", path), 1)
+		}
+	}
+
+	if password == "" && useEnv {
+		if password = os.Getenv(PASSWORD_ENV_VAR); password != "" {
+			log.Warn().Msgf("Using the encryption password from %s. Environment variables can leak through process listings, logs, and child processes.", PASSWORD_ENV_VAR)
+		}
+	}
+
+	if password == "" && c.Bool("password-prompt") {
+		return promptSecret("password", true)
+	}
+
+	if password == "" && c.Bool("encrypt") {
+		return "", cli.Exit(fmt.Sprintf("--encrypt requires a password: use --password, --password-file, %s, or --password-prompt.", PASSWORD_ENV_VAR), 1)
+	}
+	return password, nil
+}
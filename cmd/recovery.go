@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/goodieshq/bipkey/pkg/keys"
+	"github.com/rs/zerolog/log"
+	"github.com/urfave/cli/v3"
+	"gopkg.in/yaml.v3"
+)
+
+// cmdRecord generates a new key like generate, and also writes the recovery record that restores it
+var cmdRecord = &cli.Command{
+	Name:      "record",
+	Usage:     "Generate a new key and mnemonic like generate, and write a recovery record to restore it with --recovery-file",
+	UsageText: "bipkey [--algorithm <algorithm>] [-salt <salt value>] [-out <key file>] record --recovery-out <file> [--omit-salt]",
+	Action:    actionRecord,
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:     "recovery-out",
+			Usage:    "Output file for the recovery record, JSON if it ends in .json and YAML otherwise. It contains the mnemonic.",
+			Required: true,
+		},
+		&cli.BoolFlag{
+			Name:  "omit-salt",
+			Usage: "Leave the salt out of the recovery record, to be sealed separately and given with --salt on restore",
+		},
+	},
+}
+
+// actionRecord generates and outputs the key, then writes its recovery record
+func actionRecord(ctx context.Context, c *cli.Command) error {
+	ki, k, err := generateKey(ctx, c)
+	if err != nil || k == nil {
+		return err
+	}
+
+	record, err := k.RecoveryRecord()
+	if err != nil {
+		return err
+	}
+	if c.Bool("omit-salt") {
+		record.Salt = ""
+	}
+
+	path := c.String("recovery-out")
+	var data []byte
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		data, err = json.MarshalIndent(record, "", "  ")
+		data = append(data, '\n')
+	} else {
+		data, err = yaml.Marshal(record)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to marshal recovery record: %w", err)
+	}
+
+	if err := outputKey(ctx, c, ki, k); err != nil {
+		return err
+	}
+	// the record restores the key on its own (or with the salt), so it is as secret as the mnemonic
+	if err := writeOutput(path, string(data), 0600); err != nil {
+		return err
+	}
+	log.Warn().Str("file", path).Msg("The recovery record contains the mnemonic. Seal it like the mnemonic itself.")
+	return nil
+}
+
+// loadRecoveryFile applies the --recovery-file record to the flags it sets, including the --mnemonic flag of every
+// command taking one. It runs before the flags are parsed, like the config file, but takes precedence over the config
+// file. A flag the record sets must not also be given on the command line, so that parameters cannot mismatch.
+func loadRecoveryFile(cmd *cli.Command, args []string) error {
+	path, ok := flagValueFromArgs(args, "recovery-file")
+	if !ok || path == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read recovery file: %w", err)
+	}
+	record, err := keys.ParseRecoveryRecord(data)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	values, err := recoveryFlagValues(record, filepath.Dir(path))
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	for name := range values {
+		if argsHaveFlag(args, name) || slices.Contains(keyTypeFlags, name) && argsHaveFlag(args, keyTypeFlags...) {
+			return fmt.Errorf("--%s cannot be combined with --recovery-file, which sets it", name)
+		}
+	}
+	if argsHaveFlag(args, "entropy") {
+		return fmt.Errorf("--entropy cannot be combined with --recovery-file, which sets the mnemonic")
+	}
+
+	commands := append([]*cli.Command{cmd}, cmd.Commands...)
+	for _, command := range commands {
+		for _, flag := range command.Flags {
+			name := flag.Names()[0]
+			value, ok := values[name]
+			if !ok {
+				continue
+			}
+			source := &configSource{path: path, key: name, value: value}
+			switch f := flag.(type) {
+			case *cli.StringFlag:
+				f.Sources.Chain = slices.Insert(f.Sources.Chain, 0, cli.ValueSource(source))
+			case *cli.BoolFlag:
+				f.Sources.Chain = slices.Insert(f.Sources.Chain, 0, cli.ValueSource(source))
+			case *cli.IntFlag:
+				f.Sources.Chain = slices.Insert(f.Sources.Chain, 0, cli.ValueSource(source))
+			}
+		}
+	}
+	log.Debug().Str("file", path).Msg("Applied recovery file.")
+	return nil
+}
+
+// recoveryFlagValues returns the flag values the recovery record sets, keyed by flag name. A salt file is read
+// relative to the directory of the record.
+func recoveryFlagValues(record *keys.RecoveryRecord, dir string) (map[string]string, error) {
+	keyType, _, err := record.KeyID()
+	if err != nil {
+		return nil, err
+	}
+	_, language, err := record.ParseMnemonic()
+	if err != nil {
+		return nil, err
+	}
+
+	values := map[string]string{
+		"derivation":    record.Derivation,
+		"kdf":           "none",
+		"no-bip39-seed": strconv.FormatBool(record.EntropySeed),
+		"index":         strconv.Itoa(record.Index),
+		"identity":      record.Identity,
+		"language":      string(language),
+		"mnemonic":      record.Mnemonic,
+	}
+	if keyType == keys.KeyTypeECC {
+		values["algorithm"] = "ecc:" + record.Curve
+	} else {
+		values["algorithm"] = "rsa:" + strconv.Itoa(record.Size)
+	}
+	if params := record.Scrypt; params != nil {
+		values["kdf"] = "scrypt"
+		values["scrypt-n"] = strconv.Itoa(params.N)
+		values["scrypt-r"] = strconv.Itoa(params.R)
+		values["scrypt-p"] = strconv.Itoa(params.P)
+	}
+	if record.Path != "" {
+		values["bip32-path"] = record.Path
+	}
+	if exponent := record.RSAExponent; exponent != nil {
+		values["rsa-exponent"] = strconv.Itoa(exponent.E)
+		values["rsa-exponent-fallback"] = strconv.FormatBool(exponent.Fallback)
+	}
+
+	salt := record.Salt
+	if record.SaltFile != "" {
+		saltPath := record.SaltFile
+		if !filepath.IsAbs(saltPath) {
+			saltPath = filepath.Join(dir, saltPath)
+		}
+		data, err := os.ReadFile(saltPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the salt file: %w", err)
+		}
+		// a trailing newline is part of the file, not the salt
+		if salt = strings.TrimRight(string(data), "\r\n"); salt == "" {
+			return nil, fmt.Errorf("the salt file %s is empty", saltPath)
+		}
+	}
+	if salt != "" {
+		values["salt"] = salt
+	}
+	return values, nil
+}
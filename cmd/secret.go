@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/rs/zerolog/log"
+	"github.com/urfave/cli/v3"
+)
+
+// cmdDeriveSecret derives symmetric key material from an existing mnemonic
+var cmdDeriveSecret = &cli.Command{
+	Name:      "derive-secret",
+	Usage:     "Derive deterministic symmetric key material (e.g. an AES or HMAC key) from an existing mnemonic",
+	UsageText: "bipkey [-salt <salt value>] derive-secret (--bytes <n> --context <label> [--encoding hex|base64] | --pem-password)",
+	Action:    actionDeriveSecret,
+	Flags: []cli.Flag{
+		newMnemonicFlag(),
+		newEntropyFlag(),
+		&cli.IntFlag{
+			Name:  "bytes",
+			Usage: "Number of bytes of key material to derive",
+			Value: 32,
+		},
+		&cli.StringFlag{
+			Name:  "context",
+			Usage: "Context label separating this secret from others derived from the same mnemonic (e.g. \"aes-wrap\")",
+			Value: "",
+		},
+		&cli.StringFlag{
+			Name:  "encoding",
+			Usage: "Output encoding of the secret (hex, base64)",
+			Value: "hex",
+		},
+		&cli.BoolFlag{
+			Name:  "pem-password",
+			Usage: "Print the private key password of --derive-password instead, e.g. to decrypt the key with another tool",
+		},
+	},
+}
+
+// actionDeriveSecret derives and prints a symmetric secret from an existing mnemonic/salt
+func actionDeriveSecret(ctx context.Context, c *cli.Command) error {
+	setLogging(c)
+
+	var encode func([]byte) string
+	switch c.String("encoding") {
+	case "hex":
+		encode = hex.EncodeToString
+	case "base64":
+		encode = base64.StdEncoding.EncodeToString
+	default:
+		return cli.Exit(fmt.Sprintf("Unsupported encoding %q, expected hex or base64.", c.String("encoding")), 1)
+	}
+
+	pemPassword := c.Bool("pem-password")
+	if pemPassword && (c.String("context") != "" || c.IsSet("bytes") || c.IsSet("encoding")) {
+		return cli.Exit("--pem-password cannot be combined with --context, --bytes, or --encoding.", 1)
+	}
+	if c.String("context") == "" && !pemPassword {
+		log.Warn().Msg("No --context provided. It's recommended to label each secret by its purpose so they remain independent.")
+	}
+
+	gen, err := getGenerator(c)
+	if err != nil {
+		return err
+	}
+
+	salt, err := getSalt(c)
+	if err != nil {
+		return err
+	}
+
+	if err := confirmOutFile(c); err != nil {
+		return err
+	}
+
+	mnemonic, gen, err := readMnemonic(c, gen)
+	if err != nil {
+		return err
+	}
+
+	var encoded string
+	if pemPassword {
+		if encoded, err = gen.DerivePEMPassword(ctx, salt, mnemonic); err != nil {
+			return cli.Exit(err.Error(), 1)
+		}
+	} else {
+		secret, err := gen.DeriveSecret(ctx, salt, mnemonic, c.String("context"), int(c.Int("bytes")))
+		if err != nil {
+			return cli.Exit(err.Error(), 1)
+		}
+		encoded = encode(secret)
+	}
+	fmt.Println(encoded)
+	if err := writeFile(c, encoded+"\n"); err != nil {
+		log.Error().Err(err).Msg("Failed to write secret to file")
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/goodieshq/bipkey/pkg/keys"
+	"github.com/urfave/cli/v3"
+)
+
+// cmdSelfTest checks the derivation and encryption of this build against known answers
+var cmdSelfTest = &cli.Command{
+	Name:      "selftest",
+	Usage:     "Check that this build derives the known keys and encrypts/decrypts them correctly, for every supported key type",
+	UsageText: "bipkey [--algorithm <algorithm>] selftest",
+	Action:    actionSelfTest,
+}
+
+// keyTypeCase is a single key type and id covered by the self-test or benchmark
+type keyTypeCase struct {
+	keyType keys.KeyType
+	keyId   int
+}
+
+// actionSelfTest runs the self-test for the key type selected by -ecc/-rsa, or every supported key type, and
+// prints PASS or FAIL for each. It exits non-zero if any key type fails.
+func actionSelfTest(ctx context.Context, c *cli.Command) error {
+	setLogging(c)
+
+	cases, err := keyTypeCases(c)
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, tc := range cases {
+		caps, err := keys.GetCapabilities(tc.keyType, tc.keyId)
+		if err != nil {
+			return cli.Exit(err.Error(), 1)
+		}
+
+		start := time.Now()
+		if err := keys.SelfTest(ctx, tc.keyType, tc.keyId); err != nil {
+			failed++
+			fmt.Printf("FAIL  %-12s %v\n", caps.Algorithm, err)
+			continue
+		}
+		fmt.Printf("PASS  %-12s (%s)\n", caps.Algorithm, time.Since(start).Round(time.Millisecond))
+	}
+
+	if failed > 0 {
+		return cli.Exit(fmt.Sprintf("Self-test failed for %d of %d key types.", failed, len(cases)), 1)
+	}
+	fmt.Printf("\nSelf-test passed for %d of %d key types.\n", len(cases), len(cases))
+	return nil
+}
+
+// keyTypeCases returns the key type selected by --algorithm/-ecc/-rsa, or every supported key type if none is given
+func keyTypeCases(c *cli.Command) ([]keyTypeCase, error) {
+	if keyTypeGiven(c) {
+		keyType, keyId, err := getKeyType(c)
+		if err != nil {
+			return nil, err
+		}
+		return []keyTypeCase{{keyType, keyId}}, nil
+	}
+
+	var cases []keyTypeCase
+	for _, info := range keys.SupportedECCCurves() {
+		cases = append(cases, keyTypeCase{keys.KeyTypeECC, int(info.ID)})
+	}
+	for _, size := range keys.SupportedRSASizes() {
+		id, err := keys.ParseRSAKeyID(fmt.Sprint(size))
+		if err != nil {
+			return nil, err
+		}
+		cases = append(cases, keyTypeCase{keys.KeyTypeRSA, int(id)})
+	}
+	return cases, nil
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/urfave/cli/v3"
+)
+
+// cmdSpec documents the primitives and parameters the key derivation depends on
+var cmdSpec = &cli.Command{
+	Name:      "spec",
+	Usage:     "Show the exact primitives and parameters the key derivation depends on",
+	UsageText: "bipkey [-derivation <version>] [-kdf scrypt] spec [--json]",
+	Action:    actionSpec,
+}
+
+// actionSpec prints the derivation specification of the selected derivation version and seed KDF
+func actionSpec(ctx context.Context, c *cli.Command) error {
+	setLogging(c)
+
+	gen, err := getGenerator(c)
+	if err != nil {
+		return err
+	}
+
+	spec := gen.Spec()
+	if c.Bool("json") {
+		data, err := json.MarshalIndent(spec, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal derivation spec: %w", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	for _, item := range spec {
+		fmt.Printf("%s:\n  %s\n", item.Component, item.Algorithm)
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bufio"
+	"crypto/rand"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/goodieshq/bipkey/pkg/keys"
+	"github.com/rs/zerolog/log"
+	"github.com/urfave/cli/v3"
+	"golang.org/x/term"
+)
+
+// CLEAR_SCREEN clears the terminal and its scrollback once the password custodian has noted the password
+const CLEAR_SCREEN = "\033[H\033[2J\033[3J"
+
+// splitPasswordGiven reports whether the key password is split from the key file, by --split-password or --password-out
+func splitPasswordGiven(c *cli.Command) bool {
+	return c.Bool("split-password") || c.String("password-out") != ""
+}
+
+// getSplitPassword generates the random password of a split export. The encrypted key goes to --out and the password
+// to --password-out or, without it, to a second operator at the terminal, so that no single artifact holds both.
+func getSplitPassword(c *cli.Command) (string, error) {
+	if c.String("password") != "" || c.String("password-file") != "" || envPasswordGiven(c) || c.Bool("password-prompt") || c.Bool("derive-password") {
+		return "", cli.Exit("--split-password generates the password, so it cannot be combined with another password source.", 1)
+	}
+	keyPaths := outputPaths(c, "out")
+	if len(keyPaths) == 0 {
+		return "", cli.Exit("--split-password requires --out, so that the encrypted key is written to a file of its own.", 1)
+	}
+
+	if passwordPath := c.String("password-out"); passwordPath != "" {
+		for _, keyPath := range keyPaths {
+			if sameFile(keyPath, passwordPath) {
+				return "", cli.Exit("--password-out must not be the --out file.", 1)
+			}
+			if sameDirectory(keyPath, passwordPath) {
+				log.Warn().Str("key", keyPath).Str("password", passwordPath).Msg("THE KEY AND ITS PASSWORD ARE WRITTEN TO THE SAME DIRECTORY. Split knowledge only holds if they are moved to separate custodians before anyone else can read both.")
+			}
+		}
+	} else if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return "", cli.Exit("--split-password without --password-out shows the password to a second operator, which requires an interactive terminal.", 1)
+	}
+
+	return keys.GeneratePEMPassword(rand.Reader)
+}
+
+// writeSplitPassword hands the password of a split export to its custodian, once the encrypted key is written. It
+// is written owner-only to --password-out, or shown once at the terminal after the key custodian has stepped away.
+func writeSplitPassword(c *cli.Command, password string) error {
+	if !splitPasswordGiven(c) {
+		return nil
+	}
+	if path := c.String("password-out"); path != "" {
+		log.Info().Str("path", path).Msg("Writing the key password apart from the encrypted key. Give it to a different custodian than the key file.")
+		return writeOutput(path, password+"\n", 0600)
+	}
+
+	fmt.Fprint(os.Stderr, "\nThe encrypted key is written. Hand the terminal to the password custodian, who presses Enter to show the password once.")
+	if err := waitForEnter("password custodian"); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "\nKey Password: %s\n\nNote the password, then press Enter to clear the screen.", password)
+	defer fmt.Fprint(os.Stderr, CLEAR_SCREEN)
+	return waitForEnter("password custodian")
+}
+
+// waitForEnter waits for a line on stdin within the --prompt-timeout
+func waitForEnter(label string) error {
+	_, err := readWithTimeout(label, func() (string, error) {
+		return bufio.NewReader(os.Stdin).ReadString('\n')
+	})
+	if err != nil {
+		return promptError(err, fmt.Sprintf("failed to wait for the %s", label))
+	}
+	return nil
+}
+
+// sameFile reports whether the two paths name the same file, which need not exist yet
+func sameFile(a, b string) bool {
+	absA, errA := filepath.Abs(a)
+	absB, errB := filepath.Abs(b)
+	return errA == nil && errB == nil && absA == absB
+}
+
+// sameDirectory reports whether the two paths name files in the same directory
+func sameDirectory(a, b string) bool {
+	absA, errA := filepath.Abs(a)
+	absB, errB := filepath.Abs(b)
+	return errA == nil && errB == nil && filepath.Dir(absA) == filepath.Dir(absB)
+}
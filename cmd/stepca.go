@@ -0,0 +1,82 @@
+package main
+
+import (
+	"path/filepath"
+
+	"github.com/goodieshq/bipkey/pkg/keys"
+	"github.com/rs/zerolog/log"
+	"github.com/urfave/cli/v3"
+)
+
+// stepCAPaths returns the root key and certificate files written to the --step-ca directory, nil if it is not given
+func stepCAPaths(c *cli.Command) []string {
+	dir := c.String("step-ca")
+	if dir == "" {
+		return nil
+	}
+	return []string{filepath.Join(dir, keys.STEP_CA_ROOT_KEY_FILE), filepath.Join(dir, keys.STEP_CA_ROOT_CERT_FILE)}
+}
+
+// getStepCAOptions validates the --step-ca flags into the root certificate options, returning nil if no step-ca root
+// was requested. The subject is --subject or --comment; a root has no subject alternative names.
+func getStepCAOptions(c *cli.Command) (*keys.CertOptions, error) {
+	if c.String("step-ca") == "" {
+		return nil, nil
+	}
+	if len(c.StringSlice("dns")) > 0 || len(c.StringSlice("ip")) > 0 {
+		return nil, cli.Exit("A step-ca root certificate has no subject alternative names, --dns and --ip cannot be combined with --step-ca.", 1)
+	}
+
+	commonName := c.String("subject")
+	if commonName == "" {
+		commonName = c.String("comment")
+	}
+	if commonName == "" {
+		return nil, cli.Exit("A step-ca root certificate subject is required: use --subject or --comment.", 1)
+	}
+
+	opts := keys.StepCARootOptions(commonName)
+	if err := applyCertValidity(c, &opts); err != nil {
+		return nil, err
+	}
+	return &opts, nil
+}
+
+// writeStepCA writes the root key as PKCS#8 and its self-signed root certificate to the --step-ca directory, named as
+// step ca init --root and --key expect them. The key is encrypted if a password was given.
+func writeStepCA(c *cli.Command, k *keys.Key, opts *keys.CertOptions) error {
+	if opts == nil {
+		return nil
+	}
+	paths := stepCAPaths(c)
+
+	der, err := k.Certificate(*opts)
+	if err != nil {
+		return err
+	}
+	if err := verifyCertificate(c, der); err != nil {
+		return err
+	}
+	encoded, err := encodeKey(c, k, keys.FormatPKCS8)
+	if err != nil {
+		return err
+	}
+
+	keyData, err := exportPEM(c, encoded)
+	if err != nil {
+		return err
+	}
+	if err := writeOutput(paths[0], keyData, 0600); err != nil {
+		return err
+	}
+	certData, err := exportPEM(c, keys.CertificatePEM(der))
+	if err != nil {
+		return err
+	}
+	if err := writeOutput(paths[1], certData, 0644); err != nil {
+		return err
+	}
+
+	log.Info().Str("key", paths[0]).Str("cert", paths[1]).Str("step-ca", keys.STEP_CA_VERSION).Msg("Wrote step-ca root key and certificate. Import them with step ca init --root and --key.")
+	return nil
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"github.com/goodieshq/bipkey/pkg/keys"
+	"github.com/rs/zerolog/log"
+	"github.com/urfave/cli/v3"
+)
+
+// writeTink writes the key as a cleartext Tink JSON keyset to the --tink-out file, if provided
+func writeTink(c *cli.Command, k *keys.Key) error {
+	path := c.String("tink-out")
+	if path == "" {
+		return nil
+	}
+
+	template, err := k.TinkTemplate()
+	if err != nil {
+		return err
+	}
+	data, err := k.TinkKeysetJSON()
+	if err != nil {
+		return err
+	}
+
+	// the keyset holds the unencrypted private key, like the Vault import key
+	if err := writeOutput(path, string(data), 0600); err != nil {
+		return err
+	}
+	log.Info().Str("file", path).Str("template", template).Msg("Wrote Tink keyset.")
+	return nil
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"crypto/rsa"
+	"fmt"
+	"os"
+
+	"github.com/goodieshq/bipkey/pkg/keys"
+	"github.com/rs/zerolog/log"
+	"github.com/urfave/cli/v3"
+)
+
+// vaultFlags returns the flags controlling HashiCorp Vault transit import output
+func vaultFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:  "vault-out",
+			Usage: "Output file for the key in Vault transit BYOK import form: base64 PKCS#8 DER, wrapped if --vault-wrapping-key is set (secret, owner-only)",
+			Value: "",
+		},
+		&cli.StringFlag{
+			Name:  "vault-wrapping-key",
+			Usage: "PEM file of Vault's transit wrapping key, used to wrap the --vault-out key with AES-KWP and RSA-OAEP",
+			Value: "",
+		},
+	}
+}
+
+// getVaultWrappingKey validates the --vault-out flags against the key type before generation and returns the parsed
+// --vault-wrapping-key, or nil if the Vault import key is not wrapped
+func getVaultWrappingKey(c *cli.Command, keyType keys.KeyType, keyId int) (*rsa.PublicKey, error) {
+	wrappingKeyFile := c.String("vault-wrapping-key")
+	if c.String("vault-out") == "" {
+		if wrappingKeyFile != "" {
+			return nil, cli.Exit("--vault-wrapping-key requires --vault-out", 1)
+		}
+		return nil, nil
+	}
+	if _, err := keys.VaultKeyTypeOf(keyType, keyId); err != nil {
+		return nil, cli.Exit(err.Error(), 1)
+	}
+	if wrappingKeyFile == "" {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(wrappingKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Vault wrapping key: %w", err)
+	}
+	wrappingKey, err := keys.ParseVaultWrappingKey(data)
+	if err != nil {
+		return nil, cli.Exit(err.Error(), 1)
+	}
+	return wrappingKey, nil
+}
+
+// writeVault writes the key for a Vault transit import to the --vault-out file, if provided, wrapped under the
+// wrapping key validated by getVaultWrappingKey
+func writeVault(c *cli.Command, k *keys.Key, wrappingKey *rsa.PublicKey) error {
+	path := c.String("vault-out")
+	if path == "" {
+		return nil
+	}
+
+	keyType, err := k.VaultKeyType()
+	if err != nil {
+		return err
+	}
+
+	var encoded string
+	if wrappingKey != nil {
+		if encoded, err = k.VaultWrap(wrappingKey); err != nil {
+			return err
+		}
+	} else if encoded, err = k.VaultBase64(); err != nil {
+		return err
+	}
+
+	if err := writeOutput(path, encoded+"\n", 0600); err != nil {
+		return err
+	}
+	log.Info().Str("file", path).Str("type", keyType).Bool("wrapped", wrappingKey != nil).Msg("Wrote Vault import key.")
+	return nil
+}
@@ -0,0 +1,362 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/goodieshq/bipkey/pkg/keys"
+	"github.com/urfave/cli/v3"
+)
+
+// WIZARD_ATTEMPTS is the number of times the wizard asks a question before giving up on invalid answers
+const WIZARD_ATTEMPTS = 3
+
+// cmdWizard walks an operator through generating or restoring a key, asking only for what the flags do not set
+var cmdWizard = &cli.Command{
+	Name:  "wizard",
+	Usage: "Interactively choose generate or restore, the key type, salt, output, and encryption, then run it",
+	Description: "The wizard asks one question at a time and then runs generate or restore exactly as if the answers had " +
+		"been given as flags. Every question whose flag is already set (on the command line, by --config, or by " +
+		"--recovery-file) is skipped, so a ceremony can be partly or fully scripted.",
+	UsageText: "bipkey [global flags] wizard [--action generate|restore] [--mnemonic <words> | --entropy <hex>]",
+	Action:    actionWizard,
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "action",
+			Usage: "Action to run (generate, restore), skipping the first question",
+			Value: "",
+		},
+		newMnemonicFlag(),
+		newEntropyFlag(),
+	},
+}
+
+// wizard asks the questions on out and reads the answers from in, one line each
+type wizard struct {
+	in  *bufio.Reader
+	out io.Writer
+}
+
+// newWizard creates a wizard reading answers from r, re-using r if it is already buffered
+func newWizard(r io.Reader, out io.Writer) *wizard {
+	reader, ok := r.(*bufio.Reader)
+	if !ok {
+		reader = bufio.NewReader(r)
+	}
+	return &wizard{in: reader, out: out}
+}
+
+// ask asks the question and returns the trimmed answer, or def if the answer is empty
+func (w *wizard) ask(question string, def string) (string, error) {
+	if def != "" {
+		fmt.Fprintf(w.out, "%s [%s]: ", question, def)
+	} else {
+		fmt.Fprintf(w.out, "%s: ", question)
+	}
+
+	line, err := readWithTimeout("answer", func() (string, error) {
+		return w.in.ReadString('\n')
+	})
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", promptError(err, "failed to read answer")
+	}
+	answer := strings.TrimSpace(line)
+	if answer == "" && errors.Is(err, io.EOF) {
+		fmt.Fprintln(w.out)
+		return "", cli.Exit("No answer received (end of input).", 1)
+	}
+	if answer == "" {
+		return def, nil
+	}
+	return answer, nil
+}
+
+// choose asks the question with numbered options and returns the chosen option. An answer that is not an option
+// number is returned as entered, so that aliases such as "secp384r1" reach the flag validators.
+func (w *wizard) choose(question string, options []string, def string) (string, error) {
+	fmt.Fprintln(w.out, question)
+	for i, option := range options {
+		fmt.Fprintf(w.out, "  %d) %s\n", i+1, option)
+	}
+	answer, err := w.ask("Choice", def)
+	if err != nil {
+		return "", err
+	}
+	if n, err := strconv.Atoi(answer); err == nil && n >= 1 && n <= len(options) {
+		return options[n-1], nil
+	}
+	return answer, nil
+}
+
+// confirm asks a yes or no question, with def answering an empty line
+func (w *wizard) confirm(question string, def bool) (bool, error) {
+	hint := "y/N"
+	if def {
+		hint = "Y/n"
+	}
+	for attempt := 1; attempt <= WIZARD_ATTEMPTS; attempt++ {
+		answer, err := w.ask(fmt.Sprintf("%s (%s)", question, hint), "")
+		if err != nil {
+			return false, err
+		}
+		switch strings.ToLower(answer) {
+		case "":
+			return def, nil
+		case "y", "yes":
+			return true, nil
+		case "n", "no":
+			return false, nil
+		}
+		fmt.Fprintln(w.out, "Please answer yes or no.")
+	}
+	return false, cli.Exit(fmt.Sprintf("No valid answer after %d attempts.", WIZARD_ATTEMPTS), 1)
+}
+
+// setFlag asks until the answer passes the flag's validator, then sets the flag as if it was given on the command line
+func (w *wizard) setFlag(c *cli.Command, name string, ask func() (string, error)) error {
+	for attempt := 1; attempt <= WIZARD_ATTEMPTS; attempt++ {
+		answer, err := ask()
+		if err != nil {
+			return err
+		}
+		if err := c.Set(name, answer); err != nil {
+			fmt.Fprintf(w.out, "Invalid answer: %v\n", err)
+			continue
+		}
+		return nil
+	}
+	return cli.Exit(fmt.Sprintf("No valid --%s after %d attempts.", name, WIZARD_ATTEMPTS), 1)
+}
+
+// actionWizard asks for the action and every unset option, summarizes the choices, and runs the action
+func actionWizard(ctx context.Context, c *cli.Command) error {
+	setLogging(c)
+	// questions go to stderr so they never mix with key output on stdout
+	w := newWizard(os.Stdin, os.Stderr)
+	fromRecord := c.String("recovery-file") != ""
+
+	action := strings.ToLower(strings.TrimSpace(c.String("action")))
+	if action == "" {
+		if fromRecord {
+			action = "restore"
+		} else {
+			var err error
+			if action, err = w.choose("Do you want to generate a new key or restore an existing one?", []string{"generate", "restore"}, "generate"); err != nil {
+				return err
+			}
+		}
+	}
+	if action != "generate" && action != "restore" {
+		return cli.Exit(fmt.Sprintf("Unknown action %q, expected generate or restore.", action), 1)
+	}
+	restore := action == "restore"
+
+	if err := w.askKeyType(c); err != nil {
+		return err
+	}
+	if err := w.askSalt(c, restore); err != nil {
+		return err
+	}
+	if err := w.askOutput(c); err != nil {
+		return err
+	}
+	if err := w.askEncryption(c); err != nil {
+		return err
+	}
+	if restore && c.String("mnemonic") == "" && c.String("entropy") == "" {
+		mnemonic, err := promptMnemonicFrom(w.in, w.out)
+		if err != nil {
+			return err
+		}
+		if err := c.Set("mnemonic", mnemonic); err != nil {
+			return err
+		}
+		if err := w.confirmRepeatedWords(c, mnemonic); err != nil {
+			return err
+		}
+	}
+
+	w.summarize(c, action)
+	if proceed, err := w.confirm("Proceed?", false); err != nil {
+		return err
+	} else if !proceed {
+		return cli.Exit("Aborted, nothing was derived or written.", 1)
+	}
+
+	if restore {
+		return actionRestore(ctx, c)
+	}
+	return actionGenerate(ctx, c)
+}
+
+// askKeyType asks for the key type and its curve or size, unless --algorithm, --ecc, --rsa, or a recovery file sets it
+func (w *wizard) askKeyType(c *cli.Command) error {
+	if keyTypeGiven(c) || c.String("recovery-file") != "" {
+		return nil
+	}
+
+	keyType, err := w.choose("Which key type?", []string{"ECC", "RSA"}, "ECC")
+	if err != nil {
+		return err
+	}
+	switch strings.ToUpper(keyType) {
+	case string(keys.KeyTypeECC):
+		var curves []string
+		for _, info := range keys.SupportedECCCurves() {
+			curves = append(curves, info.Name)
+		}
+		return w.setFlag(c, "algorithm", func() (string, error) {
+			curve, err := w.choose("Which curve?", curves, curves[0])
+			return "ecc:" + curve, err
+		})
+	case string(keys.KeyTypeRSA):
+		var sizes []string
+		for _, size := range keys.SupportedRSASizes() {
+			sizes = append(sizes, strconv.Itoa(size))
+		}
+		return w.setFlag(c, "algorithm", func() (string, error) {
+			size, err := w.choose("Which key size in bits?", sizes, sizes[0])
+			return "rsa:" + size, err
+		})
+	}
+	return cli.Exit(fmt.Sprintf("Unknown key type %q, expected ECC or RSA.", keyType), 1)
+}
+
+// askSalt asks whether to use a salt and reads it without echo, unless --salt, --salt-prompt, or a recovery file
+// sets it. A restore must use the salt the key was generated with.
+func (w *wizard) askSalt(c *cli.Command, restore bool) error {
+	if c.String("salt") != "" || c.Bool("salt-prompt") || c.String("recovery-file") != "" {
+		return nil
+	}
+
+	question := "Use a salt? (recommended, it must be kept with the mnemonic)"
+	if restore {
+		question = "Was the key generated with a salt?"
+	}
+	useSalt, err := w.confirm(question, !restore)
+	if err != nil || !useSalt {
+		return err
+	}
+
+	salt, err := promptSecret("salt", true)
+	if err != nil {
+		return err
+	}
+	return c.Set("salt", salt)
+}
+
+// askOutput asks for the private key file and format, unless --out is set. An empty file prints the key.
+func (w *wizard) askOutput(c *cli.Command) error {
+	if c.String("out") != "" {
+		return nil
+	}
+
+	out, err := w.ask("Private key file (leave empty to print the key)", "")
+	if err != nil {
+		return err
+	}
+	if out == "" {
+		return nil
+	}
+	if err := c.Set("out", out); err != nil {
+		return err
+	}
+
+	if c.IsSet("format") {
+		return nil
+	}
+	formats := []string{string(keys.FormatPKCS8), string(keys.FormatSEC1), string(keys.FormatPKCS1), string(keys.FormatOpenSSH)}
+	return w.setFlag(c, "format", func() (string, error) {
+		return w.choose("Which private key format?", formats, c.String("format"))
+	})
+}
+
+// askEncryption asks whether to encrypt the private key, unless a password source is already given. The password is
+// then read by --password-prompt, and --encrypt ensures a key is never written unencrypted by mistake.
+func (w *wizard) askEncryption(c *cli.Command) error {
+	if c.String("password") != "" || c.String("password-file") != "" || envPasswordGiven(c) || c.Bool("password-prompt") || c.Bool("encrypt") || c.Bool("derive-password") {
+		return nil
+	}
+
+	encrypt, err := w.confirm("Encrypt the private key with a password?", c.String("out") != "")
+	if err != nil || !encrypt {
+		return err
+	}
+	if err := c.Set("password-prompt", "true"); err != nil {
+		return err
+	}
+	return c.Set("encrypt", "true")
+}
+
+// confirmRepeatedWords asks the operator to confirm the words the entered mnemonic repeats, see adviseRepeatedWords.
+// Once confirmed they are allowed, so that the restore does not ask again. A mnemonic that does not parse is left for
+// the restore to report.
+func (w *wizard) confirmRepeatedWords(c *cli.Command, mnemonicString string) error {
+	if c.Bool("allow-repeated-words") {
+		return nil
+	}
+	gen, err := getGenerator(c)
+	if err != nil {
+		return err
+	}
+	if gen, err = mnemonicGenerator(c, gen, mnemonicString); err != nil {
+		return err
+	}
+	m, err := gen.ParseMnemonic(mnemonicString)
+	if err != nil {
+		return nil
+	}
+
+	repeated := m.RepeatedWords()
+	if len(repeated) == 0 {
+		return nil
+	}
+	for _, word := range repeated {
+		fmt.Fprintln(w.out, repeatedWordMessage(word))
+	}
+	fmt.Fprintln(w.out, "BIP-39 allows repeated words, but writing a word twice is a common transcription mistake.")
+	if ok, err := w.confirm("Are the repeated words correct?", false); err != nil {
+		return err
+	} else if !ok {
+		return cli.Exit("Aborted: check the repeated words against the backup.", 1)
+	}
+	return c.Set("allow-repeated-words", "true")
+}
+
+// summarize prints the choices before the action runs, without the salt or mnemonic
+func (w *wizard) summarize(c *cli.Command, action string) {
+	keyType := "from the recovery file"
+	if algorithm := c.String("algorithm"); algorithm != "" {
+		keyType = algorithm
+	} else if ecc := c.String("ecc"); ecc != "" {
+		keyType = "ECC " + ecc
+	} else if rsa := c.String("rsa"); rsa != "" {
+		keyType = "RSA " + rsa
+	}
+	salt := "none"
+	if c.String("salt") != "" || c.Bool("salt-prompt") || c.String("recovery-file") != "" {
+		salt = "set"
+	}
+	out := "printed"
+	if path := c.String("out"); path != "" {
+		out = fmt.Sprintf("%s (%s)", path, c.String("format"))
+	}
+	encrypted := "no"
+	if c.String("password") != "" || c.String("password-file") != "" || envPasswordGiven(c) || c.Bool("password-prompt") || c.Bool("encrypt") || c.Bool("derive-password") {
+		encrypted = "yes"
+	}
+
+	fmt.Fprintln(w.out)
+	fmt.Fprintf(w.out, "Action:      %s\n", action)
+	fmt.Fprintf(w.out, "Key Type:    %s\n", keyType)
+	fmt.Fprintf(w.out, "Salt:        %s\n", salt)
+	fmt.Fprintf(w.out, "Private Key: %s\n", out)
+	fmt.Fprintf(w.out, "Encrypted:   %s\n", encrypted)
+	fmt.Fprintln(w.out)
+}
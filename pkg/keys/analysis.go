@@ -0,0 +1,98 @@
+package keys
+
+import (
+	"fmt"
+)
+
+// thresholds of the low-entropy heuristics. Each is far outside what a randomly generated mnemonic shows: a random
+// mnemonic has fewer distinct words, a longer evenly spaced run, or a narrower span with a probability below 1e-4.
+const (
+	LOW_ENTROPY_MIN_DISTINCT_WORDS = 21   // fewer distinct words than this is flagged
+	LOW_ENTROPY_MAX_RUN            = 3    // more consecutive, evenly spaced words than this is flagged
+	LOW_ENTROPY_MIN_SPAN           = 1024 // word list positions spanning less than this is flagged
+)
+
+// MnemonicAnalysis describes how the words of a mnemonic are distributed over the word list. It is a sanity check
+// that catches human-chosen or degenerate mnemonics, such as the same word repeated or words picked in word list
+// order. It cannot show that a mnemonic was generated randomly.
+type MnemonicAnalysis struct {
+	Positions     [MNEMONIC_WORD_COUNT]int // word list position of each word, 0 to 2047
+	DistinctWords int                      // number of different words
+	LongestRun    int                      // longest run of consecutive words with evenly spaced positions
+	RunStart      int                      // 1-based word position where the longest run starts
+	Span          int                      // difference between the highest and lowest position
+	Findings      []string                 // low-entropy patterns found, empty for a plausible mnemonic
+}
+
+// LowEntropy reports whether the analysis found a low-entropy pattern
+func (a MnemonicAnalysis) LowEntropy() bool {
+	return len(a.Findings) > 0
+}
+
+// Histogram counts the word positions in buckets of equal width across the word list
+func (a MnemonicAnalysis) Histogram(buckets int) []int {
+	if buckets < 1 {
+		buckets = 1
+	}
+	counts := make([]int, buckets)
+	for _, position := range a.Positions {
+		counts[position*buckets/BIP39_WORD_COUNT]++
+	}
+	return counts
+}
+
+// AnalyzeMnemonic analyzes the distribution of the mnemonic's words over the English word list
+func AnalyzeMnemonic(m Mnemonic) (MnemonicAnalysis, error) {
+	return defaultGenerator.AnalyzeMnemonic(m)
+}
+
+// AnalyzeMnemonic maps the mnemonic's words to their positions in the generator's word list with GetWordIndex, and
+// flags few distinct words, runs of evenly spaced positions (e.g. consecutive words of the list), and positions
+// clustered in part of the word list.
+func (g *Generator) AnalyzeMnemonic(m Mnemonic) (MnemonicAnalysis, error) {
+	var a MnemonicAnalysis
+	distinct := make(map[int]bool)
+	for i, word := range m {
+		index, _, err := g.GetWordIndex(word)
+		if err != nil {
+			return MnemonicAnalysis{}, fmt.Errorf("invalid mnemonic word '%s': %w", word, err)
+		}
+		a.Positions[i] = index
+		distinct[index] = true
+	}
+	a.DistinctWords = len(distinct)
+
+	lowest, highest := a.Positions[0], a.Positions[0]
+	a.LongestRun, a.RunStart = 1, 1
+	run, start := 1, 0
+	for i := 1; i < len(a.Positions); i++ {
+		lowest, highest = min(lowest, a.Positions[i]), max(highest, a.Positions[i])
+
+		step := a.Positions[i] - a.Positions[i-1]
+		if i-start >= 2 && step == a.Positions[i-1]-a.Positions[i-2] {
+			run++
+		} else {
+			run, start = 2, i-1
+		}
+		if run > a.LongestRun {
+			a.LongestRun, a.RunStart = run, start+1
+		}
+	}
+	a.Span = highest - lowest
+
+	if a.DistinctWords < LOW_ENTROPY_MIN_DISTINCT_WORDS {
+		a.Findings = append(a.Findings, fmt.Sprintf("only %d of %d words are distinct", a.DistinctWords, MNEMONIC_WORD_COUNT))
+	}
+	if a.LongestRun > LOW_ENTROPY_MAX_RUN {
+		first, last := a.RunStart, a.RunStart+a.LongestRun-1
+		if step := a.Positions[first] - a.Positions[first-1]; step == 0 {
+			a.Findings = append(a.Findings, fmt.Sprintf("words %d to %d are the same word", first, last))
+		} else {
+			a.Findings = append(a.Findings, fmt.Sprintf("words %d to %d are evenly spaced in the word list (%+d positions apart)", first, last, step))
+		}
+	}
+	if a.Span < LOW_ENTROPY_MIN_SPAN {
+		a.Findings = append(a.Findings, fmt.Sprintf("all words lie within %d positions of the %d-word list", a.Span+1, BIP39_WORD_COUNT))
+	}
+	return a, nil
+}
@@ -0,0 +1,81 @@
+package keys
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/tyler-smith/go-bip39"
+)
+
+func TestAnalyzeMnemonic(t *testing.T) {
+	a, err := AnalyzeMnemonic(MustParseMnemonic(testMnemonic))
+	if err != nil {
+		t.Fatalf("failed to analyze mnemonic: %v", err)
+	}
+	if a.LowEntropy() || a.DistinctWords != MNEMONIC_WORD_COUNT {
+		t.Fatalf("unexpected analysis of a random mnemonic: %+v", a)
+	}
+	if index, _, _ := GetWordIndex("away"); a.Positions[0] != index {
+		t.Fatalf("unexpected position %d of the first word, want %d", a.Positions[0], index)
+	}
+	total := 0
+	for _, count := range a.Histogram(8) {
+		total += count
+	}
+	if total != MNEMONIC_WORD_COUNT {
+		t.Fatalf("the histogram counts %d words", total)
+	}
+
+	// the mnemonic of all-zero entropy is valid but repeats one word
+	zero, err := MnemonicFromEntropy(make([]byte, MNEMONIC_ENTROPY_BITS/8))
+	if err != nil {
+		t.Fatalf("failed to encode entropy: %v", err)
+	}
+	if a, err = AnalyzeMnemonic(zero); err != nil || a.DistinctWords != 2 || a.LongestRun != 23 || len(a.Findings) != 3 {
+		t.Fatalf("expected every finding for the all-zero mnemonic, got %+v (%v)", a, err)
+	}
+
+	// words taken in word list order
+	var sequential Mnemonic
+	for i := range sequential {
+		sequential[i] = bip39.GetWordList()[100+i*3]
+	}
+	if a, err = AnalyzeMnemonic(sequential); err != nil || a.LongestRun != MNEMONIC_WORD_COUNT || a.RunStart != 1 || !a.LowEntropy() {
+		t.Fatalf("expected a run of evenly spaced words, got %+v (%v)", a, err)
+	}
+
+	// a run in the middle is located
+	spaced := MustParseMnemonic(testMnemonic)
+	for i := range 5 {
+		spaced[10+i] = bip39.GetWordList()[1500-i]
+	}
+	if a, err = AnalyzeMnemonic(spaced); err != nil || a.LongestRun != 5 || a.RunStart != 11 || len(a.Findings) != 1 {
+		t.Fatalf("expected words 11 to 15 to be found, got %+v (%v)", a, err)
+	}
+
+	if _, err := AnalyzeMnemonic(Mnemonic{"notaword"}); err == nil {
+		t.Fatalf("expected an error for a word that is not in the word list")
+	}
+}
+
+func TestAnalyzeMnemonicRandom(t *testing.T) {
+	// random mnemonics are not flagged; a fixed keystream keeps the test deterministic
+	seed := bytes.Repeat([]byte{0x42}, 56)
+	stream, err := NewStreamChaCha20(bytes.NewReader(seed))
+	if err != nil {
+		t.Fatalf("failed to create stream: %v", err)
+	}
+	for range 1000 {
+		m, err := GenerateMnemonicWithReader(t.Context(), stream.Keystream())
+		if err != nil {
+			t.Fatalf("failed to generate mnemonic: %v", err)
+		}
+		a, err := AnalyzeMnemonic(*m)
+		if err != nil {
+			t.Fatalf("failed to analyze mnemonic: %v", err)
+		}
+		if a.LowEntropy() {
+			t.Fatalf("random mnemonic %s flagged: %v", m, a.Findings)
+		}
+	}
+}
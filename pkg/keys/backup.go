@@ -0,0 +1,198 @@
+package keys
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// MNEMONIC_BACKUP_PEM_TYPE is the PEM type of a password-encrypted mnemonic backup
+const MNEMONIC_BACKUP_PEM_TYPE = "BIPKEY ENCRYPTED MNEMONIC"
+
+// mnemonic backup algorithms, recorded in the PEM headers so that a backup only needs its password to decrypt
+const (
+	MNEMONIC_BACKUP_KDF       = "argon2id"
+	MNEMONIC_BACKUP_CIPHER    = "AES-256-GCM"
+	MNEMONIC_BACKUP_SALT_SIZE = 16
+	MNEMONIC_BACKUP_KEY_SIZE  = 32
+
+	// MNEMONIC_BACKUP_MAX_MEMORY caps the Argon2 memory a backup may ask for, in KiB, so that a crafted backup cannot
+	// exhaust the memory of the machine decrypting it
+	MNEMONIC_BACKUP_MAX_MEMORY = 4 * 1024 * 1024
+
+	// MNEMONIC_BACKUP_MAX_TIME caps the Argon2 passes a backup may ask for, so that a crafted backup cannot keep the
+	// machine decrypting it busy indefinitely
+	MNEMONIC_BACKUP_MAX_TIME = 64
+)
+
+// ErrMnemonicBackupAuth is returned when a mnemonic backup fails to authenticate, because the password is wrong or
+// the backup was modified
+var ErrMnemonicBackupAuth = errors.New("authentication failed: wrong password or corrupted mnemonic backup")
+
+// Argon2Params are the Argon2id cost parameters of a mnemonic backup
+type Argon2Params struct {
+	Time    uint32 // number of passes over the memory
+	Memory  uint32 // memory in KiB
+	Threads uint8  // degree of parallelism
+}
+
+// DefaultArgon2Params are the second recommended Argon2id option of RFC 9106: 3 passes over 64 MiB with 4 lanes
+var DefaultArgon2Params = Argon2Params{Time: 3, Memory: 64 * 1024, Threads: 4}
+
+// String returns the parameters as recorded in the backup's KDF-Params header, e.g. "t=3,m=65536,p=4"
+func (p Argon2Params) String() string {
+	return fmt.Sprintf("t=%d,m=%d,p=%d", p.Time, p.Memory, p.Threads)
+}
+
+// Validate checks that the parameters are usable and within MNEMONIC_BACKUP_MAX_TIME and MNEMONIC_BACKUP_MAX_MEMORY
+func (p Argon2Params) Validate() error {
+	if p.Time < 1 || p.Threads < 1 {
+		return fmt.Errorf("argon2 time and threads must be at least 1")
+	}
+	if p.Time > MNEMONIC_BACKUP_MAX_TIME {
+		return fmt.Errorf("argon2 time must be at most %d passes", MNEMONIC_BACKUP_MAX_TIME)
+	}
+	if p.Memory < 8*uint32(p.Threads) {
+		return fmt.Errorf("argon2 memory must be at least 8 KiB per thread")
+	}
+	if p.Memory > MNEMONIC_BACKUP_MAX_MEMORY {
+		return fmt.Errorf("argon2 memory must be at most %d KiB", MNEMONIC_BACKUP_MAX_MEMORY)
+	}
+	return nil
+}
+
+// parseArgon2Params parses the KDF-Params header of a backup
+func parseArgon2Params(val string) (Argon2Params, error) {
+	var p Argon2Params
+	if _, err := fmt.Sscanf(val, "t=%d,m=%d,p=%d", &p.Time, &p.Memory, &p.Threads); err != nil {
+		return Argon2Params{}, fmt.Errorf("invalid KDF-Params %q: %w", val, err)
+	}
+	if err := p.Validate(); err != nil {
+		return Argon2Params{}, err
+	}
+	return p, nil
+}
+
+// EncryptMnemonic encrypts the mnemonic words under the password as a PEM "BIPKEY ENCRYPTED MNEMONIC" block. The
+// AES-256-GCM key is derived from the password with Argon2id and a random salt, and the KDF, its parameters, the salt,
+// and the nonce are stored in the PEM headers, which are authenticated along with the words. The salt and nonce are
+// random, so the backup differs on every call even though it decrypts to the same mnemonic.
+func EncryptMnemonic(m Mnemonic, password string, params Argon2Params) (string, error) {
+	if password == "" {
+		return "", fmt.Errorf("password must not be empty")
+	}
+	if err := params.Validate(); err != nil {
+		return "", err
+	}
+
+	salt := make([]byte, MNEMONIC_BACKUP_SALT_SIZE)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("failed to generate salt: %w", err)
+	}
+	aead, err := mnemonicBackupAEAD(password, salt, params)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	headers := map[string]string{
+		"KDF":        MNEMONIC_BACKUP_KDF,
+		"KDF-Params": params.String(),
+		"Salt":       hex.EncodeToString(salt),
+		"Cipher":     MNEMONIC_BACKUP_CIPHER,
+		"Nonce":      hex.EncodeToString(nonce),
+	}
+	ciphertext := aead.Seal(nil, nonce, []byte(m.String()), mnemonicBackupAAD(headers))
+	return string(pem.EncodeToMemory(&pem.Block{Type: MNEMONIC_BACKUP_PEM_TYPE, Headers: headers, Bytes: ciphertext})), nil
+}
+
+// DecryptMnemonic decrypts a backup written by EncryptMnemonic. A wrong password or a modified backup fails with
+// ErrMnemonicBackupAuth, never with different words.
+func DecryptMnemonic(data []byte, password string) (Mnemonic, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return Mnemonic{}, fmt.Errorf("no PEM block found in the input")
+	}
+	if block.Type != MNEMONIC_BACKUP_PEM_TYPE {
+		return Mnemonic{}, fmt.Errorf("the input is a %q PEM block, not a %q", block.Type, MNEMONIC_BACKUP_PEM_TYPE)
+	}
+	if kdf := block.Headers["KDF"]; kdf != MNEMONIC_BACKUP_KDF {
+		return Mnemonic{}, fmt.Errorf("unsupported mnemonic backup KDF %q", kdf)
+	}
+	if c := block.Headers["Cipher"]; c != MNEMONIC_BACKUP_CIPHER {
+		return Mnemonic{}, fmt.Errorf("unsupported mnemonic backup cipher %q", c)
+	}
+	params, err := parseArgon2Params(block.Headers["KDF-Params"])
+	if err != nil {
+		return Mnemonic{}, err
+	}
+	salt, err := hex.DecodeString(block.Headers["Salt"])
+	if err != nil || len(salt) == 0 {
+		return Mnemonic{}, fmt.Errorf("invalid mnemonic backup salt")
+	}
+	nonce, err := hex.DecodeString(block.Headers["Nonce"])
+	if err != nil {
+		return Mnemonic{}, fmt.Errorf("invalid mnemonic backup nonce")
+	}
+
+	aead, err := mnemonicBackupAEAD(password, salt, params)
+	if err != nil {
+		return Mnemonic{}, err
+	}
+	if len(nonce) != aead.NonceSize() {
+		return Mnemonic{}, fmt.Errorf("invalid mnemonic backup nonce size %d", len(nonce))
+	}
+	plaintext, err := aead.Open(nil, nonce, block.Bytes, mnemonicBackupAAD(block.Headers))
+	if err != nil {
+		return Mnemonic{}, ErrMnemonicBackupAuth
+	}
+
+	words := strings.Fields(string(plaintext))
+	if len(words) != MNEMONIC_WORD_COUNT {
+		return Mnemonic{}, fmt.Errorf("mnemonic backup holds %d words, expected %d", len(words), MNEMONIC_WORD_COUNT)
+	}
+	var m Mnemonic
+	copy(m[:], words)
+	return m, nil
+}
+
+// mnemonicBackupAEAD derives the AES-256-GCM key of a backup from the password
+func mnemonicBackupAEAD(password string, salt []byte, params Argon2Params) (cipher.AEAD, error) {
+	key := argon2.IDKey([]byte(password), salt, params.Time, params.Memory, params.Threads, MNEMONIC_BACKUP_KEY_SIZE)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GCM: %w", err)
+	}
+	return aead, nil
+}
+
+// mnemonicBackupAAD returns the PEM type and headers in sorted order as the additional authenticated data, so that
+// changing a header fails authentication instead of silently weakening the parameters
+func mnemonicBackupAAD(headers map[string]string) []byte {
+	var builder strings.Builder
+	builder.WriteString(MNEMONIC_BACKUP_PEM_TYPE + "\n")
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		fmt.Fprintf(&builder, "%s: %s\n", name, headers[name])
+	}
+	return []byte(builder.String())
+}
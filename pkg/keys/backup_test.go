@@ -0,0 +1,92 @@
+package keys
+
+import (
+	"encoding/pem"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// testArgon2Params keeps the tests fast, the parameters are stored in the backup either way
+var testArgon2Params = Argon2Params{Time: 1, Memory: 1024, Threads: 1}
+
+func TestEncryptMnemonicRoundTrip(t *testing.T) {
+	m := MustParseMnemonic(testMnemonic)
+	backup, err := EncryptMnemonic(m, PASSWORD, testArgon2Params)
+	if err != nil {
+		t.Fatalf("failed to encrypt mnemonic: %v", err)
+	}
+	if strings.Contains(backup, "away") {
+		t.Fatalf("the backup contains the mnemonic in plaintext:\n%s", backup)
+	}
+
+	decrypted, err := DecryptMnemonic([]byte(backup), PASSWORD)
+	if err != nil {
+		t.Fatalf("failed to decrypt mnemonic: %v", err)
+	}
+	if decrypted != m {
+		t.Fatalf("unexpected mnemonic: %s", decrypted)
+	}
+
+	// the salt and nonce are random, so two backups of the same mnemonic differ
+	again, err := EncryptMnemonic(m, PASSWORD, testArgon2Params)
+	if err != nil {
+		t.Fatalf("failed to encrypt mnemonic: %v", err)
+	}
+	if again == backup {
+		t.Fatalf("expected a new salt and nonce for every backup")
+	}
+}
+
+func TestDecryptMnemonicAuthentication(t *testing.T) {
+	backup, err := EncryptMnemonic(MustParseMnemonic(testMnemonic), PASSWORD, testArgon2Params)
+	if err != nil {
+		t.Fatalf("failed to encrypt mnemonic: %v", err)
+	}
+	if _, err := DecryptMnemonic([]byte(backup), "wrong-password"); !errors.Is(err, ErrMnemonicBackupAuth) {
+		t.Fatalf("expected ErrMnemonicBackupAuth for a wrong password, got %v", err)
+	}
+
+	// a modified header or ciphertext fails authentication as well
+	block, _ := pem.Decode([]byte(backup))
+	tampered := map[string]func(b *pem.Block){
+		"params":     func(b *pem.Block) { b.Headers["KDF-Params"] = "t=1,m=1024,p=2" },
+		"ciphertext": func(b *pem.Block) { b.Bytes[0] ^= 1 },
+		"extra":      func(b *pem.Block) { b.Headers["Comment"] = "added" },
+	}
+	for name, tamper := range tampered {
+		b := &pem.Block{Type: block.Type, Headers: map[string]string{}, Bytes: append([]byte(nil), block.Bytes...)}
+		for k, v := range block.Headers {
+			b.Headers[k] = v
+		}
+		tamper(b)
+		if _, err := DecryptMnemonic(pem.EncodeToMemory(b), PASSWORD); !errors.Is(err, ErrMnemonicBackupAuth) {
+			t.Fatalf("%s: expected ErrMnemonicBackupAuth for a modified backup, got %v", name, err)
+		}
+	}
+
+	// parameters beyond the memory or time cap are rejected before any work is done
+	for name, params := range map[string]string{"memory": "t=1,m=4294967295,p=1", "time": "t=4294967295,m=1024,p=1"} {
+		b := &pem.Block{Type: block.Type, Headers: map[string]string{}, Bytes: block.Bytes}
+		for k, v := range block.Headers {
+			b.Headers[k] = v
+		}
+		b.Headers["KDF-Params"] = params
+		if _, err := DecryptMnemonic(pem.EncodeToMemory(b), PASSWORD); err == nil || errors.Is(err, ErrMnemonicBackupAuth) {
+			t.Fatalf("expected excessive argon2 %s to be rejected, got %v", name, err)
+		}
+	}
+}
+
+func TestEncryptMnemonicInvalid(t *testing.T) {
+	m := MustParseMnemonic(testMnemonic)
+	if _, err := EncryptMnemonic(m, "", testArgon2Params); err == nil {
+		t.Fatalf("expected an empty password to be rejected")
+	}
+	if _, err := EncryptMnemonic(m, PASSWORD, Argon2Params{Time: 0, Memory: 1024, Threads: 1}); err == nil {
+		t.Fatalf("expected zero argon2 passes to be rejected")
+	}
+	if _, err := DecryptMnemonic([]byte(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY"})), PASSWORD); err == nil {
+		t.Fatalf("expected a different PEM type to be rejected")
+	}
+}
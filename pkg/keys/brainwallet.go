@@ -0,0 +1,64 @@
+package keys
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/rs/zerolog/log"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/text/unicode/norm"
+)
+
+// brainwallet derivation constants. The Argon2id parameters are fixed rather than configurable, so that nothing but
+// the passphrase and salt has to be remembered to restore a key.
+const (
+	BRAINWALLET_SALT_PREFIX           = "bipkey-brainwallet:" // prefixes the Argon2id salt, separating it from the other uses of the salt
+	BRAINWALLET_SEED_SIZE             = 64                    // size of the seed, the same as a BIP-39 seed
+	BRAINWALLET_MIN_PASSPHRASE_LENGTH = 16                    // minimum passphrase length in characters
+	DERIVATION_BRAINWALLET_SUFFIX     = "-brainwallet"        // marks the derivation name of brainwallet keys, e.g. "v2-brainwallet"
+)
+
+// BrainwalletArgon2Params are the Argon2id parameters of the brainwallet seed: 4 passes over 256 MiB with 4 lanes,
+// heavier than the mnemonic backup since the passphrase is the only secret
+var BrainwalletArgon2Params = Argon2Params{Time: 4, Memory: 256 * 1024, Threads: 4}
+
+// ErrBrainwalletPassphraseTooShort is returned for a brainwallet passphrase shorter than BRAINWALLET_MIN_PASSPHRASE_LENGTH
+var ErrBrainwalletPassphraseTooShort = errors.New("brainwallet passphrase is too short")
+
+// GenerateKeyFromPassphrase derives a brainwallet key selected by the label from a memorized passphrase and salt
+func GenerateKeyFromPassphrase(ctx context.Context, keyType KeyType, keyId int, salt string, passphrase string, label KeyLabel) (*Key, error) {
+	return defaultGenerator.GenerateKeyFromPassphrase(ctx, keyType, keyId, salt, passphrase, label)
+}
+
+// GenerateKeyFromPassphrase derives a brainwallet key selected by the label from a memorized passphrase and salt,
+// without a mnemonic. The NFKD-normalized passphrase is stretched with Argon2id into the seed that the HKDF key
+// derivation of mnemonic keys starts from. A brainwallet is only as strong as the passphrase, which humans choose
+// with far less entropy than a generated mnemonic, so it can be brute-forced offline by anyone who knows the salt.
+// The generator's seed options (entropy seed, scrypt stretch) do not apply.
+func (g *Generator) GenerateKeyFromPassphrase(ctx context.Context, keyType KeyType, keyId int, salt string, passphrase string, label KeyLabel) (*Key, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("key generation cancelled: %w", err)
+	}
+	if err := ValidateKeyID(keyType, keyId); err != nil {
+		return nil, err
+	}
+
+	passphrase = norm.NFKD.String(passphrase)
+	if length := utf8.RuneCountInString(passphrase); length < BRAINWALLET_MIN_PASSPHRASE_LENGTH {
+		return nil, fmt.Errorf("%w: %d characters, at least %d are required", ErrBrainwalletPassphraseTooShort, length, BRAINWALLET_MIN_PASSPHRASE_LENGTH)
+	}
+
+	p := BrainwalletArgon2Params
+	seed := argon2.IDKey([]byte(passphrase), []byte(BRAINWALLET_SALT_PREFIX+salt), p.Time, p.Memory, p.Threads, BRAINWALLET_SEED_SIZE)
+	defer clear(seed)
+	log.Debug().Str("params", p.String()).Msg("Derived brainwallet seed from the passphrase with Argon2id.")
+
+	key, err := g.generateKeyFromSeed(ctx, keyType, keyId, salt, seed, label)
+	if err != nil {
+		return nil, err
+	}
+	key.brainwallet = true
+	return key, nil
+}
@@ -0,0 +1,66 @@
+package keys
+
+import (
+	"errors"
+	"testing"
+)
+
+const testPassphrase = "correct horse battery staple"
+
+func TestBrainwalletDeterminism(t *testing.T) {
+	derive := func(salt, passphrase string, label KeyLabel) string {
+		t.Helper()
+		key, err := GenerateKeyFromPassphrase(t.Context(), KeyTypeECC, int(ECCCurveP256), salt, passphrase, label)
+		if err != nil {
+			t.Fatalf("failed to derive brainwallet key: %v", err)
+		}
+		if !key.Brainwallet() || key.DerivationName() != DEFAULT_DERIVATION.String()+DERIVATION_BRAINWALLET_SUFFIX || key.Mnemonic() != (Mnemonic{}) {
+			t.Fatalf("unexpected brainwallet key derivation %q", key.DerivationName())
+		}
+		fingerprint, err := key.PublicFingerprint(FingerprintHex)
+		if err != nil {
+			t.Fatalf("failed to fingerprint brainwallet key: %v", err)
+		}
+		return fingerprint
+	}
+
+	// the scheme is pinned, so a change to it cannot go unnoticed
+	fingerprint := derive(SALT, testPassphrase, KeyLabel{})
+	if fingerprint != "0220ed2bbb8d0606e2f9a43507c3da7cccb2bd14fd8225128900a8e5560e9ee7" {
+		t.Fatalf("unexpected brainwallet key fingerprint: %s", fingerprint)
+	}
+	if again := derive(SALT, testPassphrase, KeyLabel{}); again != fingerprint {
+		t.Fatalf("brainwallet key is not deterministic: %s != %s", again, fingerprint)
+	}
+
+	// the composed and decomposed forms of a passphrase derive the same key
+	if derive(SALT, "caf\u00e9 horse battery staple", KeyLabel{}) != derive(SALT, "cafe\u0301 horse battery staple", KeyLabel{}) {
+		t.Fatalf("expected NFKD-equal passphrases to derive the same key")
+	}
+
+	for name, other := range map[string]string{
+		"salt":       derive("other-salt", testPassphrase, KeyLabel{}),
+		"passphrase": derive(SALT, testPassphrase+"!", KeyLabel{}),
+		"index":      derive(SALT, testPassphrase, KeyLabel{Index: 1}),
+	} {
+		if other == fingerprint {
+			t.Fatalf("expected a different %s to derive a different key", name)
+		}
+	}
+
+	// the brainwallet key is unrelated to the mnemonic key of the same salt
+	key, err := GenerateKeyFromMnemonic(t.Context(), KeyTypeECC, int(ECCCurveP256), SALT, MustParseMnemonic(testMnemonic))
+	if err != nil {
+		t.Fatalf("failed to generate ECC key from mnemonic: %v", err)
+	}
+	if mnemonicFingerprint, _ := key.PublicFingerprint(FingerprintHex); mnemonicFingerprint == fingerprint {
+		t.Fatalf("expected the brainwallet key to differ from the mnemonic key")
+	}
+}
+
+func TestBrainwalletShortPassphrase(t *testing.T) {
+	_, err := GenerateKeyFromPassphrase(t.Context(), KeyTypeECC, int(ECCCurveP256), SALT, "too short", KeyLabel{})
+	if !errors.Is(err, ErrBrainwalletPassphraseTooShort) {
+		t.Fatalf("expected a short passphrase to be rejected, got: %v", err)
+	}
+}
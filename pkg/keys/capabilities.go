@@ -0,0 +1,85 @@
+package keys
+
+import "fmt"
+
+// Capabilities describes what a key type can be used for, for documentation and algorithm selection
+type Capabilities struct {
+	Algorithm  string   `json:"algorithm"`  // algorithm and parameters, e.g. "ECDSA P-256"
+	Operations []string `json:"operations"` // supported operations
+	Caveats    []string `json:"caveats"`    // limitations worth knowing before choosing the algorithm
+}
+
+// GetCapabilities returns the capabilities of keys of the given type and id
+func GetCapabilities(keyType KeyType, keyId int) (*Capabilities, error) {
+	switch keyType {
+	case KeyTypeECC:
+		return getCapabilitiesECC(ECCCurveID(keyId))
+	case KeyTypeRSA:
+		return getCapabilitiesRSA(RSAKeyID(keyId))
+	default:
+		return nil, fmt.Errorf("unsupported key type: %s", keyType)
+	}
+}
+
+// Capabilities returns the capabilities of the key
+func (k Key) Capabilities() (*Capabilities, error) {
+	return GetCapabilities(k.keyType, k.keyId)
+}
+
+// getCapabilitiesECC returns the capabilities of the given ECC curve
+func getCapabilitiesECC(id ECCCurveID) (*Capabilities, error) {
+	switch id {
+	case ECCCurveP256, ECCCurveP384, ECCCurveP521:
+		caps := &Capabilities{
+			Algorithm: fmt.Sprintf("ECDSA P-%d", getSizeECC(id)),
+			Operations: []string{
+				"signing/verification (ECDSA)",
+				"key agreement (ECDH)",
+				"X.509 certificate and CRL signing",
+				"SSH authentication",
+			},
+		}
+		if id == ECCCurveP521 {
+			caps.Caveats = append(caps.Caveats, "P-521 is not supported by some TLS clients and browsers")
+		}
+		caps.Caveats = append(caps.Caveats, "ECDSA signatures are randomized unless a deterministic (RFC 6979) signer is used")
+		return caps, nil
+	case ECCCurveEd25519:
+		return &Capabilities{
+			Algorithm: "Ed25519",
+			Operations: []string{
+				"signing/verification (EdDSA: pure Ed25519 and Ed25519ph)",
+				"X.509 certificate and CRL signing",
+				"SSH authentication",
+			},
+			Caveats: []string{
+				"signing only: key agreement (ECDH) requires conversion to X25519",
+				"Ed25519 certificates are not accepted by most TLS clients and browsers",
+			},
+		}, nil
+	}
+	return nil, fmt.Errorf("unsupported ECC curve")
+}
+
+// getCapabilitiesRSA returns the capabilities of the given RSA key size
+func getCapabilitiesRSA(id RSAKeyID) (*Capabilities, error) {
+	size := getSizeRSA(id)
+	if size == 0 {
+		return nil, fmt.Errorf("unsupported RSA key size")
+	}
+
+	caps := &Capabilities{
+		Algorithm: fmt.Sprintf("RSA-%d", size),
+		Operations: []string{
+			"signing/verification (PKCS#1 v1.5, PSS)",
+			"encryption/decryption (OAEP)",
+			"X.509 certificate and CRL signing",
+			"SSH authentication (rsa-sha2-256, rsa-sha2-512)",
+		},
+		Caveats: []string{"no key agreement: RSA keys cannot be used for ECDH"},
+	}
+	if size >= 8192 {
+		caps.Caveats = append(caps.Caveats, "RSA-8192 signing is slow and not supported by some hardware tokens and clients")
+	}
+	return caps, nil
+}
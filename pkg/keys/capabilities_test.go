@@ -0,0 +1,37 @@
+package keys
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCapabilities(t *testing.T) {
+	for id := ECCCurveNone + 1; id < eccCurveEnd; id++ {
+		caps, err := GetCapabilities(KeyTypeECC, int(id))
+		if err != nil || len(caps.Operations) == 0 {
+			t.Fatalf("ECC curve %d has no capabilities: %v", id, err)
+		}
+	}
+	for id := RSAKeyNone + 1; id < rsaKeyEnd; id++ {
+		caps, err := GetCapabilities(KeyTypeRSA, int(id))
+		if err != nil || len(caps.Operations) == 0 {
+			t.Fatalf("RSA key %d has no capabilities: %v", id, err)
+		}
+	}
+
+	ed, _ := GetCapabilities(KeyTypeECC, int(ECCCurveEd25519))
+	for _, op := range ed.Operations {
+		if strings.Contains(op, "ECDH") {
+			t.Fatalf("Ed25519 must not claim ECDH support: %q", op)
+		}
+	}
+
+	p256, _ := GetCapabilities(KeyTypeECC, int(ECCCurveP256))
+	if p256.Algorithm != "ECDSA P-256" {
+		t.Fatalf("unexpected P-256 algorithm: %s", p256.Algorithm)
+	}
+
+	if _, err := GetCapabilities(KeyTypeNone, 0); err == nil {
+		t.Fatalf("expected an error for an unsupported key type")
+	}
+}
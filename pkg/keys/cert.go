@@ -0,0 +1,302 @@
+package keys
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+const DEFAULT_CERT_VALIDITY = 365 * 24 * time.Hour
+
+// CERT_SERIAL_SIZE is the size in bytes of derived certificate serial numbers, whose top bit is cleared to keep them positive
+const CERT_SERIAL_SIZE = 16
+
+// CertOptions holds the subject and extensions used to build certificates and certificate requests
+type CertOptions struct {
+	CommonName     string        // subject common name
+	DNSNames       []string      // DNS subject alternative names
+	IPAddresses    []net.IP      // IP address subject alternative names
+	IsCA           bool          // mark the certificate as a CA certificate
+	KeyUsage       x509.KeyUsage // key usage of the certificate, chosen by IsCA if 0
+	MaxPathLen     int           // maximum number of intermediate CAs below a CA certificate, unlimited if 0 unless MaxPathLenZero
+	MaxPathLenZero bool          // limit a CA certificate to issuing end-entity certificates (a path length of 0)
+	Serial         *big.Int      // certificate serial number, derived from the mnemonic and salt when nil
+	NotBefore      time.Time     // start of the validity period, defaults to now
+	NotAfter       time.Time     // end of the validity period, defaults to NotBefore + Validity
+	Validity       time.Duration // length of the validity period, defaults to one year
+}
+
+// subject returns the certificate subject for the options
+func (o CertOptions) subject() pkix.Name {
+	return pkix.Name{CommonName: o.CommonName}
+}
+
+// signer returns the private key as a crypto.Signer
+func (k Key) signer() (crypto.Signer, error) {
+	signer, ok := k.PrivateKey.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported private key type %T", k.PrivateKey)
+	}
+	return signer, nil
+}
+
+// SubjectKeyID returns the subject key identifier of the key: the SHA-1 hash of the subjectPublicKey bit string of its
+// SubjectPublicKeyInfo (RFC 5280 section 4.2.1.2, method 1)
+func (k Key) SubjectKeyID() ([]byte, error) {
+	der, err := k.PublicKeyDER()
+	if err != nil {
+		return nil, err
+	}
+
+	var spki struct {
+		Algorithm        pkix.AlgorithmIdentifier
+		SubjectPublicKey asn1.BitString
+	}
+	if _, err := asn1.Unmarshal(der, &spki); err != nil {
+		return nil, fmt.Errorf("failed to parse public key: %w", err)
+	}
+
+	id := sha1.Sum(spki.SubjectPublicKey.Bytes)
+	return id[:], nil
+}
+
+// CertificateSerial returns the certificate serial number derived from the key's mnemonic, salt, and label, so that
+// re-issuing a certificate reproduces it. Keys without a mnemonic, e.g. loaded from a file, get a random serial.
+func (k Key) CertificateSerial() (*big.Int, error) {
+	if k.mnemonic == (Mnemonic{}) {
+		serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), CERT_SERIAL_SIZE*8-1))
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate certificate serial number: %w", err)
+		}
+		return serial.Add(serial, big.NewInt(1)), nil
+	}
+
+	info, err := certSerialInfo(k.keyType, k.keyId, k.label)
+	if err != nil {
+		return nil, err
+	}
+
+	g := k.generator()
+	_, seed, err := g.deriveSeed(k.mnemonic, k.salt)
+	if err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, CERT_SERIAL_SIZE)
+	if _, err := io.ReadFull(hkdf.New(g.hash, seed, []byte(k.salt), []byte(info)), buf); err != nil {
+		return nil, fmt.Errorf("failed to derive certificate serial number: %w", err)
+	}
+	buf[0] &= 0x7f
+
+	serial := new(big.Int).SetBytes(buf)
+	if serial.Sign() == 0 {
+		serial.SetInt64(1)
+	}
+	return serial, nil
+}
+
+// Certificate creates a DER-encoded certificate for the key, self-signed by the key itself. The serial number is
+// derived and signatures are deterministic, so the same options reproduce a byte-identical certificate.
+func (k Key) Certificate(opts CertOptions) ([]byte, error) {
+	signer, err := k.signer()
+	if err != nil {
+		return nil, err
+	}
+
+	template, err := k.certificateTemplate(opts)
+	if err != nil {
+		return nil, err
+	}
+	// the certificate is self-signed, so the authority key identifier is the key's own subject key identifier
+	template.AuthorityKeyId = template.SubjectKeyId
+
+	// a nil random source selects deterministic signatures (RFC 6979 for ECDSA, RSA PKCS#1 v1.5 and Ed25519 always are)
+	der, err := x509.CreateCertificate(nil, template, template, signer.Public(), signer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create certificate: %w", err)
+	}
+	return der, nil
+}
+
+// IssueCertificate creates a DER-encoded certificate for the subject key, signed by the key as the CA of the issuer
+// certificate, e.g. an intermediate CA certificate signed by an offline root. Like Certificate, it is reproducible.
+func (k Key) IssueCertificate(issuerDER []byte, subject Key, opts CertOptions) ([]byte, error) {
+	signer, err := k.signer()
+	if err != nil {
+		return nil, err
+	}
+
+	issuer, err := x509.ParseCertificate(issuerDER)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse issuer certificate: %w", err)
+	}
+	if !issuer.IsCA {
+		return nil, fmt.Errorf("issuer certificate %q is not a CA certificate", issuer.Subject.CommonName)
+	}
+	if pub, ok := issuer.PublicKey.(interface{ Equal(crypto.PublicKey) bool }); !ok || !pub.Equal(signer.Public()) {
+		return nil, fmt.Errorf("issuer certificate %q does not belong to the signing key", issuer.Subject.CommonName)
+	}
+
+	subjectSigner, err := subject.signer()
+	if err != nil {
+		return nil, err
+	}
+	template, err := subject.certificateTemplate(opts)
+	if err != nil {
+		return nil, err
+	}
+	template.AuthorityKeyId = issuer.SubjectKeyId
+
+	der, err := x509.CreateCertificate(nil, template, issuer, subjectSigner.Public(), signer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create certificate: %w", err)
+	}
+	return der, nil
+}
+
+// certificateTemplate returns the certificate template for the key and options, with the key's derived serial number
+// and subject key identifier
+func (k Key) certificateTemplate(opts CertOptions) (*x509.Certificate, error) {
+	serial := opts.Serial
+	if serial == nil {
+		var err error
+		if serial, err = k.CertificateSerial(); err != nil {
+			return nil, err
+		}
+	} else if serial.Sign() <= 0 {
+		return nil, fmt.Errorf("certificate serial number must be positive")
+	}
+
+	notBefore := opts.NotBefore
+	if notBefore.IsZero() {
+		notBefore = time.Now()
+	}
+	notAfter := opts.NotAfter
+	if notAfter.IsZero() {
+		validity := opts.Validity
+		if validity <= 0 {
+			validity = DEFAULT_CERT_VALIDITY
+		}
+		notAfter = notBefore.Add(validity)
+	}
+	if !notAfter.After(notBefore) {
+		return nil, fmt.Errorf("certificate must expire after its start %s, found %s", notBefore.UTC().Format(time.RFC3339), notAfter.UTC().Format(time.RFC3339))
+	}
+
+	keyId, err := k.SubjectKeyID()
+	if err != nil {
+		return nil, err
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               opts.subject(),
+		NotBefore:             notBefore.UTC(),
+		NotAfter:              notAfter.UTC(),
+		SubjectKeyId:          keyId,
+		DNSNames:              opts.DNSNames,
+		IPAddresses:           opts.IPAddresses,
+		BasicConstraintsValid: true,
+		IsCA:                  opts.IsCA,
+	}
+
+	if opts.IsCA {
+		template.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature
+		template.MaxPathLen = opts.MaxPathLen
+		template.MaxPathLenZero = opts.MaxPathLenZero
+	} else {
+		template.KeyUsage = x509.KeyUsageDigitalSignature
+		template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth}
+	}
+	if opts.KeyUsage != 0 {
+		template.KeyUsage = opts.KeyUsage
+	}
+	return template, nil
+}
+
+// VerifySelfSigned checks that a self-signed certificate validates against a pool holding only itself with x509.Verify,
+// at the start of its validity and for its first DNS name, if any. x509.Verify accepts a root without checking that it
+// may sign, so the signature of a CA certificate is checked as a CA signature as well, which rejects a missing CA
+// constraint or certSign key usage.
+func VerifySelfSigned(der []byte) error {
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return fmt.Errorf("failed to parse certificate: %w", err)
+	}
+
+	if cert.IsCA {
+		err = cert.CheckSignatureFrom(cert)
+	} else {
+		err = cert.CheckSignature(cert.SignatureAlgorithm, cert.RawTBSCertificate, cert.Signature)
+	}
+	if err != nil {
+		return fmt.Errorf("certificate %q is not validly self-signed: %w", cert.Subject.CommonName, err)
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(cert)
+	opts := x509.VerifyOptions{
+		Roots:       roots,
+		CurrentTime: cert.NotBefore,
+		KeyUsages:   []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	}
+	if len(cert.DNSNames) > 0 {
+		opts.DNSName = cert.DNSNames[0]
+	}
+	if _, err := cert.Verify(opts); err != nil {
+		return fmt.Errorf("certificate %q does not verify against itself: %w", cert.Subject.CommonName, err)
+	}
+	return nil
+}
+
+// CertificateChainPEM returns the PEM encoding of DER certificates in the given order, e.g. from the intermediate to
+// the root as TLS servers and most tools expect
+func CertificateChainPEM(ders ...[]byte) string {
+	var builder strings.Builder
+	for _, der := range ders {
+		builder.WriteString(CertificatePEM(der))
+	}
+	return builder.String()
+}
+
+// CertificateRequest creates a DER-encoded PKCS#10 certificate signing request for the key, signed deterministically
+func (k Key) CertificateRequest(opts CertOptions) ([]byte, error) {
+	signer, err := k.signer()
+	if err != nil {
+		return nil, err
+	}
+
+	template := &x509.CertificateRequest{
+		Subject:     opts.subject(),
+		DNSNames:    opts.DNSNames,
+		IPAddresses: opts.IPAddresses,
+	}
+
+	der, err := x509.CreateCertificateRequest(nil, template, signer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create certificate request: %w", err)
+	}
+	return der, nil
+}
+
+// CertificatePEM returns the PEM encoding of a DER certificate
+func CertificatePEM(der []byte) string {
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+}
+
+// CertificateRequestPEM returns the PEM encoding of a DER certificate signing request
+func CertificateRequestPEM(der []byte) string {
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: der}))
+}
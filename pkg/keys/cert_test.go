@@ -0,0 +1,307 @@
+package keys
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"math/big"
+	"net"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestCertificateSANs(t *testing.T) {
+	key, err := GenerateKeyFromMnemonic(t.Context(), KeyTypeECC, int(ECCCurveP256), SALT, MustParseMnemonic(testMnemonic))
+	if err != nil {
+		t.Fatalf("failed to generate ECC key from mnemonic: %v", err)
+	}
+
+	opts := CertOptions{
+		CommonName:  "a.example.com",
+		DNSNames:    []string{"a.example.com", "b.example.com"},
+		IPAddresses: []net.IP{net.ParseIP("10.0.0.1"), net.ParseIP("2001:db8::1")},
+	}
+
+	checkSANs := func(kind string, dnsNames []string, ips []net.IP) {
+		if !slices.Equal(dnsNames, opts.DNSNames) {
+			t.Fatalf("unexpected %s DNS names: got %v, want %v", kind, dnsNames, opts.DNSNames)
+		}
+		if len(ips) != len(opts.IPAddresses) {
+			t.Fatalf("unexpected %s IP addresses: got %v, want %v", kind, ips, opts.IPAddresses)
+		}
+		for i, ip := range ips {
+			if !ip.Equal(opts.IPAddresses[i]) {
+				t.Fatalf("unexpected %s IP address %d: got %s, want %s", kind, i, ip, opts.IPAddresses[i])
+			}
+		}
+	}
+
+	der, err := key.Certificate(opts)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("failed to parse certificate: %v", err)
+	}
+	if cert.Subject.CommonName != opts.CommonName || cert.IsCA {
+		t.Fatalf("unexpected certificate subject %q or CA flag %v", cert.Subject.CommonName, cert.IsCA)
+	}
+	checkSANs("certificate", cert.DNSNames, cert.IPAddresses)
+
+	der, err = key.CertificateRequest(opts)
+	if err != nil {
+		t.Fatalf("failed to create certificate request: %v", err)
+	}
+	csr, err := x509.ParseCertificateRequest(der)
+	if err != nil {
+		t.Fatalf("failed to parse certificate request: %v", err)
+	}
+	if err := csr.CheckSignature(); err != nil {
+		t.Fatalf("invalid certificate request signature: %v", err)
+	}
+	checkSANs("certificate request", csr.DNSNames, csr.IPAddresses)
+}
+
+func TestCertificateReproducible(t *testing.T) {
+	opts := CertOptions{
+		CommonName: "root.example.com",
+		IsCA:       true,
+		NotBefore:  time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+
+	tests := []struct {
+		keyType KeyType
+		keyId   int
+		label   KeyLabel
+	}{
+		{KeyTypeECC, int(ECCCurveP256), KeyLabel{}},
+		{KeyTypeECC, int(ECCCurveP384), KeyLabel{Index: 1}},
+		{KeyTypeECC, int(ECCCurveEd25519), KeyLabel{}},
+		{KeyTypeRSA, int(RSAKey2048), KeyLabel{}},
+	}
+
+	serials := make(map[string]bool)
+	for _, tt := range tests {
+		var certs [2][]byte
+		for i := range certs {
+			key, err := GenerateLabeledKeyFromMnemonic(t.Context(), tt.keyType, tt.keyId, SALT, MustParseMnemonic(testMnemonic), tt.label)
+			if err != nil {
+				t.Fatalf("failed to generate key: %v", err)
+			}
+			if certs[i], err = key.Certificate(opts); err != nil {
+				t.Fatalf("failed to create certificate: %v", err)
+			}
+		}
+		if !bytes.Equal(certs[0], certs[1]) {
+			t.Fatalf("certificates of %s key %d are not byte-identical", tt.keyType, tt.keyId)
+		}
+
+		cert, err := x509.ParseCertificate(certs[0])
+		if err != nil {
+			t.Fatalf("failed to parse certificate: %v", err)
+		}
+		if cert.SerialNumber.Sign() <= 0 || cert.SerialNumber.BitLen() > CERT_SERIAL_SIZE*8 {
+			t.Fatalf("invalid derived serial number %s", cert.SerialNumber)
+		}
+		if serials[cert.SerialNumber.String()] {
+			t.Fatalf("serial number %s is shared by keys of different types or labels", cert.SerialNumber)
+		}
+		serials[cert.SerialNumber.String()] = true
+	}
+
+	// an explicit serial number overrides the derived one
+	key, err := GenerateKeyFromMnemonic(t.Context(), KeyTypeECC, int(ECCCurveP256), SALT, MustParseMnemonic(testMnemonic))
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	opts.Serial = big.NewInt(42)
+	der, err := key.Certificate(opts)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("failed to parse certificate: %v", err)
+	}
+	if cert.SerialNumber.Int64() != 42 {
+		t.Fatalf("unexpected serial number: got %s, want 42", cert.SerialNumber)
+	}
+}
+
+func TestCertificateKeyIdentifiers(t *testing.T) {
+	opts := CertOptions{
+		CommonName: "root.example.com",
+		IsCA:       true,
+		NotBefore:  time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+		NotAfter:   time.Date(2045, 1, 1, 12, 30, 0, 0, time.UTC),
+	}
+
+	for _, curve := range []ECCCurveID{ECCCurveP256, ECCCurveEd25519} {
+		key, err := GenerateKeyFromMnemonic(t.Context(), KeyTypeECC, int(curve), SALT, MustParseMnemonic(testMnemonic))
+		if err != nil {
+			t.Fatalf("failed to generate key: %v", err)
+		}
+		der, err := key.Certificate(opts)
+		if err != nil {
+			t.Fatalf("failed to create certificate: %v", err)
+		}
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			t.Fatalf("failed to parse certificate: %v", err)
+		}
+
+		// SKI is the SHA-1 of the subjectPublicKey bit string, and the self-signed AKI matches it
+		var spki struct {
+			Algorithm        pkix.AlgorithmIdentifier
+			SubjectPublicKey asn1.BitString
+		}
+		if _, err := asn1.Unmarshal(cert.RawSubjectPublicKeyInfo, &spki); err != nil {
+			t.Fatalf("failed to parse certificate public key: %v", err)
+		}
+		expected := sha1.Sum(spki.SubjectPublicKey.Bytes)
+		if !bytes.Equal(cert.SubjectKeyId, expected[:]) {
+			t.Fatalf("unexpected subject key identifier %x, want %x", cert.SubjectKeyId, expected)
+		}
+		if !bytes.Equal(cert.AuthorityKeyId, cert.SubjectKeyId) {
+			t.Fatalf("authority key identifier %x does not match the subject key identifier %x", cert.AuthorityKeyId, cert.SubjectKeyId)
+		}
+
+		if !cert.NotBefore.Equal(opts.NotBefore) || !cert.NotAfter.Equal(opts.NotAfter) {
+			t.Fatalf("unexpected validity %s - %s", cert.NotBefore, cert.NotAfter)
+		}
+		if err := cert.CheckSignatureFrom(cert); err != nil {
+			t.Fatalf("self-signed certificate does not verify: %v", err)
+		}
+	}
+
+	key, err := GenerateKeyFromMnemonic(t.Context(), KeyTypeECC, int(ECCCurveP256), SALT, MustParseMnemonic(testMnemonic))
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	opts.NotAfter = opts.NotBefore
+	if _, err := key.Certificate(opts); err == nil {
+		t.Fatalf("expected an error for a certificate that expires when it starts")
+	}
+}
+
+func TestCertificateChain(t *testing.T) {
+	mnemonic := MustParseMnemonic(testMnemonic)
+	var chain [3]*Key
+	for i := range chain {
+		key, err := GenerateLabeledKeyFromMnemonic(t.Context(), KeyTypeECC, int(ECCCurveP256), SALT, mnemonic, KeyLabel{Index: i})
+		if err != nil {
+			t.Fatalf("failed to generate key %d: %v", i, err)
+		}
+		chain[i] = key
+	}
+	root, intermediate, leaf := chain[0], chain[1], chain[2]
+
+	notBefore := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	rootDER, err := root.Certificate(CertOptions{CommonName: "Root CA", IsCA: true, MaxPathLen: 1, NotBefore: notBefore, Validity: 20 * 365 * 24 * time.Hour})
+	if err != nil {
+		t.Fatalf("failed to create root certificate: %v", err)
+	}
+	intermediateOpts := CertOptions{CommonName: "Intermediate CA", IsCA: true, MaxPathLenZero: true, NotBefore: notBefore, Validity: 10 * 365 * 24 * time.Hour}
+	intermediateDER, err := root.IssueCertificate(rootDER, *intermediate, intermediateOpts)
+	if err != nil {
+		t.Fatalf("failed to issue intermediate certificate: %v", err)
+	}
+	leafDER, err := intermediate.IssueCertificate(intermediateDER, *leaf, CertOptions{CommonName: "a.example.com", DNSNames: []string{"a.example.com"}, NotBefore: notBefore})
+	if err != nil {
+		t.Fatalf("failed to issue leaf certificate: %v", err)
+	}
+
+	certs := make([]*x509.Certificate, 3)
+	for i, der := range [][]byte{rootDER, intermediateDER, leafDER} {
+		if certs[i], err = x509.ParseCertificate(der); err != nil {
+			t.Fatalf("failed to parse certificate %d: %v", i, err)
+		}
+	}
+	rootCert, intermediateCert, leafCert := certs[0], certs[1], certs[2]
+
+	if !intermediateCert.IsCA || intermediateCert.MaxPathLen != 0 || !intermediateCert.MaxPathLenZero {
+		t.Fatalf("unexpected intermediate constraints: ca=%t pathlen=%d", intermediateCert.IsCA, intermediateCert.MaxPathLen)
+	}
+	if rootCert.MaxPathLen != 1 {
+		t.Fatalf("unexpected root path length %d", rootCert.MaxPathLen)
+	}
+	if !bytes.Equal(intermediateCert.AuthorityKeyId, rootCert.SubjectKeyId) {
+		t.Fatalf("intermediate authority key identifier %x does not match the root subject key identifier %x", intermediateCert.AuthorityKeyId, rootCert.SubjectKeyId)
+	}
+	if intermediateCert.SerialNumber.Cmp(rootCert.SerialNumber) == 0 {
+		t.Fatalf("intermediate and root certificates share serial number %s", rootCert.SerialNumber)
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(rootCert)
+	intermediates := x509.NewCertPool()
+	intermediates.AddCert(intermediateCert)
+	verifyTime := notBefore.Add(24 * time.Hour)
+
+	if _, err := intermediateCert.Verify(x509.VerifyOptions{Roots: roots, CurrentTime: verifyTime, KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageAny}}); err != nil {
+		t.Fatalf("intermediate certificate does not verify against the root: %v", err)
+	}
+	if _, err := leafCert.Verify(x509.VerifyOptions{Roots: roots, Intermediates: intermediates, CurrentTime: verifyTime, DNSName: "a.example.com"}); err != nil {
+		t.Fatalf("leaf certificate does not verify through the chain: %v", err)
+	}
+
+	// the intermediate is reproducible, and cannot be issued with a key that does not match the issuer certificate
+	again, err := root.IssueCertificate(rootDER, *intermediate, intermediateOpts)
+	if err != nil || !bytes.Equal(again, intermediateDER) {
+		t.Fatalf("intermediate certificate is not reproducible: %v", err)
+	}
+	if _, err := leaf.IssueCertificate(rootDER, *intermediate, intermediateOpts); err == nil {
+		t.Fatalf("expected issuing with a key that does not match the issuer certificate to fail")
+	}
+	if _, err := root.IssueCertificate(leafDER, *intermediate, intermediateOpts); err == nil {
+		t.Fatalf("expected issuing under a non-CA certificate to fail")
+	}
+}
+
+func TestVerifySelfSigned(t *testing.T) {
+	key, err := GenerateKeyFromMnemonic(t.Context(), KeyTypeECC, int(ECCCurveP256), SALT, MustParseMnemonic(testMnemonic))
+	if err != nil {
+		t.Fatalf("failed to generate ECC key from mnemonic: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		opts  CertOptions
+		valid bool
+	}{
+		{"root", CertOptions{CommonName: "Example Root CA", IsCA: true}, true},
+		{"step-ca root", StepCARootOptions("Example Root CA"), true},
+		{"leaf", CertOptions{CommonName: "a.example.com", DNSNames: []string{"a.example.com"}}, true},
+		{"root without certSign", CertOptions{CommonName: "Example Root CA", IsCA: true, KeyUsage: x509.KeyUsageDigitalSignature}, false},
+	}
+	for _, tt := range tests {
+		der, err := key.Certificate(tt.opts)
+		if err != nil {
+			t.Fatalf("%s: failed to create certificate: %v", tt.name, err)
+		}
+		if err := VerifySelfSigned(der); (err == nil) != tt.valid {
+			t.Fatalf("%s: expected valid=%v, got %v", tt.name, tt.valid, err)
+		}
+	}
+
+	// a certificate signed by another key is not self-signed
+	other, err := GenerateLabeledKeyFromMnemonic(t.Context(), KeyTypeECC, int(ECCCurveP256), SALT, MustParseMnemonic(testMnemonic), KeyLabel{Index: 1})
+	if err != nil {
+		t.Fatalf("failed to generate ECC key from mnemonic: %v", err)
+	}
+	rootDER, err := key.Certificate(CertOptions{CommonName: "Example Root CA", IsCA: true})
+	if err != nil {
+		t.Fatalf("failed to create root certificate: %v", err)
+	}
+	issued, err := key.IssueCertificate(rootDER, *other, CertOptions{CommonName: "Example Root CA", IsCA: true})
+	if err != nil {
+		t.Fatalf("failed to issue certificate: %v", err)
+	}
+	if err := VerifySelfSigned(issued); err == nil {
+		t.Fatalf("expected a certificate signed by another key to fail self-verification")
+	}
+}
@@ -0,0 +1,194 @@
+package keys
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"strings"
+)
+
+type FingerprintFormat string
+
+const (
+	FingerprintHex    FingerprintFormat = "hex"    // lowercase hex, e.g. 4305...5957
+	FingerprintColon  FingerprintFormat = "colon"  // colon-separated uppercase hex, e.g. 43:05:...:57
+	FingerprintBase64 FingerprintFormat = "base64" // standard base64, as used by HPKP-style pins
+)
+
+var supportedFingerprintFormats = []FingerprintFormat{FingerprintHex, FingerprintColon, FingerprintBase64}
+
+// SupportedFingerprintFormats returns a string listing supported fingerprint formats
+func SupportedFingerprintFormats() string {
+	var names []string
+	for _, f := range supportedFingerprintFormats {
+		names = append(names, string(f))
+	}
+	return "Supported fingerprint formats: " + strings.Join(names, ", ")
+}
+
+// ParseFingerprintFormat parses the given string to determine the FingerprintFormat
+func ParseFingerprintFormat(val string) (FingerprintFormat, error) {
+	val = strings.ToLower(strings.TrimSpace(val))
+	if val == "" {
+		return FingerprintHex, nil
+	}
+
+	for _, f := range supportedFingerprintFormats {
+		if val == string(f) {
+			return f, nil
+		}
+	}
+
+	return "", fmt.Errorf("unsupported fingerprint format: %s", val)
+}
+
+// FormatFingerprint encodes a fingerprint digest in the given format
+func FormatFingerprint(sum []byte, format FingerprintFormat) (string, error) {
+	switch format {
+	case FingerprintHex:
+		return hex.EncodeToString(sum), nil
+	case FingerprintColon:
+		parts := make([]string, len(sum))
+		for i, b := range sum {
+			parts[i] = fmt.Sprintf("%02X", b)
+		}
+		return strings.Join(parts, ":"), nil
+	case FingerprintBase64:
+		return base64.StdEncoding.EncodeToString(sum), nil
+	default:
+		return "", fmt.Errorf("unsupported fingerprint format: %s", format)
+	}
+}
+
+// PublicKey returns the public key corresponding to the private key
+func (k Key) PublicKey() (crypto.PublicKey, error) {
+	signer, err := k.signer()
+	if err != nil {
+		return nil, err
+	}
+	return signer.Public(), nil
+}
+
+// PublicKeyDER returns the DER-encoded SubjectPublicKeyInfo of the public key
+func (k Key) PublicKeyDER() ([]byte, error) {
+	pub, err := k.PublicKey()
+	if err != nil {
+		return nil, err
+	}
+
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal public key: %w", err)
+	}
+	return der, nil
+}
+
+// PublicKeyPEM returns the PEM-encoded SubjectPublicKeyInfo of the public key
+func (k Key) PublicKeyPEM() (string, error) {
+	der, err := k.PublicKeyDER()
+	if err != nil {
+		return "", err
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})), nil
+}
+
+// PublicKeyBytes returns the raw public key for systems that do not accept PEM or DER: the uncompressed SEC1 point
+// 0x04 || X || Y for the NIST curves, the 32-byte public key for Ed25519, or the big-endian modulus for RSA
+func (k Key) PublicKeyBytes() ([]byte, error) {
+	pub, err := k.PublicKey()
+	if err != nil {
+		return nil, err
+	}
+
+	switch pub := pub.(type) {
+	case *ecdsa.PublicKey:
+		ecdhPub, err := pub.ECDH()
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode EC point: %w", err)
+		}
+		return ecdhPub.Bytes(), nil
+	case ed25519.PublicKey:
+		return bytes.Clone(pub), nil
+	case *rsa.PublicKey:
+		return pub.N.Bytes(), nil
+	default:
+		return nil, fmt.Errorf("unsupported public key type %T", pub)
+	}
+}
+
+// PublicKeyBytesCompressed returns the compressed SEC1 point 0x02/0x03 || X of a NIST curve public key. Other key
+// types have no compressed form, so they are an error.
+func (k Key) PublicKeyBytesCompressed() ([]byte, error) {
+	pub, err := k.PublicKey()
+	if err != nil {
+		return nil, err
+	}
+	ecPub, ok := pub.(*ecdsa.PublicKey)
+	if !ok {
+		algorithm := fmt.Sprintf("%T", pub)
+		if capabilities, err := k.Capabilities(); err == nil {
+			algorithm = capabilities.Algorithm
+		}
+		return nil, fmt.Errorf("compressed points are only defined for the NIST curves, not %s keys", algorithm)
+	}
+	return elliptic.MarshalCompressed(ecPub.Curve, ecPub.X, ecPub.Y), nil
+}
+
+// PublicFingerprint returns the SHA-256 fingerprint of the DER-encoded SubjectPublicKeyInfo in the given format.
+// Unlike Fingerprint, it does not depend on whether or how the private key is encrypted.
+func (k Key) PublicFingerprint(format FingerprintFormat) (string, error) {
+	der, err := k.PublicKeyDER()
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(der)
+	return FormatFingerprint(sum[:], format)
+}
+
+// SPKIPin is the pinning form of a public key: its DER-encoded SubjectPublicKeyInfo and the SHA-256 digest of it,
+// both in standard base64, as used by HPKP pin-sha256 values and most certificate and public key pinning configurations
+type SPKIPin struct {
+	SPKI   string `json:"spki"`
+	SHA256 string `json:"sha256"`
+}
+
+// SPKIPin returns the base64 SubjectPublicKeyInfo of the public key and its SHA-256 pin
+func (k Key) SPKIPin() (SPKIPin, error) {
+	der, err := k.PublicKeyDER()
+	if err != nil {
+		return SPKIPin{}, err
+	}
+
+	sum := sha256.Sum256(der)
+	return SPKIPin{
+		SPKI:   base64.StdEncoding.EncodeToString(der),
+		SHA256: base64.StdEncoding.EncodeToString(sum[:]),
+	}, nil
+}
+
+// MatchFingerprint reports whether the expected fingerprint, given in the specified format, matches the public key
+func (k Key) MatchFingerprint(expected string, format FingerprintFormat) (bool, error) {
+	actual, err := k.PublicFingerprint(format)
+	if err != nil {
+		return false, err
+	}
+
+	expected = strings.TrimSpace(expected)
+	switch format {
+	case FingerprintHex, FingerprintColon:
+		// hex fingerprints are compared case-insensitively
+		return strings.EqualFold(expected, actual), nil
+	default:
+		return expected == actual, nil
+	}
+}
@@ -0,0 +1,217 @@
+package keys
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/pem"
+	"math/big"
+	"testing"
+)
+
+func TestPublicFingerprintFormats(t *testing.T) {
+	// expected values were cross-checked with: openssl pkey -pubout -outform DER | openssl dgst -sha256
+	tests := map[FingerprintFormat]string{
+		FingerprintHex:    "5380a8a48cef955b14d3ff53e178b4a9f731505747facf1175e8b6fe5eebcb2f",
+		FingerprintColon:  "53:80:A8:A4:8C:EF:95:5B:14:D3:FF:53:E1:78:B4:A9:F7:31:50:57:47:FA:CF:11:75:E8:B6:FE:5E:EB:CB:2F",
+		FingerprintBase64: "U4CopIzvlVsU0/9T4Xi0qfcxUFdH+s8Rdei2/l7ryy8=",
+	}
+
+	key, err := GenerateKeyFromMnemonic(
+		t.Context(),
+		KeyTypeECC,
+		int(ECCCurveP256),
+		SALT,
+		MustParseMnemonic("away mistake dance place sword title nurse diary skin soon figure sense force seat inform hedgehog debate around tortoise detail uncle situate draft wait"),
+	)
+	if err != nil {
+		t.Fatalf("failed to generate ECC key from mnemonic: %v", err)
+	}
+
+	for format, expected := range tests {
+		fingerprint, err := key.PublicFingerprint(format)
+		if err != nil {
+			t.Fatalf("failed to compute %s fingerprint: %v", format, err)
+		}
+		if fingerprint != expected {
+			t.Fatalf("unexpected %s fingerprint: got %s, want %s", format, fingerprint, expected)
+		}
+
+		ok, err := key.MatchFingerprint(expected, format)
+		if err != nil || !ok {
+			t.Fatalf("expected %s fingerprint to match: %v", format, err)
+		}
+	}
+
+	// the public fingerprint must not change when the private key is encrypted
+	if err := key.Encrypt(PASSWORD); err != nil {
+		t.Fatalf("failed to encrypt ECC key: %v", err)
+	}
+	if ok, _ := key.MatchFingerprint("5380A8A48CEF955B14D3FF53E178B4A9F731505747FACF1175E8B6FE5EEBCB2F", FingerprintHex); !ok {
+		t.Fatalf("expected uppercase hex fingerprint to match after encryption")
+	}
+	if ok, _ := key.MatchFingerprint(tests[FingerprintHex], FingerprintBase64); ok {
+		t.Fatalf("expected fingerprint in the wrong format not to match")
+	}
+}
+
+func TestSPKIPin(t *testing.T) {
+	// expected pins were produced with: openssl pkey -pubin -outform DER | openssl dgst -sha256 -binary | base64
+	tests := []struct {
+		curve ECCCurveID
+		pin   SPKIPin
+	}{
+		{ECCCurveP384, SPKIPin{SHA256: "HrH43+b0y0TZ9L85GG1IUhe9GSHwO6AtYnG41hyivIs="}},
+		{ECCCurveEd25519, SPKIPin{
+			SPKI:   "MCowBQYDK2VwAyEAt4l3r/V9DnBYIhb0kMJwtfPB/NhAY6LRAVz4iUU8H2s=",
+			SHA256: "TnPD0eplIJhYLGPXrnMpWM8PU+pr3eMHRo7SmSsb340=",
+		}},
+	}
+	for _, tt := range tests {
+		key, err := GenerateKeyFromMnemonic(t.Context(), KeyTypeECC, int(tt.curve), SALT, MustParseMnemonic(testMnemonic))
+		if err != nil {
+			t.Fatalf("failed to generate ECC key from mnemonic: %v", err)
+		}
+		pin, err := key.SPKIPin()
+		if err != nil {
+			t.Fatalf("failed to compute SPKI pin: %v", err)
+		}
+		if pin.SHA256 != tt.pin.SHA256 {
+			t.Fatalf("unexpected pin for curve %d: got %s, want %s", tt.curve, pin.SHA256, tt.pin.SHA256)
+		}
+		if tt.pin.SPKI != "" && pin.SPKI != tt.pin.SPKI {
+			t.Fatalf("unexpected SPKI for curve %d: got %s, want %s", tt.curve, pin.SPKI, tt.pin.SPKI)
+		}
+
+		// the pin is the base64 form of the public key fingerprint
+		fingerprint, err := key.PublicFingerprint(FingerprintBase64)
+		if err != nil || fingerprint != pin.SHA256 {
+			t.Fatalf("expected the pin to equal the base64 fingerprint %s, got %s (%v)", fingerprint, pin.SHA256, err)
+		}
+	}
+}
+
+func TestParseFingerprintFormat(t *testing.T) {
+	for _, val := range []string{"hex", "COLON", " base64 ", ""} {
+		if _, err := ParseFingerprintFormat(val); err != nil {
+			t.Fatalf("failed to parse fingerprint format %q: %v", val, err)
+		}
+	}
+	if _, err := ParseFingerprintFormat("md5"); err == nil {
+		t.Fatalf("expected an error for an unsupported fingerprint format")
+	}
+}
+
+func TestPublicKeyPEM(t *testing.T) {
+	key, err := GenerateKeyFromMnemonic(t.Context(), KeyTypeECC, int(ECCCurveEd25519), SALT, MustParseMnemonic(testMnemonic))
+	if err != nil {
+		t.Fatalf("failed to generate ECC key from mnemonic: %v", err)
+	}
+
+	pub, err := key.PublicKeyPEM()
+	if err != nil {
+		t.Fatalf("failed to encode public key: %v", err)
+	}
+	block, _ := pem.Decode([]byte(pub))
+	if block == nil || block.Type != "PUBLIC KEY" {
+		t.Fatalf("expected a PUBLIC KEY PEM block")
+	}
+
+	der, err := key.PublicKeyDER()
+	if err != nil {
+		t.Fatalf("failed to marshal public key: %v", err)
+	}
+	if !bytes.Equal(block.Bytes, der) {
+		t.Fatalf("public key PEM does not contain the SubjectPublicKeyInfo")
+	}
+}
+
+func TestPublicKeyBytes(t *testing.T) {
+	mnemonic := MustParseMnemonic(testMnemonic)
+
+	for _, curve := range []ECCCurveID{ECCCurveP256, ECCCurveP384, ECCCurveP521} {
+		key, err := GenerateKeyFromMnemonic(t.Context(), KeyTypeECC, int(curve), SALT, mnemonic)
+		if err != nil {
+			t.Fatalf("failed to generate key: %v", err)
+		}
+		raw, err := key.PublicKeyBytes()
+		if err != nil {
+			t.Fatalf("failed to get raw public key: %v", err)
+		}
+
+		// 0x04 || X || Y, each coordinate left-padded to the field size
+		pub := key.PrivateKey.(*ecdsa.PrivateKey).PublicKey
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		if len(raw) != 1+2*size || raw[0] != 0x04 {
+			t.Fatalf("unexpected %s point layout: %d bytes, prefix %#x", pub.Curve.Params().Name, len(raw), raw[0])
+		}
+		if !bytes.Equal(raw[1:1+size], pub.X.FillBytes(make([]byte, size))) || !bytes.Equal(raw[1+size:], pub.Y.FillBytes(make([]byte, size))) {
+			t.Fatalf("%s point coordinates do not match the public key", pub.Curve.Params().Name)
+		}
+	}
+
+	key, err := GenerateKeyFromMnemonic(t.Context(), KeyTypeECC, int(ECCCurveEd25519), SALT, mnemonic)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	raw, err := key.PublicKeyBytes()
+	if err != nil {
+		t.Fatalf("failed to get raw public key: %v", err)
+	}
+	if expected := key.PrivateKey.(ed25519.PrivateKey).Public().(ed25519.PublicKey); !bytes.Equal(raw, expected) || len(raw) != ed25519.PublicKeySize {
+		t.Fatalf("unexpected Ed25519 public key %x, want %x", raw, expected)
+	}
+
+	key, err = GenerateKeyFromMnemonic(t.Context(), KeyTypeRSA, int(RSAKey2048), SALT, mnemonic)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	raw, err = key.PublicKeyBytes()
+	if err != nil {
+		t.Fatalf("failed to get raw public key: %v", err)
+	}
+	modulus := key.PrivateKey.(*rsa.PrivateKey).N
+	if len(raw) != 256 || new(big.Int).SetBytes(raw).Cmp(modulus) != 0 {
+		t.Fatalf("unexpected RSA modulus bytes: %d bytes", len(raw))
+	}
+}
+
+func TestPublicKeyBytesCompressed(t *testing.T) {
+	mnemonic := MustParseMnemonic(testMnemonic)
+
+	for _, curve := range []ECCCurveID{ECCCurveP256, ECCCurveP384, ECCCurveP521} {
+		key, err := GenerateKeyFromMnemonic(t.Context(), KeyTypeECC, int(curve), SALT, mnemonic)
+		if err != nil {
+			t.Fatalf("failed to generate key: %v", err)
+		}
+		compressed, err := key.PublicKeyBytesCompressed()
+		if err != nil {
+			t.Fatalf("failed to get compressed public key: %v", err)
+		}
+
+		pub := key.PrivateKey.(*ecdsa.PrivateKey).PublicKey
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		if len(compressed) != 1+size || compressed[0] != byte(0x02+pub.Y.Bit(0)) {
+			t.Fatalf("unexpected %s compressed point layout: %d bytes, prefix %#x", pub.Curve.Params().Name, len(compressed), compressed[0])
+		}
+		x, y := elliptic.UnmarshalCompressed(pub.Curve, compressed)
+		if x == nil || x.Cmp(pub.X) != 0 || y.Cmp(pub.Y) != 0 {
+			t.Fatalf("%s compressed point does not round-trip to the public key", pub.Curve.Params().Name)
+		}
+	}
+
+	for _, k := range []struct {
+		keyType KeyType
+		keyId   int
+	}{{KeyTypeECC, int(ECCCurveEd25519)}, {KeyTypeRSA, int(RSAKey2048)}} {
+		key, err := GenerateKeyFromMnemonic(t.Context(), k.keyType, k.keyId, SALT, mnemonic)
+		if err != nil {
+			t.Fatalf("failed to generate key: %v", err)
+		}
+		if _, err := key.PublicKeyBytesCompressed(); err == nil {
+			t.Fatalf("expected a compressed %s public key to be rejected", k.keyType)
+		}
+	}
+}
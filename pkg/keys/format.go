@@ -0,0 +1,281 @@
+package keys
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+type Format string
+
+const (
+	FormatPKCS8   Format = "pkcs8"   // PKCS#8 "PRIVATE KEY", supports every key type and password encryption
+	FormatSEC1    Format = "sec1"    // SEC1 "EC PRIVATE KEY", NIST curves only
+	FormatPKCS1   Format = "pkcs1"   // PKCS#1 "RSA PRIVATE KEY", RSA only
+	FormatOpenSSH Format = "openssh" // OpenSSH "OPENSSH PRIVATE KEY", every key type
+)
+
+// formatInfo holds information about a supported output format
+type formatInfo struct {
+	Format      Format
+	Description string
+	Extension   string // file extension of keys written in the format, distinct for every format
+	Aliases     []string
+}
+
+var supportedFormats = []formatInfo{
+	{Format: FormatPKCS8, Description: "PEM", Extension: "pem", Aliases: []string{"pkcs8", "pem"}},
+	{Format: FormatSEC1, Description: "SEC1 PEM", Extension: "sec1.pem", Aliases: []string{"sec1", "ec"}},
+	{Format: FormatPKCS1, Description: "PKCS#1 PEM", Extension: "pkcs1.pem", Aliases: []string{"pkcs1", "rsa"}},
+	{Format: FormatOpenSSH, Description: "OpenSSH", Extension: "ossh", Aliases: []string{"openssh", "ssh"}},
+}
+
+// SupportedFormats returns a string listing supported output formats and their aliases
+func SupportedFormats() string {
+	var builder strings.Builder
+	builder.WriteString("Supported formats:\n")
+	for _, info := range supportedFormats {
+		builder.WriteString(fmt.Sprintf(" - %s (aliases: %s)\n", info.Format, strings.Join(info.Aliases, ", ")))
+	}
+	return builder.String()
+}
+
+// ParseFormat parses the given string to determine the output Format, defaulting to PKCS#8
+func ParseFormat(val string) (Format, error) {
+	val = strings.ToLower(strings.TrimSpace(val))
+	if val == "" {
+		return FormatPKCS8, nil
+	}
+
+	for _, info := range supportedFormats {
+		for _, alias := range info.Aliases {
+			if val == alias {
+				return info.Format, nil
+			}
+		}
+	}
+
+	return "", fmt.Errorf("unsupported format: %s", val)
+}
+
+// ParseFormats parses a comma-separated list of output formats, e.g. "pkcs8,openssh", dropping duplicates.
+// An empty list defaults to PKCS#8.
+func ParseFormats(val string) ([]Format, error) {
+	var formats []Format
+	for _, entry := range strings.Split(val, ",") {
+		format, err := ParseFormat(entry)
+		if err != nil {
+			return nil, err
+		}
+		if strings.TrimSpace(entry) != "" && !slices.Contains(formats, format) {
+			formats = append(formats, format)
+		}
+	}
+	if len(formats) == 0 {
+		formats = append(formats, FormatPKCS8)
+	}
+	return formats, nil
+}
+
+// Extension returns the file extension of keys written in the format, e.g. "pem" or "ossh"
+func (f Format) Extension() string {
+	for _, info := range supportedFormats {
+		if info.Format == f {
+			return info.Extension
+		}
+	}
+	return string(f)
+}
+
+// describeFormat returns the human-readable description of the format
+func describeFormat(format Format) string {
+	for _, info := range supportedFormats {
+		if info.Format == format {
+			return info.Description
+		}
+	}
+	return string(format)
+}
+
+// CheckFormat validates that keys of the given type and id can be encoded in the format, so that
+// an incompatible request is rejected before any (possibly slow) key generation takes place
+func CheckFormat(format Format, keyType KeyType, keyId int) error {
+	switch format {
+	case FormatPKCS8, FormatOpenSSH:
+		return nil
+	case FormatSEC1:
+		if keyType != KeyTypeECC || ECCCurveID(keyId) == ECCCurveEd25519 {
+			return fmt.Errorf("format %s only supports NIST curve ECC keys (P-256, P-384, P-521)", format)
+		}
+		return nil
+	case FormatPKCS1:
+		if keyType != KeyTypeRSA {
+			return fmt.Errorf("format %s only supports RSA keys", format)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported format: %s", format)
+	}
+}
+
+// Encode returns the PEM encoding of the private key in the given format.
+// Only the PKCS#8 format can carry a password-encrypted key.
+func (k Key) Encode(format Format) (string, error) {
+	block, err := k.pemBlock(format)
+	if err != nil {
+		return "", err
+	}
+	return string(pem.EncodeToMemory(block)), nil
+}
+
+// WriteFormat writes the PEM encoding of the private key in the given format to w, without building the encoding
+// in memory first, and returns the number of bytes written
+func (k Key) WriteFormat(w io.Writer, format Format) (int64, error) {
+	block, err := k.pemBlock(format)
+	if err != nil {
+		return 0, err
+	}
+	cw := &countingWriter{w: w}
+	if err := pem.Encode(cw, block); err != nil {
+		return cw.n, fmt.Errorf("failed to write private key: %w", err)
+	}
+	return cw.n, nil
+}
+
+// WriteTo writes the PKCS#8 PEM encoding of the private key to w, implementing io.WriterTo
+func (k Key) WriteTo(w io.Writer) (int64, error) {
+	return k.WriteFormat(w, FormatPKCS8)
+}
+
+// pemBlock returns the PEM block of the private key in the given format
+func (k Key) pemBlock(format Format) (*pem.Block, error) {
+	if format == FormatPKCS8 {
+		return k.pkcs8Block(), nil
+	}
+
+	if k.encrypted {
+		return nil, fmt.Errorf("format %s does not support password encryption, use %s", format, FormatPKCS8)
+	}
+
+	switch format {
+	case FormatSEC1:
+		priv, ok := k.PrivateKey.(*ecdsa.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("format %s only supports NIST curve ECC keys", format)
+		}
+		der, err := x509.MarshalECPrivateKey(priv)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal SEC1 private key: %w", err)
+		}
+		return &pem.Block{Type: "EC PRIVATE KEY", Bytes: der}, nil
+	case FormatPKCS1:
+		priv, ok := k.PrivateKey.(*rsa.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("format %s only supports RSA keys", format)
+		}
+		return &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(priv)}, nil
+	case FormatOpenSSH:
+		// the OpenSSH container includes random check bytes, so only the key inside it is deterministic. The comment
+		// is stored beside the key and does not change its fingerprint.
+		block, err := ssh.MarshalPrivateKey(k.PrivateKey, k.Comment())
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal OpenSSH private key: %w", err)
+		}
+		return block, nil
+	default:
+		return nil, fmt.Errorf("unsupported format: %s", format)
+	}
+}
+
+// AuthorizedKey returns the OpenSSH public key in authorized_keys form, e.g. "ssh-ed25519 AAAA... bipkey::0",
+// ending in the key's comment. Like the OpenSSH private key comment, it does not change the key's fingerprint.
+func (k Key) AuthorizedKey() (string, error) {
+	pub, err := k.PublicKey()
+	if err != nil {
+		return "", err
+	}
+	sshPub, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		return "", fmt.Errorf("failed to convert public key to OpenSSH: %w", err)
+	}
+	line := strings.TrimSuffix(string(ssh.MarshalAuthorizedKey(sshPub)), "\n")
+	return line + " " + k.Comment() + "\n", nil
+}
+
+// named curve object identifiers of the NIST curves (RFC 5480)
+var (
+	oidNamedCurveP256 = asn1.ObjectIdentifier{1, 2, 840, 10045, 3, 1, 7}
+	oidNamedCurveP384 = asn1.ObjectIdentifier{1, 3, 132, 0, 34}
+	oidNamedCurveP521 = asn1.ObjectIdentifier{1, 3, 132, 0, 35}
+)
+
+// ECParametersPEM returns the "EC PARAMETERS" PEM block naming the curve of a NIST curve key, as written by
+// openssl ecparam. Other keys have no EC parameters, so they are an error.
+func (k Key) ECParametersPEM() (string, error) {
+	priv, ok := k.PrivateKey.(*ecdsa.PrivateKey)
+	if !ok {
+		return "", fmt.Errorf("EC parameters are only defined for NIST curve ECC keys")
+	}
+
+	var oid asn1.ObjectIdentifier
+	switch priv.Curve {
+	case elliptic.P256():
+		oid = oidNamedCurveP256
+	case elliptic.P384():
+		oid = oidNamedCurveP384
+	case elliptic.P521():
+		oid = oidNamedCurveP521
+	default:
+		return "", fmt.Errorf("unsupported curve %s", priv.Curve.Params().Name)
+	}
+
+	der, err := asn1.Marshal(oid)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal EC parameters: %w", err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "EC PARAMETERS", Bytes: der})), nil
+}
+
+// EncodeWithECParameters returns the SEC1 encoding of the private key preceded by its "EC PARAMETERS" block, as
+// written by openssl ecparam -genkey, for legacy tooling that expects the curve parameters before the key. The
+// format must be SEC1.
+func (k Key) EncodeWithECParameters(format Format) (string, error) {
+	if format != FormatSEC1 {
+		return "", fmt.Errorf("EC parameters can only precede a %s private key, not %s", FormatSEC1, format)
+	}
+	params, err := k.ECParametersPEM()
+	if err != nil {
+		return "", err
+	}
+	encoded, err := k.Encode(format)
+	if err != nil {
+		return "", err
+	}
+	return params + encoded, nil
+}
+
+// countingWriter counts the bytes written to the underlying writer
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
+
+// CheckFormat validates that the key can be encoded in the format
+func (k Key) CheckFormat(format Format) error {
+	return CheckFormat(format, k.keyType, k.keyId)
+}
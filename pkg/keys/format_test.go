@@ -0,0 +1,430 @@
+package keys
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/pem"
+	"io"
+	"slices"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+func TestCheckFormat(t *testing.T) {
+	tests := []struct {
+		format  Format
+		keyType KeyType
+		keyId   int
+		ok      bool
+	}{
+		{FormatPKCS8, KeyTypeECC, int(ECCCurveP256), true},
+		{FormatPKCS8, KeyTypeECC, int(ECCCurveEd25519), true},
+		{FormatPKCS8, KeyTypeRSA, int(RSAKey8192), true},
+		{FormatSEC1, KeyTypeECC, int(ECCCurveP384), true},
+		{FormatSEC1, KeyTypeECC, int(ECCCurveEd25519), false},
+		{FormatSEC1, KeyTypeRSA, int(RSAKey2048), false},
+		{FormatPKCS1, KeyTypeRSA, int(RSAKey4096), true},
+		{FormatPKCS1, KeyTypeECC, int(ECCCurveP521), false},
+		{FormatPKCS1, KeyTypeECC, int(ECCCurveEd25519), false},
+		{FormatOpenSSH, KeyTypeECC, int(ECCCurveEd25519), true},
+		{FormatOpenSSH, KeyTypeRSA, int(RSAKey2048), true},
+		{Format("der"), KeyTypeECC, int(ECCCurveP256), false},
+	}
+
+	for _, test := range tests {
+		err := CheckFormat(test.format, test.keyType, test.keyId)
+		if (err == nil) != test.ok {
+			t.Fatalf("CheckFormat(%s, %s, %d): got %v, want ok=%v", test.format, test.keyType, test.keyId, err, test.ok)
+		}
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	tests := map[string]Format{
+		"":        FormatPKCS8,
+		"PEM":     FormatPKCS8,
+		"sec1":    FormatSEC1,
+		"pkcs1":   FormatPKCS1,
+		" ssh ":   FormatOpenSSH,
+		"openssh": FormatOpenSSH,
+	}
+	for val, expected := range tests {
+		format, err := ParseFormat(val)
+		if err != nil || format != expected {
+			t.Fatalf("ParseFormat(%q): got %s (%v), want %s", val, format, err, expected)
+		}
+	}
+	if _, err := ParseFormat("jks"); err == nil {
+		t.Fatalf("expected an error for an unsupported format")
+	}
+}
+
+func TestParseFormats(t *testing.T) {
+	formats, err := ParseFormats(" pem, ssh,sec1,pkcs8 ,")
+	if err != nil {
+		t.Fatalf("failed to parse formats: %v", err)
+	}
+	if !slices.Equal(formats, []Format{FormatPKCS8, FormatOpenSSH, FormatSEC1}) {
+		t.Fatalf("unexpected formats: %v", formats)
+	}
+	if formats, err := ParseFormats(""); err != nil || !slices.Equal(formats, []Format{FormatPKCS8}) {
+		t.Fatalf("expected the empty list to default to pkcs8, got %v (%v)", formats, err)
+	}
+	if _, err := ParseFormats("pkcs8,jks"); err == nil {
+		t.Fatalf("expected an error for an unsupported format in the list")
+	}
+
+	// every format needs its own extension, or an --out template would write two formats to the same file
+	seen := make(map[string]Format)
+	for _, info := range supportedFormats {
+		if other, ok := seen[info.Format.Extension()]; ok {
+			t.Fatalf("formats %s and %s share the extension %q", other, info.Format, info.Extension)
+		}
+		seen[info.Format.Extension()] = info.Format
+	}
+}
+
+func TestEncodeFormats(t *testing.T) {
+	ecc, err := GenerateKey(t.Context(), KeyTypeECC, int(ECCCurveP384), SALT)
+	if err != nil {
+		t.Fatalf("failed to generate ECC key: %v", err)
+	}
+	ed, err := GenerateKey(t.Context(), KeyTypeECC, int(ECCCurveEd25519), SALT)
+	if err != nil {
+		t.Fatalf("failed to generate Ed25519 key: %v", err)
+	}
+	rsa, err := GenerateKey(t.Context(), KeyTypeRSA, int(RSAKey2048), SALT)
+	if err != nil {
+		t.Fatalf("failed to generate RSA key: %v", err)
+	}
+
+	tests := []struct {
+		key       *Key
+		format    Format
+		blockType string
+		parse     func([]byte) (any, error)
+	}{
+		{ecc, FormatPKCS8, "PRIVATE KEY", func(b []byte) (any, error) { return x509.ParsePKCS8PrivateKey(b) }},
+		{ecc, FormatSEC1, "EC PRIVATE KEY", func(b []byte) (any, error) { return x509.ParseECPrivateKey(b) }},
+		{rsa, FormatPKCS1, "RSA PRIVATE KEY", func(b []byte) (any, error) { return x509.ParsePKCS1PrivateKey(b) }},
+		{ecc, FormatOpenSSH, "OPENSSH PRIVATE KEY", nil},
+		{ed, FormatOpenSSH, "OPENSSH PRIVATE KEY", nil},
+		{rsa, FormatOpenSSH, "OPENSSH PRIVATE KEY", nil},
+	}
+
+	for _, test := range tests {
+		encoded, err := test.key.Encode(test.format)
+		if err != nil {
+			t.Fatalf("failed to encode %s key as %s: %v", test.key.keyType, test.format, err)
+		}
+
+		block, _ := pem.Decode([]byte(encoded))
+		if block == nil || block.Type != test.blockType {
+			t.Fatalf("unexpected PEM block for %s: %v", test.format, block)
+		}
+
+		var parsed any
+		if test.parse != nil {
+			parsed, err = test.parse(block.Bytes)
+		} else {
+			parsed, err = ssh.ParseRawPrivateKey([]byte(encoded))
+		}
+		if err != nil {
+			t.Fatalf("failed to parse %s encoding: %v", test.format, err)
+		}
+
+		original, _ := test.key.PublicKey()
+		restored := &Key{PrivateKey: parsed}
+		if pub, err := restored.PublicKey(); err != nil || !pub.(interface{ Equal(crypto.PublicKey) bool }).Equal(original) {
+			t.Fatalf("%s encoding does not round-trip the key: %v", test.format, err)
+		}
+	}
+
+	if _, err := ed.Encode(FormatSEC1); err == nil {
+		t.Fatalf("expected an error encoding an Ed25519 key as SEC1")
+	}
+
+	if err := ecc.Encrypt(PASSWORD); err != nil {
+		t.Fatalf("failed to encrypt ECC key: %v", err)
+	}
+	if _, err := ecc.Encode(FormatSEC1); err == nil {
+		t.Fatalf("expected an error encoding an encrypted key as SEC1")
+	}
+}
+
+func TestEncodeWithECParameters(t *testing.T) {
+	tests := map[ECCCurveID]asn1.ObjectIdentifier{
+		ECCCurveP256: {1, 2, 840, 10045, 3, 1, 7},
+		ECCCurveP384: {1, 3, 132, 0, 34},
+		ECCCurveP521: {1, 3, 132, 0, 35},
+	}
+	for curve, expectedOID := range tests {
+		k, err := GenerateKeyFromMnemonic(t.Context(), KeyTypeECC, int(curve), SALT, MustParseMnemonic(testMnemonic))
+		if err != nil {
+			t.Fatalf("failed to generate ECC key from mnemonic: %v", err)
+		}
+		encoded, err := k.EncodeWithECParameters(FormatSEC1)
+		if err != nil {
+			t.Fatalf("failed to encode with EC parameters: %v", err)
+		}
+
+		params, rest := pem.Decode([]byte(encoded))
+		if params == nil || params.Type != "EC PARAMETERS" {
+			t.Fatalf("expected an EC PARAMETERS block first, got %v", params)
+		}
+		var oid asn1.ObjectIdentifier
+		if _, err := asn1.Unmarshal(params.Bytes, &oid); err != nil || !oid.Equal(expectedOID) {
+			t.Fatalf("expected the named curve %s, got %s (%v)", expectedOID, oid, err)
+		}
+		if block, _ := pem.Decode(rest); block == nil || block.Type != "EC PRIVATE KEY" {
+			t.Fatalf("expected the EC PRIVATE KEY block after the parameters, got %v", block)
+		}
+
+		// the combined output loads back to the same key
+		loaded, err := LoadKeyFromPEM([]byte(encoded), "")
+		if err != nil {
+			t.Fatalf("failed to load the key with EC parameters: %v", err)
+		}
+		if !bytes.Equal(loaded.Der, k.Der) {
+			t.Fatalf("the key with EC parameters does not round-trip")
+		}
+	}
+
+	ecc, err := GenerateKeyFromMnemonic(t.Context(), KeyTypeECC, int(ECCCurveP256), SALT, MustParseMnemonic(testMnemonic))
+	if err != nil {
+		t.Fatalf("failed to generate ECC key from mnemonic: %v", err)
+	}
+	if _, err := ecc.EncodeWithECParameters(FormatPKCS8); err == nil {
+		t.Fatalf("expected an error for EC parameters with a format other than SEC1")
+	}
+	ed, err := GenerateKeyFromMnemonic(t.Context(), KeyTypeECC, int(ECCCurveEd25519), SALT, MustParseMnemonic(testMnemonic))
+	if err != nil {
+		t.Fatalf("failed to generate Ed25519 key from mnemonic: %v", err)
+	}
+	if _, err := ed.ECParametersPEM(); err == nil {
+		t.Fatalf("expected an error for the EC parameters of an Ed25519 key")
+	}
+}
+
+func TestWriteFormat(t *testing.T) {
+	ecc, err := GenerateKey(t.Context(), KeyTypeECC, int(ECCCurveP256), SALT)
+	if err != nil {
+		t.Fatalf("failed to generate ECC key: %v", err)
+	}
+	rsa, err := GenerateKey(t.Context(), KeyTypeRSA, int(RSAKey2048), SALT)
+	if err != nil {
+		t.Fatalf("failed to generate RSA key: %v", err)
+	}
+
+	var _ io.WriterTo = ecc
+
+	tests := []struct {
+		key    *Key
+		format Format
+	}{
+		{ecc, FormatPKCS8},
+		{ecc, FormatSEC1},
+		{ecc, FormatOpenSSH},
+		{rsa, FormatPKCS8},
+		{rsa, FormatPKCS1},
+		{rsa, FormatOpenSSH},
+	}
+
+	for _, test := range tests {
+		var buf bytes.Buffer
+		n, err := test.key.WriteFormat(&buf, test.format)
+		if err != nil {
+			t.Fatalf("failed to write %s key as %s: %v", test.key.keyType, test.format, err)
+		}
+		if n != int64(buf.Len()) {
+			t.Fatalf("%s: reported %d bytes written, found %d", test.format, n, buf.Len())
+		}
+
+		// the OpenSSH container has random check bytes, so only its length is reproducible
+		encoded, err := test.key.Encode(test.format)
+		if err != nil {
+			t.Fatalf("failed to encode %s key as %s: %v", test.key.keyType, test.format, err)
+		}
+		if test.format == FormatOpenSSH {
+			if len(encoded) != buf.Len() {
+				t.Fatalf("%s: wrote %d bytes, expected %d", test.format, buf.Len(), len(encoded))
+			}
+		} else if encoded != buf.String() {
+			t.Fatalf("%s: written encoding does not match Encode", test.format)
+		}
+	}
+
+	// WriteTo writes the (encrypted) PKCS#8 encoding
+	if err := ecc.Encrypt(PASSWORD); err != nil {
+		t.Fatalf("failed to encrypt ECC key: %v", err)
+	}
+	var buf bytes.Buffer
+	n, err := ecc.WriteTo(&buf)
+	if err != nil {
+		t.Fatalf("failed to write encrypted key: %v", err)
+	}
+	if n != int64(buf.Len()) || buf.String() != ecc.PEM() {
+		t.Fatalf("WriteTo does not match the PEM encoding")
+	}
+	if _, err := ecc.WriteFormat(io.Discard, FormatSEC1); err == nil {
+		t.Fatalf("expected an error writing an encrypted key as SEC1")
+	}
+}
+
+// Expected encoding lengths in bytes, pinning the exact shape of the marshaled keys so that a change in x509
+// marshaling is caught even where a fingerprint check would not look. EC and Ed25519 encodings have a fixed length
+// per curve, since the private scalar and public point are zero-padded to the field size. RSA lengths depend on the
+// leading bits of each integer, so the RSA-2048 values are those of the test mnemonic's key.
+const (
+	// PKCS#8 PrivateKeyInfo wrapping a SEC1 ECPrivateKey with the uncompressed public point, the curve OID only in the
+	// AlgorithmIdentifier
+	P256_PKCS8_DER_LENGTH = 138
+	P384_PKCS8_DER_LENGTH = 185
+	P521_PKCS8_DER_LENGTH = 241
+	// PKCS#8 PrivateKeyInfo wrapping the 32-byte seed as an OCTET STRING (RFC 8410), without the public key
+	ED25519_PKCS8_DER_LENGTH = 48
+	// PKCS#8 PrivateKeyInfo wrapping a PKCS#1 RSAPrivateKey with all CRT values
+	RSA2048_PKCS8_DER_LENGTH = 1218
+
+	// SEC1 ECPrivateKey with the curve OID and the uncompressed public point
+	P256_SEC1_DER_LENGTH = 121
+	P384_SEC1_DER_LENGTH = 167
+	P521_SEC1_DER_LENGTH = 223
+
+	// SubjectPublicKeyInfo, as used for the fingerprint
+	P256_SPKI_DER_LENGTH    = 91
+	P384_SPKI_DER_LENGTH    = 120
+	P521_SPKI_DER_LENGTH    = 158
+	ED25519_SPKI_DER_LENGTH = 44
+	RSA2048_SPKI_DER_LENGTH = 294
+
+	// "PRIVATE KEY" PEM of the PKCS#8 DER, base64 in 64-character lines
+	P256_PKCS8_PEM_LENGTH    = 241
+	P384_PKCS8_PEM_LENGTH    = 306
+	P521_PKCS8_PEM_LENGTH    = 384
+	ED25519_PKCS8_PEM_LENGTH = 119
+	RSA2048_PKCS8_PEM_LENGTH = 1704
+)
+
+func TestEncodingLengths(t *testing.T) {
+	tests := []struct {
+		keyType  KeyType
+		keyId    int
+		pkcs8DER int
+		pkcs8PEM int
+		spkiDER  int
+		sec1DER  int // 0 when the key has no SEC1 encoding
+	}{
+		{KeyTypeECC, int(ECCCurveP256), P256_PKCS8_DER_LENGTH, P256_PKCS8_PEM_LENGTH, P256_SPKI_DER_LENGTH, P256_SEC1_DER_LENGTH},
+		{KeyTypeECC, int(ECCCurveP384), P384_PKCS8_DER_LENGTH, P384_PKCS8_PEM_LENGTH, P384_SPKI_DER_LENGTH, P384_SEC1_DER_LENGTH},
+		{KeyTypeECC, int(ECCCurveP521), P521_PKCS8_DER_LENGTH, P521_PKCS8_PEM_LENGTH, P521_SPKI_DER_LENGTH, P521_SEC1_DER_LENGTH},
+		{KeyTypeECC, int(ECCCurveEd25519), ED25519_PKCS8_DER_LENGTH, ED25519_PKCS8_PEM_LENGTH, ED25519_SPKI_DER_LENGTH, 0},
+		{KeyTypeRSA, int(RSAKey2048), RSA2048_PKCS8_DER_LENGTH, RSA2048_PKCS8_PEM_LENGTH, RSA2048_SPKI_DER_LENGTH, 0},
+	}
+
+	for _, tt := range tests {
+		key, err := v2Generator(t).GenerateKeyFromMnemonic(t.Context(), tt.keyType, tt.keyId, SALT, MustParseMnemonic(testMnemonic))
+		if err != nil {
+			t.Fatalf("failed to generate %s key %d: %v", tt.keyType, tt.keyId, err)
+		}
+		if len(key.Der) != tt.pkcs8DER {
+			t.Fatalf("%s key %d: PKCS#8 DER is %d bytes, expected %d", tt.keyType, tt.keyId, len(key.Der), tt.pkcs8DER)
+		}
+
+		encoded, err := key.Encode(FormatPKCS8)
+		if err != nil {
+			t.Fatalf("%s key %d: failed to encode PKCS#8: %v", tt.keyType, tt.keyId, err)
+		}
+		if len(encoded) != tt.pkcs8PEM {
+			t.Fatalf("%s key %d: PKCS#8 PEM is %d bytes, expected %d", tt.keyType, tt.keyId, len(encoded), tt.pkcs8PEM)
+		}
+
+		spki, err := key.PublicKeyDER()
+		if err != nil {
+			t.Fatalf("%s key %d: failed to marshal public key: %v", tt.keyType, tt.keyId, err)
+		}
+		if len(spki) != tt.spkiDER {
+			t.Fatalf("%s key %d: SubjectPublicKeyInfo DER is %d bytes, expected %d", tt.keyType, tt.keyId, len(spki), tt.spkiDER)
+		}
+
+		if tt.sec1DER == 0 {
+			continue
+		}
+		encoded, err = key.Encode(FormatSEC1)
+		if err != nil {
+			t.Fatalf("%s key %d: failed to encode SEC1: %v", tt.keyType, tt.keyId, err)
+		}
+		block, _ := pem.Decode([]byte(encoded))
+		if block == nil || len(block.Bytes) != tt.sec1DER {
+			t.Fatalf("%s key %d: unexpected SEC1 DER length, expected %d", tt.keyType, tt.keyId, tt.sec1DER)
+		}
+	}
+}
+
+func TestKeyComment(t *testing.T) {
+	key, err := GenerateLabeledKeyFromMnemonic(t.Context(), KeyTypeECC, int(ECCCurveEd25519), SALT, MustParseMnemonic(testMnemonic), KeyLabel{Identity: "root-ca", Index: 2})
+	if err != nil {
+		t.Fatalf("failed to generate Ed25519 key from mnemonic: %v", err)
+	}
+	fingerprint := key.Fingerprint()
+	if comment := key.Comment(); comment != "bipkey:root-ca:2" {
+		t.Fatalf("unexpected default key comment: %q", comment)
+	}
+
+	key.SetComment("ops@example.com")
+	for _, format := range []Format{FormatPKCS8, FormatOpenSSH} {
+		encoded, err := key.Encode(format)
+		if err != nil {
+			t.Fatalf("failed to encode key as %s: %v", format, err)
+		}
+		block, _ := pem.Decode([]byte(encoded))
+		// only the OpenSSH format carries a comment
+		if contains := bytes.Contains(block.Bytes, []byte("ops@example.com")); contains != (format == FormatOpenSSH) {
+			t.Fatalf("unexpected comment presence %v in the %s encoding", contains, format)
+		}
+		loaded, err := LoadKeyFromPEM([]byte(encoded), "")
+		if err != nil {
+			t.Fatalf("failed to load %s key: %v", format, err)
+		}
+		if loaded.Fingerprint() != fingerprint {
+			t.Fatalf("expected the comment not to change the %s key", format)
+		}
+		// an OpenSSH key keeps its comment, the others only name bipkey
+		expected := KEY_COMMENT_PREFIX
+		if format == FormatOpenSSH {
+			expected = "ops@example.com"
+		}
+		if loaded.Comment() != expected {
+			t.Fatalf("unexpected comment of a loaded %s key: %q", format, loaded.Comment())
+		}
+	}
+
+	line, err := key.AuthorizedKey()
+	if err != nil {
+		t.Fatalf("failed to encode authorized key: %v", err)
+	}
+	pub, comment, _, _, err := ssh.ParseAuthorizedKey([]byte(line))
+	if err != nil {
+		t.Fatalf("failed to parse authorized key %q: %v", line, err)
+	}
+	if comment != "ops@example.com" || pub.Type() != ssh.KeyAlgoED25519 {
+		t.Fatalf("unexpected authorized key: %q", line)
+	}
+
+	// the comment is kept in the metadata, which describes the key as written
+	metadata, err := key.Metadata(FormatOpenSSH)
+	if err != nil {
+		t.Fatalf("failed to get key metadata: %v", err)
+	}
+	if metadata.Comment != "ops@example.com" {
+		t.Fatalf("unexpected metadata comment: %q", metadata.Comment)
+	}
+
+	key.SetComment("")
+	if key.Comment() != "bipkey:root-ca:2" {
+		t.Fatalf("expected an empty comment to restore the default, got %q", key.Comment())
+	}
+}
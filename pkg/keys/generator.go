@@ -0,0 +1,260 @@
+package keys
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"hash"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/tyler-smith/go-bip39"
+)
+
+const BIP39_WORD_COUNT = 2048
+const DEFAULT_DISPLAY_COLUMNS = 6
+
+// DISPLAY_NUMBER_WIDTH is the width of the word number before each word of the mnemonic display, e.g. "01: "
+const DISPLAY_NUMBER_WIDTH = 4
+
+// Config holds the settings used to construct a Generator. Zero values select the defaults.
+type Config struct {
+	WordList []string         // BIP-39 word list, defaults to the English word list
+	Hash     func() hash.Hash // hash function used by HKDF, defaults to SHA-256
+	Columns  int              // number of columns used when displaying the mnemonic, 0 fits them to Width
+	Width    int              // terminal width in characters the display columns are fitted to, 0 displays 6 columns
+
+	GroupSize      int    // words per group of the plain mnemonic display, 0 shows all words on one line
+	GroupSeparator string // separator between the word groups, defaults to a newline
+
+	Derivation         DerivationVersion // key derivation version, defaults to DEFAULT_DERIVATION
+	MaxPrimeCandidates int               // candidates drawn per RSA prime before giving up, defaults to DEFAULT_MAX_PRIME_CANDIDATES
+	Scrypt             *ScryptParams     // scrypt stretch of the BIP-39 seed before HKDF, disabled when nil
+	EntropySeed        bool              // use the mnemonic entropy as the HKDF secret instead of the BIP-39 seed
+	RSAExponent        RSAExponent       // public exponent of RSA keys, defaults to RSA_PUBLIC_EXPONENT without fallback
+	StreamBufferSize   int               // keystream bytes per ChaCha20 call, defaults to DEFAULT_STREAM_BUFFER_SIZE; does not change keys
+}
+
+// Generator generates and restores deterministic keys using a fixed configuration.
+// A Generator is immutable once created and is safe for concurrent use by multiple goroutines.
+// The package-level lookup tables are built during package initialization and never modified,
+// and the global go-bip39 word list is only read, so keys may be generated concurrently with
+// any number of generators. A Key itself is not safe for concurrent mutation (Encrypt/Decrypt).
+type Generator struct {
+	words       []string
+	hash        func() hash.Hash
+	columns     int // fixed display columns, 0 to fit them to the width
+	width       int
+	cellWidth   int // display width of a numbered word, sized to the longest word
+	groupSize   int
+	groupSep    string
+	formatWord  string
+	derivation  DerivationVersion
+	maxPrimes   int
+	scrypt      *ScryptParams
+	entropySeed bool
+	rsaExponent RSAExponent
+	streamBuf   int
+}
+
+// defaultGenerator backs the package-level functions
+var defaultGenerator = mustNewGenerator(Config{})
+
+// NewGenerator creates a Generator from the provided configuration
+func NewGenerator(cfg Config) (*Generator, error) {
+	words := cfg.WordList
+	if words == nil {
+		words = bip39.GetWordList()
+	}
+	if len(words) != BIP39_WORD_COUNT {
+		return nil, fmt.Errorf("word list must have %d words, found %d", BIP39_WORD_COUNT, len(words))
+	}
+
+	h := cfg.Hash
+	if h == nil {
+		h = sha256.New
+	}
+
+	if cfg.Columns < 0 || cfg.Width < 0 {
+		return nil, fmt.Errorf("display columns and width must not be negative, found %d and %d", cfg.Columns, cfg.Width)
+	}
+
+	if cfg.GroupSize < 0 {
+		return nil, fmt.Errorf("mnemonic group size must not be negative, found %d", cfg.GroupSize)
+	}
+	groupSep := cfg.GroupSeparator
+	if groupSep == "" {
+		groupSep = "\n"
+	}
+
+	derivation := cfg.Derivation
+	if derivation == DerivationNone {
+		derivation = DEFAULT_DERIVATION
+	}
+	if derivation < DerivationNone || derivation >= derivationEnd {
+		return nil, fmt.Errorf("unsupported derivation version: %d", int(derivation))
+	}
+
+	maxPrimes := cfg.MaxPrimeCandidates
+	if maxPrimes <= 0 {
+		maxPrimes = DEFAULT_MAX_PRIME_CANDIDATES
+	}
+
+	var stretch *ScryptParams
+	if cfg.Scrypt != nil {
+		if err := cfg.Scrypt.validate(); err != nil {
+			return nil, err
+		}
+		params := *cfg.Scrypt
+		stretch = &params
+	}
+
+	exponent := cfg.RSAExponent
+	if exponent.E == 0 {
+		exponent.E = RSA_PUBLIC_EXPONENT
+	}
+	if err := exponent.validate(); err != nil {
+		return nil, err
+	}
+	if derivation == DerivationV1 && exponent.E != RSA_PUBLIC_EXPONENT {
+		return nil, fmt.Errorf("a custom RSA public exponent requires derivation %s or later", DerivationV2)
+	}
+
+	streamBuf := cfg.StreamBufferSize
+	if streamBuf == 0 {
+		streamBuf = DEFAULT_STREAM_BUFFER_SIZE
+	}
+	if streamBuf < 1 || streamBuf > MAX_STREAM_BUFFER_SIZE {
+		return nil, fmt.Errorf("stream buffer size must be between 1 and %d bytes, found %d", MAX_STREAM_BUFFER_SIZE, streamBuf)
+	}
+
+	g := &Generator{
+		hash:        h,
+		columns:     cfg.Columns,
+		width:       cfg.Width,
+		groupSize:   cfg.GroupSize,
+		groupSep:    groupSep,
+		derivation:  derivation,
+		maxPrimes:   maxPrimes,
+		scrypt:      stretch,
+		entropySeed: cfg.EntropySeed,
+		rsaExponent: exponent,
+		streamBuf:   streamBuf,
+	}
+	g.setWordList(words)
+
+	return g, nil
+}
+
+// setWordList copies the word list into the generator, so later changes by the caller cannot affect it, and sizes
+// the display columns to its longest word
+func (g *Generator) setWordList(words []string) {
+	g.words = append([]string(nil), words...)
+
+	longestWordLen := 0
+	for _, word := range g.words {
+		if n := utf8.RuneCountInString(word); n > longestWordLen {
+			longestWordLen = n
+		}
+	}
+	g.formatWord = fmt.Sprintf("%%02d: %%-%ds", longestWordLen+1)
+	g.cellWidth = DISPLAY_NUMBER_WIDTH + longestWordLen + 1
+}
+
+// WithWordList returns a copy of the generator that uses the given BIP-39 word list, e.g. that of a detected language
+func (g *Generator) WithWordList(words []string) (*Generator, error) {
+	if len(words) != BIP39_WORD_COUNT {
+		return nil, fmt.Errorf("word list must have %d words, found %d", BIP39_WORD_COUNT, len(words))
+	}
+	clone := *g
+	clone.setWordList(words)
+	return &clone, nil
+}
+
+// mustNewGenerator creates a Generator and panics on an invalid configuration
+func mustNewGenerator(cfg Config) *Generator {
+	g, err := NewGenerator(cfg)
+	if err != nil {
+		panic(err)
+	}
+	return g
+}
+
+// DefaultGenerator returns the Generator used by the package-level functions
+func DefaultGenerator() *Generator {
+	return defaultGenerator
+}
+
+// WordList returns a copy of the generator's BIP-39 word list
+func (g *Generator) WordList() []string {
+	return append([]string(nil), g.words...)
+}
+
+// Derivation returns the generator's key derivation version
+func (g *Generator) Derivation() DerivationVersion {
+	return g.derivation
+}
+
+// RSAExponent returns the generator's RSA public exponent selection
+func (g *Generator) RSAExponent() RSAExponent {
+	return g.rsaExponent
+}
+
+// EntropySeed reports whether keys are derived from the mnemonic entropy instead of the BIP-39 seed
+func (g *Generator) EntropySeed() bool {
+	return g.entropySeed
+}
+
+// Scrypt returns a copy of the generator's scrypt seed stretch parameters, or nil if the seed is not stretched
+func (g *Generator) Scrypt() *ScryptParams {
+	if g.scrypt == nil {
+		return nil
+	}
+	params := *g.scrypt
+	return &params
+}
+
+// FormatMnemonicGroups returns the plain mnemonic words in the generator's word groups, or on one line if grouping is
+// disabled, as shown below the numbered display and written to mnemonic files
+func (g *Generator) FormatMnemonicGroups(m Mnemonic) string {
+	return m.Grouped(g.groupSize, g.groupSep)
+}
+
+// DisplayColumns returns the number of columns of the numbered mnemonic display: the configured columns, the most
+// that fit the configured width, or DEFAULT_DISPLAY_COLUMNS if neither is set
+func (g *Generator) DisplayColumns() int {
+	if g.columns > 0 {
+		return g.columns
+	}
+	if g.width <= 0 {
+		return DEFAULT_DISPLAY_COLUMNS
+	}
+	return FitColumns(g.width, g.cellWidth)
+}
+
+// FitColumns returns the most columns of cellWidth characters that fit in width. It picks a divisor of
+// MNEMONIC_WORD_COUNT, so that every row of the grid is full, and at least one column however narrow the width.
+func FitColumns(width, cellWidth int) int {
+	columns := 1
+	for n := 2; n <= MNEMONIC_WORD_COUNT; n++ {
+		if MNEMONIC_WORD_COUNT%n == 0 && n*cellWidth <= width {
+			columns = n
+		}
+	}
+	return columns
+}
+
+// formatMnemonic returns the numbered mnemonic words laid out in the generator's display columns
+func (g *Generator) formatMnemonic(m Mnemonic) string {
+	columns := g.DisplayColumns()
+	var builder strings.Builder
+	for i, word := range m {
+		fmt.Fprintf(&builder, g.formatWord, i+1, word)
+		if i%columns == columns-1 {
+			builder.WriteString("\n")
+		}
+	}
+	if len(m)%columns != 0 {
+		builder.WriteString("\n")
+	}
+	return builder.String()
+}
@@ -0,0 +1,299 @@
+package keys
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/sha512"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/tyler-smith/go-bip39"
+	"golang.org/x/crypto/hkdf"
+)
+
+func TestNewGeneratorRejectsInvalidWordList(t *testing.T) {
+	if _, err := NewGenerator(Config{WordList: []string{"abandon", "ability"}}); err == nil {
+		t.Fatalf("expected an error for a short word list")
+	}
+}
+
+func TestMnemonicFromEntropyMatchesBIP39(t *testing.T) {
+	entropy := make([]byte, MNEMONIC_ENTROPY_BITS/8)
+	for i := range entropy {
+		entropy[i] = byte(i * 7)
+	}
+
+	expected, err := bip39.NewMnemonic(entropy)
+	if err != nil {
+		t.Fatalf("failed to create reference mnemonic: %v", err)
+	}
+
+	m, err := defaultGenerator.mnemonicFromEntropy(entropy)
+	if err != nil {
+		t.Fatalf("failed to create mnemonic: %v", err)
+	}
+
+	if m.String() != expected {
+		t.Fatalf("mnemonic mismatch:\n got %s\nwant %s", m.String(), expected)
+	}
+}
+
+func TestGeneratorCustomWordList(t *testing.T) {
+	// a reversed English list keeps the 4-letter prefix property but maps indices differently
+	words := bip39.GetWordList()
+	reversed := slices.Clone(words)
+	slices.Reverse(reversed)
+
+	g, err := NewGenerator(Config{WordList: reversed})
+	if err != nil {
+		t.Fatalf("failed to create generator: %v", err)
+	}
+
+	// modifying the caller's slice must not affect the generator
+	reversed[0] = "modified"
+	if g.WordList()[0] != words[len(words)-1] {
+		t.Fatalf("generator word list was modified through the caller's slice")
+	}
+
+	k1, err := g.GenerateKey(t.Context(), KeyTypeECC, int(ECCCurveP256), SALT)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	idx, _, err := g.GetWordIndex(k1.mnemonic[0])
+	if err != nil {
+		t.Fatalf("generated word not found in custom word list: %v", err)
+	}
+	defaultIdx, _, _ := GetWordIndex(k1.mnemonic[0])
+	if idx != BIP39_WORD_COUNT-1-defaultIdx {
+		t.Fatalf("unexpected index %d for word %q in reversed word list", idx, k1.mnemonic[0])
+	}
+
+	m, err := g.ParseMnemonic(strings.ToUpper(k1.mnemonic.Short().String()))
+	if err != nil {
+		t.Fatalf("failed to parse short mnemonic: %v", err)
+	}
+
+	k2, err := g.GenerateKeyFromMnemonic(t.Context(), KeyTypeECC, int(ECCCurveP256), SALT, m)
+	if err != nil {
+		t.Fatalf("failed to restore key: %v", err)
+	}
+
+	if k1.Fingerprint() != k2.Fingerprint() {
+		t.Fatalf("restored key does not match: %s != %s", k1.Fingerprint(), k2.Fingerprint())
+	}
+}
+
+func TestGeneratorHashChangesDerivation(t *testing.T) {
+	m := MustParseMnemonic("away mistake dance place sword title nurse diary skin soon figure sense force seat inform hedgehog debate around tortoise detail uncle situate draft wait")
+
+	g, err := NewGenerator(Config{Hash: sha512.New})
+	if err != nil {
+		t.Fatalf("failed to create generator: %v", err)
+	}
+
+	k1, err := GenerateKeyFromMnemonic(t.Context(), KeyTypeECC, int(ECCCurveP256), SALT, m)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	k2, err := g.GenerateKeyFromMnemonic(t.Context(), KeyTypeECC, int(ECCCurveP256), SALT, m)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	if k1.Fingerprint() == k2.Fingerprint() {
+		t.Fatalf("expected a different key when using HKDF-SHA512")
+	}
+}
+
+func TestEntropySeedDeterminism(t *testing.T) {
+	g, err := NewGenerator(Config{Derivation: DerivationV2, EntropySeed: true})
+	if err != nil {
+		t.Fatalf("failed to create generator: %v", err)
+	}
+	mnemonic := MustParseMnemonic(testMnemonic)
+
+	// the HKDF secret is the mnemonic entropy itself, with the salt only as the HKDF salt
+	entropy, err := bip39.EntropyFromMnemonic(testMnemonic)
+	if err != nil {
+		t.Fatalf("failed to read entropy: %v", err)
+	}
+	expected := make([]byte, 32)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, entropy, []byte(SALT), []byte(SECRET_INFO_PREFIX+"test")), expected); err != nil {
+		t.Fatalf("failed to compute HKDF: %v", err)
+	}
+	secret, err := g.DeriveSecret(t.Context(), SALT, mnemonic, "test", 32)
+	if err != nil {
+		t.Fatalf("failed to derive secret: %v", err)
+	}
+	if !bytes.Equal(secret, expected) {
+		t.Fatalf("unexpected entropy seed secret: got %x, want %x", secret, expected)
+	}
+
+	fingerprint := func(g *Generator, keyType KeyType, keyId int, salt string) string {
+		key, err := g.GenerateKeyFromMnemonic(t.Context(), keyType, keyId, salt, mnemonic)
+		if err != nil {
+			t.Fatalf("failed to generate key: %v", err)
+		}
+		fp, err := key.PublicFingerprint(FingerprintHex)
+		if err != nil {
+			t.Fatalf("failed to compute fingerprint: %v", err)
+		}
+		return fp
+	}
+
+	// known answers pin the mode, which must never change for existing keys
+	tests := []struct {
+		keyType     KeyType
+		keyId       int
+		fingerprint string
+	}{
+		{KeyTypeECC, int(ECCCurveP256), "7ca6eea47d339bed11c4f07840d09626cc7f8c1afd22fa051f5cab5563deb0a6"},
+		{KeyTypeECC, int(ECCCurveEd25519), "20564cb049ef49e94273f3f563db580c54016f05ed9d78d149a6b5fbd9e37399"},
+		{KeyTypeRSA, int(RSAKey2048), "61ea29b8999a92de8925cdbf3c794e2fa40409f93990b3ca9a2e41d10a4714c7"},
+	}
+	for _, tt := range tests {
+		fp := fingerprint(g, tt.keyType, tt.keyId, SALT)
+		if fp != tt.fingerprint {
+			t.Fatalf("unexpected %s key %d fingerprint: got %s, want %s", tt.keyType, tt.keyId, fp, tt.fingerprint)
+		}
+		if fingerprint(g, tt.keyType, tt.keyId, SALT) != fp {
+			t.Fatalf("%s key %d is not reproducible", tt.keyType, tt.keyId)
+		}
+		if fingerprint(v2Generator(t), tt.keyType, tt.keyId, SALT) == fp {
+			t.Fatalf("the entropy seed derived the same %s key %d as the BIP-39 seed", tt.keyType, tt.keyId)
+		}
+	}
+
+	// the salt still selects the key
+	if fingerprint(g, KeyTypeECC, int(ECCCurveP256), "other-salt") == tests[0].fingerprint {
+		t.Fatalf("the salt did not change the entropy seed key")
+	}
+
+	key, err := g.GenerateKeyFromMnemonic(t.Context(), KeyTypeECC, int(ECCCurveP256), SALT, mnemonic)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	if !key.EntropySeed() || key.DerivationName() != DerivationV2.String()+DERIVATION_ENTROPY_SUFFIX {
+		t.Fatalf("unexpected derivation name %q", key.DerivationName())
+	}
+	record, err := key.RecoveryRecord()
+	if err != nil || !record.EntropySeed {
+		t.Fatalf("expected the recovery record to note the entropy seed: %+v, %v", record, err)
+	}
+
+	path, err := ParseDerivationPath("m/44'/0'/0'")
+	if err != nil {
+		t.Fatalf("failed to parse path: %v", err)
+	}
+	if _, err := g.GeneratePathKeyFromMnemonic(t.Context(), KeyTypeECC, int(ECCCurveP256), SALT, mnemonic, path); err == nil {
+		t.Fatalf("expected SLIP-10 derivation to reject the entropy seed")
+	}
+}
+
+func TestConcurrentKeyGeneration(t *testing.T) {
+	const workers = 32
+
+	reversed := slices.Clone(bip39.GetWordList())
+	slices.Reverse(reversed)
+
+	custom, err := NewGenerator(Config{WordList: reversed, Hash: sha512.New})
+	if err != nil {
+		t.Fatalf("failed to create generator: %v", err)
+	}
+
+	curves := []ECCCurveID{ECCCurveP256, ECCCurveP384, ECCCurveP521, ECCCurveEd25519}
+
+	var wg sync.WaitGroup
+	for i := range workers {
+		wg.Go(func() {
+			// alternate between the default and a custom generator to exercise both concurrently
+			g := defaultGenerator
+			if i%2 == 1 {
+				g = custom
+			}
+			curve := curves[i%len(curves)]
+
+			k1, err := g.GenerateKey(t.Context(), KeyTypeECC, int(curve), SALT)
+			if err != nil {
+				t.Errorf("failed to generate key: %v", err)
+				return
+			}
+			if err := k1.Encrypt(PASSWORD); err != nil {
+				t.Errorf("failed to encrypt key: %v", err)
+				return
+			}
+			if err := k1.Decrypt(PASSWORD); err != nil {
+				t.Errorf("failed to decrypt key: %v", err)
+				return
+			}
+
+			m, err := g.ParseMnemonic(k1.mnemonic.String())
+			if err != nil {
+				t.Errorf("failed to parse mnemonic: %v", err)
+				return
+			}
+
+			k2, err := g.GenerateKeyFromMnemonic(t.Context(), KeyTypeECC, int(curve), SALT, m)
+			if err != nil {
+				t.Errorf("failed to restore key: %v", err)
+				return
+			}
+
+			if k1.Fingerprint() != k2.Fingerprint() {
+				t.Errorf("restored key does not match: %s != %s", k1.Fingerprint(), k2.Fingerprint())
+			}
+		})
+	}
+	wg.Wait()
+}
+
+func TestDisplayColumns(t *testing.T) {
+	// a numbered English word is 13 characters wide: "01: " and the 8-letter longest word, padded by one
+	tests := []struct {
+		width   int
+		columns int
+	}{
+		{200, 12},
+		{80, 6},
+		{78, 6},
+		{77, 4},
+		{40, 3},
+		{30, 2},
+		{5, 1},
+	}
+	for _, tt := range tests {
+		if columns := FitColumns(tt.width, 13); columns != tt.columns {
+			t.Fatalf("unexpected columns for width %d: got %d, want %d", tt.width, columns, tt.columns)
+		}
+	}
+
+	mnemonic := MustParseMnemonic(testMnemonic)
+	for _, tt := range []struct {
+		cfg     Config
+		columns int
+	}{
+		{Config{}, DEFAULT_DISPLAY_COLUMNS},
+		{Config{Width: 40}, 3},
+		{Config{Columns: 4, Width: 200}, 4},
+	} {
+		g, err := NewGenerator(tt.cfg)
+		if err != nil {
+			t.Fatalf("failed to create generator: %v", err)
+		}
+		if columns := g.DisplayColumns(); columns != tt.columns {
+			t.Fatalf("unexpected display columns for %+v: got %d, want %d", tt.cfg, columns, tt.columns)
+		}
+		lines := strings.Split(strings.TrimSuffix(g.formatMnemonic(mnemonic), "\n"), "\n")
+		if len(lines) != MNEMONIC_WORD_COUNT/tt.columns {
+			t.Fatalf("expected %d rows of %d words, got:\n%s", MNEMONIC_WORD_COUNT/tt.columns, tt.columns, strings.Join(lines, "\n"))
+		}
+	}
+
+	if _, err := NewGenerator(Config{Columns: -1}); err == nil {
+		t.Fatalf("expected negative display columns to be rejected")
+	}
+}
@@ -0,0 +1,170 @@
+package keys
+
+import (
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"fmt"
+)
+
+// object identifiers of the PKCS#5 v2.1 (RFC 8018) and RFC 7914 encryption schemes, KDFs, PRFs, and ciphers
+var (
+	oidPBES2  = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 13}
+	oidPBKDF2 = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 12}
+	oidScrypt = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11591, 4, 11}
+	oidSHA1   = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 7}
+	oidSHA224 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 8}
+	oidSHA256 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 9}
+	oidSHA384 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 10}
+	oidSHA512 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 11}
+)
+
+// encryptionAlgorithm describes an algorithm identified in an encrypted PKCS#8 key
+type encryptionAlgorithm struct {
+	oid       asn1.ObjectIdentifier
+	name      string
+	supported bool // whether bipkey can decrypt keys using it
+}
+
+// encryptionAlgorithms lists the known PBES2 KDFs, PRFs, ciphers, and the legacy PBES1 and PKCS#12 schemes
+var encryptionAlgorithms = []encryptionAlgorithm{
+	{oidPBES2, "PBES2", true},
+	{oidPBKDF2, "PBKDF2", true},
+	{oidScrypt, "scrypt", true},
+	{oidSHA1, "HMAC-SHA1", true},
+	{oidSHA224, "HMAC-SHA224", true},
+	{oidSHA256, "HMAC-SHA256", true},
+	{oidSHA384, "HMAC-SHA384", true},
+	{oidSHA512, "HMAC-SHA512", true},
+	{asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 12}, "HMAC-SHA512/224", false},
+	{asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 13}, "HMAC-SHA512/256", false},
+	{asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 2}, "AES-128-CBC", true},
+	{asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 6}, "AES-128-GCM", true},
+	{asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 22}, "AES-192-CBC", true},
+	{asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 26}, "AES-192-GCM", true},
+	{asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 42}, "AES-256-CBC", true},
+	{asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 46}, "AES-256-GCM", true},
+	{asn1.ObjectIdentifier{1, 2, 840, 113549, 3, 7}, "DES-EDE3-CBC", true},
+	{asn1.ObjectIdentifier{1, 3, 14, 3, 2, 7}, "DES-CBC", false},
+	{asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 3}, "PBES1 (MD5, DES-CBC)", false},
+	{asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 10}, "PBES1 (SHA-1, DES-CBC)", false},
+	{asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 1, 3}, "PKCS#12 (SHA-1, 3-key 3DES-CBC)", false},
+	{asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 1, 6}, "PKCS#12 (SHA-1, RC2-40-CBC)", false},
+}
+
+// lookupEncryptionAlgorithm returns the name of the algorithm and whether bipkey supports it. Unknown algorithms
+// are named by their dotted OID.
+func lookupEncryptionAlgorithm(oid asn1.ObjectIdentifier) (string, bool) {
+	for _, alg := range encryptionAlgorithms {
+		if alg.oid.Equal(oid) {
+			return alg.name, alg.supported
+		}
+	}
+	return oid.String(), false
+}
+
+// EncryptionParams describes how an encrypted PKCS#8 key is protected, as read without the password
+type EncryptionParams struct {
+	Scheme     string `json:"scheme"`               // encryption scheme, e.g. "PBES2"
+	KDF        string `json:"kdf,omitempty"`        // PBES2 key derivation function, "PBKDF2" or "scrypt"
+	PRF        string `json:"prf,omitempty"`        // PBKDF2 pseudorandom function, e.g. "HMAC-SHA256"
+	Iterations int    `json:"iterations,omitempty"` // PBKDF2 iteration count
+	ScryptN    int    `json:"scrypt_n,omitempty"`
+	ScryptR    int    `json:"scrypt_r,omitempty"`
+	ScryptP    int    `json:"scrypt_p,omitempty"`
+	SaltSize   int    `json:"salt_size,omitempty"`  // KDF salt length in bytes
+	KeyLength  int    `json:"key_length,omitempty"` // explicit KDF output length in bytes, if given
+	Cipher     string `json:"cipher,omitempty"`     // PBES2 encryption scheme, e.g. "AES-256-CBC"
+	Supported  bool   `json:"supported"`            // whether bipkey can decrypt the key with the right password
+}
+
+// encryptedPrivateKeyInfo is the PKCS#8 EncryptedPrivateKeyInfo structure (RFC 5958)
+type encryptedPrivateKeyInfo struct {
+	Algorithm     pkix.AlgorithmIdentifier
+	EncryptedData []byte
+}
+
+// pbes2Params are the PBES2-params of RFC 8018
+type pbes2Params struct {
+	KeyDerivationFunc pkix.AlgorithmIdentifier
+	EncryptionScheme  pkix.AlgorithmIdentifier
+}
+
+// pbkdf2Params are the PBKDF2-params of RFC 8018, where the PRF defaults to HMAC-SHA1
+type pbkdf2Params struct {
+	Salt           []byte
+	IterationCount int
+	KeyLength      int                      `asn1:"optional"`
+	PRF            pkix.AlgorithmIdentifier `asn1:"optional"`
+}
+
+// scryptParams are the scrypt-params of RFC 7914
+type scryptParams struct {
+	Salt                     []byte
+	CostParameter            int
+	BlockSize                int
+	ParallelizationParameter int
+	KeyLength                int `asn1:"optional"`
+}
+
+// InspectEncryptedPEM reads the encryption parameters of a PEM "ENCRYPTED PRIVATE KEY" without the password
+func InspectEncryptedPEM(data []byte) (*EncryptionParams, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM block found in the input")
+	}
+	if block.Type != "ENCRYPTED PRIVATE KEY" {
+		return nil, fmt.Errorf("the input is a %q PEM block, not an encrypted PKCS#8 \"ENCRYPTED PRIVATE KEY\"", block.Type)
+	}
+	return InspectEncryptedPKCS8(block.Bytes)
+}
+
+// InspectEncryptedPKCS8 reads the encryption parameters of a DER-encoded EncryptedPrivateKeyInfo without the password
+func InspectEncryptedPKCS8(der []byte) (*EncryptionParams, error) {
+	var info encryptedPrivateKeyInfo
+	if rest, err := asn1.Unmarshal(der, &info); err != nil {
+		return nil, fmt.Errorf("failed to parse encrypted PKCS#8 key: %w", err)
+	} else if len(rest) > 0 {
+		return nil, fmt.Errorf("failed to parse encrypted PKCS#8 key: trailing data")
+	}
+
+	scheme, supported := lookupEncryptionAlgorithm(info.Algorithm.Algorithm)
+	params := &EncryptionParams{Scheme: scheme, Supported: supported}
+	if !info.Algorithm.Algorithm.Equal(oidPBES2) {
+		// legacy schemes are identified, but their parameters are not decoded
+		return params, nil
+	}
+
+	var pbes2 pbes2Params
+	if _, err := asn1.Unmarshal(info.Algorithm.Parameters.FullBytes, &pbes2); err != nil {
+		return nil, fmt.Errorf("failed to parse PBES2 parameters: %w", err)
+	}
+
+	var kdfSupported, cipherSupported bool
+	prfSupported := true
+	params.KDF, kdfSupported = lookupEncryptionAlgorithm(pbes2.KeyDerivationFunc.Algorithm)
+	params.Cipher, cipherSupported = lookupEncryptionAlgorithm(pbes2.EncryptionScheme.Algorithm)
+
+	switch {
+	case pbes2.KeyDerivationFunc.Algorithm.Equal(oidPBKDF2):
+		var kdf pbkdf2Params
+		if _, err := asn1.Unmarshal(pbes2.KeyDerivationFunc.Parameters.FullBytes, &kdf); err != nil {
+			return nil, fmt.Errorf("failed to parse PBKDF2 parameters: %w", err)
+		}
+		params.Iterations, params.SaltSize, params.KeyLength = kdf.IterationCount, len(kdf.Salt), kdf.KeyLength
+		params.PRF = "HMAC-SHA1"
+		if len(kdf.PRF.Algorithm) > 0 {
+			params.PRF, prfSupported = lookupEncryptionAlgorithm(kdf.PRF.Algorithm)
+		}
+	case pbes2.KeyDerivationFunc.Algorithm.Equal(oidScrypt):
+		var kdf scryptParams
+		if _, err := asn1.Unmarshal(pbes2.KeyDerivationFunc.Parameters.FullBytes, &kdf); err != nil {
+			return nil, fmt.Errorf("failed to parse scrypt parameters: %w", err)
+		}
+		params.ScryptN, params.ScryptR, params.ScryptP = kdf.CostParameter, kdf.BlockSize, kdf.ParallelizationParameter
+		params.SaltSize, params.KeyLength = len(kdf.Salt), kdf.KeyLength
+	}
+
+	params.Supported = kdfSupported && prfSupported && cipherSupported
+	return params, nil
+}
@@ -0,0 +1,97 @@
+package keys
+
+import (
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"testing"
+
+	"github.com/youmark/pkcs8"
+)
+
+func TestInspectEncryptedPEM(t *testing.T) {
+	key, err := GenerateKeyFromMnemonic(t.Context(), KeyTypeECC, int(ECCCurveP256), SALT, MustParseMnemonic(testMnemonic))
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	plain, err := key.Encode(FormatPKCS8)
+	if err != nil {
+		t.Fatalf("failed to encode key: %v", err)
+	}
+	if _, err := InspectEncryptedPEM([]byte(plain)); err == nil {
+		t.Fatalf("expected an unencrypted key to be rejected")
+	}
+	if _, err := InspectEncryptedPEM([]byte("not a key")); err == nil {
+		t.Fatalf("expected a non-PEM input to be rejected")
+	}
+
+	if err := key.Encrypt(PASSWORD); err != nil {
+		t.Fatalf("failed to encrypt key: %v", err)
+	}
+	encrypted, err := key.Encode(FormatPKCS8)
+	if err != nil {
+		t.Fatalf("failed to encode key: %v", err)
+	}
+	params, err := InspectEncryptedPEM([]byte(encrypted))
+	if err != nil {
+		t.Fatalf("failed to inspect encrypted key: %v", err)
+	}
+	expected := EncryptionParams{Scheme: "PBES2", KDF: "PBKDF2", PRF: "HMAC-SHA256", Iterations: 10000, SaltSize: 8, Cipher: "AES-256-CBC", Supported: true}
+	if *params != expected {
+		t.Fatalf("unexpected default parameters: %+v", params)
+	}
+}
+
+func TestInspectEncryptedPKCS8Scrypt(t *testing.T) {
+	key, err := GenerateKeyFromMnemonic(t.Context(), KeyTypeECC, int(ECCCurveEd25519), SALT, MustParseMnemonic(testMnemonic))
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	opts := &pkcs8.Opts{Cipher: pkcs8.AES128GCM, KDFOpts: pkcs8.ScryptOpts{SaltSize: 16, CostParameter: 1 << 10, BlockSize: 8, ParallelizationParameter: 1}}
+	if err := key.EncryptWithOpts(PASSWORD, opts); err != nil {
+		t.Fatalf("failed to encrypt key: %v", err)
+	}
+	params, err := InspectEncryptedPKCS8(key.Der)
+	if err != nil {
+		t.Fatalf("failed to inspect encrypted key: %v", err)
+	}
+	expected := EncryptionParams{Scheme: "PBES2", KDF: "scrypt", ScryptN: 1 << 10, ScryptR: 8, ScryptP: 1, SaltSize: 16, Cipher: "AES-128-GCM", Supported: true}
+	if *params != expected {
+		t.Fatalf("unexpected scrypt parameters: %+v", params)
+	}
+}
+
+func TestInspectEncryptedPKCS8Unsupported(t *testing.T) {
+	marshal := func(v any) asn1.RawValue {
+		der, err := asn1.Marshal(v)
+		if err != nil {
+			t.Fatalf("failed to marshal: %v", err)
+		}
+		return asn1.RawValue{FullBytes: der}
+	}
+
+	// PBKDF2 with HMAC-SHA512/256, which bipkey cannot decrypt
+	kdf := pbkdf2Params{Salt: make([]byte, 16), IterationCount: 600000, PRF: pkix.AlgorithmIdentifier{Algorithm: asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 13}, Parameters: asn1.NullRawValue}}
+	pbes2 := pbes2Params{
+		KeyDerivationFunc: pkix.AlgorithmIdentifier{Algorithm: oidPBKDF2, Parameters: marshal(kdf)},
+		EncryptionScheme:  pkix.AlgorithmIdentifier{Algorithm: asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 42}, Parameters: marshal(make([]byte, 16))},
+	}
+	der := marshal(encryptedPrivateKeyInfo{Algorithm: pkix.AlgorithmIdentifier{Algorithm: oidPBES2, Parameters: marshal(pbes2)}, EncryptedData: []byte{1}}).FullBytes
+	params, err := InspectEncryptedPEM(pem.EncodeToMemory(&pem.Block{Type: "ENCRYPTED PRIVATE KEY", Bytes: der}))
+	if err != nil {
+		t.Fatalf("failed to inspect encrypted key: %v", err)
+	}
+	if params.PRF != "HMAC-SHA512/256" || params.Iterations != 600000 || params.Cipher != "AES-256-CBC" || params.Supported {
+		t.Fatalf("unexpected parameters: %+v", params)
+	}
+
+	// a legacy PKCS#12 scheme is named, but not decoded
+	legacy := encryptedPrivateKeyInfo{Algorithm: pkix.AlgorithmIdentifier{Algorithm: asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 1, 3}, Parameters: marshal(kdf)}, EncryptedData: []byte{1}}
+	params, err = InspectEncryptedPKCS8(marshal(legacy).FullBytes)
+	if err != nil {
+		t.Fatalf("failed to inspect legacy key: %v", err)
+	}
+	if params.Scheme != "PKCS#12 (SHA-1, 3-key 3DES-CBC)" || params.Supported {
+		t.Fatalf("unexpected legacy parameters: %+v", params)
+	}
+}
@@ -0,0 +1,89 @@
+package keys
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+)
+
+// JWK is a public JSON Web Key (RFC 7517). Private key components are never included.
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid,omitempty"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+}
+
+// JWKS is a JSON Web Key Set
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// PublicJWK returns the public key as a signing JWK, with "kid" set to the hex SHA-256 public key fingerprint
+func (k Key) PublicJWK() (*JWK, error) {
+	pub, err := k.PublicKey()
+	if err != nil {
+		return nil, err
+	}
+
+	kid, err := k.PublicFingerprint(FingerprintHex)
+	if err != nil {
+		return nil, err
+	}
+
+	b64 := base64.RawURLEncoding.EncodeToString
+	jwk := &JWK{Kid: kid, Use: "sig"}
+
+	switch pub := pub.(type) {
+	case *ecdsa.PublicKey:
+		params := pub.Curve.Params()
+		size := (params.BitSize + 7) / 8
+		jwk.Kty = "EC"
+		jwk.Crv = params.Name
+		jwk.X = b64(pub.X.FillBytes(make([]byte, size)))
+		jwk.Y = b64(pub.Y.FillBytes(make([]byte, size)))
+		switch params.BitSize {
+		case 256:
+			jwk.Alg = "ES256"
+		case 384:
+			jwk.Alg = "ES384"
+		case 521:
+			jwk.Alg = "ES512"
+		}
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = b64(pub)
+		jwk.Alg = "EdDSA"
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = b64(pub.N.Bytes())
+		jwk.E = b64(big.NewInt(int64(pub.E)).Bytes())
+		jwk.Alg = "RS256"
+	default:
+		return nil, fmt.Errorf("unsupported public key type %T", pub)
+	}
+
+	return jwk, nil
+}
+
+// NewJWKS returns a JSON Web Key Set of the public keys
+func NewJWKS(keys ...*Key) (*JWKS, error) {
+	jwks := &JWKS{Keys: make([]JWK, 0, len(keys))}
+	for _, k := range keys {
+		jwk, err := k.PublicJWK()
+		if err != nil {
+			return nil, err
+		}
+		jwks.Keys = append(jwks.Keys, *jwk)
+	}
+	return jwks, nil
+}
@@ -0,0 +1,92 @@
+package keys
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+// publicKeyFromJWK rebuilds the public key described by the JWK
+func publicKeyFromJWK(t *testing.T, jwk JWK) any {
+	decode := func(val string) []byte {
+		b, err := base64.RawURLEncoding.DecodeString(val)
+		if err != nil {
+			t.Fatalf("invalid base64url value %q: %v", val, err)
+		}
+		return b
+	}
+
+	switch jwk.Kty {
+	case "EC":
+		curves := map[string]elliptic.Curve{"P-256": elliptic.P256(), "P-384": elliptic.P384(), "P-521": elliptic.P521()}
+		return &ecdsa.PublicKey{Curve: curves[jwk.Crv], X: new(big.Int).SetBytes(decode(jwk.X)), Y: new(big.Int).SetBytes(decode(jwk.Y))}
+	case "OKP":
+		return ed25519.PublicKey(decode(jwk.X))
+	case "RSA":
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(decode(jwk.N)), E: int(new(big.Int).SetBytes(decode(jwk.E)).Int64())}
+	}
+	t.Fatalf("unexpected JWK key type %q", jwk.Kty)
+	return nil
+}
+
+func TestJWKS(t *testing.T) {
+	mnemonic := MustParseMnemonic(testMnemonic)
+
+	var derived []*Key
+	for _, test := range []struct {
+		keyType KeyType
+		keyId   int
+		index   int
+	}{
+		{KeyTypeECC, int(ECCCurveP256), 0},
+		{KeyTypeECC, int(ECCCurveP256), 1},
+		{KeyTypeECC, int(ECCCurveP521), 0},
+		{KeyTypeECC, int(ECCCurveEd25519), 0},
+		{KeyTypeRSA, int(RSAKey2048), 0},
+	} {
+		k, err := GenerateLabeledKeyFromMnemonic(t.Context(), test.keyType, test.keyId, SALT, mnemonic, KeyLabel{Index: test.index})
+		if err != nil {
+			t.Fatalf("failed to generate key: %v", err)
+		}
+		derived = append(derived, k)
+	}
+
+	jwks, err := NewJWKS(derived...)
+	if err != nil {
+		t.Fatalf("failed to build JWKS: %v", err)
+	}
+
+	data, err := json.Marshal(jwks)
+	if err != nil {
+		t.Fatalf("failed to marshal JWKS: %v", err)
+	}
+	for _, private := range []string{`"d"`, `"p"`, `"q"`, `"dp"`, `"dq"`, `"qi"`} {
+		if strings.Contains(string(data), private+":") {
+			t.Fatalf("JWKS contains the private component %s", private)
+		}
+	}
+
+	for i, jwk := range jwks.Keys {
+		kid, _ := derived[i].PublicFingerprint(FingerprintHex)
+		if jwk.Kid != kid {
+			t.Fatalf("key %d: kid %q does not match the public fingerprint %q", i, jwk.Kid, kid)
+		}
+
+		pub, _ := derived[i].PublicKey()
+		rebuilt := publicKeyFromJWK(t, jwk)
+		if !pub.(interface{ Equal(crypto.PublicKey) bool }).Equal(rebuilt) {
+			t.Fatalf("key %d: JWK does not describe the derived public key", i)
+		}
+	}
+
+	if jwks.Keys[0].Kid == jwks.Keys[1].Kid {
+		t.Fatalf("keys at different indices must have different kids")
+	}
+}
@@ -3,35 +3,103 @@ package keys
 import (
 	"context"
 	"crypto"
-	"crypto/sha256"
+	"errors"
 	"fmt"
+	"io"
 
 	"github.com/rs/zerolog/log"
 	"github.com/tyler-smith/go-bip39"
-	"github.com/youmark/pkcs8"
 	"golang.org/x/crypto/hkdf"
 )
 
+// ErrUnsupportedKeyType is returned when a key is requested for a key type other than ECC or RSA
+var ErrUnsupportedKeyType = errors.New("unsupported key type")
+
+// ErrInvalidKeyID is returned when the key id is not a defined ECCCurveID for an ECC key or RSAKeyID for an RSA key,
+// e.g. an RSA key id passed with KeyTypeECC
+var ErrInvalidKeyID = errors.New("invalid key id for the key type")
+
+// ValidateKeyID checks that the key id is defined for the key type, before any work is done for the key
+func ValidateKeyID(keyType KeyType, keyId int) error {
+	switch keyType {
+	case KeyTypeECC:
+		if keyId <= int(ECCCurveNone) || keyId >= int(eccCurveEnd) {
+			return fmt.Errorf("%w: %d is not a defined ECC curve id", ErrInvalidKeyID, keyId)
+		}
+	case KeyTypeRSA:
+		if keyId <= int(RSAKeyNone) || keyId >= int(rsaKeyEnd) {
+			return fmt.Errorf("%w: %d is not a defined RSA key size id", ErrInvalidKeyID, keyId)
+		}
+	default:
+		return fmt.Errorf("%w: %q", ErrUnsupportedKeyType, keyType)
+	}
+	return nil
+}
+
 // GenerateKeyFromMnemonic generates a deterministic private key from the provided mnemonic and salt
 func GenerateKeyFromMnemonic(ctx context.Context, keyType KeyType, keyId int, salt string, mnemonic Mnemonic) (*Key, error) {
-	saltBytes := []byte(salt)
+	return defaultGenerator.GenerateKeyFromMnemonic(ctx, keyType, keyId, salt, mnemonic)
+}
+
+// GenerateLabeledKeyFromMnemonic generates the deterministic private key selected by the label from the provided mnemonic and salt
+func GenerateLabeledKeyFromMnemonic(ctx context.Context, keyType KeyType, keyId int, salt string, mnemonic Mnemonic, label KeyLabel) (*Key, error) {
+	return defaultGenerator.GenerateLabeledKeyFromMnemonic(ctx, keyType, keyId, salt, mnemonic, label)
+}
+
+// GenerateKey generates a new deterministic private key and mnemonic
+func GenerateKey(ctx context.Context, keyType KeyType, keyId int, salt string) (*Key, error) {
+	return defaultGenerator.GenerateKey(ctx, keyType, keyId, salt)
+}
 
-	mnemonic, err := mnemonic.Normalize()
+// GenerateStream returns the deterministic byte stream derived from the mnemonic and salt for the given HKDF info
+func GenerateStream(ctx context.Context, salt string, mnemonic Mnemonic, info string) (io.Reader, error) {
+	return defaultGenerator.GenerateStream(ctx, salt, mnemonic, info)
+}
+
+// GenerateKeyFromMnemonic generates a deterministic private key from the provided mnemonic and salt
+func (g *Generator) GenerateKeyFromMnemonic(ctx context.Context, keyType KeyType, keyId int, salt string, mnemonic Mnemonic) (*Key, error) {
+	return g.GenerateLabeledKeyFromMnemonic(ctx, keyType, keyId, salt, mnemonic, KeyLabel{})
+}
+
+// GenerateLabeledKeyFromMnemonic generates the deterministic private key selected by the label from the provided mnemonic
+// and salt. Keys with different labels are independent, and the zero label derives the same key as GenerateKeyFromMnemonic.
+func (g *Generator) GenerateLabeledKeyFromMnemonic(ctx context.Context, keyType KeyType, keyId int, salt string, mnemonic Mnemonic, label KeyLabel) (*Key, error) {
+	// do no work at all if the caller has already given up
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("key generation cancelled: %w", err)
+	}
+	if err := ValidateKeyID(keyType, keyId); err != nil {
+		return nil, err
+	}
+
+	mnemonic, seed, err := g.deriveSeed(mnemonic, salt)
 	if err != nil {
-		return nil, fmt.Errorf("failed to normalize mnemonic: %w", err)
+		return nil, err
 	}
-	log.Debug().Msg("Normalized mnemonic for key generation.")
+	defer clear(seed)
 
-	// derive seed from mnemonic and salt
-	seed := bip39.NewSeed(mnemonic.String(), salt)
-	log.Debug().Msg("Derived seed from mnemonic and salt.")
+	key, err := g.generateKeyFromSeed(ctx, keyType, keyId, salt, seed, label)
+	if err != nil {
+		return nil, err
+	}
+	key.mnemonic = mnemonic
+	return key, nil
+}
+
+// generateKeyFromSeed derives the private key selected by the label from the seed and salt with HKDF, and returns it
+// without a mnemonic
+func (g *Generator) generateKeyFromSeed(ctx context.Context, keyType KeyType, keyId int, salt string, seed []byte, label KeyLabel) (*Key, error) {
+	info, err := keyStreamInfo(keyType, keyId, label)
+	if err != nil {
+		return nil, err
+	}
 
-	// use HKDF to derive the private key from the BIP39 seed and salt
-	kdf := hkdf.New(sha256.New, seed, saltBytes, nil)
-	log.Debug().Msg("Initialized HKDF-SHA256 using BIP39 seed + salt for key derivation.")
+	// use HKDF to derive the private key from the seed and salt
+	kdf := hkdf.New(g.hash, seed, []byte(salt), g.streamSeedInfo(info))
+	log.Debug().Msg("Initialized HKDF using seed + salt for key derivation.")
 
 	// create ChaCha20 stream cipher from KDF output, to use as a DRBG for key generation
-	stream, err := NewStreamChaCha20(kdf)
+	stream, err := NewStreamChaCha20WithBufferSize(kdf, g.streamBuf)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create ChaCha20 stream cipher for key derivation: %w", err)
 	}
@@ -40,43 +108,116 @@ func GenerateKeyFromMnemonic(ctx context.Context, keyType KeyType, keyId int, sa
 
 	switch keyType {
 	case KeyTypeECC:
-		privKey, err = generateECC(stream, ECCCurveID(keyId))
+		privKey, err = generateECC(ctx, stream, ECCCurveID(keyId))
 		if err != nil {
 			return nil, fmt.Errorf("failed to generate ECC key: %w", err)
 		}
 	case KeyTypeRSA:
-		privKey, err = generateRSA(stream, RSAKeyID(keyId))
+		primeStream := func(i int) (DeterministicReader, error) {
+			return g.labeledStream(seed, salt, primeStreamInfo(info, i))
+		}
+		privKey, err = generateRSA(ctx, g.derivation, stream, primeStream, RSAKeyID(keyId), g.maxPrimes, g.rsaExponent)
 		if err != nil {
-			return nil, fmt.Errorf("failed to generate ECC key: %w", err)
+			return nil, fmt.Errorf("failed to generate RSA key: %w", err)
 		}
 	default:
-		return nil, fmt.Errorf("unsupported key type: %s", keyType)
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedKeyType, keyType)
 	}
 
-	// marshal private key to DER format
-	der, err := pkcs8.MarshalPrivateKey(privKey, nil, nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal EC private key: %w", err)
+	// generation may take a long time, wipe and discard the result if the context was cancelled meanwhile
+	if err := ctx.Err(); err != nil {
+		zeroPrivateKey(privKey)
+		return nil, fmt.Errorf("key generation cancelled: %w", err)
 	}
-	log.Debug().Msg("Marshalled private key to PKCS8 key format.")
 
-	return &Key{
+	key := &Key{
 		keyType:    keyType,
 		keyId:      keyId,
 		salt:       salt,
 		PrivateKey: privKey,
-		Der:        der,
-		mnemonic:   mnemonic,
-	}, nil
+		derivation: g.derivation,
+		label:      label,
+		gen:        g,
+	}
+
+	// marshal private key to DER format
+	if err := key.Rederive(); err != nil {
+		key.Zero()
+		return nil, err
+	}
+	log.Debug().Msg("Marshalled private key to PKCS8 key format.")
+
+	return key, nil
 }
 
 // GenerateKey generates a new deterministic private key and mnemonic
-func GenerateKey(ctx context.Context, keyType KeyType, keyId int, salt string) (*Key, error) {
-	mnemonic, err := GenerateMnemonic(ctx)
+func (g *Generator) GenerateKey(ctx context.Context, keyType KeyType, keyId int, salt string) (*Key, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("key generation cancelled: %w", err)
+	}
+	// an invalid key must not cost a new mnemonic
+	if err := ValidateKeyID(keyType, keyId); err != nil {
+		return nil, err
+	}
+
+	mnemonic, err := g.GenerateMnemonic(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate mnemonic: %w", err)
 	}
 	log.Debug().Msg("Created a new mnemonic for key generation.")
 
-	return GenerateKeyFromMnemonic(ctx, keyType, keyId, salt, *mnemonic)
+	return g.GenerateKeyFromMnemonic(ctx, keyType, keyId, salt, *mnemonic)
+}
+
+// GenerateStream returns the deterministic byte stream derived from the mnemonic and salt for the given HKDF info,
+// e.g. to feed another system's KDF. It is the same stream key generation consumes: the empty info yields the stream
+// of unlabeled keys, and a labeled key's info (bipkey-key;...;alg=<algorithm>;index=<n>) yields that key's stream.
+func (g *Generator) GenerateStream(ctx context.Context, salt string, mnemonic Mnemonic, info string) (io.Reader, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("stream derivation cancelled: %w", err)
+	}
+
+	_, seed, err := g.deriveSeed(mnemonic, salt)
+	if err != nil {
+		return nil, err
+	}
+	// HKDF extracts its key from the seed up front, so the seed is not needed past the stream setup
+	defer clear(seed)
+
+	stream, err := g.labeledStream(seed, salt, info)
+	if err != nil {
+		return nil, err
+	}
+	log.Debug().Str("info", info).Msg("Initialized ChaCha20 stream using BIP39 seed + salt.")
+
+	return stream.Keystream(), nil
+}
+
+// deriveSeed normalizes the mnemonic and derives the BIP39 seed from it and the salt, stretched if configured. With
+// EntropySeed the 32-byte mnemonic entropy takes the place of the seed, and the salt only enters through HKDF.
+func (g *Generator) deriveSeed(mnemonic Mnemonic, salt string) (Mnemonic, []byte, error) {
+	mnemonic, err := g.NormalizeMnemonic(mnemonic)
+	if err != nil {
+		return mnemonic, nil, fmt.Errorf("failed to normalize mnemonic: %w", err)
+	}
+	log.Debug().Msg("Normalized mnemonic for key generation.")
+
+	var seed []byte
+	if g.entropySeed {
+		if seed, err = g.EntropyFromMnemonic(mnemonic); err != nil {
+			return mnemonic, nil, fmt.Errorf("failed to read mnemonic entropy: %w", err)
+		}
+		log.Debug().Msg("Using the mnemonic entropy as the seed, without the BIP-39 seed derivation.")
+	} else {
+		// derive seed from mnemonic and salt
+		seed = bip39.NewSeed(mnemonic.String(), salt)
+		log.Debug().Msg("Derived seed from mnemonic and salt.")
+	}
+
+	seed, err = g.stretchSeed(seed, salt)
+	if err != nil {
+		return mnemonic, nil, err
+	}
+
+	return mnemonic, seed, nil
 }
@@ -1,6 +1,7 @@
 package keys
 
 import (
+	"context"
 	// "crypto/ecdh"
 	"crypto"
 	"crypto/ecdsa"
@@ -9,11 +10,15 @@ import (
 	"fmt"
 	"io"
 	"math/big"
+	"slices"
 	"strings"
 
 	"github.com/rs/zerolog/log"
 )
 
+// SCALAR_EXTRA_BITS is the number of extra stream bits read for a NIST curve scalar to make the modular reduction bias negligible
+const SCALAR_EXTRA_BITS = 128
+
 type ECCCurveID int
 
 const (
@@ -22,6 +27,8 @@ const (
 	ECCCurveP384
 	ECCCurveP521
 	ECCCurveEd25519
+
+	eccCurveEnd // sentinel marking the end of the defined curves, new curves go above
 )
 
 func getSizeECC(id ECCCurveID) int {
@@ -38,14 +45,14 @@ func getSizeECC(id ECCCurveID) int {
 	return 0
 }
 
-// eccCurveInfo holds information and aliases about supported ECC curves
-type eccCurveInfo struct {
+// ECCCurveInfo holds information and aliases about supported ECC curves
+type ECCCurveInfo struct {
 	ID      ECCCurveID // associated ECCCurveID
 	Name    string     // canonical name
 	Aliases []string   // all accepted user inputs (lowercase)
 }
 
-var supportedECCCurves = []eccCurveInfo{
+var supportedECCCurves = []ECCCurveInfo{
 	{
 		ID:      ECCCurveP256,
 		Name:    "P-256",
@@ -68,22 +75,35 @@ var supportedECCCurves = []eccCurveInfo{
 	},
 }
 
-var eccAliases map[string]eccCurveInfo
+// eccAliases maps every accepted alias to its curve, it is built once and never modified
+var eccAliases = buildECCAliases()
 
-func init() {
-	eccAliases = make(map[string]eccCurveInfo)
+// buildECCAliases builds the alias lookup table from the supported curves
+func buildECCAliases() map[string]ECCCurveInfo {
+	aliases := make(map[string]ECCCurveInfo)
 	for _, info := range supportedECCCurves {
 		for _, alias := range info.Aliases {
-			eccAliases[strings.ToLower(alias)] = info
+			aliases[strings.ToLower(alias)] = info
 		}
 	}
+	return aliases
+}
+
+// SupportedECCCurves returns the supported ECC curves and their aliases, in definition order
+func SupportedECCCurves() []ECCCurveInfo {
+	curves := make([]ECCCurveInfo, len(supportedECCCurves))
+	for i, info := range supportedECCCurves {
+		info.Aliases = slices.Clone(info.Aliases)
+		curves[i] = info
+	}
+	return curves
 }
 
 // SupportedECC returns a string listing supported ECC curves and their aliases
 func SupportedECC() string {
 	var builder strings.Builder
 	builder.WriteString("Supported ECC curves:\n")
-	for _, info := range supportedECCCurves {
+	for _, info := range SupportedECCCurves() {
 		builder.WriteString(fmt.Sprintf(" - %s (aliases: %s)\n", info.Name, strings.Join(info.Aliases, ", ")))
 	}
 	return builder.String()
@@ -108,6 +128,7 @@ func generateEdECC(r DeterministicReader, id ECCCurveID) (crypto.PrivateKey, err
 	switch id {
 	case ECCCurveEd25519:
 		seed := make([]byte, ed25519.SeedSize)
+		defer clear(seed) // the private key holds its own copy
 		if _, err := io.ReadFull(r, seed); err != nil {
 			return nil, fmt.Errorf("failed to read seed for Ed25519 key: %w", err)
 		}
@@ -136,8 +157,8 @@ func generateNistECC(r DeterministicReader, id ECCCurveID) (crypto.PrivateKey, e
 
 	// Read the number of bytes needed for the curve's scalar, with extra bytes to reduce bias
 	params := ecdsaCurve.Params()
-	scalarSize := (params.N.BitLen() + 7) / 8           // bytes needed for scalar
-	scalarSizeWide := (params.N.BitLen() + 128 + 7) / 8 // add 128 bits to reduce bias
+	scalarSize := (params.N.BitLen() + 7) / 8                         // bytes needed for scalar
+	scalarSizeWide := (params.N.BitLen() + SCALAR_EXTRA_BITS + 7) / 8 // add extra bits to reduce bias
 
 	d, err := generateScalarWide(r, params.N, scalarSizeWide)
 	if err != nil {
@@ -147,6 +168,7 @@ func generateNistECC(r DeterministicReader, id ECCCurveID) (crypto.PrivateKey, e
 
 	// Convert to ECDH private key first
 	scalarBytes := make([]byte, scalarSize)
+	defer clear(scalarBytes)
 	d.FillBytes(scalarBytes)
 
 	// Convert to ecdsa.PrivateKey for compatibility
@@ -163,7 +185,10 @@ func generateNistECC(r DeterministicReader, id ECCCurveID) (crypto.PrivateKey, e
 }
 
 // generateECC generates an ECC private key of the specified size using the provided reader for randomness.
-func generateECC(r DeterministicReader, id ECCCurveID) (crypto.PrivateKey, error) {
+func generateECC(ctx context.Context, r DeterministicReader, id ECCCurveID) (crypto.PrivateKey, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("key generation cancelled: %w", err)
+	}
 	switch id {
 	case ECCCurveP256, ECCCurveP384, ECCCurveP521:
 		return generateNistECC(r, id)
@@ -177,6 +202,7 @@ func generateECC(r DeterministicReader, id ECCCurveID) (crypto.PrivateKey, error
 // generateScalarWidw generates a scalar in [1, n-1] using wide byte input to reduce bias
 func generateScalarWide(r DeterministicReader, n *big.Int, byteLen int) (*big.Int, error) {
 	buf := make([]byte, byteLen)
+	defer clear(buf)
 	if _, err := io.ReadFull(r, buf); err != nil {
 		return nil, err
 	}
@@ -0,0 +1,93 @@
+package keys
+
+import (
+	"crypto/ecdsa"
+	"encoding/hex"
+	"testing"
+)
+
+// counterReader is a DeterministicReader returning the byte sequence 00 01 02 ... ff 00 01 ...
+type counterReader struct {
+	n byte
+}
+
+func (r *counterReader) Read(dst []byte) (int, error) {
+	for i := range dst {
+		dst[i] = r.n
+		r.n++
+	}
+	return len(dst), nil
+}
+
+func (r *counterReader) IgnoresMaybeReadByte() bool {
+	return true
+}
+
+func TestNistECCScalarEncoding(t *testing.T) {
+	// expected values were computed independently: d = (wide big-endian input mod (n-1)) + 1, encoded as a fixed-width
+	// big-endian scalar, and Q = d*G as an uncompressed point. The 0xff inputs reduce to scalars with leading zero bytes.
+	tests := []struct {
+		name   string
+		id     ECCCurveID
+		reader func() DeterministicReader
+		d      string
+		q      string
+	}{
+		{
+			"P256/counter", ECCCurveP256, func() DeterministicReader { return &counterReader{} },
+			"18185bb801b6065828af9bb667d107070b661a42a64f1f66b1a71e49aa7ff400",
+			"043306be3e4aad764674da3e995b0417c0d7c4a93cd7727988f8e8edf75f7c298f4ac85b7d5d2bbea4cfad3cc2ba8bdf5cece54aa9d6bd2c4dc76b57645520064d",
+		},
+		{
+			"P256/ff", ECCCurveP256, func() DeterministicReader { return constantReader(0xff) },
+			"431905529c0166ce652e96b7ccca0a9a679b73e29ad16947f01cf012fc632550",
+			"044b0bac526bd810f3663527e929ee8e2188d5070146396d862fb139cbf107568cefa020c62d211153424929ffea5d1ad19fc3ea0fe3f0399039cdb5bcb434e195",
+		},
+		{
+			"P384/counter", ECCCurveP384, func() DeterministicReader { return &counterReader{} },
+			"101112131415161718195329b2b9cca856249af745e235d8d0c7570126873ed3842e4fc5927825f19be105ec01a19492",
+			"04b35f5b1f8c4632a6e979f7b809da8a4d38577cf146bd38022385deeb31962103f93a65e283a93b258af67a63b241752a9e7ae86d62c4bf1f8a0cf64a02c6ff79d023bdf6a2aa3ff1c4d8867685f847639485347491ab831cbd220a3b199c463a",
+		},
+		{
+			"P384/ff", ECCCurveP384, func() DeterministicReader { return constantReader(0xff) },
+			"0000000000000000389cb27e0bc8d220a7e5f24db74f58851313e695333ad68e00000000000000000000000000000000",
+			"041e30f083feae02ee2d1c68e74b096bce0be42061a28e925ff32ae969d7e02f3e8b4579b6990f903320c62aa040571ad7ae2878b9cad04a6bbbed68d615ee475d46cc5b3d3a6d2a920e60b2fe9434eb55620e7d9b05c5cd1701305306b9d49304",
+		},
+		{
+			"P521/counter", ECCCurveP521, func() DeterministicReader { return &counterReader{} },
+			"001112131415161718191a1b1c1d1e1f2021250017ebfe30cb9b350f02f6451866e2456d25442a8730b846d9fcef341c826a8a2368314d36834f2ac9c2d2ad65f412",
+			"040066bc6fa0d697f9eab02557597db5bdcfb97e6bb93fd18091b4622120703ecfdf80f3cdb4526b8f3afd479778209336d2d27bb33ad02cf3ed79e9ef4b07f88b97fd01675c29401ca777ef1a3a110a452dc705355562ffdebddca4281166f4725af7458e0e7b3ba678a6a978671adea7201bac6bebee59a4519f5150f8979ecef0d33be2",
+		},
+		{
+			"P521/ff", ECCCurveP521, func() DeterministicReader { return constantReader(0xff) },
+			"0000000000000000000000000000000002d73cbc3e206834ca4019ff5b847b2d17e2251b23bb31dc28a2482470b763cdfc0000000000000000000000000000000000",
+			"04019feec428a8c6226abea920f9bd8699bb94704e1b6770bc9e07cff8f17defff67b0a3241818e2fe656b739d605aa0aba25552163cea452ddd752f2cc845a94fbcf7014affd6f6ac7a3bf1f203864178d87fe70cd597444b81b735003b9fc53cb4c31974b410970010ed053e33c2a8f5aa23a1d74907cebbfcca9064c32c74c5ac9bef5e",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := generateNistECC(tt.reader(), tt.id)
+			if err != nil {
+				t.Fatalf("failed to generate ECC key: %v", err)
+			}
+			priv := key.(*ecdsa.PrivateKey)
+
+			d, err := priv.Bytes()
+			if err != nil {
+				t.Fatalf("failed to encode private scalar: %v", err)
+			}
+			if hex.EncodeToString(d) != tt.d {
+				t.Fatalf("unexpected private scalar: got %x, want %s", d, tt.d)
+			}
+
+			q, err := priv.PublicKey.Bytes()
+			if err != nil {
+				t.Fatalf("failed to encode public point: %v", err)
+			}
+			if hex.EncodeToString(q) != tt.q {
+				t.Fatalf("unexpected public point: got %x, want %s", q, tt.q)
+			}
+		})
+	}
+}
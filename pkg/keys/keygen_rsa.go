@@ -1,13 +1,86 @@
 package keys
 
 import (
+	"context"
 	"crypto/rsa"
 	"fmt"
+	"io"
+	"math/big"
 	"strings"
+
+	"github.com/rs/zerolog/log"
 )
 
+// PRIMALITY_TESTS is the fixed number of Miller-Rabin rounds used before the rounds were chosen by prime size.
+//
+// Deprecated: derivePrime uses primalityRounds, which follows FIPS 186-4 Table C.3.
 const PRIMALITY_TESTS = 256
 
+// primalityRounds returns the number of Miller-Rabin rounds for a prime of the given bit length, following
+// FIPS 186-4 Table C.3 (error probability of at most 2^-100 for RSA prime factors). math/big also runs a
+// Baillie-PSW test on every candidate, and no test ever rejects an actual prime, so the first accepted prime
+// of a stream, and therefore the derived key, does not depend on the number of rounds.
+func primalityRounds(bits int) int {
+	switch {
+	case bits <= 512:
+		return 7
+	case bits <= 1024:
+		return 5
+	default:
+		return 4
+	}
+}
+
+// RSA_PUBLIC_EXPONENT is the default public exponent of derived RSA keys
+const RSA_PUBLIC_EXPONENT = 65537
+
+// rsaExponentCandidates are the Fermat primes, the public exponents tried in order after the configured exponent
+// when exponent fallback is enabled
+var rsaExponentCandidates = []int{3, 5, 17, 257, 65537}
+
+// RSAExponent selects the public exponent of derived RSA keys
+type RSAExponent struct {
+	E        int  `json:"e"`        // configured public exponent, odd and at least 3
+	Fallback bool `json:"fallback"` // use the next invertible exponent of rsaExponentCandidates instead of redrawing q
+}
+
+// validate checks that the exponent is usable by crypto/rsa
+func (e RSAExponent) validate() error {
+	if e.E < 3 || e.E%2 == 0 || e.E > 1<<31-1 {
+		return fmt.Errorf("RSA public exponent must be an odd number from 3 to 2^31-1, found %d", e.E)
+	}
+	return nil
+}
+
+// candidates returns the exponents tried for a key in order: the configured exponent, followed by the larger
+// candidate exponents if fallback is enabled
+func (e RSAExponent) candidates() []int {
+	exponents := []int{e.E}
+	if e.Fallback {
+		for _, candidate := range rsaExponentCandidates {
+			if candidate > e.E {
+				exponents = append(exponents, candidate)
+			}
+		}
+	}
+	return exponents
+}
+
+// invert returns the first of the exponent candidates that is invertible mod phi along with its inverse d, or a nil
+// d if there is none
+func (e RSAExponent) invert(phi *big.Int) (int, *big.Int) {
+	for _, candidate := range e.candidates() {
+		if d := new(big.Int).ModInverse(big.NewInt(int64(candidate)), phi); d != nil {
+			return candidate, d
+		}
+	}
+	return 0, nil
+}
+
+// DEFAULT_MAX_PRIME_CANDIDATES bounds the candidates drawn per RSA prime. A random 4096-bit candidate is prime with a
+// probability of about 1/1420 (odd candidates), so a legitimate search exceeds the cap with a probability below 2^-100.
+const DEFAULT_MAX_PRIME_CANDIDATES = 100000
+
 type RSAKeyID int
 
 const (
@@ -16,6 +89,8 @@ const (
 	RSAKey3072
 	RSAKey4096
 	RSAKey8192
+
+	rsaKeyEnd // sentinel marking the end of the defined key sizes, new sizes go above
 )
 
 func getSizeRSA(id RSAKeyID) int {
@@ -32,14 +107,22 @@ func getSizeRSA(id RSAKeyID) int {
 	return 0
 }
 
+// SupportedRSASizes returns the supported RSA key sizes in bits, in ascending order
+func SupportedRSASizes() []int {
+	var sizes []int
+	for id := RSAKeyNone + 1; id < rsaKeyEnd; id++ {
+		sizes = append(sizes, getSizeRSA(id))
+	}
+	return sizes
+}
+
 // SupportedRSA returns a string listing supported RSA key sizes
 func SupportedRSA() string {
 	var builder strings.Builder
 	builder.WriteString("Supported RSA sizes:\n")
-	builder.WriteString(fmt.Sprintf(" - %d\n", getSizeRSA(RSAKey2048)))
-	builder.WriteString(fmt.Sprintf(" - %d\n", getSizeRSA(RSAKey3072)))
-	builder.WriteString(fmt.Sprintf(" - %d\n", getSizeRSA(RSAKey4096)))
-	builder.WriteString(fmt.Sprintf(" - %d\n", getSizeRSA(RSAKey8192)))
+	for _, size := range SupportedRSASizes() {
+		builder.WriteString(fmt.Sprintf(" - %d\n", size))
+	}
 	return builder.String()
 }
 
@@ -64,100 +147,167 @@ func ParseRSAKeyID(val string) (RSAKeyID, error) {
 	}
 }
 
-// generateRSA generates an RSA private key using the provided reader for randomness
-func generateRSA(r DeterministicReader, id RSAKeyID) (*rsa.PrivateKey, error) {
+// primeStreamFunc returns the independent deterministic stream used to draw the i-th RSA prime
+type primeStreamFunc func(i int) (DeterministicReader, error)
+
+// generateRSA generates an RSA private key using the given derivation version
+// The prime search of derivation v2 and later gives up after maxCandidates candidates, v1 cannot be bounded.
+func generateRSA(ctx context.Context, version DerivationVersion, r DeterministicReader, primeStream primeStreamFunc, id RSAKeyID, maxCandidates int, exponent RSAExponent) (*rsa.PrivateKey, error) {
 	var size = getSizeRSA(id)
 	if size == 0 {
 		return nil, fmt.Errorf("unsupported RSA key size")
 	}
 
-	return rsa.GenerateKey(r, size)
+	switch version {
+	case DerivationV1:
+		// rsa.GenerateKey is not guaranteed to be deterministic across Go versions, and always uses e = 65537
+		if exponent.E != RSA_PUBLIC_EXPONENT {
+			return nil, fmt.Errorf("a custom RSA public exponent requires derivation %s or later", DerivationV2)
+		}
+		return rsa.GenerateKey(r, size)
+	case DerivationV2, DerivationV3:
+		return generateRSAPrimes(ctx, primeStream, size, maxCandidates, exponent)
+	default:
+		return nil, fmt.Errorf("unsupported derivation version: %s", version)
+	}
 }
 
-/*
-// generateRSA generates an RSA private key using the provided reader for randomness
-func generateRSA(r DeterministicReader, id RSAKeyID) (*rsa.PrivateKey, error) {
-	var size = getSizeRSA(id)
-	if size == 0 {
-		return nil, fmt.Errorf("unsupported RSA key size")
-	}
+// generateRSAPrimes constructs an RSA private key from primes drawn from independent per-prime streams.
+//
+// Without exponent fallback, p is redrawn from its stream until e is coprime to p-1, and q is redrawn until e is
+// invertible mod (p-1)(q-1), so that a small e such as 3 does not exhaust the q draws against an unusable p. With
+// fallback, p is never redrawn for e, and the key uses the first of e and the larger rsaExponentCandidates that is
+// invertible mod (p-1)(q-1); q is only redrawn if none is. Both choices depend on the primes alone, so the derived key
+// stays reproducible. For the default e = 65537, this yields the same keys as before, except that a p = 1 mod 65537,
+// which could never succeed, is now redrawn instead of exhausting the q draws.
+//
+// The search stops as soon as the context is cancelled, and every prime drawn so far is wiped unless it ends up in
+// the returned key.
+func generateRSAPrimes(ctx context.Context, primeStream primeStreamFunc, size int, maxCandidates int, exponent RSAExponent) (priv *rsa.PrivateKey, err error) {
+	half := size / 2
+	one := big.NewInt(1)
 
-	// using rsa.GenerateKey is not guaranteed to be deterministic across future Go versions
-	// so we implement our own key generation using the provided reader
-	half := int(size / 2)
-	p, err := derivePrime(r, half)
+	var discarded []*big.Int
+	defer func() {
+		for _, prime := range discarded {
+			if priv == nil || (prime != priv.Primes[0] && prime != priv.Primes[1]) {
+				zeroInt(prime)
+			}
+		}
+	}()
+
+	pStream, err := primeStream(0)
 	if err != nil {
-		return nil, fmt.Errorf("failed to generate prime p: %w", err)
+		return nil, err
+	}
+	var p *big.Int
+	for attempt := 1; ; attempt++ {
+		if attempt > maxCandidates {
+			return nil, fmt.Errorf("no usable prime p after %d attempts, the entropy stream may be degenerate", maxCandidates)
+		}
+		if p, err = derivePrime(ctx, pStream, half, maxCandidates); err != nil {
+			return nil, fmt.Errorf("failed to generate prime p: %w", err)
+		}
+		discarded = append(discarded, p)
+		if exponent.Fallback || new(big.Int).GCD(nil, nil, big.NewInt(int64(exponent.E)), new(big.Int).Sub(p, one)).Cmp(one) == 0 {
+			break
+		}
+		log.Debug().Msg("Discarded prime p not coprime to the RSA public exponent.")
 	}
 	log.Debug().Msg("Generated prime p for RSA key.")
 
-	q := p
-	// ensure p and q are distinct primes
-	for p.Cmp(q) == 0 {
-		q, err = derivePrime(r, half)
+	qStream, err := primeStream(1)
+	if err != nil {
+		return nil, err
+	}
+
+	for attempt := 1; ; attempt++ {
+		// a degenerate stream could keep yielding an unusable q, so the redraws are bounded as well
+		if attempt > maxCandidates {
+			return nil, fmt.Errorf("no usable prime q after %d attempts, the entropy stream may be degenerate", maxCandidates)
+		}
+
+		q, err := derivePrime(ctx, qStream, half, maxCandidates)
 		if err != nil {
-			return nil, fmt.Errorf("failed to generate distinct prime q: %w", err)
+			return nil, fmt.Errorf("failed to generate prime q: %w", err)
 		}
-	}
-	log.Debug().Msg("Generated prime q for RSA key.")
+		discarded = append(discarded, q)
 
-	// compute RSA private key components
-	n := new(big.Int).Mul(p, q)
-	phi := new(big.Int).Mul(
-		new(big.Int).Sub(p, big.NewInt(1)),
-		new(big.Int).Sub(q, big.NewInt(1)),
-	)
-	e := big.NewInt(65537) // common public exponent
-	d := new(big.Int).ModInverse(e, phi)
-	if d == nil {
-		return nil, fmt.Errorf("failed to compute modular inverse")
-	}
+		// p and q must be distinct and e must be invertible, otherwise draw the next q from the same stream
+		if p.Cmp(q) == 0 {
+			continue
+		}
+		// two primes with only their top bit set can multiply to a modulus one bit short of the key size,
+		// in which case the next q is drawn as well, so the modulus always has exactly the requested size
+		n := new(big.Int).Mul(p, q)
+		if n.BitLen() != size {
+			log.Debug().Int("bits", n.BitLen()).Msg("Discarded prime q yielding a short RSA modulus.")
+			continue
+		}
+		phi := new(big.Int).Mul(new(big.Int).Sub(p, one), new(big.Int).Sub(q, one))
+		e, d := exponent.invert(phi)
+		zeroInt(phi)
+		if d == nil {
+			continue
+		}
+		if e != exponent.E {
+			log.Debug().Int("e", e).Msg("Selected the next invertible RSA public exponent.")
+		}
+		log.Debug().Msg("Generated prime q for RSA key.")
 
-	priv := &rsa.PrivateKey{
-		PublicKey: rsa.PublicKey{
-			N: n,
-			E: int(e.Int64()),
-		},
-		D:      d,
-		Primes: []*big.Int{p, q},
-	}
-	if err := priv.Validate(); err != nil {
-		return nil, fmt.Errorf("invalid RSA key: %w", err)
+		priv = &rsa.PrivateKey{
+			PublicKey: rsa.PublicKey{
+				N: n,
+				E: e,
+			},
+			D:      d,
+			Primes: []*big.Int{p, q},
+		}
+		if err := priv.Validate(); err != nil {
+			zeroPrivateKey(priv)
+			return nil, fmt.Errorf("invalid RSA key: %w", err)
+		}
+		priv.Precompute()
+		log.Debug().Msg("Constructed and validated RSA private key.")
+		return priv, nil
 	}
-	log.Debug().Msg("Constructed and validated RSA private key.")
-	return priv, nil
 }
 
-// derivePrime creates a prime number of the specified bit length from the provided reader
-func derivePrime(r DeterministicReader, bits int) (*big.Int, error) {
+// derivePrime draws odd candidates of the specified bit length from the reader until one is prime, failing after
+// maxCandidates candidates. Every candidate is a fresh draw, so the accepted prime only depends on the stream and
+// not on a search distance. The search stops between candidates once the context is cancelled.
+func derivePrime(ctx context.Context, r DeterministicReader, bits int, maxCandidates int) (*big.Int, error) {
+	return derivePrimeRounds(ctx, r, bits, primalityRounds(bits), maxCandidates)
+}
+
+// derivePrimeRounds is derivePrime with an explicit number of Miller-Rabin rounds. Rejected candidates and the
+// candidate buffer are wiped, since a near miss still says something about the stream.
+func derivePrimeRounds(ctx context.Context, r DeterministicReader, bits int, rounds int, maxCandidates int) (*big.Int, error) {
 	byteLen := (bits + 7) / 8
 	buf := make([]byte, byteLen)
+	defer clear(buf)
+	topBit := byte(1) << ((bits - 1) % 8)
+	mask := topBit<<1 - 1
 
-	// only read once from r to minimize calls to the reader, preserve limited HKDF entropy
-	_, err := io.ReadFull(r, buf)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read random bytes for prime: %w", err)
-	}
-
-	buf[0] |= 0x80         // ensure the number is of the desired bit length
-	buf[byteLen-1] |= 0x01 // ensure the number is odd
-	k := new(big.Int).SetBytes(buf)
+	for count := 1; count <= maxCandidates; count++ {
+		if err := ctx.Err(); err != nil {
+			return nil, fmt.Errorf("prime search cancelled: %w", err)
+		}
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, fmt.Errorf("failed to read random bytes for prime: %w", err)
+		}
 
-	two := big.NewInt(2)
-	count := 0
-	defer func() {
-		log.Debug().Msgf("Derived prime after %d attempts.", count)
-	}()
+		buf[0] &= mask         // discard bits above the desired bit length
+		buf[0] |= topBit       // ensure the number is of the desired bit length
+		buf[byteLen-1] |= 0x01 // ensure the number is odd
+		k := new(big.Int).SetBytes(buf)
 
-	for {
-		count++
-		// test for primality
-		if k.ProbablyPrime(PRIMALITY_TESTS) {
+		if k.ProbablyPrime(rounds) {
+			log.Debug().Msgf("Derived prime after %d candidates.", count)
 			return k, nil
 		}
-
-		// Increment and try again
-		k.Add(k, two)
+		zeroInt(k)
 	}
+
+	return nil, fmt.Errorf("no %d-bit prime found in %d candidates, the entropy stream may be degenerate", bits, maxCandidates)
 }
-*/
@@ -0,0 +1,323 @@
+package keys
+
+import (
+	"bytes"
+	"crypto/rsa"
+	"fmt"
+	"math/big"
+	"slices"
+	"testing"
+)
+
+const rsaTestMnemonic = "worth ball broom life calm name foil fringe final average since traffic pig cook clap alert brush swallow rural glance guilt board vendor slight"
+
+// v2Generator returns a generator with derivation v2, whose RSA keys do not depend on the Go version
+func v2Generator(t testing.TB) *Generator {
+	t.Helper()
+	g, err := NewGenerator(Config{Derivation: DerivationV2})
+	if err != nil {
+		t.Fatalf("failed to create generator: %v", err)
+	}
+	return g
+}
+
+func TestDerivePrimeIsDeterministic(t *testing.T) {
+	g := DefaultGenerator()
+	_, seed, err := g.deriveSeed(MustParseMnemonic(rsaTestMnemonic), SALT)
+	if err != nil {
+		t.Fatalf("failed to derive seed: %v", err)
+	}
+
+	var primes [2]string
+	for i := range primes {
+		stream, err := g.labeledStream(seed, SALT, "prime-0")
+		if err != nil {
+			t.Fatalf("failed to create prime stream: %v", err)
+		}
+		p, err := derivePrime(t.Context(), stream, 1024, DEFAULT_MAX_PRIME_CANDIDATES)
+		if err != nil {
+			t.Fatalf("failed to derive prime: %v", err)
+		}
+		if p.BitLen() != 1024 || !p.ProbablyPrime(20) {
+			t.Fatalf("derived value is not a 1024-bit prime")
+		}
+		primes[i] = p.String()
+	}
+	if primes[0] != primes[1] {
+		t.Fatalf("prime derivation is not deterministic")
+	}
+}
+
+func TestRSAPrimesAreIndependentDraws(t *testing.T) {
+	g := v2Generator(t)
+	mnemonic := MustParseMnemonic(rsaTestMnemonic)
+	key, err := g.GenerateKeyFromMnemonic(t.Context(), KeyTypeRSA, int(RSAKey2048), SALT, mnemonic)
+	if err != nil {
+		t.Fatalf("failed to generate RSA key: %v", err)
+	}
+	if key.Derivation() != DerivationV2 {
+		t.Fatalf("expected derivation %s, got %s", DerivationV2, key.Derivation())
+	}
+	priv := key.PrivateKey.(*rsa.PrivateKey)
+
+	// each prime must be reproducible from its own labeled stream alone
+	_, seed, err := g.deriveSeed(mnemonic, SALT)
+	if err != nil {
+		t.Fatalf("failed to derive seed: %v", err)
+	}
+	for i, expected := range priv.Primes {
+		stream, err := g.labeledStream(seed, SALT, fmt.Sprintf("prime-%d", i))
+		if err != nil {
+			t.Fatalf("failed to create prime stream: %v", err)
+		}
+		p, err := derivePrime(t.Context(), stream, 1024, DEFAULT_MAX_PRIME_CANDIDATES)
+		if err != nil {
+			t.Fatalf("failed to derive prime %d: %v", i, err)
+		}
+		if p.Cmp(expected) != 0 {
+			t.Fatalf("prime %d does not match an independent draw from its stream", i)
+		}
+	}
+}
+
+func TestDerivationVersions(t *testing.T) {
+	legacy, err := NewGenerator(Config{Derivation: DerivationV1})
+	if err != nil {
+		t.Fatalf("failed to create legacy generator: %v", err)
+	}
+	mnemonic := MustParseMnemonic(rsaTestMnemonic)
+
+	k1, err := legacy.GenerateKeyFromMnemonic(t.Context(), KeyTypeRSA, int(RSAKey2048), SALT, mnemonic)
+	if err != nil {
+		t.Fatalf("failed to generate legacy RSA key: %v", err)
+	}
+	k2, err := v2Generator(t).GenerateKeyFromMnemonic(t.Context(), KeyTypeRSA, int(RSAKey2048), SALT, mnemonic)
+	if err != nil {
+		t.Fatalf("failed to generate RSA key: %v", err)
+	}
+	if k1.Derivation() != DerivationV1 || k1.Fingerprint() == k2.Fingerprint() {
+		t.Fatalf("expected derivation versions to produce different RSA keys")
+	}
+
+	// ECC derivation is identical in every version
+	e1, err := legacy.GenerateKeyFromMnemonic(t.Context(), KeyTypeECC, int(ECCCurveP256), SALT, mnemonic)
+	if err != nil {
+		t.Fatalf("failed to generate legacy ECC key: %v", err)
+	}
+	e2, err := v2Generator(t).GenerateKeyFromMnemonic(t.Context(), KeyTypeECC, int(ECCCurveP256), SALT, mnemonic)
+	if err != nil {
+		t.Fatalf("failed to generate ECC key: %v", err)
+	}
+	if e1.Fingerprint() != e2.Fingerprint() {
+		t.Fatalf("expected ECC keys to be identical across derivation versions")
+	}
+
+	for _, val := range []string{"1", "v2", " V1 ", ""} {
+		if _, err := ParseDerivationVersion(val); err != nil {
+			t.Fatalf("failed to parse derivation version %q: %v", val, err)
+		}
+	}
+	if _, err := NewGenerator(Config{Derivation: derivationEnd}); err == nil {
+		t.Fatalf("expected an error for an unsupported derivation version")
+	}
+}
+
+func TestPrimalityRoundsKeepSelectedPrime(t *testing.T) {
+	g := DefaultGenerator()
+	_, seed, err := g.deriveSeed(MustParseMnemonic(rsaTestMnemonic), SALT)
+	if err != nil {
+		t.Fatalf("failed to derive seed: %v", err)
+	}
+
+	for _, bits := range []int{512, 1024, 1536} {
+		for i := range 2 {
+			label := fmt.Sprintf("prime-%d", i)
+			fixed, err := g.labeledStream(seed, SALT, label)
+			if err != nil {
+				t.Fatalf("failed to create prime stream: %v", err)
+			}
+			adaptive, err := g.labeledStream(seed, SALT, label)
+			if err != nil {
+				t.Fatalf("failed to create prime stream: %v", err)
+			}
+
+			p1, err := derivePrimeRounds(t.Context(), fixed, bits, PRIMALITY_TESTS, DEFAULT_MAX_PRIME_CANDIDATES)
+			if err != nil {
+				t.Fatalf("failed to derive %d-bit prime with fixed rounds: %v", bits, err)
+			}
+			p2, err := derivePrime(t.Context(), adaptive, bits, DEFAULT_MAX_PRIME_CANDIDATES)
+			if err != nil {
+				t.Fatalf("failed to derive %d-bit prime with adaptive rounds: %v", bits, err)
+			}
+			if p1.Cmp(p2) != 0 {
+				t.Fatalf("%d-bit %s: adaptive rounds selected a different prime", bits, label)
+			}
+		}
+	}
+}
+
+func BenchmarkDerivePrime(b *testing.B) {
+	g := DefaultGenerator()
+	_, seed, err := g.deriveSeed(MustParseMnemonic(rsaTestMnemonic), SALT)
+	if err != nil {
+		b.Fatalf("failed to derive seed: %v", err)
+	}
+
+	for _, bits := range []int{1024, 2048} {
+		for _, mode := range []struct {
+			name   string
+			rounds int
+		}{{"fixed", PRIMALITY_TESTS}, {"adaptive", primalityRounds(bits)}} {
+			b.Run(fmt.Sprintf("%d/%s", bits, mode.name), func(b *testing.B) {
+				for b.Loop() {
+					stream, err := g.labeledStream(seed, SALT, "prime-0")
+					if err != nil {
+						b.Fatalf("failed to create prime stream: %v", err)
+					}
+					if _, err := derivePrimeRounds(b.Context(), stream, bits, mode.rounds, DEFAULT_MAX_PRIME_CANDIDATES); err != nil {
+						b.Fatalf("failed to derive prime: %v", err)
+					}
+				}
+			})
+		}
+	}
+}
+
+// constantReader is a degenerate DeterministicReader that always returns the same byte
+type constantReader byte
+
+func (r constantReader) Read(dst []byte) (int, error) {
+	for i := range dst {
+		dst[i] = byte(r)
+	}
+	return len(dst), nil
+}
+
+func (r constantReader) IgnoresMaybeReadByte() bool {
+	return true
+}
+
+func TestPrimeCandidateCap(t *testing.T) {
+	// every candidate is 2^1023 + 1, which is divisible by 3, so the search can never succeed
+	if _, err := derivePrime(t.Context(), constantReader(0), 1024, 1000); err == nil {
+		t.Fatalf("expected the prime search to stop at the candidate cap")
+	}
+
+	// a stream that keeps yielding the same prime can never produce a distinct q
+	prime := func(i int) (DeterministicReader, error) { return constantReader(0x83), nil } // always 131
+	if _, err := generateRSAPrimes(t.Context(), prime, 16, 100, RSAExponent{E: RSA_PUBLIC_EXPONENT}); err == nil {
+		t.Fatalf("expected the q redraws to stop at the candidate cap")
+	}
+
+	g, err := NewGenerator(Config{Derivation: DerivationV2, MaxPrimeCandidates: 1})
+	if err != nil {
+		t.Fatalf("failed to create generator: %v", err)
+	}
+	if _, err := g.GenerateKeyFromMnemonic(t.Context(), KeyTypeRSA, int(RSAKey2048), SALT, MustParseMnemonic(rsaTestMnemonic)); err == nil {
+		t.Fatalf("expected RSA generation to fail with a single allowed candidate")
+	}
+}
+
+func TestRSAModulusSize(t *testing.T) {
+	// about 39% of prime pairs with only their top bit set yield a short modulus, so several indices exercise the q redraw
+	mnemonic := MustParseMnemonic(testMnemonic)
+	for index := range 8 {
+		key, err := v2Generator(t).GenerateLabeledKeyFromMnemonic(t.Context(), KeyTypeRSA, int(RSAKey2048), SALT, mnemonic, KeyLabel{Index: index})
+		if err != nil {
+			t.Fatalf("failed to generate RSA key: %v", err)
+		}
+		if bits := key.PrivateKey.(*rsa.PrivateKey).N.BitLen(); bits != 2048 {
+			t.Fatalf("unexpected RSA modulus size at index %d: got %d bits, want 2048", index, bits)
+		}
+	}
+}
+
+func TestRSAExponentInvert(t *testing.T) {
+	// phi = 30 shares a factor with 3 and 5, so the fallback selects 17
+	phi := big.NewInt(30)
+	if e, d := (RSAExponent{E: 3, Fallback: true}).invert(phi); e != 17 || d == nil {
+		t.Fatalf("expected the fallback to select e = 17, found %d", e)
+	}
+	if _, d := (RSAExponent{E: 3}).invert(phi); d != nil {
+		t.Fatalf("expected no invertible exponent without fallback")
+	}
+
+	for _, e := range []int{-3, 1, 2, 4, 65536} {
+		if _, err := NewGenerator(Config{RSAExponent: RSAExponent{E: e}}); err == nil {
+			t.Fatalf("expected the RSA public exponent %d to be rejected", e)
+		}
+	}
+	if _, err := NewGenerator(Config{Derivation: DerivationV1, RSAExponent: RSAExponent{E: 3}}); err == nil {
+		t.Fatalf("expected a custom RSA public exponent to be rejected with derivation v1")
+	}
+}
+
+func TestRSAExponentFallback(t *testing.T) {
+	fallback, err := NewGenerator(Config{Derivation: DerivationV2, RSAExponent: RSAExponent{E: 3, Fallback: true}})
+	if err != nil {
+		t.Fatalf("failed to create generator: %v", err)
+	}
+	strict, err := NewGenerator(Config{Derivation: DerivationV2, RSAExponent: RSAExponent{E: 3}})
+	if err != nil {
+		t.Fatalf("failed to create generator: %v", err)
+	}
+
+	// e = 3 divides p-1 or q-1 for about 3 in 4 prime pairs, so several mnemonics exercise the fallback
+	fellBack := 0
+	for i := range 4 {
+		mnemonic, err := MnemonicFromEntropy(bytes.Repeat([]byte{byte(i + 1)}, MNEMONIC_ENTROPY_BITS/8))
+		if err != nil {
+			t.Fatalf("failed to create mnemonic: %v", err)
+		}
+
+		k, err := fallback.GenerateKeyFromMnemonic(t.Context(), KeyTypeRSA, int(RSAKey2048), SALT, mnemonic)
+		if err != nil {
+			t.Fatalf("failed to generate RSA key with exponent fallback: %v", err)
+		}
+		priv := k.PrivateKey.(*rsa.PrivateKey)
+		if err := priv.Validate(); err != nil {
+			t.Fatalf("invalid RSA key: %v", err)
+		}
+
+		// every smaller candidate must share a factor with phi, otherwise it would have been selected
+		one := big.NewInt(1)
+		phi := new(big.Int).Mul(new(big.Int).Sub(priv.Primes[0], one), new(big.Int).Sub(priv.Primes[1], one))
+		if !slices.Contains(rsaExponentCandidates, priv.E) {
+			t.Fatalf("unexpected public exponent %d", priv.E)
+		}
+		for _, candidate := range rsaExponentCandidates {
+			if candidate >= priv.E {
+				break
+			}
+			if new(big.Int).GCD(nil, nil, big.NewInt(int64(candidate)), phi).Cmp(one) == 0 {
+				t.Fatalf("exponent %d was skipped although it is invertible", candidate)
+			}
+		}
+
+		again, err := fallback.GenerateKeyFromMnemonic(t.Context(), KeyTypeRSA, int(RSAKey2048), SALT, mnemonic)
+		if err != nil {
+			t.Fatalf("failed to regenerate RSA key: %v", err)
+		}
+		if again.Fingerprint() != k.Fingerprint() {
+			t.Fatalf("RSA key with exponent fallback is not reproducible")
+		}
+
+		// without fallback the primes are redrawn instead, and both agree whenever e = 3 works for the first primes
+		s, err := strict.GenerateKeyFromMnemonic(t.Context(), KeyTypeRSA, int(RSAKey2048), SALT, mnemonic)
+		if err != nil {
+			t.Fatalf("failed to generate RSA key with e = 3: %v", err)
+		}
+		if e := s.PrivateKey.(*rsa.PrivateKey).E; e != 3 {
+			t.Fatalf("expected e = 3 without fallback, found %d", e)
+		}
+		if priv.E == 3 && s.Fingerprint() != k.Fingerprint() {
+			t.Fatalf("keys with e = 3 differ between fallback and redraw")
+		}
+		if priv.E != 3 {
+			fellBack++
+		}
+	}
+	if fellBack == 0 {
+		t.Fatalf("expected at least one mnemonic to need the exponent fallback")
+	}
+}
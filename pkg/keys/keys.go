@@ -1,14 +1,19 @@
 package keys
 
 import (
+	"bytes"
 	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
 	"crypto/sha256"
 	"encoding/pem"
+	"errors"
 	"fmt"
-	"reflect"
+	"math/big"
+	"slices"
 
 	"github.com/rs/zerolog/log"
-	"github.com/tyler-smith/go-bip39"
 	"github.com/youmark/pkcs8"
 )
 
@@ -21,38 +26,66 @@ const (
 )
 
 type Key struct {
-	encrypted  bool
-	keyType    KeyType
-	keyId      int
-	salt       string
-	PrivateKey crypto.PrivateKey
-	Der        []byte
-	mnemonic   Mnemonic
+	encrypted   bool
+	keyType     KeyType
+	keyId       int
+	salt        string
+	PrivateKey  crypto.PrivateKey
+	Der         []byte
+	mnemonic    Mnemonic
+	derivation  DerivationVersion
+	label       KeyLabel
+	path        DerivationPath // BIP-32 path of a SLIP-10 key, nil for keys derived by the HKDF scheme
+	brainwallet bool           // derived from a passphrase instead of a mnemonic, see GenerateKeyFromPassphrase
+	comment     string         // comment embedded in the formats that carry one, empty for the label's default
+	gen         *Generator     // generator used to derive the key, used for display formatting
 }
 
-var longestWordLen int
-var formatWord string
-
-func init() {
-	for _, word := range bip39.GetWordList() {
-		if len(word) > longestWordLen {
-			longestWordLen = len(word)
-		}
+// generator returns the generator that derived the key, or the default generator
+func (k *Key) generator() *Generator {
+	if k.gen == nil {
+		return defaultGenerator
 	}
-
-	formatWord = fmt.Sprintf("%%02d: %%-%ds", longestWordLen+1)
+	return k.gen
 }
 
-// Encrypt encrypts the private key using the provided password
+// ErrEncryptUnsupportedForKeyType is returned when the private key cannot be password-encrypted as PKCS#8
+var ErrEncryptUnsupportedForKeyType = errors.New("password encryption is not supported for this key type")
+
+// Encrypt encrypts the private key using the provided password, with the default PKCS#8 options (PBES2 with
+// PBKDF2-HMAC-SHA256 and AES-256-CBC)
 func (k *Key) Encrypt(password string) error {
+	return k.EncryptWithOpts(password, pkcs8.DefaultOpts)
+}
+
+// EncryptWithOpts encrypts the private key using the provided password and PKCS#8 cipher and KDF options. The result
+// is decrypted again before it is accepted, so a key type the options cannot protect fails with
+// ErrEncryptUnsupportedForKeyType instead of producing an unrecoverable key.
+func (k *Key) EncryptWithOpts(password string, opts *pkcs8.Opts) error {
 	if k.encrypted {
 		return fmt.Errorf("key is already encrypted")
 	}
+	if password == "" {
+		return fmt.Errorf("password must not be empty")
+	}
+
+	switch k.PrivateKey.(type) {
+	case *rsa.PrivateKey, *ecdsa.PrivateKey, ed25519.PrivateKey:
+	default:
+		return fmt.Errorf("%w: %T", ErrEncryptUnsupportedForKeyType, k.PrivateKey)
+	}
 
 	// marshal and encrypt private key to DER format
-	der, err := pkcs8.MarshalPrivateKey(k.PrivateKey, []byte(password), pkcs8.DefaultOpts)
+	der, err := pkcs8.MarshalPrivateKey(k.PrivateKey, []byte(password), opts)
 	if err != nil {
-		return fmt.Errorf("failed to encrypt private key: %w", err)
+		return fmt.Errorf("%w: %T: %v (try EncryptWithOpts with another cipher)", ErrEncryptUnsupportedForKeyType, k.PrivateKey, err)
+	}
+	privKey, err := pkcs8.ParsePKCS8PrivateKey(der, []byte(password))
+	if err == nil {
+		err = matchPrivateKey(privKey, k.PrivateKey)
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %T does not decrypt to the original key: %v (try EncryptWithOpts with another cipher)", ErrEncryptUnsupportedForKeyType, k.PrivateKey, err)
 	}
 
 	k.Der = der
@@ -71,35 +104,159 @@ func (k *Key) Decrypt(password string) error {
 		return fmt.Errorf("failed to decrypt private key: %w", err)
 	}
 
-	if reflect.TypeOf(privKey) != reflect.TypeOf(k.PrivateKey) {
-		return fmt.Errorf("decrypted key type does not match original key type")
+	if err := matchPrivateKey(privKey, k.PrivateKey); err != nil {
+		return err
 	}
 
-	if !reflect.DeepEqual(privKey, k.PrivateKey) {
-		return fmt.Errorf("decrypted key does not match original key")
+	return k.Rederive()
+}
+
+// matchPrivateKey checks that a decrypted private key is the original key. The algorithm and shape of the key are
+// compared before its value, so that a mismatch is reported precisely.
+func matchPrivateKey(decrypted, original crypto.PrivateKey) error {
+	switch orig := original.(type) {
+	case *rsa.PrivateKey:
+		dec, ok := decrypted.(*rsa.PrivateKey)
+		if !ok {
+			return fmt.Errorf("decrypted key is %T, expected an RSA key", decrypted)
+		}
+		if dec.N.BitLen() != orig.N.BitLen() {
+			return fmt.Errorf("decrypted RSA modulus is %d bits, expected %d", dec.N.BitLen(), orig.N.BitLen())
+		}
+		if dec.N.Cmp(orig.N) != 0 {
+			return fmt.Errorf("decrypted RSA modulus differs")
+		}
+		if !dec.PublicKey.Equal(&orig.PublicKey) {
+			return fmt.Errorf("decrypted RSA public exponent differs")
+		}
+		if !dec.Equal(orig) {
+			return fmt.Errorf("decrypted RSA private exponent or primes differ")
+		}
+	case *ecdsa.PrivateKey:
+		dec, ok := decrypted.(*ecdsa.PrivateKey)
+		if !ok {
+			return fmt.Errorf("decrypted key is %T, expected an ECDSA key", decrypted)
+		}
+		if dec.Curve.Params().Name != orig.Curve.Params().Name {
+			return fmt.Errorf("decrypted ECDSA key is on curve %s, expected %s", dec.Curve.Params().Name, orig.Curve.Params().Name)
+		}
+		if !dec.PublicKey.Equal(&orig.PublicKey) {
+			return fmt.Errorf("decrypted ECDSA public key differs")
+		}
+		if !dec.Equal(orig) {
+			return fmt.Errorf("decrypted ECDSA private scalar differs")
+		}
+	case ed25519.PrivateKey:
+		dec, ok := decrypted.(ed25519.PrivateKey)
+		if !ok {
+			return fmt.Errorf("decrypted key is %T, expected an Ed25519 key", decrypted)
+		}
+		if len(dec) != len(orig) {
+			return fmt.Errorf("decrypted Ed25519 key is %d bytes, expected %d", len(dec), len(orig))
+		}
+		if !dec.Equal(orig) {
+			return fmt.Errorf("decrypted Ed25519 key differs")
+		}
+	default:
+		return fmt.Errorf("unsupported private key type %T", original)
 	}
+	return nil
+}
 
-	k.Der, err = pkcs8.MarshalPrivateKey(privKey, nil, nil)
+// Rederive re-marshals the private key to canonical unencrypted PKCS#8 DER, discarding any encryption
+func (k *Key) Rederive() error {
+	der, err := pkcs8.MarshalPrivateKey(k.PrivateKey, nil, nil)
 	if err != nil {
 		return fmt.Errorf("failed to marshal private key: %w", err)
 	}
+
+	k.Der = der
 	k.encrypted = false
 	return nil
 }
 
+// Clone returns an independent copy of the key. The DER, BIP-32 path, and RSA, ECDSA, and Ed25519 private keys are
+// deep-copied, so zeroizing or re-marshaling one copy does not affect the other. Other private key types are shared.
+func (k *Key) Clone() *Key {
+	clone := *k
+	clone.Der = bytes.Clone(k.Der)
+	clone.path = slices.Clone(k.path)
+	clone.PrivateKey = clonePrivateKey(k.PrivateKey)
+	return &clone
+}
+
+// clonePrivateKey deep-copies the private key types that bipkey derives
+func clonePrivateKey(priv crypto.PrivateKey) crypto.PrivateKey {
+	switch priv := priv.(type) {
+	case *rsa.PrivateKey:
+		clone := &rsa.PrivateKey{
+			PublicKey: rsa.PublicKey{N: new(big.Int).Set(priv.N), E: priv.E},
+			D:         new(big.Int).Set(priv.D),
+		}
+		for _, prime := range priv.Primes {
+			clone.Primes = append(clone.Primes, new(big.Int).Set(prime))
+		}
+		clone.Precompute()
+		return clone
+	case *ecdsa.PrivateKey:
+		return &ecdsa.PrivateKey{
+			PublicKey: ecdsa.PublicKey{Curve: priv.Curve, X: new(big.Int).Set(priv.X), Y: new(big.Int).Set(priv.Y)},
+			D:         new(big.Int).Set(priv.D),
+		}
+	case ed25519.PrivateKey:
+		return ed25519.PrivateKey(bytes.Clone(priv))
+	}
+	return priv
+}
+
+// Zero overwrites the DER, the mnemonic, and the secret values of the private key, and drops the private key. The
+// key cannot be used afterwards. Copies made with Clone are not affected.
+func (k *Key) Zero() {
+	clear(k.Der)
+	clear(k.mnemonic[:])
+	zeroPrivateKey(k.PrivateKey)
+	k.Der, k.PrivateKey = nil, nil
+}
+
+// zeroPrivateKey overwrites the secret values of a private key, e.g. one discarded when generation is cancelled
+func zeroPrivateKey(priv crypto.PrivateKey) {
+	switch priv := priv.(type) {
+	case *rsa.PrivateKey:
+		zeroInt(priv.D)
+		for _, prime := range priv.Primes {
+			zeroInt(prime)
+		}
+		zeroInt(priv.Precomputed.Dp)
+		zeroInt(priv.Precomputed.Dq)
+		zeroInt(priv.Precomputed.Qinv)
+	case *ecdsa.PrivateKey:
+		zeroInt(priv.D)
+	case ed25519.PrivateKey:
+		clear(priv)
+	}
+}
+
+// zeroInt overwrites the words of a big integer and sets it to zero
+func zeroInt(n *big.Int) {
+	if n == nil {
+		return
+	}
+	clear(n.Bits())
+	n.SetInt64(0)
+}
+
 // PEM returns the PEM-encoded representation of the private key
 func (k Key) PEM() string {
-	var t string
+	return string(pem.EncodeToMemory(k.pkcs8Block()))
+}
+
+// pkcs8Block returns the PEM block of the PKCS#8 private key, which is encrypted if the key is
+func (k Key) pkcs8Block() *pem.Block {
+	t := "PRIVATE KEY"
 	if k.encrypted {
 		t = "ENCRYPTED PRIVATE KEY"
-	} else {
-		t = "PRIVATE KEY"
 	}
-
-	return string(pem.EncodeToMemory(&pem.Block{
-		Type:  t,
-		Bytes: k.Der,
-	}))
+	return &pem.Block{Type: t, Bytes: k.Der}
 }
 
 // Fingerprint returns the SHA-256 fingerprint of the PEM-encoded private key
@@ -113,39 +270,161 @@ func (k Key) Fingerprint() string {
 	return fmt.Sprintf("%x", h.Sum(nil))
 }
 
-func (k *Key) Display() {
-	const cols = 6
+// Derivation returns the derivation version the key was derived with
+func (k Key) Derivation() DerivationVersion {
+	if k.derivation == DerivationNone {
+		return DEFAULT_DERIVATION
+	}
+	return k.derivation
+}
 
-	// Display key information
-	fmt.Printf("Key Type: %s\n", k.keyType)
-	var keySize int
+// Path returns the BIP-32 path of a key derived by SLIP-10, or nil
+func (k Key) Path() DerivationPath {
+	return k.path
+}
+
+// DerivationName returns the derivation version, suffixed with "-entropy" for a key derived from the mnemonic
+// entropy or "-brainwallet" for a key derived from a passphrase, or "slip10" for a key derived along a BIP-32 path
+func (k Key) DerivationName() string {
+	if k.path != nil {
+		return DERIVATION_SLIP10
+	}
+	if k.brainwallet {
+		return k.Derivation().String() + DERIVATION_BRAINWALLET_SUFFIX
+	}
+	if k.EntropySeed() {
+		return k.Derivation().String() + DERIVATION_ENTROPY_SUFFIX
+	}
+	return k.Derivation().String()
+}
+
+// EntropySeed reports whether the key was derived from the mnemonic entropy instead of the BIP-39 seed
+func (k Key) EntropySeed() bool {
+	return k.generator().EntropySeed()
+}
+
+// Brainwallet reports whether the key was derived from a passphrase instead of a mnemonic
+func (k Key) Brainwallet() bool {
+	return k.brainwallet
+}
+
+// Mnemonic returns the normalized mnemonic the key was derived from
+func (k Key) Mnemonic() Mnemonic {
+	return k.mnemonic
+}
+
+// Scrypt returns the scrypt seed stretch parameters the key was derived with, or nil if the seed was not stretched
+func (k Key) Scrypt() *ScryptParams {
+	return k.generator().Scrypt()
+}
+
+// Label returns the label that selected the key
+func (k Key) Label() KeyLabel {
+	return k.label
+}
+
+// Comment returns the comment embedded in the formats that carry one: the comment set with SetComment, or the
+// default comment of the key's label, e.g. "bipkey:root-ca:0"
+func (k Key) Comment() string {
+	if k.comment != "" {
+		return k.comment
+	}
+	return k.label.Comment()
+}
+
+// SetComment sets the comment embedded in the formats that carry one, or restores the label's default if empty.
+// The comment is not part of the key, so it never changes the key's fingerprint.
+func (k *Key) SetComment(comment string) {
+	k.comment = comment
+}
+
+// Size returns the size of the key in bits, or 0 if the key type or id is unknown
+func (k Key) Size() int {
 	switch k.keyType {
 	case KeyTypeECC:
-		keySize = getSizeECC(ECCCurveID(k.keyId))
+		return getSizeECC(ECCCurveID(k.keyId))
 	case KeyTypeRSA:
-		keySize = getSizeRSA(RSAKeyID(k.keyId))
+		return getSizeRSA(RSAKeyID(k.keyId))
+	}
+	return 0
+}
+
+// ModulusBits returns the actual bit length of an RSA key's modulus, which should equal Size, or 0 for other keys
+func (k Key) ModulusBits() int {
+	if priv, ok := k.PrivateKey.(*rsa.PrivateKey); ok {
+		return priv.N.BitLen()
 	}
+	return 0
+}
 
-	fmt.Printf("Key Size: %d\n", keySize)
+// Display prints the key information, mnemonic, and PKCS#8 PEM-encoded private key
+func (k *Key) Display() {
+	_ = k.DisplayFormat(FormatPKCS8)
+}
+
+// DisplayFormat prints the key information, mnemonic, and private key encoded in the given format
+func (k *Key) DisplayFormat(format Format) error {
+	encoded, err := k.Encode(format)
+	if err != nil {
+		return err
+	}
+
+	g := k.generator()
+
+	// Display key information
+	fmt.Printf("Key Type: %s\n", k.keyType)
+	fmt.Printf("Key Size: %d\n", k.Size())
+	if bits := k.ModulusBits(); bits != 0 {
+		if bits == k.Size() {
+			fmt.Printf("Key Modulus: %d bits (matches the key size)\n", bits)
+		} else {
+			fmt.Printf("Key Modulus: %d bits (expected %d)\n", bits, k.Size())
+		}
+	}
+	if k.path != nil {
+		fmt.Printf("Key Derivation: SLIP-10 (BIP-32 path %s)\n", k.path)
+	} else if k.brainwallet {
+		fmt.Printf("Key Derivation: %s (BRAINWALLET: passphrase with Argon2id %s, no mnemonic)\n", k.Derivation(), BrainwalletArgon2Params)
+	} else if g.entropySeed {
+		fmt.Printf("Key Derivation: %s (mnemonic entropy seed, no BIP-39 seed)\n", k.Derivation())
+	} else {
+		fmt.Printf("Key Derivation: %s\n", k.Derivation())
+	}
+	if params := g.scrypt; params != nil {
+		fmt.Printf("Key KDF: %s\n", params)
+	}
+	if k.label.Identity != "" {
+		fmt.Printf("Key Identity: \"%s\"\n", k.label.Identity)
+	}
+	if !k.label.IsZero() {
+		fmt.Printf("Key Index: %d\n", k.label.Index)
+	}
 	if k.salt == "" {
 		fmt.Printf("Key Salt: (none)\n")
 	} else {
 		fmt.Printf("Key Salt: \"%s\"\n", k.salt)
 	}
 
-	fmt.Println("\nMnemonic Words:")
-	for i, word := range k.mnemonic {
-		fmt.Printf(formatWord, i+1, word)
-		if i%cols == cols-1 {
-			fmt.Println()
-		}
+	if k.brainwallet {
+		fmt.Println("\nMnemonic Words: none, the key is restored from the passphrase and salt alone")
+	} else {
+		fmt.Println("\nMnemonic Words:")
+		fmt.Print(g.formatMnemonic(k.mnemonic))
+		fmt.Println()
+		fmt.Println(g.FormatMnemonicGroups(k.mnemonic))
+	}
+
+	if fingerprint, err := k.PublicFingerprint(FingerprintHex); err == nil {
+		fmt.Printf("\nPublic Key Fingerprint (SHA-256): %s\n", fingerprint)
+	}
+	if art, err := k.Randomart(); err == nil {
+		fmt.Println(art)
 	}
-	fmt.Println()
-	fmt.Println(k.mnemonic.String())
 
-	fmt.Println("\nPrivate Key (PEM):")
+	fmt.Printf("\nPrivate Key (%s):\n", describeFormat(format))
 	fmt.Println()
 
 	log.Debug().Str("fingerprint", k.Fingerprint()).Msg("Generated key fingerprint.")
-	fmt.Println(k.PEM())
+	fmt.Println(encoded)
+	return nil
 }
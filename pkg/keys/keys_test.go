@@ -1,9 +1,23 @@
 package keys
 
 import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/rs/zerolog"
+	"github.com/youmark/pkcs8"
 )
 
 const SALT = "bipkey-test-salt"
@@ -137,41 +151,25 @@ func TestECCKeyRestoration(t *testing.T) {
 	}
 }
 
-func TestRSAKeyRestoration(t *testing.T) {
-	tests := []testKey{
-		{
-			keyId:               int(RSAKey2048),
-			mnemonic:            MustParseMnemonic("worth ball broom life calm name foil fringe final average since traffic pig cook clap alert brush swallow rural glance guilt board vendor slight"),
-			expectedFingerprint: "9351ddab1a122380da119ff25efd15a84ea56797740be2c1a60dac75edd42bb2",
-		},
-		{
-			keyId:               int(RSAKey3072),
-			mnemonic:            MustParseMnemonic("radar spoil crazy alien park lottery bitter return original burger upon fruit clarify magnet exist wheat sugar need donor allow ripple tuna cry scatter"),
-			expectedFingerprint: "6c4efe263432292af4d4de9f9ef3d8e2b4c003ab50bbecb5b6be358384d6187f",
-		},
-		{
-			keyId:               int(RSAKey4096),
-			mnemonic:            MustParseMnemonic("kingdom marine vehicle senior cinnamon squeeze oxygen print home chest voyage service toward source glove host fit bench era bullet general kiss early math"),
-			expectedFingerprint: "89033e95b464650780b269a7ebc2b601816119d52f6909c6fd2596ee648e3cef",
-		},
-		{
-			keyId:               int(RSAKey8192),
-			mnemonic:            MustParseMnemonic("rhythm fun flush habit genuine topple dune fire food chuckle rain shoulder describe digital idle movie upgrade nerve bicycle chuckle sport alien scan frost"),
-			expectedFingerprint: "ecc797920f47adf1b043c8f304c41fa0684b184f2110aef7711794b95a531a52",
-		},
-	}
+// rsaRestorationMnemonics are the mnemonics of the RSA known-answer vectors, by key size
+var rsaRestorationMnemonics = map[RSAKeyID]string{
+	RSAKey2048: "worth ball broom life calm name foil fringe final average since traffic pig cook clap alert brush swallow rural glance guilt board vendor slight",
+	RSAKey3072: "radar spoil crazy alien park lottery bitter return original burger upon fruit clarify magnet exist wheat sugar need donor allow ripple tuna cry scatter",
+	RSAKey4096: "kingdom marine vehicle senior cinnamon squeeze oxygen print home chest voyage service toward source glove host fit bench era bullet general kiss early math",
+	RSAKey8192: "rhythm fun flush habit genuine topple dune fire food chuckle rain shoulder describe digital idle movie upgrade nerve bicycle chuckle sport alien scan frost",
+}
 
-	for _, test := range tests {
-		key, err := GenerateKeyFromMnemonic(
-			t.Context(),
-			KeyTypeRSA,
-			test.keyId,
-			SALT,
-			test.mnemonic,
-		)
+// checkRSARestoration derives the RSA known-answer keys with the generator and compares their fingerprints
+func checkRSARestoration(t *testing.T, g *Generator, expected map[RSAKeyID]string) {
+	t.Helper()
+	for id := RSAKeyNone + 1; id < rsaKeyEnd; id++ {
+		key, err := g.GenerateKeyFromMnemonic(t.Context(), KeyTypeRSA, int(id), SALT, MustParseMnemonic(rsaRestorationMnemonics[id]))
 		if err != nil {
 			t.Fatalf("failed to generate RSA key from mnemonic: %v", err)
 		}
+		if bits := key.PrivateKey.(*rsa.PrivateKey).N.BitLen(); bits != key.Size() {
+			t.Fatalf("unexpected RSA modulus size: got %d bits, want %d", bits, key.Size())
+		}
 
 		fingerprint1 := key.Fingerprint()
 
@@ -188,8 +186,397 @@ func TestRSAKeyRestoration(t *testing.T) {
 			t.Fatalf("RSA key fingerprints do not match after encrypt/decrypt: %s != %s", fingerprint1, fingerprint2)
 		}
 
-		if fingerprint1 != test.expectedFingerprint {
-			t.Fatalf("unexpected RSA key fingerprint: got %s, want %s", fingerprint1, test.expectedFingerprint)
+		if fingerprint1 != expected[id] {
+			t.Fatalf("unexpected RSA-%d key fingerprint: got %s, want %s", getSizeRSA(id), fingerprint1, expected[id])
+		}
+	}
+}
+
+func TestRSAKeyRestoration(t *testing.T) {
+	// the original vectors, which keys restored with default flags must keep matching
+	expected := map[RSAKeyID]string{
+		RSAKey2048: "9351ddab1a122380da119ff25efd15a84ea56797740be2c1a60dac75edd42bb2",
+		RSAKey3072: "6c4efe263432292af4d4de9f9ef3d8e2b4c003ab50bbecb5b6be358384d6187f",
+		RSAKey4096: "89033e95b464650780b269a7ebc2b601816119d52f6909c6fd2596ee648e3cef",
+		RSAKey8192: "ecc797920f47adf1b043c8f304c41fa0684b184f2110aef7711794b95a531a52",
+	}
+	g, err := NewGenerator(Config{Derivation: DerivationV1})
+	if err != nil {
+		t.Fatalf("failed to create generator: %v", err)
+	}
+	checkRSARestoration(t, g, expected)
+}
+
+func TestRSAKeyRestorationV2(t *testing.T) {
+	checkRSARestoration(t, v2Generator(t), map[RSAKeyID]string{
+		RSAKey2048: "5328079f5fdc59942a6fedb303459d5ca3da31d96b27dab7e9011974d495d785",
+		RSAKey3072: "dd50d36dc4387042bbdab5b3965425ebd12852e99cb4f4acfb0fc0bb30431b1f",
+		RSAKey4096: "ad8e830c1d9338e39d9d6fd45be38046e228cc48f2dcbd147732b12c8f77c6d6",
+		RSAKey8192: "c35e3b33e0b51ff78432c49127e10197699fc83c9ea6e75e04b0c11dba5f1d36",
+	})
+}
+
+func TestDefaultDerivation(t *testing.T) {
+	// keys made with default settings must keep restoring with default settings, on any Go release
+	if DEFAULT_DERIVATION != DerivationV1 || DefaultGenerator().Derivation() != DerivationV1 {
+		t.Fatalf("expected the default derivation to stay %s, got %s", DerivationV1, DefaultGenerator().Derivation())
+	}
+	mnemonic := MustParseMnemonic(rsaRestorationMnemonics[RSAKey2048])
+	legacy, err := NewGenerator(Config{Derivation: DerivationV1})
+	if err != nil {
+		t.Fatalf("failed to create generator: %v", err)
+	}
+	expected, err := legacy.GenerateKeyFromMnemonic(t.Context(), KeyTypeRSA, int(RSAKey2048), SALT, mnemonic)
+	if err != nil {
+		t.Fatalf("failed to generate RSA key from mnemonic: %v", err)
+	}
+	key, err := GenerateKeyFromMnemonic(t.Context(), KeyTypeRSA, int(RSAKey2048), SALT, mnemonic)
+	if err != nil {
+		t.Fatalf("failed to generate RSA key from mnemonic: %v", err)
+	}
+	if key.Derivation() != DerivationV1 || key.Fingerprint() != expected.Fingerprint() {
+		t.Fatalf("expected the default generator to derive the v1 key")
+	}
+}
+
+func TestCancelledContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+
+	k, err := GenerateKey(ctx, KeyTypeRSA, int(RSAKey8192), SALT)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if k != nil {
+		t.Fatalf("expected no key for a cancelled context")
+	}
+
+	mnemonic := MustParseMnemonic("rhythm fun flush habit genuine topple dune fire food chuckle rain shoulder describe digital idle movie upgrade nerve bicycle chuckle sport alien scan frost")
+	k, err = GenerateKeyFromMnemonic(ctx, KeyTypeRSA, int(RSAKey8192), SALT, mnemonic)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if k != nil {
+		t.Fatalf("expected no key for a cancelled context")
+	}
+}
+
+func TestCancelMidGeneration(t *testing.T) {
+	// cancel while the RSA-8192 prime search is running, as on Ctrl-C. Only the v2 prime search can be interrupted,
+	// v1 leaves it to rsa.GenerateKey.
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	start := time.Now()
+	k, err := v2Generator(t).GenerateKeyFromMnemonic(ctx, KeyTypeRSA, int(RSAKey8192), SALT, MustParseMnemonic(rsaTestMnemonic))
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if k != nil {
+		t.Fatalf("expected no key when generation is cancelled")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("expected the prime search to stop promptly on cancellation, took %s", elapsed)
+	}
+}
+
+func TestInvalidKeyID(t *testing.T) {
+	tests := []struct {
+		keyType KeyType
+		keyId   int
+		err     error
+	}{
+		{KeyTypeECC, int(ECCCurveNone), ErrInvalidKeyID},
+		{KeyTypeECC, int(eccCurveEnd), ErrInvalidKeyID},
+		{KeyTypeECC, -1, ErrInvalidKeyID},
+		{KeyTypeRSA, int(RSAKeyNone), ErrInvalidKeyID},
+		{KeyTypeRSA, int(rsaKeyEnd), ErrInvalidKeyID},
+		{KeyTypeRSA, 2048, ErrInvalidKeyID},
+		{KeyTypeNone, int(ECCCurveP256), ErrUnsupportedKeyType},
+		{KeyType("DSA"), 1, ErrUnsupportedKeyType},
+	}
+	mnemonic := MustParseMnemonic(testMnemonic)
+	for _, tt := range tests {
+		k, err := GenerateKeyFromMnemonic(t.Context(), tt.keyType, tt.keyId, SALT, mnemonic)
+		if !errors.Is(err, tt.err) || k != nil {
+			t.Fatalf("%q %d: expected %v, got %v", tt.keyType, tt.keyId, tt.err, err)
+		}
+		if _, err := GenerateKey(t.Context(), tt.keyType, tt.keyId, SALT); !errors.Is(err, tt.err) {
+			t.Fatalf("%q %d: expected %v from GenerateKey, got %v", tt.keyType, tt.keyId, tt.err, err)
+		}
+	}
+
+	for id := ECCCurveNone + 1; id < eccCurveEnd; id++ {
+		if err := ValidateKeyID(KeyTypeECC, int(id)); err != nil {
+			t.Fatalf("expected ECC curve id %d to be valid: %v", id, err)
+		}
+	}
+	for id := RSAKeyNone + 1; id < rsaKeyEnd; id++ {
+		if err := ValidateKeyID(KeyTypeRSA, int(id)); err != nil {
+			t.Fatalf("expected RSA key size id %d to be valid: %v", id, err)
+		}
+	}
+}
+
+func TestEnumsAreFullyWired(t *testing.T) {
+	for id := ECCCurveNone + 1; id < eccCurveEnd; id++ {
+		if getSizeECC(id) == 0 {
+			t.Fatalf("ECC curve %d has no size in getSizeECC", id)
+		}
+
+		k := Key{keyType: KeyTypeECC, keyId: int(id)}
+		if k.Size() != getSizeECC(id) {
+			t.Fatalf("ECC curve %d: Display size %d does not match getSizeECC %d", id, k.Size(), getSizeECC(id))
+		}
+
+		found := false
+		for _, info := range supportedECCCurves {
+			if info.ID != id {
+				continue
+			}
+			found = true
+			for _, alias := range info.Aliases {
+				parsed, err := ParseECCCurve(alias)
+				if err != nil || parsed != id {
+					t.Fatalf("ECC curve %d: alias %q parsed to %d (%v)", id, alias, parsed, err)
+				}
+			}
+		}
+		if !found {
+			t.Fatalf("ECC curve %d is missing from supportedECCCurves", id)
+		}
+	}
+
+	for id := RSAKeyNone + 1; id < rsaKeyEnd; id++ {
+		size := getSizeRSA(id)
+		if size == 0 {
+			t.Fatalf("RSA key %d has no size in getSizeRSA", id)
+		}
+
+		k := Key{keyType: KeyTypeRSA, keyId: int(id)}
+		if k.Size() != size {
+			t.Fatalf("RSA key %d: Display size %d does not match getSizeRSA %d", id, k.Size(), size)
+		}
+
+		parsed, err := ParseRSAKeyID(fmt.Sprint(size))
+		if err != nil || parsed != id {
+			t.Fatalf("RSA key %d: size %d parsed to %d (%v)", id, size, parsed, err)
+		}
+	}
+
+	if getSizeECC(ECCCurveNone) != 0 || getSizeECC(eccCurveEnd) != 0 {
+		t.Fatalf("expected size 0 for undefined ECC curves")
+	}
+	if getSizeRSA(RSAKeyNone) != 0 || getSizeRSA(rsaKeyEnd) != 0 {
+		t.Fatalf("expected size 0 for undefined RSA keys")
+	}
+}
+
+func TestSupportedListings(t *testing.T) {
+	curves := SupportedECCCurves()
+	if len(curves) != int(eccCurveEnd-ECCCurveNone-1) {
+		t.Fatalf("expected %d ECC curves, found %d", eccCurveEnd-ECCCurveNone-1, len(curves))
+	}
+	for i, info := range curves {
+		if info.ID != ECCCurveNone+1+ECCCurveID(i) {
+			t.Fatalf("ECC curve %q listed at position %d has id %d", info.Name, i, info.ID)
+		}
+		if getSizeECC(info.ID) == 0 || len(info.Aliases) == 0 {
+			t.Fatalf("ECC curve %q is not fully defined", info.Name)
+		}
+		if !strings.Contains(SupportedECC(), info.Name) {
+			t.Fatalf("ECC curve %q is missing from SupportedECC", info.Name)
+		}
+	}
+
+	// the listing is a copy, so callers cannot change the accepted aliases
+	curves[0].Aliases[0] = "changed"
+	if _, err := ParseECCCurve("changed"); err == nil || SupportedECCCurves()[0].Aliases[0] == "changed" {
+		t.Fatalf("modifying the listing changed the supported curves")
+	}
+
+	sizes := SupportedRSASizes()
+	if len(sizes) != int(rsaKeyEnd-RSAKeyNone-1) {
+		t.Fatalf("expected %d RSA sizes, found %d", rsaKeyEnd-RSAKeyNone-1, len(sizes))
+	}
+	for i, size := range sizes {
+		id, err := ParseRSAKeyID(fmt.Sprint(size))
+		if err != nil || getSizeRSA(id) != size {
+			t.Fatalf("RSA size %d does not parse back to itself: %d (%v)", size, id, err)
+		}
+		if i > 0 && sizes[i-1] >= size {
+			t.Fatalf("RSA sizes are not in ascending order: %v", sizes)
+		}
+		if !strings.Contains(SupportedRSA(), fmt.Sprintf(" - %d\n", size)) {
+			t.Fatalf("RSA size %d is missing from SupportedRSA", size)
+		}
+	}
+}
+
+func TestRederive(t *testing.T) {
+	for _, curve := range []ECCCurveID{ECCCurveP256, ECCCurveEd25519} {
+		k, err := GenerateKeyFromMnemonic(t.Context(), KeyTypeECC, int(curve), SALT, MustParseMnemonic(testMnemonic))
+		if err != nil {
+			t.Fatalf("failed to generate ECC key from mnemonic: %v", err)
+		}
+		original := bytes.Clone(k.Der)
+
+		if err := k.Encrypt(PASSWORD); err != nil {
+			t.Fatalf("failed to encrypt ECC key: %v", err)
+		}
+		if err := k.Rederive(); err != nil {
+			t.Fatalf("failed to rederive ECC key: %v", err)
+		}
+		if k.encrypted {
+			t.Fatalf("expected rederived key to be unencrypted")
+		}
+		if !bytes.Equal(k.Der, original) {
+			t.Fatalf("rederived DER does not match the generated DER")
+		}
+	}
+}
+
+func TestCloneIsIndependent(t *testing.T) {
+	tests := []struct {
+		keyType KeyType
+		keyId   int
+	}{
+		{KeyTypeECC, int(ECCCurveP256)},
+		{KeyTypeECC, int(ECCCurveEd25519)},
+		{KeyTypeRSA, int(RSAKey2048)},
+	}
+	for _, tt := range tests {
+		k, err := GenerateKeyFromMnemonic(t.Context(), tt.keyType, tt.keyId, SALT, MustParseMnemonic(testMnemonic))
+		if err != nil {
+			t.Fatalf("failed to generate %s key from mnemonic: %v", tt.keyType, err)
+		}
+		fingerprint := k.Fingerprint()
+
+		clone := k.Clone()
+		k.Zero()
+		if k.PrivateKey != nil || k.Der != nil || k.Mnemonic() != (Mnemonic{}) {
+			t.Fatalf("expected the zeroized %s key to hold no key material", tt.keyType)
+		}
+
+		if clone.Fingerprint() != fingerprint {
+			t.Fatalf("zeroizing the %s key changed the clone's DER", tt.keyType)
+		}
+		if clone.Mnemonic() != MustParseMnemonic(testMnemonic) {
+			t.Fatalf("zeroizing the %s key changed the clone's mnemonic", tt.keyType)
+		}
+		// the clone's private key must still marshal to the original DER
+		der := bytes.Clone(clone.Der)
+		if err := clone.Rederive(); err != nil {
+			t.Fatalf("failed to rederive the %s clone: %v", tt.keyType, err)
+		}
+		if !bytes.Equal(clone.Der, der) {
+			t.Fatalf("zeroizing the %s key changed the clone's private key", tt.keyType)
+		}
+	}
+}
+
+func TestEncryptKeyTypes(t *testing.T) {
+	// every supported key type can be password-encrypted with the current pkcs8 dependency
+	type keyType struct {
+		keyType KeyType
+		keyId   int
+	}
+	var keyTypes []keyType
+	for id := ECCCurveNone + 1; id < eccCurveEnd; id++ {
+		keyTypes = append(keyTypes, keyType{KeyTypeECC, int(id)})
+	}
+	keyTypes = append(keyTypes, keyType{KeyTypeRSA, int(RSAKey2048)})
+
+	for _, kt := range keyTypes {
+		k, err := GenerateKeyFromMnemonic(t.Context(), kt.keyType, kt.keyId, SALT, MustParseMnemonic(testMnemonic))
+		if err != nil {
+			t.Fatalf("failed to generate %s key %d: %v", kt.keyType, kt.keyId, err)
+		}
+		fingerprint := k.Fingerprint()
+
+		if err := k.Encrypt(PASSWORD); err != nil {
+			t.Fatalf("%s key %d is not encryptable: %v", kt.keyType, kt.keyId, err)
+		}
+		if err := k.Decrypt(PASSWORD); err != nil || k.Fingerprint() != fingerprint {
+			t.Fatalf("%s key %d did not survive an encryption round trip (%v)", kt.keyType, kt.keyId, err)
+		}
+	}
+
+	// explicit options select another cipher and KDF
+	k, err := GenerateKeyFromMnemonic(t.Context(), KeyTypeECC, int(ECCCurveEd25519), SALT, MustParseMnemonic(testMnemonic))
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	opts := &pkcs8.Opts{Cipher: pkcs8.AES256GCM, KDFOpts: pkcs8.ScryptOpts{SaltSize: 16, CostParameter: 1 << 10, BlockSize: 8, ParallelizationParameter: 1}}
+	if err := k.EncryptWithOpts(PASSWORD, opts); err != nil {
+		t.Fatalf("failed to encrypt with explicit options: %v", err)
+	}
+	if err := k.Decrypt(PASSWORD); err != nil {
+		t.Fatalf("failed to decrypt a key encrypted with explicit options: %v", err)
+	}
+
+	if err := k.Encrypt(""); err == nil {
+		t.Fatalf("expected an error for an empty password")
+	}
+
+	unsupported := Key{keyType: KeyTypeECC, PrivateKey: struct{}{}}
+	if err := unsupported.Encrypt(PASSWORD); !errors.Is(err, ErrEncryptUnsupportedForKeyType) {
+		t.Fatalf("expected ErrEncryptUnsupportedForKeyType, got %v", err)
+	}
+}
+
+func TestMatchPrivateKey(t *testing.T) {
+	rsa1, _ := rsa.GenerateKey(rand.Reader, 1024)
+	rsa2, _ := rsa.GenerateKey(rand.Reader, 1024)
+	rsa3, _ := rsa.GenerateKey(rand.Reader, 1536)
+	p256a, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	p256b, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	p384, _ := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	_, ed1, _ := ed25519.GenerateKey(rand.Reader)
+	_, ed2, _ := ed25519.GenerateKey(rand.Reader)
+
+	// same public key, different private values
+	rsaExponent := *rsa1
+	rsaExponent.PublicKey.E = 3
+	rsaPrivate := *rsa1
+	rsaPrivate.D = new(big.Int).Add(rsa1.D, big.NewInt(1))
+	eccPrivate := *p256a
+	eccPrivate.D = new(big.Int).Add(p256a.D, big.NewInt(1))
+
+	tests := []struct {
+		name      string
+		decrypted crypto.PrivateKey
+		original  crypto.PrivateKey
+		message   string
+	}{
+		{"rsa match", rsa1, rsa1, ""},
+		{"rsa type", p256a, rsa1, "expected an RSA key"},
+		{"rsa size", rsa3, rsa1, "modulus is 1536 bits, expected 1024"},
+		{"rsa modulus", rsa2, rsa1, "RSA modulus differs"},
+		{"rsa exponent", &rsaExponent, rsa1, "public exponent differs"},
+		{"rsa private", &rsaPrivate, rsa1, "private exponent or primes differ"},
+		{"ecdsa match", p256a, p256a, ""},
+		{"ecdsa type", ed1, p256a, "expected an ECDSA key"},
+		{"ecdsa curve", p384, p256a, "on curve P-384, expected P-256"},
+		{"ecdsa public", p256b, p256a, "ECDSA public key differs"},
+		{"ecdsa private", &eccPrivate, p256a, "private scalar differs"},
+		{"ed25519 match", ed1, ed1, ""},
+		{"ed25519 type", rsa1, ed1, "expected an Ed25519 key"},
+		{"ed25519 length", ed1[:32], ed1, "is 32 bytes, expected 64"},
+		{"ed25519 value", ed2, ed1, "Ed25519 key differs"},
+	}
+
+	for _, test := range tests {
+		err := matchPrivateKey(test.decrypted, test.original)
+		if test.message == "" {
+			if err != nil {
+				t.Fatalf("%s: expected the keys to match, found %v", test.name, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), test.message) {
+			t.Fatalf("%s: expected an error containing %q, found %v", test.name, test.message, err)
 		}
 	}
 }
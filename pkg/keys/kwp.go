@@ -0,0 +1,57 @@
+package keys
+
+import (
+	"crypto/aes"
+	"encoding/binary"
+	"fmt"
+)
+
+// KWP_ALTERNATIVE_IV is the RFC 5649 alternative initial value, followed by the 32-bit plaintext length
+const KWP_ALTERNATIVE_IV = 0xA65959A6
+
+// wrapKeyWithPadding wraps the plaintext with the key encryption key using AES Key Wrap with Padding (RFC 5649)
+func wrapKeyWithPadding(kek []byte, plaintext []byte) ([]byte, error) {
+	block, err := aes.NewCipher(kek)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create AES key wrap cipher: %w", err)
+	}
+	if len(plaintext) == 0 || uint64(len(plaintext)) > 0xFFFFFFFF {
+		return nil, fmt.Errorf("invalid key wrap plaintext length %d", len(plaintext))
+	}
+
+	// the alternative IV carries the plaintext length, which is then zero padded to a multiple of 8 bytes
+	var aiv [8]byte
+	binary.BigEndian.PutUint32(aiv[:4], KWP_ALTERNATIVE_IV)
+	binary.BigEndian.PutUint32(aiv[4:], uint32(len(plaintext)))
+	padded := make([]byte, (len(plaintext)+7)/8*8)
+	copy(padded, plaintext)
+
+	// a single block is encrypted directly instead of with the wrapping process
+	if len(padded) == 8 {
+		out := make([]byte, 16)
+		copy(out, aiv[:])
+		copy(out[8:], padded)
+		block.Encrypt(out, out)
+		return out, nil
+	}
+
+	// RFC 3394 wrapping process with the alternative IV
+	n := len(padded) / 8
+	out := make([]byte, 8+len(padded))
+	copy(out[8:], padded)
+	a := aiv
+	buf := make([]byte, 16)
+	for j := range 6 {
+		for i := 1; i <= n; i++ {
+			copy(buf, a[:])
+			copy(buf[8:], out[i*8:(i+1)*8])
+			block.Encrypt(buf, buf)
+
+			t := uint64(n*j + i)
+			binary.BigEndian.PutUint64(a[:], binary.BigEndian.Uint64(buf[:8])^t)
+			copy(out[i*8:(i+1)*8], buf[8:])
+		}
+	}
+	copy(out, a[:])
+	return out, nil
+}
@@ -0,0 +1,117 @@
+package keys
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// KEY_INFO_PREFIX prefixes the HKDF info of labeled key streams
+const KEY_INFO_PREFIX = "bipkey-key"
+
+// KEY_COMMENT_PREFIX prefixes the default comment of a key, see KeyLabel.Comment
+const KEY_COMMENT_PREFIX = "bipkey"
+
+// KeyLabel selects one of many independent keys derived from the same mnemonic and salt.
+// The zero KeyLabel derives the original, unlabeled key.
+type KeyLabel struct {
+	Identity string // logical identity shared by the keys of every algorithm, e.g. "root-ca"
+	Index    int    // key index, e.g. the position of the key in a rotation set
+}
+
+// IsZero reports whether the label selects the original, unlabeled key
+func (l KeyLabel) IsZero() bool {
+	return l == KeyLabel{}
+}
+
+// Comment returns the deterministic comment of the keys the label selects, "bipkey:<identity>:<index>"
+func (l KeyLabel) Comment() string {
+	return fmt.Sprintf("%s:%s:%d", KEY_COMMENT_PREFIX, l.Identity, l.Index)
+}
+
+// validate checks that the label can be encoded
+func (l KeyLabel) validate() error {
+	if l.Index < 0 {
+		return fmt.Errorf("key index must not be negative, got %d", l.Index)
+	}
+	// the separator characters would make distinct labels encode to the same info
+	if strings.ContainsAny(l.Identity, ";=") || strings.ContainsFunc(l.Identity, unicode.IsControl) {
+		return fmt.Errorf("key identity must not contain ';', '=', or control characters: %q", l.Identity)
+	}
+	return nil
+}
+
+// algorithmName returns the stable name of the key algorithm used for domain separation, e.g. "ecc-p-256" or "rsa-4096"
+func algorithmName(keyType KeyType, keyId int) (string, error) {
+	switch keyType {
+	case KeyTypeECC:
+		for _, info := range supportedECCCurves {
+			if info.ID == ECCCurveID(keyId) {
+				return "ecc-" + strings.ToLower(info.Name), nil
+			}
+		}
+		return "", fmt.Errorf("unsupported ECC curve")
+	case KeyTypeRSA:
+		size := getSizeRSA(RSAKeyID(keyId))
+		if size == 0 {
+			return "", fmt.Errorf("unsupported RSA key size")
+		}
+		return fmt.Sprintf("rsa-%d", size), nil
+	default:
+		return "", fmt.Errorf("unsupported key type: %s", keyType)
+	}
+}
+
+// keyStreamInfo returns the HKDF info of the key stream. The zero label uses no info, so unlabeled keys are unchanged,
+// while labeled keys are separated by identity, algorithm, and index:
+//
+//	bipkey-key;identity=<identity>;alg=<algorithm>;index=<n>
+//
+// The identity element is omitted when the identity is empty.
+func keyStreamInfo(keyType KeyType, keyId int, label KeyLabel) ([]byte, error) {
+	if err := label.validate(); err != nil {
+		return nil, err
+	}
+	if label.IsZero() {
+		return nil, nil
+	}
+
+	alg, err := algorithmName(keyType, keyId)
+	if err != nil {
+		return nil, err
+	}
+	info := KEY_INFO_PREFIX
+	if label.Identity != "" {
+		info += ";identity=" + label.Identity
+	}
+	return fmt.Appendf(nil, "%s;alg=%s;index=%d", info, alg, label.Index), nil
+}
+
+// primeStreamInfo returns the HKDF info of the i-th RSA prime stream for the given key stream info
+func primeStreamInfo(keyInfo []byte, i int) string {
+	if keyInfo == nil {
+		return fmt.Sprintf("prime-%d", i)
+	}
+	return fmt.Sprintf("%s;prime=%d", keyInfo, i)
+}
+
+// CERT_SERIAL_INFO labels the HKDF stream of derived certificate serial numbers
+const CERT_SERIAL_INFO = "cert-serial"
+
+// certSerialInfo returns the HKDF info of the certificate serial number of the key selected by the label. Unlike
+// unlabeled key streams, the serial info always names the algorithm, so keys of different types never share a serial.
+func certSerialInfo(keyType KeyType, keyId int, label KeyLabel) (string, error) {
+	info, err := keyStreamInfo(keyType, keyId, label)
+	if err != nil {
+		return "", err
+	}
+	if info != nil {
+		return fmt.Sprintf("%s;%s", info, CERT_SERIAL_INFO), nil
+	}
+
+	alg, err := algorithmName(keyType, keyId)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s;alg=%s", CERT_SERIAL_INFO, alg), nil
+}
@@ -0,0 +1,136 @@
+package keys
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"testing"
+)
+
+func TestKeyLabels(t *testing.T) {
+	mnemonic := MustParseMnemonic(testMnemonic)
+
+	for _, test := range []struct {
+		keyType KeyType
+		keyId   int
+	}{
+		{KeyTypeECC, int(ECCCurveP256)},
+		{KeyTypeECC, int(ECCCurveEd25519)},
+		{KeyTypeRSA, int(RSAKey2048)},
+	} {
+		original, err := GenerateKeyFromMnemonic(t.Context(), test.keyType, test.keyId, SALT, mnemonic)
+		if err != nil {
+			t.Fatalf("failed to generate key: %v", err)
+		}
+		zero, err := GenerateLabeledKeyFromMnemonic(t.Context(), test.keyType, test.keyId, SALT, mnemonic, KeyLabel{})
+		if err != nil {
+			t.Fatalf("failed to generate zero labeled key: %v", err)
+		}
+		if original.Fingerprint() != zero.Fingerprint() {
+			t.Fatalf("%s %d: the zero label must derive the unlabeled key", test.keyType, test.keyId)
+		}
+
+		first, err := GenerateLabeledKeyFromMnemonic(t.Context(), test.keyType, test.keyId, SALT, mnemonic, KeyLabel{Index: 1})
+		if err != nil {
+			t.Fatalf("failed to generate labeled key: %v", err)
+		}
+		again, err := GenerateLabeledKeyFromMnemonic(t.Context(), test.keyType, test.keyId, SALT, mnemonic, KeyLabel{Index: 1})
+		if err != nil {
+			t.Fatalf("failed to generate labeled key: %v", err)
+		}
+		if first.Fingerprint() != again.Fingerprint() {
+			t.Fatalf("%s %d: labeled key derivation is not deterministic", test.keyType, test.keyId)
+		}
+		if first.Fingerprint() == original.Fingerprint() {
+			t.Fatalf("%s %d: index 1 must derive a different key than index 0", test.keyType, test.keyId)
+		}
+
+		// RSA primes come from their own streams, which must be separated by the label as well
+		if test.keyType == KeyTypeRSA {
+			p0 := original.PrivateKey.(*rsa.PrivateKey).Primes
+			p1 := first.PrivateKey.(*rsa.PrivateKey).Primes
+			for _, a := range p0 {
+				for _, b := range p1 {
+					if a.Cmp(b) == 0 {
+						t.Fatalf("labeled RSA key shares a prime with the unlabeled key")
+					}
+				}
+			}
+		}
+	}
+
+	if _, err := GenerateLabeledKeyFromMnemonic(t.Context(), KeyTypeECC, int(ECCCurveP256), SALT, mnemonic, KeyLabel{Index: -1}); err == nil {
+		t.Fatalf("expected an error for a negative key index")
+	}
+}
+
+func TestKeyIdentities(t *testing.T) {
+	mnemonic := MustParseMnemonic(testMnemonic)
+	label := KeyLabel{Identity: "root-ca"}
+
+	// the labeling scheme is part of the derivation, so lock the exact HKDF info
+	g := DefaultGenerator()
+	_, seed, err := g.deriveSeed(mnemonic, SALT)
+	if err != nil {
+		t.Fatalf("failed to derive seed: %v", err)
+	}
+	stream, err := g.labeledStream(seed, SALT, "bipkey-key;identity=root-ca;alg=ecc-p-256;index=0")
+	if err != nil {
+		t.Fatalf("failed to create key stream: %v", err)
+	}
+	expected, err := generateECC(t.Context(), stream, ECCCurveP256)
+	if err != nil {
+		t.Fatalf("failed to generate ECC key: %v", err)
+	}
+
+	ecc, err := GenerateLabeledKeyFromMnemonic(t.Context(), KeyTypeECC, int(ECCCurveP256), SALT, mnemonic, label)
+	if err != nil {
+		t.Fatalf("failed to generate ECC identity key: %v", err)
+	}
+	if !ecc.PrivateKey.(interface{ Equal(crypto.PrivateKey) bool }).Equal(expected) {
+		t.Fatalf("identity key does not use the documented HKDF info")
+	}
+
+	// the same identity yields a reproducible key per algorithm
+	fingerprints := map[string]bool{}
+	for _, test := range []struct {
+		keyType KeyType
+		keyId   int
+	}{
+		{KeyTypeECC, int(ECCCurveP256)},
+		{KeyTypeECC, int(ECCCurveEd25519)},
+		{KeyTypeRSA, int(RSAKey2048)},
+	} {
+		var fingerprint string
+		for range 2 {
+			k, err := GenerateLabeledKeyFromMnemonic(t.Context(), test.keyType, test.keyId, SALT, mnemonic, label)
+			if err != nil {
+				t.Fatalf("failed to generate identity key: %v", err)
+			}
+			if fingerprint != "" && k.Fingerprint() != fingerprint {
+				t.Fatalf("%s %d: identity key derivation is not deterministic", test.keyType, test.keyId)
+			}
+			fingerprint = k.Fingerprint()
+		}
+		fingerprints[fingerprint] = true
+	}
+	if len(fingerprints) != 3 {
+		t.Fatalf("expected a distinct key per algorithm")
+	}
+
+	// other identities and the unlabeled key are unrelated
+	for _, other := range []KeyLabel{{}, {Identity: "intermediate-ca"}, {Identity: "root-ca", Index: 1}} {
+		k, err := GenerateLabeledKeyFromMnemonic(t.Context(), KeyTypeECC, int(ECCCurveP256), SALT, mnemonic, other)
+		if err != nil {
+			t.Fatalf("failed to generate key: %v", err)
+		}
+		if k.Fingerprint() == ecc.Fingerprint() {
+			t.Fatalf("label %+v derives the same key as %+v", other, label)
+		}
+	}
+
+	for _, identity := range []string{"a;alg=rsa-2048", "a=b", "line\nbreak"} {
+		if _, err := GenerateLabeledKeyFromMnemonic(t.Context(), KeyTypeECC, int(ECCCurveP256), SALT, mnemonic, KeyLabel{Identity: identity}); err == nil {
+			t.Fatalf("expected an error for the identity %q", identity)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package keys
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/tyler-smith/go-bip39/wordlists"
+)
+
+type Language string
+
+const (
+	LanguageEnglish            Language = "english"
+	LanguageSpanish            Language = "spanish"
+	LanguageFrench             Language = "french"
+	LanguageItalian            Language = "italian"
+	LanguageCzech              Language = "czech"
+	LanguageJapanese           Language = "japanese"
+	LanguageKorean             Language = "korean"
+	LanguageChineseSimplified  Language = "chinese-simplified"
+	LanguageChineseTraditional Language = "chinese-traditional"
+)
+
+// ErrAmbiguousLanguage is returned when a mnemonic is valid in more than one word list language
+var ErrAmbiguousLanguage = errors.New("mnemonic matches the word lists of multiple languages")
+
+// composedLetters decomposes the accented letters of the Latin word lists, which are published in Unicode NFKD form,
+// so that words typed with precomposed letters (as most keyboards produce) still match
+var composedLetters = strings.NewReplacer(
+	"á", "a\u0301", "é", "e\u0301", "í", "i\u0301", "ó", "o\u0301", "ú", "u\u0301",
+	"à", "a\u0300", "è", "e\u0300", "ì", "i\u0300", "ò", "o\u0300", "ù", "u\u0300",
+	"ñ", "n\u0303",
+)
+
+// languageInfo holds information about a supported BIP-39 word list language
+type languageInfo struct {
+	Language Language
+	Words    []string
+	Aliases  []string
+}
+
+// supportedLanguages lists the BIP-39 word lists in the order they are tried during detection
+var supportedLanguages = []languageInfo{
+	{Language: LanguageEnglish, Words: wordlists.English, Aliases: []string{"english", "en"}},
+	{Language: LanguageSpanish, Words: wordlists.Spanish, Aliases: []string{"spanish", "es"}},
+	{Language: LanguageFrench, Words: wordlists.French, Aliases: []string{"french", "fr"}},
+	{Language: LanguageItalian, Words: wordlists.Italian, Aliases: []string{"italian", "it"}},
+	{Language: LanguageCzech, Words: wordlists.Czech, Aliases: []string{"czech", "cs"}},
+	{Language: LanguageJapanese, Words: wordlists.Japanese, Aliases: []string{"japanese", "ja"}},
+	{Language: LanguageKorean, Words: wordlists.Korean, Aliases: []string{"korean", "ko"}},
+	{Language: LanguageChineseSimplified, Words: wordlists.ChineseSimplified, Aliases: []string{"chinese-simplified", "zh-hans", "zh-cn"}},
+	{Language: LanguageChineseTraditional, Words: wordlists.ChineseTraditional, Aliases: []string{"chinese-traditional", "zh-hant", "zh-tw"}},
+}
+
+// SupportedLanguages returns a string listing supported word list languages and their aliases
+func SupportedLanguages() string {
+	var builder strings.Builder
+	builder.WriteString("Supported languages:\n")
+	for _, info := range supportedLanguages {
+		builder.WriteString(fmt.Sprintf(" - %s (aliases: %s)\n", info.Language, strings.Join(info.Aliases, ", ")))
+	}
+	return builder.String()
+}
+
+// ParseLanguage parses the given string to determine the word list Language, defaulting to English
+func ParseLanguage(val string) (Language, error) {
+	val = strings.ToLower(strings.TrimSpace(val))
+	if val == "" {
+		return LanguageEnglish, nil
+	}
+
+	for _, info := range supportedLanguages {
+		for _, alias := range info.Aliases {
+			if val == alias {
+				return info.Language, nil
+			}
+		}
+	}
+
+	return "", fmt.Errorf("unsupported language: %s", val)
+}
+
+// WordList returns a copy of the BIP-39 word list of the language
+func (l Language) WordList() ([]string, error) {
+	for _, info := range supportedLanguages {
+		if info.Language == l {
+			return append([]string(nil), info.Words...), nil
+		}
+	}
+	return nil, fmt.Errorf("unsupported language: %s", l)
+}
+
+// DetectLanguage returns the language whose word list contains every word of the mnemonic. Words are matched as
+// ParseMnemonic would, including 4-letter prefixes. If the words match several lists, only the lists under which the
+// checksum is valid are kept, and ErrAmbiguousLanguage is returned if that still leaves more than one.
+func DetectLanguage(mnemonicString string) (Language, error) {
+	var matched, valid []Language
+	for _, info := range supportedLanguages {
+		g, err := NewGenerator(Config{WordList: info.Words})
+		if err != nil {
+			return "", err
+		}
+		mnemonic, err := g.ParseMnemonic(mnemonicString)
+		if err != nil {
+			continue
+		}
+		matched = append(matched, info.Language)
+		if _, err := g.EntropyFromMnemonic(mnemonic); err == nil {
+			valid = append(valid, info.Language)
+		}
+	}
+
+	switch {
+	case len(matched) == 0:
+		return "", fmt.Errorf("mnemonic words do not match the word list of any supported language")
+	case len(matched) == 1:
+		return matched[0], nil
+	case len(valid) == 1:
+		return valid[0], nil
+	}
+
+	candidates := valid
+	if len(candidates) == 0 {
+		candidates = matched
+	}
+	names := make([]string, len(candidates))
+	for i, l := range candidates {
+		names[i] = string(l)
+	}
+	return "", fmt.Errorf("%w: %s", ErrAmbiguousLanguage, strings.Join(names, ", "))
+}
+
+// Language returns the language of the generator's word list, or an empty Language for a custom word list
+func (g *Generator) Language() Language {
+	for _, info := range supportedLanguages {
+		if slices.Equal(g.words, info.Words) {
+			return info.Language
+		}
+	}
+	return ""
+}
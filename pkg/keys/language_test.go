@@ -0,0 +1,92 @@
+package keys
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseLanguage(t *testing.T) {
+	tests := map[string]Language{
+		"":        LanguageEnglish,
+		"English": LanguageEnglish,
+		"es":      LanguageSpanish,
+		"zh-tw":   LanguageChineseTraditional,
+	}
+	for val, expected := range tests {
+		language, err := ParseLanguage(val)
+		if err != nil {
+			t.Fatalf("failed to parse language %q: %v", val, err)
+		}
+		if language != expected {
+			t.Fatalf("language %q parsed as %s, expected %s", val, language, expected)
+		}
+	}
+
+	if _, err := ParseLanguage("klingon"); err == nil {
+		t.Fatalf("expected an unsupported language to be rejected")
+	}
+}
+
+func TestDetectLanguage(t *testing.T) {
+	if language, err := DetectLanguage(testMnemonic); err != nil || language != LanguageEnglish {
+		t.Fatalf("expected the test mnemonic to be detected as english, found %q: %v", language, err)
+	}
+
+	entropy, err := EntropyFromMnemonic(MustParseMnemonic(testMnemonic))
+	if err != nil {
+		t.Fatalf("failed to decode entropy: %v", err)
+	}
+	for _, expected := range []Language{LanguageSpanish, LanguageJapanese} {
+		words, err := expected.WordList()
+		if err != nil {
+			t.Fatalf("failed to get the %s word list: %v", expected, err)
+		}
+		g, err := NewGenerator(Config{WordList: words})
+		if err != nil {
+			t.Fatalf("failed to create generator: %v", err)
+		}
+		mnemonic, err := g.MnemonicFromEntropy(entropy)
+		if err != nil {
+			t.Fatalf("failed to encode %s mnemonic: %v", expected, err)
+		}
+
+		language, err := DetectLanguage(mnemonic.String())
+		if err != nil {
+			t.Fatalf("failed to detect the language of the %s mnemonic: %v", expected, err)
+		}
+		if language != expected {
+			t.Fatalf("%s mnemonic detected as %s", expected, language)
+		}
+
+		// words typed with precomposed accented letters must match the decomposed word list
+		composed := strings.NewReplacer("a\u0301", "á", "e\u0301", "é", "i\u0301", "í", "o\u0301", "ó", "u\u0301", "ú", "n\u0303", "ñ").Replace(mnemonic.String())
+		if language, err := DetectLanguage(composed); err != nil || language != expected {
+			t.Fatalf("composed %s mnemonic detected as %q: %v", expected, language, err)
+		}
+
+		// the detected word list must decode the same entropy
+		decoded, err := g.EntropyFromMnemonic(mnemonic)
+		if err != nil || !bytes.Equal(decoded, entropy) {
+			t.Fatalf("%s mnemonic does not decode to the original entropy: %v", expected, err)
+		}
+	}
+
+	if _, err := DetectLanguage("away mistake dance place sword title nurse diary skin soon figure sense force seat inform hedgehog debate around tortoise detail uncle situate draft zzzz"); err == nil || errors.Is(err, ErrAmbiguousLanguage) {
+		t.Fatalf("expected a mnemonic with an unknown word to match no language, found %v", err)
+	}
+}
+
+func TestDetectLanguageAmbiguous(t *testing.T) {
+	// "abandon" is in both the English and French word lists, and the checksum is invalid under either
+	mnemonic := strings.TrimSpace(strings.Repeat("abandon ", MNEMONIC_WORD_COUNT))
+
+	_, err := DetectLanguage(mnemonic)
+	if !errors.Is(err, ErrAmbiguousLanguage) {
+		t.Fatalf("expected an ambiguous language error, found %v", err)
+	}
+	if !strings.Contains(err.Error(), "english") || !strings.Contains(err.Error(), "french") {
+		t.Fatalf("expected the error to name the candidate languages, found %v", err)
+	}
+}
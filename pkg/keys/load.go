@@ -0,0 +1,120 @@
+package keys
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+
+	"github.com/youmark/pkcs8"
+	"golang.org/x/crypto/ssh"
+)
+
+// ErrPasswordRequired is returned when loading an encrypted private key without a password
+var ErrPasswordRequired = errors.New("the private key is encrypted and requires a password")
+
+// LoadKeyFromPEM loads a private key from PEM data in any supported format, decrypting an encrypted PKCS#8 or
+// OpenSSH key with the password. The loaded key is not derived from a mnemonic, so it has no mnemonic, salt, or label.
+func LoadKeyFromPEM(data []byte, password string) (*Key, error) {
+	block, rest := pem.Decode(data)
+	// a SEC1 key may be preceded by its curve, as written by openssl ecparam -genkey
+	if block != nil && block.Type == "EC PARAMETERS" {
+		data = rest
+		block, _ = pem.Decode(data)
+	}
+	if block == nil {
+		return nil, fmt.Errorf("no PEM block found")
+	}
+
+	var privKey crypto.PrivateKey
+	var err error
+	// the label of a loaded key is unknown, so its comment only names bipkey unless the format carries one
+	comment := KEY_COMMENT_PREFIX
+	switch block.Type {
+	case "PRIVATE KEY":
+		privKey, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "ENCRYPTED PRIVATE KEY":
+		if password == "" {
+			return nil, ErrPasswordRequired
+		}
+		privKey, err = pkcs8.ParsePKCS8PrivateKey(block.Bytes, []byte(password))
+	case "EC PRIVATE KEY":
+		privKey, err = x509.ParseECPrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		privKey, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "OPENSSH PRIVATE KEY":
+		privKey, err = ssh.ParseRawPrivateKey(data)
+		var missing *ssh.PassphraseMissingError
+		if errors.As(err, &missing) {
+			if password == "" {
+				return nil, ErrPasswordRequired
+			}
+			privKey, err = ssh.ParseRawPrivateKeyWithPassphrase(data, []byte(password))
+		}
+		// keep the comment of the parsed key, e.g. the user@host of ssh-keygen, so that convert does not replace it
+		if err == nil {
+			var parsed string
+			if parsed, err = opensshComment(block.Bytes, password); err == nil && parsed != "" {
+				comment = parsed
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unsupported PEM block type: %s", block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", block.Type, err)
+	}
+
+	// the OpenSSH parser returns Ed25519 keys by pointer
+	if priv, ok := privKey.(*ed25519.PrivateKey); ok {
+		privKey = *priv
+	}
+
+	keyType, keyId, err := identifyPrivateKey(privKey)
+	if err != nil {
+		return nil, err
+	}
+
+	key := &Key{
+		keyType:    keyType,
+		keyId:      keyId,
+		PrivateKey: privKey,
+		comment:    comment,
+	}
+	if err := key.Rederive(); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// identifyPrivateKey returns the key type and id of a supported private key
+func identifyPrivateKey(privKey crypto.PrivateKey) (KeyType, int, error) {
+	switch priv := privKey.(type) {
+	case *ecdsa.PrivateKey:
+		switch priv.Curve {
+		case elliptic.P256():
+			return KeyTypeECC, int(ECCCurveP256), nil
+		case elliptic.P384():
+			return KeyTypeECC, int(ECCCurveP384), nil
+		case elliptic.P521():
+			return KeyTypeECC, int(ECCCurveP521), nil
+		}
+		return KeyTypeNone, 0, fmt.Errorf("unsupported ECC curve: %s", priv.Curve.Params().Name)
+	case ed25519.PrivateKey:
+		return KeyTypeECC, int(ECCCurveEd25519), nil
+	case *rsa.PrivateKey:
+		// the nominal size is the modulus size in whole bytes, which tolerates a modulus one bit short
+		for id := RSAKeyNone + 1; id < rsaKeyEnd; id++ {
+			if getSizeRSA(id) == priv.Size()*8 {
+				return KeyTypeRSA, int(id), nil
+			}
+		}
+		return KeyTypeNone, 0, fmt.Errorf("unsupported RSA key size: %d", priv.Size()*8)
+	}
+	return KeyTypeNone, 0, fmt.Errorf("unsupported private key type %T", privKey)
+}
@@ -0,0 +1,131 @@
+package keys
+
+import (
+	"crypto/ed25519"
+	"encoding/pem"
+	"errors"
+	"reflect"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+func TestLoadKeyFromPEM(t *testing.T) {
+	tests := []struct {
+		keyType KeyType
+		keyId   int
+		formats []Format
+	}{
+		{KeyTypeECC, int(ECCCurveP256), []Format{FormatPKCS8, FormatSEC1, FormatOpenSSH}},
+		{KeyTypeECC, int(ECCCurveEd25519), []Format{FormatPKCS8, FormatOpenSSH}},
+		{KeyTypeRSA, int(RSAKey2048), []Format{FormatPKCS8, FormatPKCS1, FormatOpenSSH}},
+	}
+
+	for _, tt := range tests {
+		key, err := GenerateKeyFromMnemonic(t.Context(), tt.keyType, tt.keyId, SALT, MustParseMnemonic(testMnemonic))
+		if err != nil {
+			t.Fatalf("failed to generate key: %v", err)
+		}
+
+		for _, format := range tt.formats {
+			encoded, err := key.Encode(format)
+			if err != nil {
+				t.Fatalf("failed to encode %s key as %s: %v", tt.keyType, format, err)
+			}
+			loaded, err := LoadKeyFromPEM([]byte(encoded), "")
+			if err != nil {
+				t.Fatalf("failed to load %s key from %s: %v", tt.keyType, format, err)
+			}
+			if loaded.keyType != tt.keyType || loaded.keyId != tt.keyId || !reflect.DeepEqual(loaded.Der, key.Der) {
+				t.Fatalf("loaded %s key from %s does not match the original key", tt.keyType, format)
+			}
+		}
+
+		// an encrypted key needs its password
+		if err := key.Encrypt(PASSWORD); err != nil {
+			t.Fatalf("failed to encrypt key: %v", err)
+		}
+		if _, err := LoadKeyFromPEM([]byte(key.PEM()), ""); !errors.Is(err, ErrPasswordRequired) {
+			t.Fatalf("expected ErrPasswordRequired for an encrypted key, got %v", err)
+		}
+		if _, err := LoadKeyFromPEM([]byte(key.PEM()), "wrong"); err == nil {
+			t.Fatalf("expected an encrypted key to fail to load with the wrong password")
+		}
+		loaded, err := LoadKeyFromPEM([]byte(key.PEM()), PASSWORD)
+		if err != nil {
+			t.Fatalf("failed to load encrypted key: %v", err)
+		}
+		if !reflect.DeepEqual(loaded.PrivateKey, key.PrivateKey) {
+			t.Fatalf("loaded encrypted key does not match the original key")
+		}
+	}
+}
+
+func TestLoadedKeyFormatCheck(t *testing.T) {
+	key, err := GenerateKeyFromMnemonic(t.Context(), KeyTypeECC, int(ECCCurveEd25519), SALT, MustParseMnemonic(testMnemonic))
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	loaded, err := LoadKeyFromPEM([]byte(key.PEM()), "")
+	if err != nil {
+		t.Fatalf("failed to load key: %v", err)
+	}
+
+	for _, format := range []Format{FormatSEC1, FormatPKCS1} {
+		if err := loaded.CheckFormat(format); err == nil {
+			t.Fatalf("expected format %s to be rejected for an Ed25519 key", format)
+		}
+	}
+	if err := loaded.CheckFormat(FormatOpenSSH); err != nil {
+		t.Fatalf("unexpected error for the OpenSSH format: %v", err)
+	}
+}
+
+func TestLoadKeyKeepsOpenSSHComment(t *testing.T) {
+	for _, tt := range []struct {
+		keyType KeyType
+		keyId   int
+	}{
+		{KeyTypeECC, int(ECCCurveP256)},
+		{KeyTypeECC, int(ECCCurveEd25519)},
+		{KeyTypeRSA, int(RSAKey2048)},
+	} {
+		key, err := GenerateKeyFromMnemonic(t.Context(), tt.keyType, tt.keyId, SALT, MustParseMnemonic(testMnemonic))
+		if err != nil {
+			t.Fatalf("failed to generate key: %v", err)
+		}
+		privKey := key.PrivateKey
+		if priv, ok := privKey.(ed25519.PrivateKey); ok {
+			privKey = &priv
+		}
+
+		// as written by ssh-keygen, unencrypted and with a passphrase
+		plain, err := ssh.MarshalPrivateKey(privKey, "user@host")
+		if err != nil {
+			t.Fatalf("failed to marshal OpenSSH key: %v", err)
+		}
+		encrypted, err := ssh.MarshalPrivateKeyWithPassphrase(privKey, "user@host", []byte(PASSWORD))
+		if err != nil {
+			t.Fatalf("failed to marshal encrypted OpenSSH key: %v", err)
+		}
+		for _, data := range [][]byte{pem.EncodeToMemory(plain), pem.EncodeToMemory(encrypted)} {
+			loaded, err := LoadKeyFromPEM(data, PASSWORD)
+			if err != nil {
+				t.Fatalf("failed to load OpenSSH key: %v", err)
+			}
+			if loaded.Comment() != "user@host" {
+				t.Fatalf("expected the OpenSSH comment to be kept, got %q", loaded.Comment())
+			}
+		}
+	}
+
+	// formats without a comment fall back to naming bipkey
+	key, err := GenerateKeyFromMnemonic(t.Context(), KeyTypeECC, int(ECCCurveP256), SALT, MustParseMnemonic(testMnemonic))
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	loaded, err := LoadKeyFromPEM([]byte(key.PEM()), "")
+	if err != nil || loaded.Comment() != KEY_COMMENT_PREFIX {
+		t.Fatalf("expected a PKCS#8 key to have the comment %q, got %q (%v)", KEY_COMMENT_PREFIX, loaded.Comment(), err)
+	}
+}
@@ -2,33 +2,83 @@ package keys
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/sha256"
 	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"strconv"
 	"strings"
-
-	"github.com/tyler-smith/go-bip39"
 )
 
 const MNEMONIC_WORD_COUNT = 24
 const MNEMONIC_ENTROPY_BITS = 256
 
+// numberedWordPattern matches a word position prefix as shown in the mnemonic display, e.g. "01:" or "01:away"
+var numberedWordPattern = regexp.MustCompile(`^(\d{1,2}):(.*)$`)
+
 type Mnemonic [MNEMONIC_WORD_COUNT]string
 
+// MnemonicDifference describes a word position where two mnemonics differ
+type MnemonicDifference struct {
+	Position       int    // 1-based word position
+	Word           string // normalized word, or the word as entered if it is not in the word list
+	Reference      string // normalized reference word, or the word as entered if it is not in the word list
+	WordKnown      bool   // whether Word is in the word list
+	ReferenceKnown bool   // whether Reference is in the word list
+}
+
+// RepeatedWord describes a word that appears more than once in a mnemonic
+type RepeatedWord struct {
+	Word      string // the repeated word
+	Positions []int  // 1-based positions of the word, in ascending order
+}
+
 // String returns the mnemonic as a space-delimited string
 func (m Mnemonic) String() string {
 	return strings.Join(m[:], " ")
 }
 
-// Normalize returns a normalized version of the mnemonic with the complete words
-func (m Mnemonic) Normalize() (Mnemonic, error) {
-	var normalized Mnemonic
+// Grouped returns the mnemonic words in groups of size words, the words of a group separated by spaces and the
+// groups by the separator, e.g. for a backup card. A size of 0 or less returns a single group.
+func (m Mnemonic) Grouped(size int, separator string) string {
+	if size <= 0 || size >= len(m) {
+		return m.String()
+	}
+
+	var groups []string
+	for i := 0; i < len(m); i += size {
+		groups = append(groups, strings.Join(m[i:min(i+size, len(m))], " "))
+	}
+	return strings.Join(groups, separator)
+}
+
+// RepeatedWords returns the words that appear more than once, in the order of their first appearance. BIP-39 allows
+// repeated words, and about one in eight mnemonics has one, but writing a word twice is also a common transcription
+// mistake, so callers can ask for a second look. Words are compared as stored, so the mnemonic should be normalized.
+func (m Mnemonic) RepeatedWords() []RepeatedWord {
+	var repeated []RepeatedWord
 	for i, word := range m {
-		_, wordFull, err := GetWordIndex(word)
-		if err != nil {
-			return m, err
+		if slices.Index(m[:i], word) >= 0 {
+			continue
+		}
+		var positions []int
+		for j := i; j < len(m); j++ {
+			if m[j] == word {
+				positions = append(positions, j+1)
+			}
+		}
+		if len(positions) > 1 {
+			repeated = append(repeated, RepeatedWord{Word: word, Positions: positions})
 		}
-		normalized[i] = wordFull
 	}
-	return normalized, nil
+	return repeated
+}
+
+// Normalize returns a normalized version of the mnemonic with the complete words
+func (m Mnemonic) Normalize() (Mnemonic, error) {
+	return defaultGenerator.NormalizeMnemonic(m)
 }
 
 // MnemonicShort returns the mnemonic words uppercase truncated to their first 4 letters.
@@ -36,8 +86,8 @@ func (m *Mnemonic) Short() Mnemonic {
 	var short Mnemonic
 
 	for i, word := range m {
-		if len(word) > 4 {
-			word = word[:4]
+		if letters := []rune(word); len(letters) > 4 {
+			word = string(letters[:4])
 		}
 		short[i] = strings.ToUpper(word)
 	}
@@ -45,53 +95,206 @@ func (m *Mnemonic) Short() Mnemonic {
 	return short
 }
 
+// DiffMnemonic compares two mnemonics word by word and returns the positions where they differ
+func DiffMnemonic(mnemonic, reference string) ([]MnemonicDifference, error) {
+	return defaultGenerator.DiffMnemonic(mnemonic, reference)
+}
+
 // GenerateMnemonic generates a new, random BIP-39 mnemonic with 24 words.
 func GenerateMnemonic(ctx context.Context) (*Mnemonic, error) {
-	entropy, err := bip39.NewEntropy(MNEMONIC_ENTROPY_BITS)
+	return defaultGenerator.GenerateMnemonic(ctx)
+}
+
+// GenerateMnemonicWithReader generates a new BIP-39 mnemonic with 24 words using entropy read from r.
+func GenerateMnemonicWithReader(ctx context.Context, r io.Reader) (*Mnemonic, error) {
+	return defaultGenerator.GenerateMnemonicWithReader(ctx, r)
+}
+
+func MustParseMnemonic(mnemonicString string) Mnemonic {
+	mnemonic, err := ParseMnemonic(mnemonicString)
 	if err != nil {
+		panic(err)
+	}
+	return mnemonic
+}
+
+func ParseMnemonic(mnemonicString string) (Mnemonic, error) {
+	return defaultGenerator.ParseMnemonic(mnemonicString)
+}
+
+// ParseMnemonicStrict parses a mnemonic of exact, full, lowercase BIP-39 words and verifies its checksum.
+func ParseMnemonicStrict(mnemonicString string) (Mnemonic, error) {
+	return defaultGenerator.ParseMnemonicStrict(mnemonicString)
+}
+
+// MnemonicFromEntropy returns the 24-word mnemonic encoding the given 256 bits of entropy.
+func MnemonicFromEntropy(entropy []byte) (Mnemonic, error) {
+	return defaultGenerator.MnemonicFromEntropy(entropy)
+}
+
+// EntropyFromMnemonic returns the 256 bits of entropy encoded by the mnemonic, verifying its checksum.
+func EntropyFromMnemonic(m Mnemonic) ([]byte, error) {
+	return defaultGenerator.EntropyFromMnemonic(m)
+}
+
+// GetWordIndex returns the index and full word from the BIP-39 word list for the given word or its 4-letter prefix.
+func GetWordIndex(word string) (int, string, error) {
+	return defaultGenerator.GetWordIndex(word)
+}
+
+// NormalizeMnemonic returns a normalized version of the mnemonic with the complete words from the generator's word list
+func (g *Generator) NormalizeMnemonic(m Mnemonic) (Mnemonic, error) {
+	var normalized Mnemonic
+	for i, word := range m {
+		_, wordFull, err := g.GetWordIndex(word)
+		if err != nil {
+			return m, err
+		}
+		normalized[i] = wordFull
+	}
+	return normalized, nil
+}
+
+// GenerateMnemonic generates a new, random BIP-39 mnemonic with 24 words from the generator's word list.
+func (g *Generator) GenerateMnemonic(ctx context.Context) (*Mnemonic, error) {
+	return g.GenerateMnemonicWithReader(ctx, rand.Reader)
+}
+
+// GenerateMnemonicWithReader generates a new BIP-39 mnemonic with 24 words from the generator's word list,
+// using entropy read from r. This allows supplying a hardware RNG, or a fixed reader for reproducible tests.
+func (g *Generator) GenerateMnemonicWithReader(ctx context.Context, r io.Reader) (*Mnemonic, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("mnemonic generation cancelled: %w", err)
+	}
+
+	entropy := make([]byte, MNEMONIC_ENTROPY_BITS/8)
+	if _, err := io.ReadFull(r, entropy); err != nil {
 		return nil, fmt.Errorf("failed to generate entropy for mnemonic generation: %w", err)
 	}
-	mnemonicString, err := bip39.NewMnemonic(entropy)
+
+	m, err := g.mnemonicFromEntropy(entropy)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate mnemonic: %w", err)
 	}
 
-	var m Mnemonic
-	for i, word := range strings.Fields(strings.TrimSpace(mnemonicString)) {
-		m[i] = word
+	return &m, nil
+}
+
+// MnemonicFromEntropy encodes 256 bits of entropy as a 24-word mnemonic from the generator's word list,
+// e.g. to restore a mnemonic from an entropy backup
+func (g *Generator) MnemonicFromEntropy(entropy []byte) (Mnemonic, error) {
+	return g.mnemonicFromEntropy(entropy)
+}
+
+// mnemonicFromEntropy encodes 256 bits of entropy and its checksum as 24 words from the generator's word list
+func (g *Generator) mnemonicFromEntropy(entropy []byte) (Mnemonic, error) {
+	if len(entropy)*8 != MNEMONIC_ENTROPY_BITS {
+		return Mnemonic{}, fmt.Errorf("entropy must be %d bits, found %d", MNEMONIC_ENTROPY_BITS, len(entropy)*8)
 	}
 
-	m, err = m.Normalize()
-	if err != nil {
-		return nil, fmt.Errorf("failed to normalize mnemonic: %w", err)
+	// the checksum is the first ENT/32 bits of SHA-256(entropy), which is exactly one byte for 256 bits
+	checksum := sha256.Sum256(entropy)
+	data := append(append([]byte(nil), entropy...), checksum[0])
+
+	// split the 264 bits into 24 groups of 11 bits, each indexing the word list
+	var m Mnemonic
+	for i := range m {
+		index := 0
+		for bit := i * 11; bit < (i+1)*11; bit++ {
+			index = index<<1 | int(data[bit/8]>>(7-bit%8)&1)
+		}
+		m[i] = g.words[index]
 	}
 
-	return &m, nil
+	return m, nil
 }
 
-var bip39Words []string
+// EntropyFromMnemonic decodes the 24 words into the 256 bits of entropy they encode and verifies the checksum
+func (g *Generator) EntropyFromMnemonic(m Mnemonic) ([]byte, error) {
+	// join the 24 groups of 11 bits back into 264 bits of entropy and checksum
+	data := make([]byte, (MNEMONIC_WORD_COUNT*11+7)/8)
+	for i, word := range m {
+		index, _, err := g.GetWordIndex(word)
+		if err != nil {
+			return nil, fmt.Errorf("invalid mnemonic word '%s': %w", word, err)
+		}
+		for bit := 0; bit < 11; bit++ {
+			if index>>(10-bit)&1 == 1 {
+				pos := i*11 + bit
+				data[pos/8] |= 1 << (7 - pos%8)
+			}
+		}
+	}
 
-func init() {
-	bip39Words = bip39.GetWordList()
+	entropy := data[:MNEMONIC_ENTROPY_BITS/8]
+	checksum := sha256.Sum256(entropy)
+	if data[len(data)-1] != checksum[0] {
+		return nil, fmt.Errorf("invalid mnemonic checksum")
+	}
+	return entropy, nil
 }
 
-func MustParseMnemonic(mnemonicString string) Mnemonic {
-	mnemonic, err := ParseMnemonic(mnemonicString)
+// ParseMnemonicStrict parses a mnemonic that must consist of exact, full, lowercase words from the generator's
+// word list with a valid checksum. Unlike ParseMnemonic, prefixes and other letter cases are rejected.
+func (g *Generator) ParseMnemonicStrict(mnemonicString string) (Mnemonic, error) {
+	words, err := mnemonicFields(mnemonicString)
 	if err != nil {
-		panic(err)
+		return Mnemonic{}, err
 	}
-	return mnemonic
+	if len(words) != MNEMONIC_WORD_COUNT {
+		return Mnemonic{}, fmt.Errorf("mnemonic must have %d words, found %d", MNEMONIC_WORD_COUNT, len(words))
+	}
+
+	var mnemonic Mnemonic
+	for i, word := range words {
+		word = composedLetters.Replace(word)
+		if !slices.Contains(g.words, word) {
+			return Mnemonic{}, fmt.Errorf("word %d '%s' is not an exact word from the BIP-39 word list", i+1, word)
+		}
+		mnemonic[i] = word
+	}
+
+	if _, err := g.EntropyFromMnemonic(mnemonic); err != nil {
+		return Mnemonic{}, err
+	}
+	return mnemonic, nil
 }
 
-func ParseMnemonic(mnemonicString string) (Mnemonic, error) {
-	words := strings.Fields(strings.TrimSpace(mnemonicString))
+// mnemonicFields splits a mnemonic into its words. Position prefixes copied from the numbered display ("01: away
+// 02: mistake ...") are removed, and must match the position of their word so that a scrambled paste is rejected.
+func mnemonicFields(mnemonicString string) ([]string, error) {
+	var words []string
+	for _, field := range strings.Fields(mnemonicString) {
+		match := numberedWordPattern.FindStringSubmatch(field)
+		if match == nil {
+			words = append(words, field)
+			continue
+		}
+
+		if position, _ := strconv.Atoi(match[1]); position != len(words)+1 {
+			return nil, fmt.Errorf("word number %s does not match its position %d", match[1], len(words)+1)
+		}
+		if match[2] != "" {
+			words = append(words, match[2])
+		}
+	}
+	return words, nil
+}
+
+// ParseMnemonic parses a space-delimited mnemonic using the generator's word list. The numbered form of the
+// mnemonic display is accepted as well.
+func (g *Generator) ParseMnemonic(mnemonicString string) (Mnemonic, error) {
+	words, err := mnemonicFields(mnemonicString)
+	if err != nil {
+		return Mnemonic{}, err
+	}
 	if len(words) != MNEMONIC_WORD_COUNT {
 		return Mnemonic{}, fmt.Errorf("mnemonic must have %d words, found %d", MNEMONIC_WORD_COUNT, len(words))
 	}
 
 	var mnemonic Mnemonic
 	for i, word := range words {
-		_, wordFull, err := GetWordIndex(word)
+		_, wordFull, err := g.GetWordIndex(word)
 		if err != nil {
 			return Mnemonic{}, fmt.Errorf("invalid mnemonic word '%s': %w", word, err)
 		}
@@ -100,22 +303,66 @@ func ParseMnemonic(mnemonicString string) (Mnemonic, error) {
 	return mnemonic, nil
 }
 
-// GetWordIndex returns the index and full word from the BIP-39 word list for the given word or its 4-letter prefix.
-func GetWordIndex(word string) (int, string, error) {
+// DiffMnemonic compares a mnemonic against a reference mnemonic after normalizing both with the generator's word
+// list, so that prefixes and letter case do not count as differences. Words that are not in the word list are
+// compared as entered, which pinpoints transcription errors that would otherwise only show as a fingerprint mismatch.
+func (g *Generator) DiffMnemonic(mnemonic, reference string) ([]MnemonicDifference, error) {
+	words, err := mnemonicFields(mnemonic)
+	if err != nil {
+		return nil, err
+	}
+	refWords, err := mnemonicFields(reference)
+	if err != nil {
+		return nil, fmt.Errorf("reference: %w", err)
+	}
+	if len(words) != MNEMONIC_WORD_COUNT {
+		return nil, fmt.Errorf("mnemonic must have %d words, found %d", MNEMONIC_WORD_COUNT, len(words))
+	}
+	if len(refWords) != MNEMONIC_WORD_COUNT {
+		return nil, fmt.Errorf("reference mnemonic must have %d words, found %d", MNEMONIC_WORD_COUNT, len(refWords))
+	}
+
+	var diffs []MnemonicDifference
+	for i := range words {
+		_, word, err := g.GetWordIndex(words[i])
+		wordKnown := err == nil
+		if !wordKnown {
+			word = words[i]
+		}
+		_, ref, err := g.GetWordIndex(refWords[i])
+		refKnown := err == nil
+		if !refKnown {
+			ref = refWords[i]
+		}
+
+		if !wordKnown || !refKnown || word != ref {
+			diffs = append(diffs, MnemonicDifference{
+				Position:       i + 1,
+				Word:           word,
+				Reference:      ref,
+				WordKnown:      wordKnown,
+				ReferenceKnown: refKnown,
+			})
+		}
+	}
+	return diffs, nil
+}
+
+// GetWordIndex returns the index and full word from the generator's word list for the given word or its 4-letter prefix.
+// Prefixes are counted in letters rather than bytes, so that word lists with accented or non-Latin words work as well.
+func (g *Generator) GetWordIndex(word string) (int, string, error) {
 	originalWord := word
+	word = composedLetters.Replace(strings.ToLower(word))
 
-	if len(word) > 4 {
-		word = word[:4]
+	// an exact word takes precedence, since not every word list is unique in its first 4 letters
+	if i := slices.Index(g.words, word); i >= 0 {
+		return i, word, nil
 	}
-	word = strings.ToLower(word)
 
-	for i, w := range bip39Words {
-		if len(word) < 4 {
-			if word == w {
-				return i, w, nil
-			}
-		} else {
-			if strings.HasPrefix(w, strings.ToLower(word)) {
+	if letters := []rune(word); len(letters) >= 4 {
+		prefix := string(letters[:4])
+		for i, w := range g.words {
+			if strings.HasPrefix(w, prefix) {
 				return i, w, nil
 			}
 		}
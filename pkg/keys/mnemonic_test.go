@@ -0,0 +1,320 @@
+package keys
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/tyler-smith/go-bip39"
+)
+
+const testMnemonic = "away mistake dance place sword title nurse diary skin soon figure sense force seat inform hedgehog debate around tortoise detail uncle situate draft wait"
+
+func TestParseMnemonicWordCount(t *testing.T) {
+	words := strings.Fields(testMnemonic)
+
+	tests := []struct {
+		name  string
+		input string
+		ok    bool
+	}{
+		{"23 words", strings.Join(words[:23], " "), false},
+		{"24 words", strings.Join(words, " "), true},
+		{"25 words", strings.Join(append(words, "wait"), " "), false},
+		{"empty", "", false},
+		{"extra whitespace", "  " + strings.Join(words, "  \t ") + "\n", true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			defer func() {
+				if r := recover(); r != nil {
+					t.Fatalf("ParseMnemonic panicked: %v", r)
+				}
+			}()
+
+			m, err := ParseMnemonic(test.input)
+			if test.ok {
+				if err != nil {
+					t.Fatalf("failed to parse mnemonic: %v", err)
+				}
+				if m.String() != testMnemonic {
+					t.Fatalf("unexpected mnemonic: %s", m.String())
+				}
+				return
+			}
+
+			if err == nil {
+				t.Fatalf("expected an error, parsed %q", m.String())
+			}
+			if m != (Mnemonic{}) {
+				t.Fatalf("expected an empty mnemonic on error, got %q", m.String())
+			}
+		})
+	}
+}
+
+func TestGenerateMnemonicWithReader(t *testing.T) {
+	entropy := bytes.Repeat([]byte{0x7f}, MNEMONIC_ENTROPY_BITS/8)
+
+	expected, err := bip39.NewMnemonic(entropy)
+	if err != nil {
+		t.Fatalf("failed to create reference mnemonic: %v", err)
+	}
+
+	m1, err := GenerateMnemonicWithReader(t.Context(), bytes.NewReader(entropy))
+	if err != nil {
+		t.Fatalf("failed to generate mnemonic: %v", err)
+	}
+	m2, err := GenerateMnemonicWithReader(t.Context(), bytes.NewReader(entropy))
+	if err != nil {
+		t.Fatalf("failed to generate mnemonic: %v", err)
+	}
+
+	if m1.String() != expected || m2.String() != expected {
+		t.Fatalf("unexpected mnemonic from fixed reader:\n got %s\nwant %s", m1.String(), expected)
+	}
+
+	// a reader that runs out of entropy must fail rather than produce a weak mnemonic
+	if _, err := GenerateMnemonicWithReader(t.Context(), bytes.NewReader(entropy[:16])); err == nil {
+		t.Fatalf("expected an error for a short entropy reader")
+	}
+}
+
+func TestParseMnemonicStrict(t *testing.T) {
+	words := strings.Fields(testMnemonic)
+
+	if _, err := ParseMnemonicStrict(testMnemonic); err != nil {
+		t.Fatalf("failed to strictly parse a canonical mnemonic: %v", err)
+	}
+
+	short := MustParseMnemonic(testMnemonic)
+	short = short.Short()
+	tests := map[string]string{
+		"prefixes":   strings.ToLower(short.String()),
+		"uppercase":  strings.ToUpper(testMnemonic),
+		"one prefix": strings.Join(append([]string{"awa"}, words[1:]...), " "),
+		"checksum":   strings.Join(append(append([]string{}, words[:23]...), "zoo"), " "),
+		"23 words":   strings.Join(words[:23], " "),
+	}
+	for name, input := range tests {
+		if _, err := ParseMnemonicStrict(input); err == nil {
+			t.Fatalf("expected strict parsing to reject %s input %q", name, input)
+		}
+	}
+
+	// the forgiving parser still accepts the prefix input
+	if _, err := ParseMnemonic(tests["prefixes"]); err != nil {
+		t.Fatalf("expected the default parser to accept prefixes: %v", err)
+	}
+}
+
+func TestEntropyFromMnemonic(t *testing.T) {
+	entropy := bytes.Repeat([]byte{0x5a}, MNEMONIC_ENTROPY_BITS/8)
+	m, err := DefaultGenerator().mnemonicFromEntropy(entropy)
+	if err != nil {
+		t.Fatalf("failed to encode entropy: %v", err)
+	}
+
+	decoded, err := EntropyFromMnemonic(m)
+	if err != nil {
+		t.Fatalf("failed to decode mnemonic: %v", err)
+	}
+	if !bytes.Equal(decoded, entropy) {
+		t.Fatalf("decoded entropy does not match: %x", decoded)
+	}
+
+	expected, err := bip39.EntropyFromMnemonic(m.String())
+	if err != nil || !bytes.Equal(decoded, expected) {
+		t.Fatalf("decoded entropy does not match go-bip39: %x (%v)", expected, err)
+	}
+}
+
+func TestMnemonicEntropyRoundTrip(t *testing.T) {
+	m := MustParseMnemonic(testMnemonic)
+
+	entropy, err := EntropyFromMnemonic(m)
+	if err != nil {
+		t.Fatalf("failed to decode mnemonic: %v", err)
+	}
+	restored, err := MnemonicFromEntropy(entropy)
+	if err != nil {
+		t.Fatalf("failed to encode entropy: %v", err)
+	}
+	if restored != m {
+		t.Fatalf("entropy round trip changed the mnemonic: %s", restored.String())
+	}
+
+	again, err := EntropyFromMnemonic(restored)
+	if err != nil || !bytes.Equal(again, entropy) {
+		t.Fatalf("mnemonic round trip changed the entropy: %x (%v)", again, err)
+	}
+
+	// the encoding must match the reference BIP-39 implementation
+	expected, err := bip39.NewMnemonic(entropy)
+	if err != nil || expected != m.String() {
+		t.Fatalf("mnemonic does not match go-bip39: %q (%v)", expected, err)
+	}
+
+	if _, err := MnemonicFromEntropy(entropy[:16]); err == nil {
+		t.Fatalf("expected an error for 128-bit entropy")
+	}
+}
+
+func TestParseNumberedMnemonic(t *testing.T) {
+	g := DefaultGenerator()
+	mnemonic := MustParseMnemonic(testMnemonic)
+
+	// the exact block printed by the key display restores the same key
+	displayed := g.formatMnemonic(mnemonic)
+	parsed, err := ParseMnemonic(displayed)
+	if err != nil {
+		t.Fatalf("failed to parse the displayed mnemonic: %v\n%s", err, displayed)
+	}
+	if parsed != mnemonic {
+		t.Fatalf("unexpected mnemonic: got %q, want %q", parsed.String(), mnemonic.String())
+	}
+	if _, err := ParseMnemonicStrict(displayed); err != nil {
+		t.Fatalf("failed to strictly parse the displayed mnemonic: %v", err)
+	}
+
+	k1, err := GenerateKeyFromMnemonic(t.Context(), KeyTypeECC, int(ECCCurveP256), SALT, mnemonic)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	k2, err := GenerateKeyFromMnemonic(t.Context(), KeyTypeECC, int(ECCCurveP256), SALT, parsed)
+	if err != nil {
+		t.Fatalf("failed to restore key: %v", err)
+	}
+	if k1.Fingerprint() != k2.Fingerprint() {
+		t.Fatalf("key restored from the displayed mnemonic does not match")
+	}
+
+	// prefixes without a space and short words work as well
+	words := strings.Fields(testMnemonic)
+	var compact []string
+	for i, word := range words {
+		compact = append(compact, fmt.Sprintf("%d:%s", i+1, strings.ToUpper(word[:min(4, len(word))])))
+	}
+	if parsed, err := ParseMnemonic(strings.Join(compact, " ")); err != nil || parsed != mnemonic {
+		t.Fatalf("failed to parse compact numbered mnemonic: %v", err)
+	}
+
+	// numbers that do not match the word positions indicate a scrambled paste
+	swapped := strings.Replace(displayed, "01:", "02:", 1)
+	if _, err := ParseMnemonic(swapped); err == nil {
+		t.Fatalf("expected mismatched word numbers to be rejected")
+	}
+}
+
+func TestDiffMnemonic(t *testing.T) {
+	words := strings.Fields(testMnemonic)
+
+	// prefixes, letter case, and the numbered display are not differences
+	short := MustParseMnemonic(testMnemonic)
+	short = short.Short()
+	diffs, err := DiffMnemonic(short.String(), DefaultGenerator().formatMnemonic(MustParseMnemonic(testMnemonic)))
+	if err != nil || len(diffs) != 0 {
+		t.Fatalf("expected no differences for equivalent mnemonics: %v (%v)", diffs, err)
+	}
+
+	entered := append([]string{}, words...)
+	entered[16] = "turtle"
+	entered[18] = "tortise" // not a word, but its 4-letter prefix still resolves to "tortoise"
+	entered[19] = "xyzzy"
+	diffs, err = DiffMnemonic(strings.Join(entered, " "), testMnemonic)
+	if err != nil {
+		t.Fatalf("failed to diff mnemonics: %v", err)
+	}
+
+	expected := []MnemonicDifference{
+		{Position: 17, Word: "turtle", Reference: "debate", WordKnown: true, ReferenceKnown: true},
+		{Position: 20, Word: "xyzzy", Reference: "detail", WordKnown: false, ReferenceKnown: true},
+	}
+	if len(diffs) != len(expected) {
+		t.Fatalf("unexpected differences: %+v", diffs)
+	}
+	for i := range expected {
+		if diffs[i] != expected[i] {
+			t.Fatalf("unexpected difference %d: got %+v, want %+v", i, diffs[i], expected[i])
+		}
+	}
+
+	if _, err := DiffMnemonic(strings.Join(words[:23], " "), testMnemonic); err == nil {
+		t.Fatalf("expected an error for a short mnemonic")
+	}
+}
+
+func TestMnemonicRepeatedWords(t *testing.T) {
+	if repeated := MustParseMnemonic(testMnemonic).RepeatedWords(); len(repeated) != 0 {
+		t.Fatalf("expected no repeated words, got %+v", repeated)
+	}
+
+	// the mnemonic of all-zero entropy is valid and repeats "abandon" 23 times
+	zero, err := MnemonicFromEntropy(make([]byte, MNEMONIC_ENTROPY_BITS/8))
+	if err != nil {
+		t.Fatalf("failed to encode entropy: %v", err)
+	}
+	if repeated := zero.RepeatedWords(); len(repeated) != 1 || repeated[0].Word != "abandon" || len(repeated[0].Positions) != 23 {
+		t.Fatalf("unexpected repeated words: %+v", repeated)
+	}
+
+	words := strings.Fields(testMnemonic)
+	words[2], words[16], words[20] = "sword", "away", "sword"
+	var m Mnemonic
+	copy(m[:], words)
+	repeated := m.RepeatedWords()
+	expected := []RepeatedWord{
+		{Word: "away", Positions: []int{1, 17}},
+		{Word: "sword", Positions: []int{3, 5, 21}},
+	}
+	if len(repeated) != len(expected) {
+		t.Fatalf("unexpected repeated words: %+v", repeated)
+	}
+	for i := range expected {
+		if repeated[i].Word != expected[i].Word || !slices.Equal(repeated[i].Positions, expected[i].Positions) {
+			t.Fatalf("unexpected repeated word %d: got %+v, want %+v", i, repeated[i], expected[i])
+		}
+	}
+}
+
+func TestMnemonicGrouped(t *testing.T) {
+	m := MustParseMnemonic(testMnemonic)
+	words := strings.Fields(testMnemonic)
+
+	grouped := m.Grouped(4, "\n")
+	lines := strings.Split(grouped, "\n")
+	if len(lines) != 6 {
+		t.Fatalf("expected 6 groups of 4 words, found %d:\n%s", len(lines), grouped)
+	}
+	for i, line := range lines {
+		if line != strings.Join(words[i*4:i*4+4], " ") {
+			t.Fatalf("unexpected group %d: %q", i+1, line)
+		}
+	}
+
+	// an uneven size leaves a shorter last group
+	groups := strings.Split(m.Grouped(5, " | "), " | ")
+	if len(groups) != 5 || len(strings.Fields(groups[4])) != 4 {
+		t.Fatalf("expected 4 groups of 5 words and one of 4, found %q", groups)
+	}
+
+	for _, size := range []int{0, -1, MNEMONIC_WORD_COUNT} {
+		if m.Grouped(size, "\n") != m.String() {
+			t.Fatalf("expected group size %d to show the words on one line", size)
+		}
+	}
+
+	g, err := NewGenerator(Config{GroupSize: 6})
+	if err != nil {
+		t.Fatalf("failed to create generator: %v", err)
+	}
+	if g.FormatMnemonicGroups(m) != m.Grouped(6, "\n") {
+		t.Fatalf("expected the generator to group by newlines by default")
+	}
+	if _, err := NewGenerator(Config{GroupSize: -1}); err == nil {
+		t.Fatalf("expected a negative group size to be rejected")
+	}
+}
@@ -0,0 +1,190 @@
+package keys
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/blowfish"
+	"golang.org/x/crypto/ssh"
+)
+
+// OPENSSH_KEY_MAGIC starts the binary "openssh-key-v1" private key container inside its PEM block
+const OPENSSH_KEY_MAGIC = "openssh-key-v1\x00"
+
+// opensshKeyFields is the number of key fields preceding the comment in an OpenSSH private key, by key type
+var opensshKeyFields = map[string]int{
+	ssh.KeyAlgoRSA:      6, // n, e, d, iqmp, p, q
+	ssh.KeyAlgoED25519:  2, // public key, private key
+	ssh.KeyAlgoECDSA256: 3, // curve, public point, private scalar
+	ssh.KeyAlgoECDSA384: 3,
+	ssh.KeyAlgoECDSA521: 3,
+}
+
+// opensshContainer is the "openssh-key-v1" container of a single private key
+type opensshContainer struct {
+	CipherName   string
+	KdfName      string
+	KdfOpts      string
+	NumKeys      uint32
+	PubKey       []byte
+	PrivKeyBlock []byte
+	Rest         []byte `ssh:"rest"`
+}
+
+// opensshComment returns the comment of an OpenSSH private key, the body of its PEM block. The ssh package parses the
+// key but drops the comment, so the private section is read again, decrypting it with the password if needed.
+func opensshComment(body []byte, password string) (string, error) {
+	if len(body) < len(OPENSSH_KEY_MAGIC) || string(body[:len(OPENSSH_KEY_MAGIC)]) != OPENSSH_KEY_MAGIC {
+		return "", errors.New("invalid OpenSSH private key")
+	}
+	var container opensshContainer
+	if err := ssh.Unmarshal(body[len(OPENSSH_KEY_MAGIC):], &container); err != nil {
+		return "", fmt.Errorf("invalid OpenSSH private key: %w", err)
+	}
+
+	// never decrypt in place, the block belongs to the caller's PEM data
+	private := append([]byte(nil), container.PrivKeyBlock...)
+	defer clear(private)
+	if container.CipherName != "none" {
+		if err := decryptOpenSSHKey(&container, private, password); err != nil {
+			return "", err
+		}
+	}
+
+	// two check integers and the key type precede the key fields
+	if len(private) < 8 {
+		return "", errors.New("invalid OpenSSH private key section")
+	}
+	keyType, rest, ok := readSSHString(private[8:])
+	if !ok {
+		return "", errors.New("invalid OpenSSH private key section")
+	}
+	fields, ok := opensshKeyFields[string(keyType)]
+	if !ok {
+		return "", fmt.Errorf("unsupported OpenSSH key type: %s", keyType)
+	}
+	for range fields {
+		if _, rest, ok = readSSHString(rest); !ok {
+			return "", errors.New("invalid OpenSSH private key section")
+		}
+	}
+	comment, _, ok := readSSHString(rest)
+	if !ok {
+		return "", errors.New("invalid OpenSSH private key section")
+	}
+	return string(comment), nil
+}
+
+// readSSHString reads a length-prefixed SSH wire string (or mpint) from b
+func readSSHString(b []byte) ([]byte, []byte, bool) {
+	if len(b) < 4 {
+		return nil, nil, false
+	}
+	n := binary.BigEndian.Uint32(b)
+	if uint64(n) > uint64(len(b)-4) {
+		return nil, nil, false
+	}
+	return b[4 : 4+n], b[4+n:], true
+}
+
+// decryptOpenSSHKey decrypts the private section of an OpenSSH key in place, supporting the bcrypt KDF with the
+// aes256-ctr and aes256-cbc ciphers like the ssh package
+func decryptOpenSSHKey(container *opensshContainer, private []byte, password string) error {
+	if container.KdfName != "bcrypt" {
+		return fmt.Errorf("unsupported OpenSSH key KDF: %s", container.KdfName)
+	}
+	var opts struct {
+		Salt   string
+		Rounds uint32
+	}
+	if err := ssh.Unmarshal([]byte(container.KdfOpts), &opts); err != nil {
+		return fmt.Errorf("invalid OpenSSH key KDF options: %w", err)
+	}
+
+	derived := bcryptPBKDF([]byte(password), []byte(opts.Salt), int(opts.Rounds), 32+aes.BlockSize)
+	defer clear(derived)
+	block, err := aes.NewCipher(derived[:32])
+	if err != nil {
+		return err
+	}
+	iv := derived[32:]
+	switch container.CipherName {
+	case "aes256-ctr":
+		cipher.NewCTR(block, iv).XORKeyStream(private, private)
+	case "aes256-cbc":
+		if len(private)%aes.BlockSize != 0 {
+			return errors.New("invalid OpenSSH private key section length")
+		}
+		cipher.NewCBCDecrypter(block, iv).CryptBlocks(private, private)
+	default:
+		return fmt.Errorf("unsupported OpenSSH key cipher: %s", container.CipherName)
+	}
+	return nil
+}
+
+// BCRYPT_PBKDF_MAGIC is the block encrypted by the bcrypt hash of bcrypt_pbkdf
+const BCRYPT_PBKDF_MAGIC = "OxychromaticBlowfishSwatDynamite"
+
+// bcryptPBKDF derives a key with OpenBSD's bcrypt_pbkdf, the KDF of encrypted OpenSSH private keys. The ssh package
+// implements it internally only.
+func bcryptPBKDF(password, salt []byte, rounds, keyLen int) []byte {
+	const blockSize = len(BCRYPT_PBKDF_MAGIC)
+	numBlocks := (keyLen + blockSize - 1) / blockSize
+	key := make([]byte, numBlocks*blockSize)
+
+	h := sha512.New()
+	h.Write(password)
+	shapass := h.Sum(nil)
+
+	var cnt [4]byte
+	tmp := make([]byte, blockSize)
+	for block := 1; block <= numBlocks; block++ {
+		h.Reset()
+		h.Write(salt)
+		binary.BigEndian.PutUint32(cnt[:], uint32(block))
+		h.Write(cnt[:])
+		bcryptHash(tmp, shapass, h.Sum(nil))
+
+		out := append([]byte(nil), tmp...)
+		for i := 2; i <= rounds; i++ {
+			h.Reset()
+			h.Write(tmp)
+			bcryptHash(tmp, shapass, h.Sum(nil))
+			for j := range out {
+				out[j] ^= tmp[j]
+			}
+		}
+
+		// the output bytes of the blocks are interleaved
+		for i, v := range out {
+			key[i*numBlocks+(block-1)] = v
+		}
+	}
+	return key[:keyLen]
+}
+
+// bcryptHash is the bcrypt hash of bcrypt_pbkdf, encrypting the magic block with a salted Blowfish key schedule
+func bcryptHash(out, shapass, shasalt []byte) {
+	c, err := blowfish.NewSaltedCipher(shapass, shasalt)
+	if err != nil {
+		panic(err) // the SHA-512 digests are always valid key and salt lengths
+	}
+	for range 64 {
+		blowfish.ExpandKey(shasalt, c)
+		blowfish.ExpandKey(shapass, c)
+	}
+	copy(out, BCRYPT_PBKDF_MAGIC)
+	for i := 0; i < len(out); i += 8 {
+		for range 64 {
+			c.Encrypt(out[i:i+8], out[i:i+8])
+		}
+	}
+	// the blocks are written as little-endian words
+	for i := 0; i < len(out); i += 4 {
+		out[i], out[i+1], out[i+2], out[i+3] = out[i+3], out[i+2], out[i+1], out[i]
+	}
+}
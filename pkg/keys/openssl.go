@@ -0,0 +1,79 @@
+package keys
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/youmark/pkcs8"
+)
+
+// OpenSSLVersion names the OpenSSL release whose default key encodings a compatibility profile mirrors
+type OpenSSLVersion string
+
+const (
+	OpenSSL111 OpenSSLVersion = "1.1.1" // keys as written by openssl genrsa, ec, and genpkey 1.1.1
+	OpenSSL30  OpenSSLVersion = "3.0"   // keys as written by openssl genrsa and genpkey 3.x
+)
+
+var supportedOpenSSLVersions = []OpenSSLVersion{OpenSSL111, OpenSSL30}
+
+// PKCS#8 encryption defaults of OpenSSL (PKCS5_DEFAULT_ITER and PKCS5_SALT_LEN), unchanged since 1.1.0
+const (
+	OPENSSL_PBKDF2_ITERATIONS = 2048 // PBKDF2 iteration count
+	OPENSSL_PBKDF2_SALT_SIZE  = 8    // PBKDF2 salt size in bytes
+)
+
+// SupportedOpenSSLVersions returns a string listing the OpenSSL versions with a compatibility profile
+func SupportedOpenSSLVersions() string {
+	var names []string
+	for _, version := range supportedOpenSSLVersions {
+		names = append(names, string(version))
+	}
+	return "Supported OpenSSL versions: " + strings.Join(names, ", ")
+}
+
+// ParseOpenSSLVersion parses an OpenSSL version, e.g. "1.1.1", "3", or "3.2". Every 3.x release shares the 3.0
+// profile, and 1.1 is 1.1.1.
+func ParseOpenSSLVersion(val string) (OpenSSLVersion, error) {
+	val = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(val)), "openssl")
+	val = strings.TrimSpace(val)
+
+	switch {
+	case val == "1.1" || strings.HasPrefix(val, "1.1.1"):
+		return OpenSSL111, nil
+	case val == "3" || strings.HasPrefix(val, "3."):
+		return OpenSSL30, nil
+	}
+	return "", fmt.Errorf("unsupported OpenSSL version: %s", val)
+}
+
+// Format returns the format OpenSSL writes a key of the given type in. OpenSSL 3.0 writes PKCS#8 for every key type.
+// OpenSSL 1.1.1 writes RSA keys as PKCS#1 and NIST curve keys as SEC1, which cannot carry a password, so an encrypted
+// key is PKCS#8 as written by openssl pkcs8 -topk8.
+func (v OpenSSLVersion) Format(keyType KeyType, keyId int, encrypted bool) Format {
+	if v != OpenSSL111 || encrypted {
+		return FormatPKCS8
+	}
+	switch {
+	case keyType == KeyTypeRSA:
+		return FormatPKCS1
+	case keyType == KeyTypeECC && ECCCurveID(keyId) != ECCCurveEd25519:
+		return FormatSEC1
+	default:
+		return FormatPKCS8
+	}
+}
+
+// OpenSSLPKCS8Opts returns the PKCS#8 encryption options of OpenSSL: PBES2 with PBKDF2-HMAC-SHA256 over 2048
+// iterations and an 8-byte salt, and AES-256-CBC. The iteration count is lower than that of the default options.
+func OpenSSLPKCS8Opts() *pkcs8.Opts {
+	return &pkcs8.Opts{
+		Cipher:  pkcs8.AES256CBC,
+		KDFOpts: pbkdf2Opts{SaltSize: OPENSSL_PBKDF2_SALT_SIZE, IterationCount: OPENSSL_PBKDF2_ITERATIONS, PRF: oidSHA256},
+	}
+}
+
+// OpenSSLFormat returns the format the OpenSSL version writes the key in, see OpenSSLVersion.Format
+func (k Key) OpenSSLFormat(v OpenSSLVersion) Format {
+	return v.Format(k.keyType, k.keyId, k.encrypted)
+}
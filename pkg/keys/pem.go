@@ -0,0 +1,70 @@
+package keys
+
+import (
+	"encoding/pem"
+	"fmt"
+	"strings"
+)
+
+// PEM_LINE_LENGTH is the standard width of the base64 lines in a PEM block (RFC 7468), as written by encoding/pem
+const PEM_LINE_LENGTH = 64
+
+// WrapPEM re-wraps the base64 body of every PEM block in data at width characters per line, for parsers that expect
+// a width other than the standard 64. A width of 0 writes each body on a single line. Headers are preserved.
+func WrapPEM(data string, width int) (string, error) {
+	if width < 0 {
+		return "", fmt.Errorf("PEM line length must not be negative, found %d", width)
+	}
+	if width == PEM_LINE_LENGTH {
+		return data, nil
+	}
+
+	var builder strings.Builder
+	rest := []byte(data)
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		builder.WriteString(wrapPEMBlock(block, width))
+	}
+
+	if builder.Len() == 0 {
+		return "", fmt.Errorf("no PEM block found")
+	}
+	return builder.String(), nil
+}
+
+// wrapPEMBlock encodes a single PEM block with its base64 body wrapped at width characters per line
+func wrapPEMBlock(block *pem.Block, width int) string {
+	lines := strings.Split(strings.TrimSuffix(string(pem.EncodeToMemory(block)), "\n"), "\n")
+	begin, body, end := lines[0], lines[1:len(lines)-1], lines[len(lines)-1]
+
+	// headers are followed by an empty line and are kept as they are
+	var headers []string
+	if len(block.Headers) > 0 {
+		for i, line := range body {
+			if line == "" {
+				headers, body = body[:i+1], body[i+1:]
+				break
+			}
+		}
+	}
+
+	encoded := strings.Join(body, "")
+	var builder strings.Builder
+	builder.WriteString(begin + "\n")
+	for _, header := range headers {
+		builder.WriteString(header + "\n")
+	}
+	for width > 0 && len(encoded) > width {
+		builder.WriteString(encoded[:width] + "\n")
+		encoded = encoded[width:]
+	}
+	if encoded != "" {
+		builder.WriteString(encoded + "\n")
+	}
+	builder.WriteString(end + "\n")
+	return builder.String()
+}
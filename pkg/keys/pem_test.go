@@ -0,0 +1,60 @@
+package keys
+
+import (
+	"bytes"
+	"encoding/pem"
+	"strings"
+	"testing"
+)
+
+func TestWrapPEM(t *testing.T) {
+	k, err := GenerateKeyFromMnemonic(t.Context(), KeyTypeECC, int(ECCCurveP384), SALT, MustParseMnemonic(testMnemonic))
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	original := k.PEM()
+
+	for _, width := range []int{0, 16, 76, PEM_LINE_LENGTH} {
+		wrapped, err := WrapPEM(original, width)
+		if err != nil {
+			t.Fatalf("failed to wrap PEM at %d: %v", width, err)
+		}
+
+		lines := strings.Split(strings.TrimSuffix(wrapped, "\n"), "\n")
+		body := lines[1 : len(lines)-1]
+		for i, line := range body {
+			if width == 0 && len(body) != 1 {
+				t.Fatalf("expected an unwrapped body, found %d lines", len(body))
+			}
+			if width > 0 && (len(line) > width || i < len(body)-1 && len(line) != width) {
+				t.Fatalf("line %d has length %d, want %d:\n%s", i, len(line), width, wrapped)
+			}
+		}
+
+		block, _ := pem.Decode([]byte(wrapped))
+		if block == nil || block.Type != "PRIVATE KEY" || !bytes.Equal(block.Bytes, k.Der) {
+			t.Fatalf("PEM wrapped at %d does not decode to the key", width)
+		}
+	}
+
+	if wrapped, _ := WrapPEM(original, PEM_LINE_LENGTH); wrapped != original {
+		t.Fatalf("expected the standard width to leave the PEM unchanged")
+	}
+
+	// every block is rewrapped and headers are kept
+	legacy := string(pem.EncodeToMemory(&pem.Block{Type: "TEST", Headers: map[string]string{"Proc-Type": "4,ENCRYPTED"}, Bytes: k.Der}))
+	wrapped, err := WrapPEM(original+legacy, 0)
+	if err != nil {
+		t.Fatalf("failed to wrap PEM blocks: %v", err)
+	}
+	if strings.Count(wrapped, "-----BEGIN") != 2 || !strings.Contains(wrapped, "Proc-Type: 4,ENCRYPTED\n\n") {
+		t.Fatalf("unexpected rewrapped blocks:\n%s", wrapped)
+	}
+
+	if _, err := WrapPEM(original, -1); err == nil {
+		t.Fatalf("expected an error for a negative width")
+	}
+	if _, err := WrapPEM("not pem", 0); err == nil {
+		t.Fatalf("expected an error without a PEM block")
+	}
+}
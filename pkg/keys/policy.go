@@ -0,0 +1,160 @@
+package keys
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// AlgorithmPolicy restricts the key algorithms that may be derived, e.g. to forbid RSA-2048 in a locked-down
+// deployment. The zero policy allows every supported algorithm.
+type AlgorithmPolicy struct {
+	allowed []string // stable algorithm names, e.g. "ecc-p-256" or "rsa-4096", nil if everything is allowed
+}
+
+// ParseAlgorithmPolicy parses a comma-separated list of allowed algorithms. An entry is a key type ("ecc" or "rsa")
+// allowing all of its curves or sizes, an ECC curve name or alias (e.g. "p384", "ed25519"), an RSA size prefixed with
+// "rsa-" (e.g. "rsa-4096"), or an algorithm name as reported in key labels (e.g. "ecc-p-256"). An empty string,
+// i.e. no policy, allows everything, but a policy of only commas or whitespace is rejected rather than allowing all.
+func ParseAlgorithmPolicy(val string) (AlgorithmPolicy, error) {
+	var policy AlgorithmPolicy
+	if val == "" {
+		return policy, nil
+	}
+	for _, entry := range strings.Split(val, ",") {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if entry == "" {
+			continue
+		}
+
+		names, err := policyAlgorithms(entry)
+		if err != nil {
+			return AlgorithmPolicy{}, err
+		}
+		for _, name := range names {
+			if !slices.Contains(policy.allowed, name) {
+				policy.allowed = append(policy.allowed, name)
+			}
+		}
+	}
+	// an explicit but empty policy fails closed
+	if len(policy.allowed) == 0 {
+		return AlgorithmPolicy{}, fmt.Errorf("the algorithm policy %q names no algorithms", val)
+	}
+	return policy, nil
+}
+
+// policyAlgorithms returns the algorithm names selected by a single policy entry
+func policyAlgorithms(entry string) ([]string, error) {
+	var names []string
+	switch entry {
+	case "ecc":
+		for _, info := range supportedECCCurves {
+			name, err := algorithmName(KeyTypeECC, int(info.ID))
+			if err != nil {
+				return nil, err
+			}
+			names = append(names, name)
+		}
+		return names, nil
+	case "rsa":
+		for id := RSAKeyNone + 1; id < rsaKeyEnd; id++ {
+			name, err := algorithmName(KeyTypeRSA, int(id))
+			if err != nil {
+				return nil, err
+			}
+			names = append(names, name)
+		}
+		return names, nil
+	}
+
+	if keyType, keyId, err := ParseAlgorithm(entry); err == nil {
+		return algorithmNames(keyType, keyId)
+	}
+	return nil, fmt.Errorf("unsupported algorithm in policy: %s", entry)
+}
+
+// SupportedAlgorithms returns a string listing the accepted forms of an algorithm selection
+func SupportedAlgorithms() string {
+	var curves []string
+	for _, info := range supportedECCCurves {
+		curves = append(curves, strings.ToLower(strings.ReplaceAll(info.Name, "-", "")))
+	}
+	var sizes []string
+	for _, size := range SupportedRSASizes() {
+		sizes = append(sizes, fmt.Sprint(size))
+	}
+
+	var builder strings.Builder
+	builder.WriteString("Supported algorithms:\n")
+	builder.WriteString(fmt.Sprintf(" - ecc:<curve>, or the curve alone (curves: %s, or any alias)\n", strings.Join(curves, ", ")))
+	builder.WriteString(fmt.Sprintf(" - rsa:<size> (sizes: %s)\n", strings.Join(sizes, ", ")))
+	builder.WriteString(" - the algorithm name of key labels and policies, e.g. ecc-p-256 or rsa-4096\n")
+	return builder.String()
+}
+
+// ParseAlgorithm parses an algorithm selection into its key type and id. It accepts "<type>:<id>" such as
+// "rsa:4096" or "ecc:p256", an ECC curve name or alias on its own such as "ed25519", and the stable algorithm names
+// of key labels such as "ecc-p-256" or "rsa-4096". New key types only need a case here to be selectable.
+func ParseAlgorithm(val string) (KeyType, int, error) {
+	val = strings.ToLower(strings.TrimSpace(val))
+	if val == "" {
+		return KeyTypeNone, 0, fmt.Errorf("no algorithm specified")
+	}
+
+	kind, id, typed := strings.Cut(val, ":")
+	if !typed {
+		if size, ok := strings.CutPrefix(val, "rsa-"); ok {
+			kind, id = "rsa", size
+		} else {
+			kind, id = "ecc", strings.TrimPrefix(val, "ecc-")
+		}
+	}
+
+	switch kind {
+	case "ecc":
+		curve, err := ParseECCCurve(id)
+		if err == nil && curve != ECCCurveNone {
+			return KeyTypeECC, int(curve), nil
+		}
+	case "rsa":
+		size, err := ParseRSAKeyID(id)
+		if err == nil && size != RSAKeyNone {
+			return KeyTypeRSA, int(size), nil
+		}
+	default:
+		return KeyTypeNone, 0, fmt.Errorf("unsupported key type in algorithm %q", val)
+	}
+	return KeyTypeNone, 0, fmt.Errorf("unsupported algorithm: %s", val)
+}
+
+// algorithmNames returns the algorithm name of the key type and id as a single-element list
+func algorithmNames(keyType KeyType, keyId int) ([]string, error) {
+	name, err := algorithmName(keyType, keyId)
+	if err != nil {
+		return nil, err
+	}
+	return []string{name}, nil
+}
+
+// Allows reports whether the policy allows deriving keys of the given type and id
+func (p AlgorithmPolicy) Allows(keyType KeyType, keyId int) bool {
+	name, err := algorithmName(keyType, keyId)
+	if err != nil {
+		return false
+	}
+	return p.allows(name)
+}
+
+// allows reports whether the policy allows the algorithm name
+func (p AlgorithmPolicy) allows(name string) bool {
+	return p.allowed == nil || slices.Contains(p.allowed, name)
+}
+
+// String returns the allowed algorithm names, or "all" if everything is allowed
+func (p AlgorithmPolicy) String() string {
+	if p.allowed == nil {
+		return "all"
+	}
+	return strings.Join(p.allowed, ", ")
+}
@@ -0,0 +1,119 @@
+package keys
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestAlgorithmPolicy(t *testing.T) {
+	policy, err := ParseAlgorithmPolicy("")
+	if err != nil {
+		t.Fatalf("failed to parse empty policy: %v", err)
+	}
+	if !policy.Allows(KeyTypeRSA, int(RSAKey2048)) || !policy.Allows(KeyTypeECC, int(ECCCurveEd25519)) {
+		t.Fatalf("expected the empty policy to allow everything")
+	}
+	if policy.String() != "all" {
+		t.Fatalf("unexpected empty policy string: %s", policy)
+	}
+	for _, val := range []string{",", " ", " , ,"} {
+		if _, err := ParseAlgorithmPolicy(val); err == nil {
+			t.Fatalf("expected the policy %q without algorithms to be rejected", val)
+		}
+	}
+
+	policy, err = ParseAlgorithmPolicy(" P384, rsa-4096 ,ecc-p-521,p384")
+	if err != nil {
+		t.Fatalf("failed to parse policy: %v", err)
+	}
+	allowed := map[KeyType][]int{
+		KeyTypeECC: {int(ECCCurveP384), int(ECCCurveP521)},
+		KeyTypeRSA: {int(RSAKey4096)},
+	}
+	denied := map[KeyType][]int{
+		KeyTypeECC: {int(ECCCurveP256), int(ECCCurveEd25519)},
+		KeyTypeRSA: {int(RSAKey2048), int(RSAKey3072), int(RSAKey8192)},
+	}
+	for keyType, ids := range allowed {
+		for _, id := range ids {
+			if !policy.Allows(keyType, id) {
+				t.Fatalf("expected %s key %d to be allowed", keyType, id)
+			}
+		}
+	}
+	for keyType, ids := range denied {
+		for _, id := range ids {
+			if policy.Allows(keyType, id) {
+				t.Fatalf("expected %s key %d to be denied", keyType, id)
+			}
+		}
+	}
+	if policy.String() != "ecc-p-384, rsa-4096, ecc-p-521" {
+		t.Fatalf("unexpected policy string: %s", policy)
+	}
+
+	policy, err = ParseAlgorithmPolicy("rsa")
+	if err != nil {
+		t.Fatalf("failed to parse policy: %v", err)
+	}
+	for _, size := range SupportedRSASizes() {
+		id, _ := ParseRSAKeyID(fmt.Sprint(size))
+		if !policy.Allows(KeyTypeRSA, int(id)) {
+			t.Fatalf("expected RSA-%d to be allowed by the rsa family", size)
+		}
+	}
+	if policy.Allows(KeyTypeECC, int(ECCCurveP256)) {
+		t.Fatalf("expected the rsa family to deny ECC keys")
+	}
+
+	for _, val := range []string{"dsa", "rsa-1024", "ecc-foo", "p384,rsa-"} {
+		if _, err := ParseAlgorithmPolicy(val); err == nil {
+			t.Fatalf("expected policy %q to be rejected", val)
+		}
+	}
+}
+
+func TestParseAlgorithm(t *testing.T) {
+	tests := []struct {
+		val     string
+		keyType KeyType
+		keyId   int
+	}{
+		{"rsa:2048", KeyTypeRSA, int(RSAKey2048)},
+		{"RSA:4096", KeyTypeRSA, int(RSAKey4096)},
+		{"rsa-8192", KeyTypeRSA, int(RSAKey8192)},
+		{"ecc:p256", KeyTypeECC, int(ECCCurveP256)},
+		{"ecc:secp384r1", KeyTypeECC, int(ECCCurveP384)},
+		{"ecc:ed25519", KeyTypeECC, int(ECCCurveEd25519)},
+		{" ed25519 ", KeyTypeECC, int(ECCCurveEd25519)},
+		{"p521", KeyTypeECC, int(ECCCurveP521)},
+		{"prime256v1", KeyTypeECC, int(ECCCurveP256)},
+		{"ecc-p-384", KeyTypeECC, int(ECCCurveP384)},
+	}
+	for _, tc := range tests {
+		keyType, keyId, err := ParseAlgorithm(tc.val)
+		if err != nil {
+			t.Fatalf("failed to parse algorithm %q: %v", tc.val, err)
+		}
+		if keyType != tc.keyType || keyId != tc.keyId {
+			t.Fatalf("algorithm %q parsed as %s key %d, expected %s key %d", tc.val, keyType, keyId, tc.keyType, tc.keyId)
+		}
+	}
+
+	// every stable algorithm name parses back to its key
+	for _, info := range SupportedECCCurves() {
+		name, err := algorithmName(KeyTypeECC, int(info.ID))
+		if err != nil {
+			t.Fatalf("failed to name curve %s: %v", info.Name, err)
+		}
+		if keyType, keyId, err := ParseAlgorithm(name); err != nil || keyType != KeyTypeECC || keyId != int(info.ID) {
+			t.Fatalf("failed to parse algorithm name %q: %v", name, err)
+		}
+	}
+
+	for _, val := range []string{"", "rsa", "ecc", "rsa:1024", "ecc:p192", "rsa:p256", "ecc:4096x", "dsa:2048", "p-999"} {
+		if _, _, err := ParseAlgorithm(val); err == nil {
+			t.Fatalf("expected algorithm %q to be rejected", val)
+		}
+	}
+}
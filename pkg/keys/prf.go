@@ -0,0 +1,141 @@
+package keys
+
+import (
+	"crypto/pbkdf2"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"fmt"
+	"hash"
+	"strings"
+
+	"github.com/youmark/pkcs8"
+)
+
+// PBKDF2PRF is the pseudorandom function PBKDF2 uses to derive the PKCS#8 encryption key from the password
+type PBKDF2PRF string
+
+const (
+	PBKDF2PRFSHA256 PBKDF2PRF = "sha256" // HMAC-SHA256, the default and what OpenSSL 1.1+ writes
+	PBKDF2PRFSHA512 PBKDF2PRF = "sha512" // HMAC-SHA512, required by some HSM import tools and FIPS profiles
+)
+
+var supportedPBKDF2PRFs = []PBKDF2PRF{PBKDF2PRFSHA256, PBKDF2PRFSHA512}
+
+// pbkdf2PRF is an HMAC PRF of RFC 8018 that PBKDF2 keys are encrypted or decrypted with
+type pbkdf2PRF struct {
+	oid  asn1.ObjectIdentifier
+	hash func() hash.Hash
+}
+
+// pbkdf2PRFs lists the PRFs bipkey decrypts PBKDF2 keys with. The pkcs8 library only knows HMAC-SHA1 and HMAC-SHA256,
+// so init registers the PBKDF2 parameters again with this wider set.
+var pbkdf2PRFs = []pbkdf2PRF{
+	{oidSHA1, sha1.New},
+	{oidSHA224, sha256.New224},
+	{oidSHA256, sha256.New},
+	{oidSHA384, sha512.New384},
+	{oidSHA512, sha512.New},
+}
+
+func init() {
+	pkcs8.RegisterKDF(oidPBKDF2, func() pkcs8.KDFParameters {
+		return new(pbkdf2Params)
+	})
+}
+
+// SupportedPBKDF2PRFs returns a string listing the PBKDF2 PRFs keys can be encrypted with
+func SupportedPBKDF2PRFs() string {
+	var names []string
+	for _, prf := range supportedPBKDF2PRFs {
+		names = append(names, string(prf))
+	}
+	return "Supported PBKDF2 PRFs: " + strings.Join(names, ", ")
+}
+
+// ParsePBKDF2PRF parses the name of a PBKDF2 PRF, with or without an "hmac-" prefix. An empty name is the default,
+// HMAC-SHA256.
+func ParsePBKDF2PRF(val string) (PBKDF2PRF, error) {
+	val = strings.ToLower(strings.TrimSpace(val))
+	val = strings.ReplaceAll(strings.TrimPrefix(val, "hmac-"), "-", "")
+	if val == "" {
+		return PBKDF2PRFSHA256, nil
+	}
+
+	for _, prf := range supportedPBKDF2PRFs {
+		if val == string(prf) {
+			return prf, nil
+		}
+	}
+
+	return "", fmt.Errorf("unsupported PBKDF2 PRF: %s", val)
+}
+
+// PKCS8Opts returns the PKCS#8 encryption options for EncryptWithOpts that use PBKDF2 with the given PRF. The cipher,
+// salt size, and iteration count are those of the default options.
+func PKCS8Opts(prf PBKDF2PRF) (*pkcs8.Opts, error) {
+	var oid asn1.ObjectIdentifier
+	switch prf {
+	case PBKDF2PRFSHA256, "":
+		oid = oidSHA256
+	case PBKDF2PRFSHA512:
+		oid = oidSHA512
+	default:
+		return nil, fmt.Errorf("unsupported PBKDF2 PRF: %s", prf)
+	}
+
+	defaults := pkcs8.DefaultOpts.KDFOpts.(pkcs8.PBKDF2Opts)
+	return &pkcs8.Opts{
+		Cipher:  pkcs8.DefaultOpts.Cipher,
+		KDFOpts: pbkdf2Opts{SaltSize: defaults.SaltSize, IterationCount: defaults.IterationCount, PRF: oid},
+	}, nil
+}
+
+// pbkdf2Opts are PBKDF2 encryption options like pkcs8.PBKDF2Opts, but with any PRF of pbkdf2PRFs
+type pbkdf2Opts struct {
+	SaltSize       int
+	IterationCount int
+	PRF            asn1.ObjectIdentifier
+}
+
+func (o pbkdf2Opts) DeriveKey(password, salt []byte, size int) ([]byte, pkcs8.KDFParameters, error) {
+	params := pbkdf2Params{
+		Salt:           salt,
+		IterationCount: o.IterationCount,
+		PRF:            pkix.AlgorithmIdentifier{Algorithm: o.PRF, Parameters: asn1.NullRawValue},
+	}
+	key, err := params.DeriveKey(password, size)
+	if err != nil {
+		return nil, nil, err
+	}
+	return key, params, nil
+}
+
+func (o pbkdf2Opts) GetSaltSize() int {
+	return o.SaltSize
+}
+
+func (o pbkdf2Opts) OID() asn1.ObjectIdentifier {
+	return oidPBKDF2
+}
+
+// DeriveKey derives the decryption key from the password, so that pbkdf2Params decode PBKDF2 keys for pkcs8. The PRF
+// defaults to HMAC-SHA1.
+func (p pbkdf2Params) DeriveKey(password []byte, size int) ([]byte, error) {
+	if p.KeyLength != 0 && p.KeyLength != size {
+		return nil, fmt.Errorf("PBKDF2 key length %d does not match the cipher key size %d", p.KeyLength, size)
+	}
+
+	oid := p.PRF.Algorithm
+	if len(oid) == 0 {
+		oid = oidSHA1
+	}
+	for _, prf := range pbkdf2PRFs {
+		if prf.oid.Equal(oid) {
+			return pbkdf2.Key(prf.hash, string(password), p.Salt, p.IterationCount, size)
+		}
+	}
+	return nil, fmt.Errorf("unsupported PBKDF2 PRF %s", oid)
+}
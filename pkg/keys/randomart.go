@@ -0,0 +1,101 @@
+package keys
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"fmt"
+	"strings"
+)
+
+// randomart field dimensions and symbols, as used by OpenSSH's drunken bishop (sshkey_fingerprint_randomart). The
+// symbols are ordered by visit count, followed by the start (S) and end (E) markers.
+const (
+	RANDOMART_WIDTH   = 17
+	RANDOMART_HEIGHT  = 9
+	RANDOMART_SYMBOLS = " .o+=*BOX@%&#/^SE"
+)
+
+// Randomart renders the SHA-256 public key fingerprint (see PublicFingerprint) as ssh-keygen style randomart, so that
+// two keys can be compared at a glance. The art is of the SubjectPublicKeyInfo fingerprint that bipkey displays, so
+// it differs from ssh-keygen's art of the same key, which hashes the OpenSSH public key instead.
+func (k Key) Randomart() (string, error) {
+	der, err := k.PublicKeyDER()
+	if err != nil {
+		return "", err
+	}
+	pub, err := k.PublicKey()
+	if err != nil {
+		return "", err
+	}
+
+	var title string
+	switch pub := pub.(type) {
+	case *rsa.PublicKey:
+		title = fmt.Sprintf("[RSA %d]", pub.N.BitLen())
+	case *ecdsa.PublicKey:
+		title = fmt.Sprintf("[ECDSA %d]", pub.Curve.Params().BitSize)
+	case ed25519.PublicKey:
+		title = "[ED25519 256]"
+	default:
+		return "", fmt.Errorf("unsupported public key type %T", pub)
+	}
+
+	sum := sha256.Sum256(der)
+	return randomart(title, "[SHA256]", sum[:]), nil
+}
+
+// randomart walks a bishop across the field from its center, two bits of the digest per move, counting the visits
+// of every square, and frames the field with the title above and the hash name below
+func randomart(title string, hash string, digest []byte) string {
+	var field [RANDOMART_WIDTH][RANDOMART_HEIGHT]int
+	end := len(RANDOMART_SYMBOLS) - 1
+	x, y := RANDOMART_WIDTH/2, RANDOMART_HEIGHT/2
+
+	for _, b := range digest {
+		for range 4 {
+			if b&0x1 != 0 {
+				x++
+			} else {
+				x--
+			}
+			if b&0x2 != 0 {
+				y++
+			} else {
+				y--
+			}
+			x = max(0, min(x, RANDOMART_WIDTH-1))
+			y = max(0, min(y, RANDOMART_HEIGHT-1))
+			// the count saturates below the start and end markers
+			if field[x][y] < end-2 {
+				field[x][y]++
+			}
+			b >>= 2
+		}
+	}
+	field[RANDOMART_WIDTH/2][RANDOMART_HEIGHT/2] = end - 1
+	field[x][y] = end
+
+	var builder strings.Builder
+	builder.WriteString(randomartBorder(title))
+	builder.WriteString("\n")
+	for y := range RANDOMART_HEIGHT {
+		builder.WriteString("|")
+		for x := range RANDOMART_WIDTH {
+			builder.WriteByte(RANDOMART_SYMBOLS[field[x][y]])
+		}
+		builder.WriteString("|\n")
+	}
+	builder.WriteString(randomartBorder(hash))
+	return builder.String()
+}
+
+// randomartBorder returns a horizontal border with the label centered as ssh-keygen does, rounding to the left
+func randomartBorder(label string) string {
+	if len(label) > RANDOMART_WIDTH {
+		label = label[:RANDOMART_WIDTH]
+	}
+	left := (RANDOMART_WIDTH - len(label)) / 2
+	return "+" + strings.Repeat("-", left) + label + strings.Repeat("-", RANDOMART_WIDTH-left-len(label)) + "+"
+}
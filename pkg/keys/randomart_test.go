@@ -0,0 +1,69 @@
+package keys
+
+import (
+	"crypto/sha256"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+func TestRandomartMatchesSSHKeygen(t *testing.T) {
+	// expected art is the output of ssh-keygen -lv for the OpenSSH public key of the same key
+	expected := "" +
+		"+--[ED25519 256]--+\n" +
+		"|   .  o+.        |\n" +
+		"|  o ..o          |\n" +
+		"| + o o+.         |\n" +
+		"|  B ooo..        |\n" +
+		"| . = + .S.       |\n" +
+		"|Eo  = = o .      |\n" +
+		"|=o o ++= o       |\n" +
+		"|.o  ====.        |\n" +
+		"| o=o+=++o        |\n" +
+		"+----[SHA256]-----+"
+
+	k, err := GenerateKeyFromMnemonic(t.Context(), KeyTypeECC, int(ECCCurveEd25519), SALT, MustParseMnemonic(testMnemonic))
+	if err != nil {
+		t.Fatalf("failed to generate Ed25519 key from mnemonic: %v", err)
+	}
+	pub, err := k.PublicKey()
+	if err != nil {
+		t.Fatalf("failed to get public key: %v", err)
+	}
+	sshPub, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		t.Fatalf("failed to convert public key: %v", err)
+	}
+
+	sum := sha256.Sum256(sshPub.Marshal())
+	if art := randomart("[ED25519 256]", "[SHA256]", sum[:]); art != expected {
+		t.Fatalf("unexpected randomart:\n%s\nwant:\n%s", art, expected)
+	}
+}
+
+func TestKeyRandomart(t *testing.T) {
+	expected := "" +
+		"+---[ECDSA 256]---+\n" +
+		"|     . o.      oB|\n" +
+		"|  . . + ..    +o=|\n" +
+		"|oo .   o ..  =o=.|\n" +
+		"|o..   .  .. ..O..|\n" +
+		"| .   o  S  . =.o.|\n" +
+		"|  . o .  .  + o+o|\n" +
+		"| . . o       + .*|\n" +
+		"|  . .         E +|\n" +
+		"|              .O*|\n" +
+		"+----[SHA256]-----+"
+
+	k, err := GenerateKeyFromMnemonic(t.Context(), KeyTypeECC, int(ECCCurveP256), SALT, MustParseMnemonic(testMnemonic))
+	if err != nil {
+		t.Fatalf("failed to generate ECC key from mnemonic: %v", err)
+	}
+	art, err := k.Randomart()
+	if err != nil {
+		t.Fatalf("failed to render randomart: %v", err)
+	}
+	if art != expected {
+		t.Fatalf("unexpected randomart:\n%s\nwant:\n%s", art, expected)
+	}
+}
@@ -0,0 +1,197 @@
+package keys
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// RecoveryRecord holds everything needed to restore a key from one file: the derivation parameters and the mnemonic.
+// The salt may be included, referenced by the path of a file holding it, or left out to be sealed separately.
+// Records are read from YAML or JSON, and must be stored as securely as the mnemonic itself.
+type RecoveryRecord struct {
+	Type        KeyType       `json:"type" yaml:"type"`                                     // key type, ECC or RSA
+	Curve       string        `json:"curve,omitempty" yaml:"curve,omitempty"`               // ECC curve, e.g. "P-384"
+	Size        int           `json:"size,omitempty" yaml:"size,omitempty"`                 // RSA modulus size in bits
+	Derivation  string        `json:"derivation" yaml:"derivation"`                         // derivation version, e.g. "v2"
+	Scrypt      *ScryptParams `json:"scrypt,omitempty" yaml:"scrypt,omitempty"`             // scrypt seed stretch, if any
+	EntropySeed bool          `json:"entropy_seed,omitempty" yaml:"entropy_seed,omitempty"` // derived from the mnemonic entropy
+	RSAExponent *RSAExponent  `json:"rsa_exponent,omitempty" yaml:"rsa_exponent,omitempty"` // RSA public exponent settings
+	Identity    string        `json:"identity,omitempty" yaml:"identity,omitempty"`         // key identity of a labeled key
+	Index       int           `json:"index" yaml:"index"`                                   // key index
+	Path        string        `json:"bip32_path,omitempty" yaml:"bip32_path,omitempty"`     // BIP-32 path of a SLIP-10 key
+	Salt        string        `json:"salt,omitempty" yaml:"salt,omitempty"`                 // salt, if stored in the record
+	SaltFile    string        `json:"salt_file,omitempty" yaml:"salt_file,omitempty"`       // path of a file holding the salt
+	Language    Language      `json:"language,omitempty" yaml:"language,omitempty"`         // mnemonic word list language
+	Mnemonic    string        `json:"mnemonic" yaml:"mnemonic"`                             // mnemonic words
+}
+
+// RecoveryRecord returns the recovery record of the key, including its salt and mnemonic
+func (k *Key) RecoveryRecord() (*RecoveryRecord, error) {
+	if k.mnemonic == (Mnemonic{}) {
+		return nil, fmt.Errorf("key has no mnemonic to record")
+	}
+	g := k.generator()
+
+	record := &RecoveryRecord{
+		Type:        k.keyType,
+		Derivation:  k.Derivation().String(),
+		Scrypt:      k.Scrypt(),
+		EntropySeed: k.EntropySeed(),
+		Identity:    k.label.Identity,
+		Index:       k.label.Index,
+		Salt:        k.salt,
+		Language:    g.Language(),
+		Mnemonic:    k.mnemonic.String(),
+	}
+	if k.path != nil {
+		record.Path = k.path.String()
+	}
+	switch k.keyType {
+	case KeyTypeECC:
+		for _, info := range supportedECCCurves {
+			if info.ID == ECCCurveID(k.keyId) {
+				record.Curve = info.Name
+			}
+		}
+	case KeyTypeRSA:
+		record.Size = k.Size()
+		exponent := g.RSAExponent()
+		record.RSAExponent = &exponent
+	}
+	return record, nil
+}
+
+// ParseRecoveryRecord parses and validates a YAML or JSON recovery record, rejecting unknown fields
+func ParseRecoveryRecord(data []byte) (*RecoveryRecord, error) {
+	var record RecoveryRecord
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&record); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("recovery record is empty")
+		}
+		return nil, fmt.Errorf("invalid recovery record: %w", err)
+	}
+	if err := record.Validate(); err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// KeyID returns the key type and id of the record's key
+func (r *RecoveryRecord) KeyID() (KeyType, int, error) {
+	switch KeyType(strings.ToUpper(string(r.Type))) {
+	case KeyTypeECC:
+		if r.Size != 0 {
+			return KeyTypeNone, 0, fmt.Errorf("recovery record of an ECC key must give its curve, not a size")
+		}
+		id, err := ParseECCCurve(r.Curve)
+		if err != nil || id == ECCCurveNone {
+			return KeyTypeNone, 0, fmt.Errorf("recovery record has an invalid ECC curve %q", r.Curve)
+		}
+		return KeyTypeECC, int(id), nil
+	case KeyTypeRSA:
+		if r.Curve != "" {
+			return KeyTypeNone, 0, fmt.Errorf("recovery record of an RSA key must give its size, not a curve")
+		}
+		id, err := ParseRSAKeyID(fmt.Sprint(r.Size))
+		if err != nil || id == RSAKeyNone {
+			return KeyTypeNone, 0, fmt.Errorf("recovery record has an invalid RSA key size %d", r.Size)
+		}
+		return KeyTypeRSA, int(id), nil
+	default:
+		return KeyTypeNone, 0, fmt.Errorf("recovery record has an invalid key type %q, expected %s or %s", r.Type, KeyTypeECC, KeyTypeRSA)
+	}
+}
+
+// Validate checks every field of the record, and that the mnemonic parses with a valid checksum in its language
+func (r *RecoveryRecord) Validate() error {
+	keyType, _, err := r.KeyID()
+	if err != nil {
+		return err
+	}
+	derivation, err := ParseDerivationVersion(r.Derivation)
+	if err != nil {
+		return fmt.Errorf("recovery record has an invalid derivation: %w", err)
+	}
+	if r.Scrypt != nil {
+		if err := r.Scrypt.validate(); err != nil {
+			return fmt.Errorf("recovery record has invalid scrypt parameters: %w", err)
+		}
+	}
+	if r.RSAExponent != nil {
+		if keyType != KeyTypeRSA {
+			return fmt.Errorf("recovery record of an %s key must not give an RSA exponent", keyType)
+		}
+		if err := r.RSAExponent.validate(); err != nil {
+			return fmt.Errorf("recovery record has an invalid RSA exponent: %w", err)
+		}
+		if derivation == DerivationV1 && r.RSAExponent.E != RSA_PUBLIC_EXPONENT {
+			return fmt.Errorf("recovery record has a custom RSA exponent, which requires derivation %s or later", DerivationV2)
+		}
+	}
+	if r.Index < 0 {
+		return fmt.Errorf("recovery record has a negative key index %d", r.Index)
+	}
+	if err := (KeyLabel{Identity: r.Identity, Index: r.Index}).validate(); err != nil {
+		return fmt.Errorf("recovery record has an invalid identity: %w", err)
+	}
+	if r.Path != "" {
+		path, err := ParseDerivationPath(r.Path)
+		if err != nil {
+			return fmt.Errorf("recovery record has an invalid BIP-32 path: %w", err)
+		}
+		_, keyId, _ := r.KeyID()
+		if err := CheckDerivationPath(path, keyType, keyId); err != nil {
+			return fmt.Errorf("recovery record has an invalid BIP-32 path: %w", err)
+		}
+		if r.EntropySeed {
+			return fmt.Errorf("recovery record of a BIP-32 path key must not use the entropy seed")
+		}
+	}
+	if r.Salt != "" && r.SaltFile != "" {
+		return fmt.Errorf("recovery record must give at most one of salt and salt_file")
+	}
+
+	if _, _, err := r.ParseMnemonic(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// ParseMnemonic parses the record's mnemonic with the word list of its language, or of the detected language if the
+// record does not name one, and returns it with that language
+func (r *RecoveryRecord) ParseMnemonic() (Mnemonic, Language, error) {
+	var language Language
+	var err error
+	if r.Language == "" {
+		if language, err = DetectLanguage(r.Mnemonic); err != nil {
+			return Mnemonic{}, "", fmt.Errorf("recovery record mnemonic: %w", err)
+		}
+	} else if language, err = ParseLanguage(string(r.Language)); err != nil {
+		return Mnemonic{}, "", fmt.Errorf("recovery record has an invalid language: %w", err)
+	}
+	words, err := language.WordList()
+	if err != nil {
+		return Mnemonic{}, "", err
+	}
+	g, err := NewGenerator(Config{WordList: words})
+	if err != nil {
+		return Mnemonic{}, "", err
+	}
+
+	mnemonic, err := g.ParseMnemonic(r.Mnemonic)
+	if err != nil {
+		return Mnemonic{}, "", fmt.Errorf("recovery record has an invalid mnemonic: %w", err)
+	}
+	// a recorded mnemonic was written by bipkey, so a bad checksum means the record is damaged
+	if _, err := g.EntropyFromMnemonic(mnemonic); err != nil {
+		return Mnemonic{}, "", fmt.Errorf("recovery record has an invalid mnemonic: %w", err)
+	}
+	return mnemonic, language, nil
+}
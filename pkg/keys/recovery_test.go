@@ -0,0 +1,87 @@
+package keys
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestRecoveryRecordRoundTrip(t *testing.T) {
+	key, err := GenerateLabeledKeyFromMnemonic(t.Context(), KeyTypeECC, int(ECCCurveP384), SALT, MustParseMnemonic(testMnemonic), KeyLabel{Identity: "root-ca", Index: 2})
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	record, err := key.RecoveryRecord()
+	if err != nil {
+		t.Fatalf("failed to build recovery record: %v", err)
+	}
+	if record.Curve != "P-384" || record.Index != 2 || record.Identity != "root-ca" || record.Salt != SALT || record.Language != LanguageEnglish {
+		t.Fatalf("unexpected recovery record: %+v", record)
+	}
+
+	yamlData, err := yaml.Marshal(record)
+	if err != nil {
+		t.Fatalf("failed to marshal YAML: %v", err)
+	}
+	jsonData, err := json.Marshal(record)
+	if err != nil {
+		t.Fatalf("failed to marshal JSON: %v", err)
+	}
+	for _, data := range [][]byte{yamlData, jsonData} {
+		parsed, err := ParseRecoveryRecord(data)
+		if err != nil {
+			t.Fatalf("failed to parse recovery record %s: %v", data, err)
+		}
+		keyType, keyId, err := parsed.KeyID()
+		if err != nil || keyType != KeyTypeECC || keyId != int(ECCCurveP384) {
+			t.Fatalf("unexpected key id %s/%d: %v", keyType, keyId, err)
+		}
+		mnemonic, _, err := parsed.ParseMnemonic()
+		if err != nil {
+			t.Fatalf("failed to parse recorded mnemonic: %v", err)
+		}
+
+		restored, err := GenerateLabeledKeyFromMnemonic(t.Context(), keyType, keyId, parsed.Salt, mnemonic, KeyLabel{Identity: parsed.Identity, Index: parsed.Index})
+		if err != nil {
+			t.Fatalf("failed to restore key: %v", err)
+		}
+		if restored.Fingerprint() != key.Fingerprint() {
+			t.Fatalf("restored key %s does not match %s", restored.Fingerprint(), key.Fingerprint())
+		}
+	}
+}
+
+func TestParseRecoveryRecordRejectsInvalid(t *testing.T) {
+	valid := "type: ECC\ncurve: P-256\nderivation: v2\nindex: 0\nmnemonic: " + testMnemonic + "\n"
+	if _, err := ParseRecoveryRecord([]byte(valid)); err != nil {
+		t.Fatalf("failed to parse valid record: %v", err)
+	}
+
+	badChecksum := strings.Replace(testMnemonic, "wait", "zoo", 1)
+	tests := map[string]string{
+		"empty":            "",
+		"unknown field":    valid + "comment: hello\n",
+		"ecc size":         strings.Replace(valid, "curve: P-256", "size: 256", 1),
+		"rsa size":         strings.Replace(valid, "type: ECC\ncurve: P-256", "type: RSA\nsize: 1024", 1),
+		"derivation":       strings.Replace(valid, "v2", "v9", 1),
+		"negative index":   strings.Replace(valid, "index: 0", "index: -1", 1),
+		"salt and file":    valid + "salt: a\nsalt_file: salt.txt\n",
+		"ecc exponent":     valid + "rsa_exponent: {e: 3}\n",
+		"bad checksum":     strings.Replace(valid, testMnemonic, badChecksum, 1),
+		"unknown word":     strings.Replace(valid, "wait", "zzzz", 1),
+		"bad language":     valid + "language: klingon\n",
+		"wrong language":   valid + "language: spanish\n",
+		"identity":         valid + "identity: \"a;b\"\n",
+		"scrypt":           valid + "scrypt: {n: 3, r: 8, p: 1}\n",
+		"missing curve":    strings.Replace(valid, "curve: P-256\n", "", 1),
+		"missing type":     strings.Replace(valid, "type: ECC\n", "", 1),
+		"missing mnemonic": strings.Replace(valid, "mnemonic: "+testMnemonic+"\n", "", 1),
+	}
+	for name, data := range tests {
+		if _, err := ParseRecoveryRecord([]byte(data)); err == nil {
+			t.Fatalf("%s: expected the recovery record to be rejected", name)
+		}
+	}
+}
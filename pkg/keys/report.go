@@ -0,0 +1,234 @@
+package keys
+
+import (
+	"fmt"
+	"time"
+)
+
+// MnemonicWord holds a single mnemonic word along with its 1-based position and its BIP-39 word list index
+type MnemonicWord struct {
+	Position int    `json:"position"`
+	Word     string `json:"word"`
+	Index    int    `json:"index"`
+}
+
+// KeyReport is the machine-readable description of a key, suitable for JSON output.
+// The mnemonic and private key are only populated when explicitly requested.
+type KeyReport struct {
+	Type          KeyType        `json:"type"`
+	Size          int            `json:"size"`
+	ModulusBits   int            `json:"modulus_bits,omitempty"`       // actual RSA modulus bit length
+	ModulusExact  *bool          `json:"modulus_size_match,omitempty"` // whether the RSA modulus has exactly Size bits
+	Derivation    string         `json:"derivation"`
+	Path          string         `json:"bip32_path,omitempty"`
+	KDF           string         `json:"kdf,omitempty"`
+	Identity      string         `json:"identity,omitempty"`
+	Index         int            `json:"index"`
+	Comment       string         `json:"comment"`
+	Salt          string         `json:"salt"`
+	Fingerprint   string         `json:"public_key_fingerprint"`
+	PublicKey     string         `json:"public_key"`
+	Algorithm     string         `json:"algorithm"`
+	Capabilities  []string       `json:"capabilities"`
+	Caveats       []string       `json:"caveats,omitempty"`
+	Format        Format         `json:"format,omitempty"`
+	PrivateKey    string         `json:"private_key,omitempty"`
+	Mnemonic      string         `json:"mnemonic,omitempty"`
+	MnemonicWords []MnemonicWord `json:"mnemonic_words,omitempty"`
+}
+
+// MnemonicWords returns the position, word, and BIP-39 index of every word in the mnemonic
+func MnemonicWords(m Mnemonic) ([]MnemonicWord, error) {
+	return defaultGenerator.MnemonicWords(m)
+}
+
+// MnemonicWords returns the position, word, and word list index of every word in the mnemonic
+func (g *Generator) MnemonicWords(m Mnemonic) ([]MnemonicWord, error) {
+	words := make([]MnemonicWord, 0, len(m))
+	for i, word := range m {
+		index, wordFull, err := g.GetWordIndex(word)
+		if err != nil {
+			return nil, fmt.Errorf("invalid mnemonic word '%s': %w", word, err)
+		}
+		words = append(words, MnemonicWord{Position: i + 1, Word: wordFull, Index: index})
+	}
+	return words, nil
+}
+
+// Report returns the machine-readable description of the key. The mnemonic, its word indices, and the
+// private key encoded in the given format are only included when includePrivate is set.
+func (k *Key) Report(format Format, includePrivate bool) (*KeyReport, error) {
+	fingerprint, err := k.PublicFingerprint(FingerprintHex)
+	if err != nil {
+		return nil, err
+	}
+
+	publicKey, err := k.PublicKeyPEM()
+	if err != nil {
+		return nil, err
+	}
+
+	caps, err := k.Capabilities()
+	if err != nil {
+		return nil, err
+	}
+
+	report := &KeyReport{
+		Type:        k.keyType,
+		Size:        k.Size(),
+		Derivation:  k.DerivationName(),
+		Identity:    k.label.Identity,
+		Index:       k.label.Index,
+		Comment:     k.Comment(),
+		Salt:        k.salt,
+		Fingerprint: fingerprint,
+		PublicKey:   publicKey,
+
+		Algorithm:    caps.Algorithm,
+		Capabilities: caps.Operations,
+		Caveats:      caps.Caveats,
+	}
+	if params := k.Scrypt(); params != nil {
+		report.KDF = params.String()
+	}
+	if k.brainwallet {
+		report.KDF = "argon2id " + BrainwalletArgon2Params.String()
+	}
+	if k.path != nil {
+		report.Path = k.path.String()
+	}
+	if bits := k.ModulusBits(); bits != 0 {
+		exact := bits == report.Size
+		report.ModulusBits, report.ModulusExact = bits, &exact
+	}
+
+	if !includePrivate {
+		return report, nil
+	}
+
+	encoded, err := k.Encode(format)
+	if err != nil {
+		return nil, err
+	}
+	report.Format = format
+	report.PrivateKey = encoded
+	if k.brainwallet {
+		return report, nil
+	}
+
+	words, err := k.generator().MnemonicWords(k.mnemonic)
+	if err != nil {
+		return nil, err
+	}
+
+	report.Mnemonic = k.mnemonic.String()
+	report.MnemonicWords = words
+	return report, nil
+}
+
+// KeyMetadata holds the derivation parameters of a key, everything except the mnemonic needed to restore or verify
+// it. It includes the salt, so it is only produced on request.
+type KeyMetadata struct {
+	Type        KeyType       `json:"type"`
+	Size        int           `json:"size"`
+	Derivation  string        `json:"derivation"`
+	Path        string        `json:"bip32_path,omitempty"`
+	KDF         string        `json:"kdf"`
+	Scrypt      *ScryptParams `json:"scrypt,omitempty"`
+	Identity    string        `json:"identity,omitempty"`
+	Index       int           `json:"index"`
+	Comment     string        `json:"comment"`
+	Salt        string        `json:"salt"`
+	RSAExponent *RSAExponent  `json:"rsa_exponent,omitempty"`
+	Format      Format        `json:"format"`
+	Encrypted   bool          `json:"encrypted"`
+	Fingerprint string        `json:"public_key_fingerprint"`
+}
+
+// Metadata returns the derivation parameters of the key, as written in the given format
+func (k *Key) Metadata(format Format) (*KeyMetadata, error) {
+	fingerprint, err := k.PublicFingerprint(FingerprintHex)
+	if err != nil {
+		return nil, err
+	}
+
+	metadata := &KeyMetadata{
+		Type:        k.keyType,
+		Size:        k.Size(),
+		Derivation:  k.DerivationName(),
+		KDF:         "none",
+		Scrypt:      k.Scrypt(),
+		Identity:    k.label.Identity,
+		Index:       k.label.Index,
+		Comment:     k.Comment(),
+		Salt:        k.salt,
+		Format:      format,
+		Encrypted:   k.encrypted,
+		Fingerprint: fingerprint,
+	}
+	if metadata.Scrypt != nil {
+		metadata.KDF = "scrypt"
+	}
+	if k.brainwallet {
+		metadata.KDF = "argon2id " + BrainwalletArgon2Params.String()
+	}
+	if k.path != nil {
+		metadata.Path = k.path.String()
+	}
+	if k.keyType == KeyTypeRSA {
+		exponent := k.generator().RSAExponent()
+		metadata.RSAExponent = &exponent
+	}
+	return metadata, nil
+}
+
+// KeyRecord is the inventory record of a key: what it is and where it came from, without the mnemonic, the private
+// key, or anything else secret. The salt is only included on request.
+type KeyRecord struct {
+	Type        KeyType   `json:"type"`
+	Size        int       `json:"size"`
+	Algorithm   string    `json:"algorithm"`
+	Derivation  string    `json:"derivation"`
+	Path        string    `json:"bip32_path,omitempty"`
+	Identity    string    `json:"identity,omitempty"`
+	Index       int       `json:"index"`
+	Comment     string    `json:"comment"`
+	Salt        string    `json:"salt,omitempty"`
+	Fingerprint string    `json:"public_key_fingerprint"`
+	Created     time.Time `json:"created"`
+	Tool        string    `json:"tool,omitempty"`    // the tool and version that derived the key, e.g. "bipkey v1.4.0"
+	Command     string    `json:"command,omitempty"` // the command that derived the key, e.g. "generate"
+}
+
+// Record returns the inventory record of the key, created now, with the public key fingerprint in the given format.
+// The salt is only included when includeSalt is set.
+func (k *Key) Record(format FingerprintFormat, includeSalt bool) (*KeyRecord, error) {
+	fingerprint, err := k.PublicFingerprint(format)
+	if err != nil {
+		return nil, err
+	}
+
+	caps, err := k.Capabilities()
+	if err != nil {
+		return nil, err
+	}
+
+	record := &KeyRecord{
+		Type:        k.keyType,
+		Size:        k.Size(),
+		Algorithm:   caps.Algorithm,
+		Derivation:  k.DerivationName(),
+		Identity:    k.label.Identity,
+		Index:       k.label.Index,
+		Comment:     k.Comment(),
+		Fingerprint: fingerprint,
+		Created:     time.Now().UTC().Truncate(time.Second),
+	}
+	if k.path != nil {
+		record.Path = k.path.String()
+	}
+	if includeSalt {
+		record.Salt = k.salt
+	}
+	return record, nil
+}
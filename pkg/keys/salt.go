@@ -0,0 +1,85 @@
+package keys
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// SaltNormalization selects how a salt is normalized before it is used for key derivation. The salt is the BIP-39
+// passphrase and the HKDF salt, so any change to it derives a different key. The zero value uses the salt as entered.
+type SaltNormalization struct {
+	NFKD bool // apply Unicode NFKD, so that composed and decomposed forms of the same text are equal
+	Trim bool // remove leading and trailing whitespace
+}
+
+// SALT_NORMALIZATION_NONE is the salt normalization that uses the salt as entered, the default
+const SALT_NORMALIZATION_NONE = "none"
+
+// ParseSaltNormalization parses a comma-separated list of salt normalizations, "nfkd" and "trim", or "none"
+func ParseSaltNormalization(val string) (SaltNormalization, error) {
+	var n SaltNormalization
+	for _, entry := range strings.Split(val, ",") {
+		switch strings.ToLower(strings.TrimSpace(entry)) {
+		case "", SALT_NORMALIZATION_NONE:
+		case "nfkd":
+			n.NFKD = true
+		case "trim":
+			n.Trim = true
+		default:
+			return SaltNormalization{}, fmt.Errorf("unsupported salt normalization %q, expected nfkd, trim, or none", strings.TrimSpace(entry))
+		}
+	}
+	return n, nil
+}
+
+// String returns the normalizations as a comma-separated list, or "none"
+func (n SaltNormalization) String() string {
+	var names []string
+	if n.NFKD {
+		names = append(names, "nfkd")
+	}
+	if n.Trim {
+		names = append(names, "trim")
+	}
+	if len(names) == 0 {
+		return SALT_NORMALIZATION_NONE
+	}
+	return strings.Join(names, ",")
+}
+
+// Apply returns the normalized salt. Whitespace is trimmed after NFKD, which may turn other spaces into plain ones.
+func (n SaltNormalization) Apply(salt string) string {
+	if n.NFKD {
+		salt = norm.NFKD.String(salt)
+	}
+	if n.Trim {
+		salt = strings.TrimSpace(salt)
+	}
+	return salt
+}
+
+// SaltIssues describes the properties of a salt that make it easy to enter inconsistently, so that a later restore
+// silently derives a different key
+type SaltIssues struct {
+	OuterWhitespace bool // leading or trailing whitespace, which is invisible when the salt is written down
+	NonASCII        bool // non-ASCII characters, which keyboards and input methods may encode differently
+	NotNFKD         bool // the salt is not in Unicode NFKD form, so the same text can be entered as other code points
+}
+
+// CheckSalt reports the properties of the salt that could be entered inconsistently
+func CheckSalt(salt string) SaltIssues {
+	issues := SaltIssues{
+		OuterWhitespace: salt != strings.TrimSpace(salt),
+		NotNFKD:         !norm.NFKD.IsNormalString(salt),
+	}
+	for _, r := range salt {
+		if r > unicode.MaxASCII {
+			issues.NonASCII = true
+			break
+		}
+	}
+	return issues
+}
@@ -0,0 +1,64 @@
+package keys
+
+import "testing"
+
+func TestParseSaltNormalization(t *testing.T) {
+	tests := map[string]SaltNormalization{
+		"":              {},
+		"none":          {},
+		"NFKD":          {NFKD: true},
+		"trim":          {Trim: true},
+		"nfkd,trim":     {NFKD: true, Trim: true},
+		" trim , nfkd ": {NFKD: true, Trim: true},
+	}
+	for val, expected := range tests {
+		n, err := ParseSaltNormalization(val)
+		if err != nil || n != expected {
+			t.Fatalf("%q: expected %+v, got %+v, %v", val, expected, n, err)
+		}
+	}
+	if _, err := ParseSaltNormalization("nfc"); err == nil {
+		t.Fatalf("expected an error for an unsupported normalization")
+	}
+	if s := (SaltNormalization{NFKD: true, Trim: true}).String(); s != "nfkd,trim" {
+		t.Fatalf("unexpected normalization name %q", s)
+	}
+}
+
+func TestSaltNormalizationApply(t *testing.T) {
+	composed, decomposed := "caf\u00e9 salt", "cafe\u0301 salt"
+	tests := []struct {
+		n        SaltNormalization
+		salt     string
+		expected string
+	}{
+		// the default uses the salt exactly as entered
+		{SaltNormalization{}, " " + composed + "\t", " " + composed + "\t"},
+		{SaltNormalization{NFKD: true}, composed, decomposed},
+		{SaltNormalization{NFKD: true}, decomposed, decomposed},
+		{SaltNormalization{Trim: true}, " " + composed + "\n", composed},
+		// NFKD turns the no-break space into a plain space, which is then trimmed
+		{SaltNormalization{NFKD: true, Trim: true}, "\u00a0" + composed + " ", decomposed},
+	}
+	for _, tt := range tests {
+		if salt := tt.n.Apply(tt.salt); salt != tt.expected {
+			t.Fatalf("%s of %q: expected %q, got %q", tt.n, tt.salt, tt.expected, salt)
+		}
+	}
+}
+
+func TestCheckSalt(t *testing.T) {
+	tests := map[string]SaltIssues{
+		"MyExampleSalt":    {},
+		" MyExampleSalt":   {OuterWhitespace: true},
+		"MyExampleSalt\n":  {OuterWhitespace: true},
+		"caf\u00e9 salt":   {NonASCII: true, NotNFKD: true},
+		"cafe\u0301 salt":  {NonASCII: true},
+		" caf\u00e9 salt ": {OuterWhitespace: true, NonASCII: true, NotNFKD: true},
+	}
+	for salt, expected := range tests {
+		if issues := CheckSalt(salt); issues != expected {
+			t.Fatalf("%q: expected %+v, got %+v", salt, expected, issues)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package keys
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+
+	"github.com/rs/zerolog/log"
+	"golang.org/x/crypto/hkdf"
+)
+
+// SECRET_INFO_PREFIX prefixes the HKDF info of symmetric secrets, separating them from asymmetric key derivation
+const SECRET_INFO_PREFIX = "bipkey-secret:"
+
+// PEM_PASSWORD_INFO is the HKDF info of the PKCS#8 encryption password derived from the mnemonic
+const PEM_PASSWORD_INFO = "pem-password"
+
+// PEM_PASSWORD_SIZE is the number of HKDF bytes in a derived PEM password, encoded as 32 base64url characters
+const PEM_PASSWORD_SIZE = 24
+
+// DeriveSecret derives n bytes of symmetric key material from the mnemonic and salt for the given context label
+func DeriveSecret(ctx context.Context, salt string, mnemonic Mnemonic, label string, n int) ([]byte, error) {
+	return defaultGenerator.DeriveSecret(ctx, salt, mnemonic, label, n)
+}
+
+// DeriveSecret derives n bytes of symmetric key material from the mnemonic and salt for the given context label.
+// Different labels produce independent secrets, none of which are related to the asymmetric keys.
+func (g *Generator) DeriveSecret(ctx context.Context, salt string, mnemonic Mnemonic, label string, n int) ([]byte, error) {
+	// HKDF can expand at most 255 blocks of the underlying hash
+	maxBytes := 255 * g.hash().Size()
+	if n <= 0 || n > maxBytes {
+		return nil, fmt.Errorf("secret length must be between 1 and %d bytes, got %d", maxBytes, n)
+	}
+
+	return g.readHKDF(ctx, salt, mnemonic, SECRET_INFO_PREFIX+label, n)
+}
+
+// DerivePEMPassword derives the password to encrypt a PKCS#8 private key under from the mnemonic and salt
+func DerivePEMPassword(ctx context.Context, salt string, mnemonic Mnemonic) (string, error) {
+	return defaultGenerator.DerivePEMPassword(ctx, salt, mnemonic)
+}
+
+// DerivePEMPassword derives the password to encrypt a PKCS#8 private key under from the mnemonic and salt: the first
+// PEM_PASSWORD_SIZE bytes of HKDF with info PEM_PASSWORD_INFO, as unpadded base64url so that it can be typed into
+// other tools. Whoever holds the mnemonic can derive the password, so the encryption adds no secrecy beyond the
+// mnemonic; it only keeps the key file from being usable on its own.
+func (g *Generator) DerivePEMPassword(ctx context.Context, salt string, mnemonic Mnemonic) (string, error) {
+	password, err := g.readHKDF(ctx, salt, mnemonic, PEM_PASSWORD_INFO, PEM_PASSWORD_SIZE)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(password), nil
+}
+
+// GeneratePEMPassword generates a random password to encrypt a PKCS#8 private key under from PEM_PASSWORD_SIZE bytes
+// read from r, in the same base64url form as a derived password. Unlike a derived password, it cannot be recovered
+// from the mnemonic, so whoever holds the mnemonic does not also hold the key file's password.
+func GeneratePEMPassword(r io.Reader) (string, error) {
+	password := make([]byte, PEM_PASSWORD_SIZE)
+	if _, err := io.ReadFull(r, password); err != nil {
+		return "", fmt.Errorf("failed to generate password: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(password), nil
+}
+
+// readHKDF reads n bytes of HKDF output over the (stretched) seed and salt directly, without a stream
+func (g *Generator) readHKDF(ctx context.Context, salt string, mnemonic Mnemonic, info string, n int) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("secret derivation cancelled: %w", err)
+	}
+
+	_, seed, err := g.deriveSeed(mnemonic, salt)
+	if err != nil {
+		return nil, err
+	}
+
+	kdf := hkdf.New(g.hash, seed, []byte(salt), []byte(info))
+	log.Debug().Str("info", info).Msg("Initialized HKDF using BIP39 seed + salt for secret derivation.")
+
+	secret := make([]byte, n)
+	if _, err := io.ReadFull(kdf, secret); err != nil {
+		return nil, fmt.Errorf("failed to derive secret: %w", err)
+	}
+
+	return secret, nil
+}
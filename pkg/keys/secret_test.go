@@ -0,0 +1,148 @@
+package keys
+
+import (
+	"bytes"
+	"crypto/pbkdf2"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"io"
+	"testing"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+func TestDeriveSecret(t *testing.T) {
+	mnemonic := MustParseMnemonic("away mistake dance place sword title nurse diary skin soon figure sense force seat inform hedgehog debate around tortoise detail uncle situate draft wait")
+
+	// expected value was cross-checked with an independent PBKDF2-HMAC-SHA512 + HKDF-SHA256 implementation
+	const expected = "ad783fd59b695b0bf640fce92cc92ece5b495078641131ecfd771e9770a821b8"
+
+	s1, err := DeriveSecret(t.Context(), SALT, mnemonic, "aes-wrap", 32)
+	if err != nil {
+		t.Fatalf("failed to derive secret: %v", err)
+	}
+	if hex.EncodeToString(s1) != expected {
+		t.Fatalf("unexpected secret: got %x, want %s", s1, expected)
+	}
+
+	s2, err := DeriveSecret(t.Context(), SALT, mnemonic.Short(), "aes-wrap", 32)
+	if err != nil {
+		t.Fatalf("failed to derive secret from short mnemonic: %v", err)
+	}
+	if !bytes.Equal(s1, s2) {
+		t.Fatalf("secret derivation is not deterministic: %x != %x", s1, s2)
+	}
+
+	// a longer secret shares its prefix with a shorter one for the same context
+	s3, err := DeriveSecret(t.Context(), SALT, mnemonic, "aes-wrap", 64)
+	if err != nil {
+		t.Fatalf("failed to derive secret: %v", err)
+	}
+	if len(s3) != 64 || !bytes.Equal(s3[:32], s1) {
+		t.Fatalf("unexpected 64-byte secret: %x", s3)
+	}
+
+	s4, err := DeriveSecret(t.Context(), SALT, mnemonic, "hmac", 32)
+	if err != nil {
+		t.Fatalf("failed to derive secret: %v", err)
+	}
+	if bytes.Equal(s1, s4) {
+		t.Fatalf("secrets for different contexts must differ")
+	}
+}
+
+func TestDeriveSecretLength(t *testing.T) {
+	mnemonic := MustParseMnemonic("away mistake dance place sword title nurse diary skin soon figure sense force seat inform hedgehog debate around tortoise detail uncle situate draft wait")
+
+	for _, n := range []int{1, 16, 32, 255 * 32} {
+		secret, err := DeriveSecret(t.Context(), SALT, mnemonic, "length", n)
+		if err != nil {
+			t.Fatalf("failed to derive %d-byte secret: %v", n, err)
+		}
+		if len(secret) != n {
+			t.Fatalf("unexpected secret length: got %d, want %d", len(secret), n)
+		}
+	}
+
+	for _, n := range []int{0, -1, 255*32 + 1} {
+		if _, err := DeriveSecret(t.Context(), SALT, mnemonic, "length", n); err == nil {
+			t.Fatalf("expected an error for a %d-byte secret", n)
+		}
+	}
+}
+
+func TestDerivePEMPassword(t *testing.T) {
+	mnemonic := MustParseMnemonic(testMnemonic)
+
+	password, err := DerivePEMPassword(t.Context(), SALT, mnemonic)
+	if err != nil {
+		t.Fatalf("failed to derive PEM password: %v", err)
+	}
+
+	// the password is HKDF with info "pem-password" over the BIP-39 seed, read directly and base64url encoded
+	seed, err := pbkdf2.Key(sha512.New, mnemonic.String(), []byte("mnemonic"+SALT), BIP39_SEED_ITERATIONS, 64)
+	if err != nil {
+		t.Fatalf("failed to derive seed: %v", err)
+	}
+	raw := make([]byte, PEM_PASSWORD_SIZE)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, seed, []byte(SALT), []byte("pem-password")), raw); err != nil {
+		t.Fatalf("failed to read HKDF output: %v", err)
+	}
+	if expected := base64.RawURLEncoding.EncodeToString(raw); password != expected || len(password) != 32 {
+		t.Fatalf("unexpected PEM password: got %s, want %s", password, expected)
+	}
+
+	other, err := DerivePEMPassword(t.Context(), SALT+"-other", mnemonic)
+	if err != nil || other == password {
+		t.Fatalf("expected the salt to change the PEM password: %v", err)
+	}
+
+	// a key encrypted under the derived password decrypts with the password derived again from the mnemonic alone
+	key, err := GenerateKeyFromMnemonic(t.Context(), KeyTypeECC, int(ECCCurveP256), SALT, mnemonic)
+	if err != nil {
+		t.Fatalf("failed to generate ECC key from mnemonic: %v", err)
+	}
+	fingerprint, err := key.PublicFingerprint(FingerprintHex)
+	if err != nil {
+		t.Fatalf("failed to compute fingerprint: %v", err)
+	}
+	if err := key.Encrypt(password); err != nil {
+		t.Fatalf("failed to encrypt key: %v", err)
+	}
+	data, err := key.Encode(FormatPKCS8)
+	if err != nil {
+		t.Fatalf("failed to encode key: %v", err)
+	}
+
+	again, err := DerivePEMPassword(t.Context(), SALT, mnemonic.Short())
+	if err != nil {
+		t.Fatalf("failed to derive PEM password: %v", err)
+	}
+	loaded, err := LoadKeyFromPEM([]byte(data), again)
+	if err != nil {
+		t.Fatalf("failed to decrypt key with the derived password: %v", err)
+	}
+	if loadedFingerprint, err := loaded.PublicFingerprint(FingerprintHex); err != nil || loadedFingerprint != fingerprint {
+		t.Fatalf("decrypted key does not match: %s (%v)", loadedFingerprint, err)
+	}
+	if _, err := LoadKeyFromPEM([]byte(data), other); err == nil {
+		t.Fatalf("expected the password of another salt not to decrypt the key")
+	}
+}
+
+func TestGeneratePEMPassword(t *testing.T) {
+	raw := bytes.Repeat([]byte{0xfb}, PEM_PASSWORD_SIZE)
+	password, err := GeneratePEMPassword(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatalf("failed to generate PEM password: %v", err)
+	}
+	if expected := base64.RawURLEncoding.EncodeToString(raw); password != expected || len(password) != 32 {
+		t.Fatalf("unexpected PEM password: got %s, want %s", password, expected)
+	}
+
+	if _, err := GeneratePEMPassword(bytes.NewReader(raw[:PEM_PASSWORD_SIZE-1])); err == nil {
+		t.Fatalf("expected a short entropy source to fail")
+	}
+}
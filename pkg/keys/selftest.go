@@ -0,0 +1,170 @@
+package keys
+
+import (
+	"context"
+	"crypto"
+	"crypto/sha256"
+	"fmt"
+)
+
+// SELFTEST_SALT and SELFTEST_PASSWORD are the fixed inputs of the self-test, whose mnemonic encodes all-zero entropy
+const (
+	SELFTEST_SALT     = "bipkey-selftest"
+	SELFTEST_PASSWORD = "bipkey-selftest-password"
+)
+
+// SELFTEST_DERIVATION is the derivation version of the self-test. It is v2 rather than the default, since v1 RSA keys
+// depend on the Go version and could not have a known answer.
+const SELFTEST_DERIVATION = DerivationV2
+
+// selfTestFingerprints holds the known public key fingerprint of every supported algorithm for the self-test inputs,
+// derived with SELFTEST_DERIVATION
+var selfTestFingerprints = map[string]string{
+	"ecc-p-256":   "950a625ba183861eda501e3cfac900f36bcaa49a32c7b37d664da258e859cf57",
+	"ecc-p-384":   "3c0034404888526d82b4b69677a6ed82c4b16313a29a65b4dcf292ae1c1b456e",
+	"ecc-p-521":   "59f0069524db2ba7e6ac8c44d4112889e5c699e146a6d1983ece0fb5113b3890",
+	"ecc-ed25519": "d3c7aa6cb3ca3635335a6274f1a46e98d9fc6041112995d34dff6baf01ebc363",
+	"rsa-2048":    "eb223e45a85295f591437ecccaf6184ce1c8b615c036e3900f88065a31067aa6",
+	"rsa-3072":    "146f31850316bfaa223c1550a6f47ff6fb7b47b353c7971a85293f2c6758b913",
+	"rsa-4096":    "432c0d055ce256dac367632c26c3478b6dc4a216b37e6672b8adb946cbe06e0b",
+	"rsa-8192":    "93808bb564fe7c951a5a5a18fc96b2fb65f350efef83f9316e7ff5ecd1e3eb3a",
+}
+
+// LEGACY_KNOWN_ANSWER_SALT is the salt of the original v1 RSA known answers
+const LEGACY_KNOWN_ANSWER_SALT = "bipkey-test-salt"
+
+// legacyRSAKnownAnswers holds the original v1 RSA known answers by key size: the mnemonic and the fingerprint of the
+// private key it derived with LEGACY_KNOWN_ANSWER_SALT. Keys generated with default settings restore only on builds
+// that reproduce them.
+var legacyRSAKnownAnswers = map[RSAKeyID]struct {
+	mnemonic    string
+	fingerprint string
+}{
+	RSAKey2048: {
+		"worth ball broom life calm name foil fringe final average since traffic pig cook clap alert brush swallow rural glance guilt board vendor slight",
+		"9351ddab1a122380da119ff25efd15a84ea56797740be2c1a60dac75edd42bb2",
+	},
+	RSAKey3072: {
+		"radar spoil crazy alien park lottery bitter return original burger upon fruit clarify magnet exist wheat sugar need donor allow ripple tuna cry scatter",
+		"6c4efe263432292af4d4de9f9ef3d8e2b4c003ab50bbecb5b6be358384d6187f",
+	},
+	RSAKey4096: {
+		"kingdom marine vehicle senior cinnamon squeeze oxygen print home chest voyage service toward source glove host fit bench era bullet general kiss early math",
+		"89033e95b464650780b269a7ebc2b601816119d52f6909c6fd2596ee648e3cef",
+	},
+	RSAKey8192: {
+		"rhythm fun flush habit genuine topple dune fire food chuckle rain shoulder describe digital idle movie upgrade nerve bicycle chuckle sport alien scan frost",
+		"ecc797920f47adf1b043c8f304c41fa0684b184f2110aef7711794b95a531a52",
+	},
+}
+
+// SelfTest derives the key of the given type and id from fixed inputs and checks it against its known fingerprint,
+// then checks that it survives a password encryption round trip and can sign and verify. It exercises the derivation
+// and the pkcs8 dependency of the running build, e.g. before relying on it for a real recovery.
+func SelfTest(ctx context.Context, keyType KeyType, keyId int) error {
+	k, _, err := deriveKnownAnswer(ctx, keyType, keyId)
+	if err != nil {
+		return err
+	}
+
+	// the encrypted key must only decrypt with the right password, and back to the same key
+	original := k.Fingerprint()
+	if err := k.Encrypt(SELFTEST_PASSWORD); err != nil {
+		return err
+	}
+	if k.Fingerprint() == original {
+		return fmt.Errorf("encryption did not change the encoded key")
+	}
+	if err := k.Decrypt(SELFTEST_PASSWORD + "-wrong"); err == nil {
+		return fmt.Errorf("the encrypted key decrypted with a wrong password")
+	}
+	if err := k.Decrypt(SELFTEST_PASSWORD); err != nil {
+		return err
+	}
+	if k.Fingerprint() != original {
+		return fmt.Errorf("the decrypted key does not match the original key")
+	}
+
+	// Ed25519 signs the full message instead of a digest
+	message := []byte(SELFTEST_SALT)
+	var opts crypto.SignerOpts = ED25519_PURE
+	if keyType != KeyTypeECC || ECCCurveID(keyId) != ECCCurveEd25519 {
+		digest := sha256.Sum256(message)
+		message, opts = digest[:], crypto.SHA256
+	}
+	sig, err := k.Sign(message, opts)
+	if err != nil {
+		return err
+	}
+	if err := k.Verify(message, sig, opts); err != nil {
+		return fmt.Errorf("failed to verify the signature of the key: %w", err)
+	}
+	return nil
+}
+
+// CheckKnownAnswer derives the key of the given type and id from the self-test inputs and checks it against its known
+// fingerprint, which every build must reproduce regardless of the platform, Go version, or dependency versions. It
+// returns the derived fingerprint, also when it does not match.
+func CheckKnownAnswer(ctx context.Context, keyType KeyType, keyId int) (string, error) {
+	_, fingerprint, err := deriveKnownAnswer(ctx, keyType, keyId)
+	return fingerprint, err
+}
+
+// deriveKnownAnswer derives the self-test key and checks its fingerprint against the known answer
+func deriveKnownAnswer(ctx context.Context, keyType KeyType, keyId int) (*Key, string, error) {
+	alg, err := algorithmName(keyType, keyId)
+	if err != nil {
+		return nil, "", err
+	}
+
+	mnemonic, err := MnemonicFromEntropy(make([]byte, MNEMONIC_ENTROPY_BITS/8))
+	if err != nil {
+		return nil, "", err
+	}
+	g, err := NewGenerator(Config{Derivation: SELFTEST_DERIVATION})
+	if err != nil {
+		return nil, "", err
+	}
+	k, err := g.GenerateKeyFromMnemonic(ctx, keyType, keyId, SELFTEST_SALT, mnemonic)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to derive key: %w", err)
+	}
+
+	// the derivation must match the known answer, otherwise this build restores different keys
+	fingerprint, err := k.PublicFingerprint(FingerprintHex)
+	if err != nil {
+		return nil, "", err
+	}
+	if expected := selfTestFingerprints[alg]; fingerprint != expected {
+		return nil, fingerprint, fmt.Errorf("derived key fingerprint %s does not match the known answer %s", fingerprint, expected)
+	}
+	return k, fingerprint, nil
+}
+
+// CheckLegacyRSAKnownAnswer derives the original v1 RSA known-answer key of the given id and checks its fingerprint.
+// v1 RSA keys come from rsa.GenerateKey, so a build whose Go release changed it fails here and cannot restore RSA keys
+// generated with default settings. It returns the derived fingerprint, also when it does not match.
+func CheckLegacyRSAKnownAnswer(ctx context.Context, keyId int) (string, error) {
+	answer, ok := legacyRSAKnownAnswers[RSAKeyID(keyId)]
+	if !ok {
+		return "", fmt.Errorf("no %s known answer for RSA key id %d", DerivationV1, keyId)
+	}
+	mnemonic, err := ParseMnemonic(answer.mnemonic)
+	if err != nil {
+		return "", err
+	}
+	g, err := NewGenerator(Config{Derivation: DerivationV1})
+	if err != nil {
+		return "", err
+	}
+	k, err := g.GenerateKeyFromMnemonic(ctx, KeyTypeRSA, keyId, LEGACY_KNOWN_ANSWER_SALT, mnemonic)
+	if err != nil {
+		return "", fmt.Errorf("failed to derive key: %w", err)
+	}
+
+	fingerprint := k.Fingerprint()
+	if fingerprint != answer.fingerprint {
+		return fingerprint, fmt.Errorf("derived %s key fingerprint %s does not match the known answer %s, rsa.GenerateKey of this Go release differs", DerivationV1, fingerprint, answer.fingerprint)
+	}
+	return fingerprint, nil
+}
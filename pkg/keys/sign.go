@@ -0,0 +1,111 @@
+package keys
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha512"
+	"fmt"
+)
+
+// Ed25519 signing modes of Key.Sign and Key.Verify, selected by the hash function of the opts. The same Ed25519 key
+// signs in either mode and both are deterministic, but a signature only verifies in the mode it was made in. Nil opts
+// select pure Ed25519.
+const (
+	ED25519_PURE = crypto.Hash(0) // pure Ed25519 (RFC 8032), signing the full message
+	ED25519_PH   = crypto.SHA512  // Ed25519ph (RFC 8032), signing the SHA-512 digest of the message
+)
+
+// Sign signs the digest with the private key. Ed25519 keys sign the full message with ED25519_PURE (or nil opts), or
+// the SHA-512 digest of the message with ED25519_PH, which suits large payloads that are hashed as they stream. RSA
+// keys sign with PSS when opts is *rsa.PSSOptions, otherwise PKCS#1 v1.5.
+func (k Key) Sign(digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	signer, err := k.signer()
+	if err != nil {
+		return nil, err
+	}
+	switch signer.(type) {
+	case ed25519.PrivateKey:
+		if opts == nil {
+			opts = ED25519_PURE
+		}
+		if err := checkEd25519Opts(digest, opts); err != nil {
+			return nil, err
+		}
+	case *rsa.PrivateKey:
+		if opts == nil {
+			return nil, fmt.Errorf("RSA signing requires opts selecting the hash function")
+		}
+	}
+
+	sig, err := signer.Sign(rand.Reader, digest, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign: %w", err)
+	}
+	return sig, nil
+}
+
+// Verify checks that sig is a valid signature of the digest (the full message for Ed25519) by the key's public key,
+// using the same opts that were passed to Sign
+func (k Key) Verify(digest []byte, sig []byte, opts crypto.SignerOpts) error {
+	pub, err := k.PublicKey()
+	if err != nil {
+		return err
+	}
+
+	switch pub := pub.(type) {
+	case *ecdsa.PublicKey:
+		if !ecdsa.VerifyASN1(pub, digest, sig) {
+			return fmt.Errorf("invalid ECDSA signature")
+		}
+		return nil
+	case *rsa.PublicKey:
+		if pss, ok := opts.(*rsa.PSSOptions); ok {
+			if err := rsa.VerifyPSS(pub, pss.HashFunc(), digest, sig, pss); err != nil {
+				return fmt.Errorf("invalid RSA-PSS signature: %w", err)
+			}
+			return nil
+		}
+		if opts == nil {
+			return fmt.Errorf("RSA verification requires opts selecting the hash function")
+		}
+		if err := rsa.VerifyPKCS1v15(pub, opts.HashFunc(), digest, sig); err != nil {
+			return fmt.Errorf("invalid RSA PKCS#1 v1.5 signature: %w", err)
+		}
+		return nil
+	case ed25519.PublicKey:
+		if opts == nil {
+			opts = ED25519_PURE
+		}
+		if err := checkEd25519Opts(digest, opts); err != nil {
+			return err
+		}
+		edOpts := &ed25519.Options{Hash: opts.HashFunc()}
+		if o, ok := opts.(*ed25519.Options); ok {
+			edOpts = o
+		}
+		if err := ed25519.VerifyWithOptions(pub, digest, sig, edOpts); err != nil {
+			return fmt.Errorf("invalid Ed25519 signature: %w", err)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported public key type %T", pub)
+	}
+}
+
+// checkEd25519Opts checks that opts selects pure Ed25519 or Ed25519ph, and that an Ed25519ph digest is a SHA-512 digest
+func checkEd25519Opts(digest []byte, opts crypto.SignerOpts) error {
+	switch hash := opts.HashFunc(); hash {
+	case crypto.Hash(0):
+		return nil
+	case crypto.SHA512:
+		if len(digest) != sha512.Size {
+			return fmt.Errorf("Ed25519ph signs a %d-byte SHA-512 digest, not %d bytes", sha512.Size, len(digest))
+		}
+		return nil
+	default:
+		return fmt.Errorf("Ed25519 signs with crypto.Hash(0) (pure) or crypto.SHA512 (Ed25519ph), not %v", hash)
+	}
+}
@@ -0,0 +1,111 @@
+package keys
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"slices"
+	"testing"
+)
+
+func TestSignVerify(t *testing.T) {
+	message := []byte("bipkey signing test")
+	digest := sha256.Sum256(message)
+	prehash := sha512.Sum512(message)
+
+	tests := []struct {
+		name    string
+		keyType KeyType
+		keyId   int
+		input   []byte
+		opts    crypto.SignerOpts
+	}{
+		{"P-256", KeyTypeECC, int(ECCCurveP256), digest[:], crypto.SHA256},
+		{"P-384", KeyTypeECC, int(ECCCurveP384), digest[:], crypto.SHA256},
+		{"P-521", KeyTypeECC, int(ECCCurveP521), digest[:], crypto.SHA256},
+		{"Ed25519", KeyTypeECC, int(ECCCurveEd25519), message, ED25519_PURE},
+		{"Ed25519ph", KeyTypeECC, int(ECCCurveEd25519), prehash[:], ED25519_PH},
+		{"RSA PKCS#1 v1.5", KeyTypeRSA, int(RSAKey2048), digest[:], crypto.SHA256},
+		{"RSA-PSS", KeyTypeRSA, int(RSAKey2048), digest[:], &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: crypto.SHA256}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			key, err := GenerateKeyFromMnemonic(t.Context(), test.keyType, test.keyId, SALT, MustParseMnemonic(testMnemonic))
+			if err != nil {
+				t.Fatalf("failed to generate key from mnemonic: %v", err)
+			}
+
+			sig, err := key.Sign(test.input, test.opts)
+			if err != nil {
+				t.Fatalf("failed to sign: %v", err)
+			}
+			if err := key.Verify(test.input, sig, test.opts); err != nil {
+				t.Fatalf("failed to verify signature: %v", err)
+			}
+
+			tampered := append([]byte(nil), test.input...)
+			tampered[0] ^= 0xff
+			if err := key.Verify(tampered, sig, test.opts); err == nil {
+				t.Fatalf("expected verification of a tampered input to fail")
+			}
+		})
+	}
+
+	// Ed25519 signs the full message, so a digest hash function in opts is rejected
+	key, err := GenerateKeyFromMnemonic(t.Context(), KeyTypeECC, int(ECCCurveEd25519), SALT, MustParseMnemonic(testMnemonic))
+	if err != nil {
+		t.Fatalf("failed to generate Ed25519 key from mnemonic: %v", err)
+	}
+	if _, err := key.Sign(digest[:], crypto.SHA256); err == nil {
+		t.Fatalf("expected Ed25519 signing with crypto.SHA256 opts to fail")
+	}
+}
+
+func TestSignEd25519Modes(t *testing.T) {
+	message := []byte("bipkey signing test")
+	prehash := sha512.Sum512(message)
+
+	key, err := GenerateKeyFromMnemonic(t.Context(), KeyTypeECC, int(ECCCurveEd25519), SALT, MustParseMnemonic(testMnemonic))
+	if err != nil {
+		t.Fatalf("failed to generate Ed25519 key from mnemonic: %v", err)
+	}
+
+	sign := func(input []byte, opts crypto.SignerOpts) []byte {
+		t.Helper()
+		sig, err := key.Sign(input, opts)
+		if err != nil {
+			t.Fatalf("failed to sign: %v", err)
+		}
+		return sig
+	}
+	pure, ph := sign(message, ED25519_PURE), sign(prehash[:], ED25519_PH)
+
+	// both modes are deterministic, and a signature does not verify in the other mode
+	if !slices.Equal(pure, sign(message, ED25519_PURE)) || !slices.Equal(ph, sign(prehash[:], ED25519_PH)) {
+		t.Fatalf("expected Ed25519 signatures to be deterministic")
+	}
+	if slices.Equal(pure, ph) {
+		t.Fatalf("expected the pure Ed25519 and Ed25519ph signatures to differ")
+	}
+	if err := key.Verify(prehash[:], pure, ED25519_PH); err == nil {
+		t.Fatalf("expected a pure Ed25519 signature not to verify as Ed25519ph")
+	}
+	if err := key.Verify(message, ph, ED25519_PURE); err == nil {
+		t.Fatalf("expected an Ed25519ph signature not to verify as pure Ed25519")
+	}
+
+	// Ed25519ph signs a SHA-512 digest, not the message itself
+	if _, err := key.Sign(message, ED25519_PH); err == nil {
+		t.Fatalf("expected Ed25519ph signing of a message that is not a SHA-512 digest to fail")
+	}
+
+	// nil opts select pure Ed25519 instead of panicking
+	if !slices.Equal(pure, sign(message, nil)) {
+		t.Fatalf("expected nil opts to sign with pure Ed25519")
+	}
+	if err := key.Verify(message, pure, nil); err != nil {
+		t.Fatalf("expected nil opts to verify a pure Ed25519 signature: %v", err)
+	}
+}
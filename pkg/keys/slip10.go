@@ -0,0 +1,261 @@
+package keys
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"github.com/tyler-smith/go-bip39"
+)
+
+// DERIVATION_SLIP10 names the SLIP-10 derivation of keys along a BIP-32 path in reports and metadata
+const DERIVATION_SLIP10 = "slip10"
+
+// HARDENED_OFFSET is added to the index of a hardened BIP-32 path element, written with a trailing ' or h
+const HARDENED_OFFSET uint32 = 0x80000000
+
+// DerivationPath is a BIP-32 derivation path, e.g. m/0'/1'/2'/2, as a list of child indices
+type DerivationPath []uint32
+
+// ParseDerivationPath parses a BIP-32 path such as "m/0'/1'/2'/2". Hardened elements are marked with ', h, or H.
+func ParseDerivationPath(val string) (DerivationPath, error) {
+	elements := strings.Split(strings.TrimSpace(val), "/")
+	if elements[0] != "m" {
+		return nil, fmt.Errorf("invalid derivation path %q: it must start with \"m\"", val)
+	}
+
+	path := make(DerivationPath, 0, len(elements)-1)
+	for _, element := range elements[1:] {
+		hardened := false
+		if trimmed, ok := strings.CutSuffix(element, "'"); ok {
+			element, hardened = trimmed, true
+		} else if trimmed, ok := strings.CutSuffix(strings.ToLower(element), "h"); ok {
+			element, hardened = trimmed, true
+		}
+		index, err := strconv.ParseUint(element, 10, 31)
+		if err != nil {
+			return nil, fmt.Errorf("invalid derivation path %q: element %q is not an index from 0 to 2^31-1", val, element)
+		}
+		if hardened {
+			index += uint64(HARDENED_OFFSET)
+		}
+		path = append(path, uint32(index))
+	}
+	return path, nil
+}
+
+// String returns the path in its canonical form, with ' marking hardened elements
+func (p DerivationPath) String() string {
+	var builder strings.Builder
+	builder.WriteString("m")
+	for _, index := range p {
+		if index >= HARDENED_OFFSET {
+			fmt.Fprintf(&builder, "/%d'", index-HARDENED_OFFSET)
+		} else {
+			fmt.Fprintf(&builder, "/%d", index)
+		}
+	}
+	return builder.String()
+}
+
+// slip10Curve holds the SLIP-10 parameters of a curve: the HMAC key of the master node and the NIST curve. The curve
+// is nil for Ed25519, whose keys are the raw HMAC output and only support hardened derivation.
+type slip10Curve struct {
+	seedKey string
+	curve   elliptic.Curve
+}
+
+// getSLIP10Curve returns the SLIP-10 parameters of the ECC curve. Only NIST P-256 and Ed25519 are supported, since
+// bipkey has no secp256k1 keys.
+func getSLIP10Curve(id ECCCurveID) (*slip10Curve, error) {
+	switch id {
+	case ECCCurveP256:
+		return &slip10Curve{seedKey: "Nist256p1 seed", curve: elliptic.P256()}, nil
+	case ECCCurveEd25519:
+		return &slip10Curve{seedKey: "ed25519 seed"}, nil
+	default:
+		return nil, fmt.Errorf("BIP-32 (SLIP-10) derivation is only supported for the P-256 and Ed25519 curves")
+	}
+}
+
+// CheckDerivationPath validates that keys of the given type and id can be derived along the path, so that an
+// unsupported request is rejected before the mnemonic is entered
+func CheckDerivationPath(path DerivationPath, keyType KeyType, keyId int) error {
+	if err := ValidateKeyID(keyType, keyId); err != nil {
+		return err
+	}
+	if keyType != KeyTypeECC {
+		return fmt.Errorf("BIP-32 (SLIP-10) derivation is only defined for ECC keys")
+	}
+	curve, err := getSLIP10Curve(ECCCurveID(keyId))
+	if err != nil {
+		return err
+	}
+	if curve.curve == nil {
+		for _, index := range path {
+			if index < HARDENED_OFFSET {
+				return fmt.Errorf("Ed25519 only supports hardened derivation, %s has a normal element", path)
+			}
+		}
+	}
+	return nil
+}
+
+// hmacSHA512 returns the HMAC-SHA512 of the data parts
+func hmacSHA512(key []byte, parts ...[]byte) []byte {
+	mac := hmac.New(sha512.New, key)
+	for _, part := range parts {
+		mac.Write(part)
+	}
+	return mac.Sum(nil)
+}
+
+// slip10Node is a node of a SLIP-10 key tree
+type slip10Node struct {
+	key       []byte // private key, a big-endian scalar or the Ed25519 seed
+	chainCode []byte
+}
+
+// deriveSLIP10 derives the private key node at the path from the seed, following SLIP-10, which for P-256 is
+// BIP-32 with the curve and master key HMAC key replaced
+func deriveSLIP10(curve *slip10Curve, seed []byte, path DerivationPath) (*slip10Node, error) {
+	// master node, retried with the HMAC output for an invalid NIST scalar
+	I := hmacSHA512([]byte(curve.seedKey), seed)
+	for curve.curve != nil && !validScalar(curve.curve, I[:32]) {
+		I = hmacSHA512([]byte(curve.seedKey), I)
+	}
+	node := &slip10Node{key: I[:32], chainCode: I[32:]}
+
+	for _, index := range path {
+		child, err := node.child(curve, index)
+		if err != nil {
+			return nil, err
+		}
+		node = child
+	}
+	return node, nil
+}
+
+// validScalar reports whether the big-endian scalar is a valid private key, in [1, n-1]
+func validScalar(curve elliptic.Curve, b []byte) bool {
+	k := new(big.Int).SetBytes(b)
+	return k.Sign() > 0 && k.Cmp(curve.Params().N) < 0
+}
+
+// child derives the child node at the index
+func (n *slip10Node) child(curve *slip10Curve, index uint32) (*slip10Node, error) {
+	ser32 := binary.BigEndian.AppendUint32(nil, index)
+
+	if curve.curve == nil {
+		if index < HARDENED_OFFSET {
+			return nil, fmt.Errorf("Ed25519 only supports hardened derivation")
+		}
+		I := hmacSHA512(n.chainCode, []byte{0x00}, n.key, ser32)
+		return &slip10Node{key: I[:32], chainCode: I[32:]}, nil
+	}
+
+	var data []byte
+	if index >= HARDENED_OFFSET {
+		data = append([]byte{0x00}, n.key...)
+	} else {
+		x, y := curve.curve.ScalarBaseMult(n.key)
+		data = elliptic.MarshalCompressed(curve.curve, x, y)
+	}
+
+	order := curve.curve.Params().N
+	I := hmacSHA512(n.chainCode, data, ser32)
+	for {
+		il := new(big.Int).SetBytes(I[:32])
+		child := new(big.Int).Add(il, new(big.Int).SetBytes(n.key))
+		child.Mod(child, order)
+		if il.Cmp(order) < 0 && child.Sign() != 0 {
+			return &slip10Node{key: child.FillBytes(make([]byte, 32)), chainCode: I[32:]}, nil
+		}
+		// an invalid child, which is astronomically unlikely, is replaced as specified by SLIP-10
+		I = hmacSHA512(n.chainCode, []byte{0x01}, I[32:], ser32)
+	}
+}
+
+// privateKey returns the node's private key on the curve
+func (n *slip10Node) privateKey(curve *slip10Curve) crypto.PrivateKey {
+	if curve.curve == nil {
+		return ed25519.NewKeyFromSeed(n.key)
+	}
+	priv := &ecdsa.PrivateKey{
+		PublicKey: ecdsa.PublicKey{Curve: curve.curve},
+		D:         new(big.Int).SetBytes(n.key),
+	}
+	priv.X, priv.Y = curve.curve.ScalarBaseMult(n.key)
+	return priv
+}
+
+// GeneratePathKeyFromMnemonic derives the ECC key at the BIP-32 path from the BIP-39 seed of the mnemonic, following
+// SLIP-10 instead of bipkey's HKDF scheme, so that wallets and other BIP-32 tooling derive the same key. The salt is
+// used as the BIP-39 passphrase. The seed is not stretched, so the generator must not use a seed KDF.
+func (g *Generator) GeneratePathKeyFromMnemonic(ctx context.Context, keyType KeyType, keyId int, salt string, mnemonic Mnemonic, path DerivationPath) (*Key, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("key generation cancelled: %w", err)
+	}
+	if err := CheckDerivationPath(path, keyType, keyId); err != nil {
+		return nil, err
+	}
+	if g.scrypt != nil {
+		return nil, fmt.Errorf("BIP-32 (SLIP-10) derivation uses the plain BIP-39 seed and cannot be combined with a seed KDF")
+	}
+	if g.entropySeed {
+		return nil, fmt.Errorf("BIP-32 (SLIP-10) derivation uses the plain BIP-39 seed and cannot be combined with the entropy seed")
+	}
+	curve, err := getSLIP10Curve(ECCCurveID(keyId))
+	if err != nil {
+		return nil, err
+	}
+
+	mnemonic, err = g.NormalizeMnemonic(mnemonic)
+	if err != nil {
+		return nil, fmt.Errorf("failed to normalize mnemonic: %w", err)
+	}
+	seed := bip39.NewSeed(mnemonic.String(), salt)
+
+	node, err := deriveSLIP10(curve, seed, path)
+	if err != nil {
+		return nil, err
+	}
+	log.Debug().Str("path", path.String()).Msg("Derived SLIP-10 key along the BIP-32 path.")
+
+	key := &Key{
+		keyType:    keyType,
+		keyId:      keyId,
+		salt:       salt,
+		PrivateKey: node.privateKey(curve),
+		mnemonic:   mnemonic,
+		derivation: g.derivation,
+		path:       path,
+		gen:        g,
+	}
+	if err := key.Rederive(); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// GeneratePathKey generates a new mnemonic and derives the ECC key at the BIP-32 path from it
+func (g *Generator) GeneratePathKey(ctx context.Context, keyType KeyType, keyId int, salt string, path DerivationPath) (*Key, error) {
+	if err := CheckDerivationPath(path, keyType, keyId); err != nil {
+		return nil, err
+	}
+	mnemonic, err := g.GenerateMnemonic(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate mnemonic: %w", err)
+	}
+	return g.GeneratePathKeyFromMnemonic(ctx, keyType, keyId, salt, *mnemonic, path)
+}
@@ -0,0 +1,119 @@
+package keys
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"testing"
+
+	"github.com/tyler-smith/go-bip39"
+)
+
+// SLIP-10 test vector 1, seed 000102030405060708090a0b0c0d0e0f
+var slip10Vectors = []struct {
+	curve     ECCCurveID
+	path      string
+	chainCode string
+	key       string
+}{
+	{ECCCurveP256, "m", "beeb672fe4621673f722f38529c07392fecaa61015c80c34f29ce8b41b3cb6ea", "612091aaa12e22dd2abef664f8a01a82cae99ad7441b7ef8110424915c268bc2"},
+	{ECCCurveP256, "m/0'", "3460cea53e6a6bb5fb391eeef3237ffd8724bf0a40e94943c98b83825342ee11", "6939694369114c67917a182c59ddb8cafc3004e63ca5d3b84403ba8613debc0c"},
+	{ECCCurveP256, "m/0'/1", "4187afff1aafa8445010097fb99d23aee9f599450c7bd140b6826ac22ba21d0c", "284e9d38d07d21e4e281b645089a94f4cf5a5a81369acf151a1c3a57f18b2129"},
+	{ECCCurveP256, "m/0'/1/2'", "98c7514f562e64e74170cc3cf304ee1ce54d6b6da4f880f313e8204c2a185318", "694596e8a54f252c960eb771a3c41e7e32496d03b954aeb90f61635b8e092aa7"},
+	{ECCCurveP256, "m/0'/1/2'/2", "ba96f776a5c3907d7fd48bde5620ee374d4acfd540378476019eab70790c63a0", "5996c37fd3dd2679039b23ed6f70b506c6b56b3cb5e424681fb0fa64caf82aaa"},
+	{ECCCurveEd25519, "m", "90046a93de5380a72b5e45010748567d5ea02bbf6522f979e05c0d8d8ca9fffb", "2b4be7f19ee27bbf30c667b642d5f4aa69fd169872f8fc3059c08ebae2eb19e7"},
+	{ECCCurveEd25519, "m/0'", "8b59aa11380b624e81507a27fedda59fea6d0b779a778918a2fd3590e16e9c69", "68e0fe46dfb67e368c75379acec591dad19df3cde26e63b93a8e704f1dade7a3"},
+	{ECCCurveEd25519, "m/0'/1'", "a320425f77d1b5c2505a6b1b27382b37368ee640e3557c315416801243552f14", "b1d0bad404bf35da785a64ca1ac54b2617211d2777696fbffaf208f746ae84f2"},
+	{ECCCurveEd25519, "m/0'/1'/2'/2'/1000000000'", "68789923a0cac2cd5a29172a475fe9e0fb14cd6adb5ad98a3fa70333e7afa230", "8f94d394a8e8fd6b1bc2f3f49f5c47e385281d5c17e65324b0f62483e37e8793"},
+}
+
+func TestSLIP10Vectors(t *testing.T) {
+	seed, _ := hex.DecodeString("000102030405060708090a0b0c0d0e0f")
+	for _, v := range slip10Vectors {
+		curve, err := getSLIP10Curve(v.curve)
+		if err != nil {
+			t.Fatalf("failed to get SLIP-10 curve: %v", err)
+		}
+		path, err := ParseDerivationPath(v.path)
+		if err != nil {
+			t.Fatalf("failed to parse path %s: %v", v.path, err)
+		}
+		node, err := deriveSLIP10(curve, seed, path)
+		if err != nil {
+			t.Fatalf("failed to derive %s: %v", v.path, err)
+		}
+		if hex.EncodeToString(node.chainCode) != v.chainCode || hex.EncodeToString(node.key) != v.key {
+			t.Fatalf("curve %d, path %s: got chain code %x and key %x", v.curve, v.path, node.chainCode, node.key)
+		}
+	}
+}
+
+func TestParseDerivationPath(t *testing.T) {
+	path, err := ParseDerivationPath("m/44'/0h/0H/0/7")
+	if err != nil {
+		t.Fatalf("failed to parse path: %v", err)
+	}
+	expected := DerivationPath{44 + HARDENED_OFFSET, HARDENED_OFFSET, HARDENED_OFFSET, 0, 7}
+	if len(path) != len(expected) {
+		t.Fatalf("unexpected path: %v", path)
+	}
+	for i := range path {
+		if path[i] != expected[i] {
+			t.Fatalf("unexpected path: %v", path)
+		}
+	}
+	if path.String() != "m/44'/0'/0'/0/7" {
+		t.Fatalf("unexpected canonical path: %s", path)
+	}
+
+	for _, val := range []string{"", "44'/0'", "m/", "m/-1", "m/2147483648", "m/1''", "m/a"} {
+		if _, err := ParseDerivationPath(val); err == nil {
+			t.Fatalf("expected path %q to be rejected", val)
+		}
+	}
+}
+
+func TestGeneratePathKey(t *testing.T) {
+	mnemonic := MustParseMnemonic(testMnemonic)
+	path, _ := ParseDerivationPath("m/44'/0'/0'")
+	key, err := defaultGenerator.GeneratePathKeyFromMnemonic(t.Context(), KeyTypeECC, int(ECCCurveEd25519), SALT, mnemonic, path)
+	if err != nil {
+		t.Fatalf("failed to derive key: %v", err)
+	}
+
+	// the key is the SLIP-10 node of the BIP-39 seed, with the salt as the passphrase
+	curve, _ := getSLIP10Curve(ECCCurveEd25519)
+	node, err := deriveSLIP10(curve, bip39.NewSeed(mnemonic.String(), SALT), path)
+	if err != nil {
+		t.Fatalf("failed to derive node: %v", err)
+	}
+	if !key.PrivateKey.(ed25519.PrivateKey).Equal(ed25519.NewKeyFromSeed(node.key)) {
+		t.Fatalf("path key does not match the SLIP-10 node")
+	}
+	report, err := key.Report(FormatPKCS8, false)
+	if err != nil {
+		t.Fatalf("failed to build report: %v", err)
+	}
+	if report.Derivation != DERIVATION_SLIP10 || report.Path != "m/44'/0'/0'" {
+		t.Fatalf("unexpected derivation in report: %s %s", report.Derivation, report.Path)
+	}
+
+	// SLIP-10 keys are independent of the HKDF scheme keys
+	hkdfKey, err := GenerateKeyFromMnemonic(t.Context(), KeyTypeECC, int(ECCCurveEd25519), SALT, mnemonic)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	if hkdfKey.Fingerprint() == key.Fingerprint() {
+		t.Fatalf("expected the SLIP-10 key to differ from the HKDF key")
+	}
+
+	normal, _ := ParseDerivationPath("m/0")
+	if _, err := defaultGenerator.GeneratePathKeyFromMnemonic(t.Context(), KeyTypeECC, int(ECCCurveEd25519), SALT, mnemonic, normal); err == nil {
+		t.Fatalf("expected normal derivation of an Ed25519 key to be rejected")
+	}
+	if err := CheckDerivationPath(normal, KeyTypeECC, int(ECCCurveP384)); err == nil {
+		t.Fatalf("expected P-384 to be rejected")
+	}
+	if err := CheckDerivationPath(normal, KeyTypeRSA, int(RSAKey2048)); err == nil {
+		t.Fatalf("expected RSA to be rejected")
+	}
+}
@@ -0,0 +1,95 @@
+package keys
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/chacha20"
+)
+
+// BIP39_SEED_ITERATIONS is the PBKDF2-HMAC-SHA512 iteration count BIP-39 uses to derive the seed
+const BIP39_SEED_ITERATIONS = 2048
+
+// SpecItem describes one primitive the key derivation depends on, and its parameters
+type SpecItem struct {
+	Component string `json:"component"`
+	Algorithm string `json:"algorithm"`
+}
+
+// Spec returns the primitives and parameters the default generator's key derivation depends on
+func Spec() []SpecItem {
+	return defaultGenerator.Spec()
+}
+
+// Spec returns the primitives and parameters the generator's key derivation depends on, in derivation order.
+// It is built from the same constants the derivation uses, so that it documents exactly what the code does and
+// a reimplementation can reproduce keys bit-for-bit.
+func (g *Generator) Spec() []SpecItem {
+	kdf := "HKDF-" + hashName(g)
+
+	exponent := fmt.Sprintf("p is redrawn from its stream until gcd(e, p-1) = 1. q is redrawn from its stream until q != p, n = pq has exactly "+
+		"the requested bit length, and e is invertible mod (p-1)(q-1); e = %d", g.rsaExponent.E)
+	if g.rsaExponent.Fallback {
+		exponent = fmt.Sprintf("q is redrawn from its stream until q != p, n = pq has exactly the requested bit length, and one of "+
+			"the exponents %v is invertible mod (p-1)(q-1); e is the first of them that is", g.rsaExponent.candidates())
+	}
+	rsa := fmt.Sprintf("primes p and q of half the modulus size, each from its own %s stream with info \"prime-0\"/\"prime-1\", or \"<key info>;prime=0\"/\"<key info>;prime=1\" "+
+		"for a labeled key (same secret and salt as the key stream). Every candidate is a fresh big-endian draw of the prime's byte length "+
+		"with the top bit and the lowest bit set, accepted when math/big ProbablyPrime passes (Miller-Rabin rounds per "+
+		"FIPS 186-4 Table C.3, plus Baillie-PSW). %s, d = e^-1 mod (p-1)(q-1)", kdf, exponent)
+	if g.derivation == DerivationV1 {
+		rsa = fmt.Sprintf("crypto/rsa GenerateKey reading from the key stream, e = %d (depends on the Go version)", RSA_PUBLIC_EXPONENT)
+	}
+
+	layering := ""
+	if g.derivation >= DerivationV3 {
+		layering = fmt.Sprintf(". Every stream (key, RSA prime, and raw streams) is keyed from HKDF info %q for the empty info and %q + info "+
+			"otherwise, so stream seeds never overlap direct HKDF reads such as derive-secret", STREAM_SEED_INFO, STREAM_SEED_INFO+";")
+	}
+
+	seed := fmt.Sprintf("BIP-39 seed: PBKDF2-HMAC-SHA512, %d iterations, password = full mnemonic words joined by single spaces, "+
+		"salt = \"mnemonic\" + salt as raw UTF-8 (no NFKD normalization), 64-byte output", BIP39_SEED_ITERATIONS)
+	seedName, seedSize := "BIP-39 seed", 64
+	if g.entropySeed {
+		seed = fmt.Sprintf("mnemonic entropy: the %d bytes the words encode (BIP-39 EntropyFromMnemonic), without the BIP-39 seed "+
+			"derivation; the salt only enters through HKDF", MNEMONIC_ENTROPY_BITS/8)
+		seedName, seedSize = "mnemonic entropy", MNEMONIC_ENTROPY_BITS/8
+	}
+
+	stretch := fmt.Sprintf("none, the %s is used directly", seedName)
+	if g.scrypt != nil {
+		stretch = fmt.Sprintf("seed = scrypt(%s, salt = %q + salt, N = %d, r = %d, p = %d, %d-byte output)",
+			seedName, SCRYPT_SALT_PREFIX, g.scrypt.N, g.scrypt.R, g.scrypt.P, seedSize)
+	}
+
+	return []SpecItem{
+		{"derivation", g.derivation.String()},
+		{"mnemonic", fmt.Sprintf("BIP-39, %d words encoding %d bits of entropy and an %d-bit SHA-256 checksum",
+			MNEMONIC_WORD_COUNT, MNEMONIC_ENTROPY_BITS, MNEMONIC_ENTROPY_BITS/32)},
+		{"seed", seed},
+		{"stretch", stretch},
+		{"kdf", fmt.Sprintf("%s, secret = (stretched) %s, salt = salt (UTF-8), info = empty for the key stream of an unlabeled key, "+
+			"\"%s[;identity=<identity>];alg=<algorithm>;index=<n>\" for a labeled key", kdf, seedName, KEY_INFO_PREFIX)},
+		{"stream", fmt.Sprintf("XChaCha20 keystream: key = first %d bytes of %s output, nonce = next %d bytes, counter starts at 0. "+
+			"Single-byte reads return without consuming the stream (crypto/rand.MaybeReadByte is ignored)%s",
+			chacha20.KeySize, kdf, chacha20.NonceSizeX, layering)},
+		{"ecc-nist", fmt.Sprintf("P-256/P-384/P-521: read ceil((bitlen(n) + %d) / 8) bytes as a big-endian integer k, "+
+			"d = (k mod (n - 1)) + 1", SCALAR_EXTRA_BITS)},
+		{"ecc-ed25519", "Ed25519: read a 32-byte RFC 8032 private key seed"},
+		{"rsa", rsa},
+		{"encoding", "unencrypted PKCS#8 DER; password encryption and every other format are applied after derivation"},
+		{"secret", fmt.Sprintf("derive-secret: %s over the (stretched) %s and salt with info = %q + context, read directly", kdf, seedName, SECRET_INFO_PREFIX)},
+		{"pem-password", fmt.Sprintf("--derive-password: %s over the (stretched) %s and salt with info = %q, %d bytes read directly, "+
+			"encoded as unpadded base64url", kdf, seedName, PEM_PASSWORD_INFO, PEM_PASSWORD_SIZE)},
+	}
+}
+
+// hashName returns the name of the generator's HKDF hash function
+func hashName(g *Generator) string {
+	h := g.hash()
+	t := fmt.Sprintf("%T", h)
+	if strings.Contains(t, "sha256") || strings.Contains(t, "sha512") {
+		return fmt.Sprintf("SHA-%d", h.Size()*8)
+	}
+	return fmt.Sprintf("%s (%d-byte output)", t, h.Size())
+}
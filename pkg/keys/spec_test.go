@@ -0,0 +1,83 @@
+package keys
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/pbkdf2"
+	"crypto/sha256"
+	"crypto/sha512"
+	"io"
+	"math/big"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/chacha20"
+	"golang.org/x/crypto/hkdf"
+)
+
+// TestSpecReimplementation derives a P-256 key only from the primitives documented by Spec and compares it with the generator
+func TestSpecReimplementation(t *testing.T) {
+	mnemonic := MustParseMnemonic(testMnemonic)
+
+	// seed: PBKDF2-HMAC-SHA512 over the mnemonic with "mnemonic" + salt
+	seed, err := pbkdf2.Key(sha512.New, mnemonic.String(), []byte("mnemonic"+SALT), BIP39_SEED_ITERATIONS, 64)
+	if err != nil {
+		t.Fatalf("failed to derive seed: %v", err)
+	}
+
+	// stream: XChaCha20 keyed by the first HKDF-SHA256 output bytes
+	kdf := hkdf.New(sha256.New, seed, []byte(SALT), nil)
+	keyNonce := make([]byte, chacha20.KeySize+chacha20.NonceSizeX)
+	if _, err := io.ReadFull(kdf, keyNonce); err != nil {
+		t.Fatalf("failed to read HKDF output: %v", err)
+	}
+	cipher, err := chacha20.NewUnauthenticatedCipher(keyNonce[:chacha20.KeySize], keyNonce[chacha20.KeySize:])
+	if err != nil {
+		t.Fatalf("failed to create XChaCha20 cipher: %v", err)
+	}
+
+	// scalar: wide read reduced into [1, n-1]
+	n := elliptic.P256().Params().N
+	buf := make([]byte, (n.BitLen()+SCALAR_EXTRA_BITS+7)/8)
+	cipher.XORKeyStream(buf, buf)
+	d := new(big.Int).SetBytes(buf)
+	d.Mod(d, new(big.Int).Sub(n, big.NewInt(1)))
+	d.Add(d, big.NewInt(1))
+
+	key, err := GenerateKeyFromMnemonic(t.Context(), KeyTypeECC, int(ECCCurveP256), SALT, mnemonic)
+	if err != nil {
+		t.Fatalf("failed to generate ECC key from mnemonic: %v", err)
+	}
+	if key.PrivateKey.(*ecdsa.PrivateKey).D.Cmp(d) != 0 {
+		t.Fatalf("the documented derivation does not reproduce the generated key")
+	}
+
+	_, genSeed, err := DefaultGenerator().deriveSeed(mnemonic, SALT)
+	if err != nil || !bytes.Equal(seed, genSeed) {
+		t.Fatalf("the documented seed derivation does not match BIP-39: %v", err)
+	}
+}
+
+func TestSpecDescribesGenerator(t *testing.T) {
+	specs := map[string]string{}
+	for _, item := range Spec() {
+		specs[item.Component] = item.Algorithm
+	}
+	if specs["derivation"] != DEFAULT_DERIVATION.String() || !strings.HasPrefix(specs["kdf"], "HKDF-SHA-256") {
+		t.Fatalf("unexpected default spec: %v", specs)
+	}
+
+	g, err := NewGenerator(Config{Hash: sha512.New, Derivation: DerivationV1})
+	if err != nil {
+		t.Fatalf("failed to create generator: %v", err)
+	}
+	for _, item := range g.Spec() {
+		if item.Component == "kdf" && !strings.HasPrefix(item.Algorithm, "HKDF-SHA-512") {
+			t.Fatalf("unexpected kdf spec for SHA-512: %s", item.Algorithm)
+		}
+		if item.Component == "rsa" && !strings.Contains(item.Algorithm, "GenerateKey") {
+			t.Fatalf("unexpected rsa spec for %s: %s", DerivationV1, item.Algorithm)
+		}
+	}
+}
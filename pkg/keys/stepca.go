@@ -0,0 +1,28 @@
+package keys
+
+import (
+	"crypto/x509"
+	"time"
+)
+
+// step-ca root profile. step ca init --root root_ca.crt --key root_ca.key imports an existing root from these files,
+// and the certificate mirrors the default root template of step (x509util.DefaultRootTemplate of go.step.sm/crypto).
+const (
+	STEP_CA_VERSION           = "0.28"                    // the step-ca release the profile targets
+	STEP_CA_ROOT_KEY_FILE     = "root_ca.key"             // the PKCS#8 PEM root key, encrypted if a password is given
+	STEP_CA_ROOT_CERT_FILE    = "root_ca.crt"             // the self-signed PEM root certificate
+	STEP_CA_ROOT_VALIDITY     = 10 * 365 * 24 * time.Hour // the root validity of step ca init
+	STEP_CA_ROOT_MAX_PATH_LEN = 1                         // the root signs the intermediate, which signs leaves
+)
+
+// StepCARootOptions returns the certificate options of a step-ca root: a CA certificate with only the certSign and
+// crlSign key usages and a path length of 1, valid for ten years unless the validity is set.
+func StepCARootOptions(commonName string) CertOptions {
+	return CertOptions{
+		CommonName: commonName,
+		IsCA:       true,
+		KeyUsage:   x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		MaxPathLen: STEP_CA_ROOT_MAX_PATH_LEN,
+		Validity:   STEP_CA_ROOT_VALIDITY,
+	}
+}
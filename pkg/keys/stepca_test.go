@@ -0,0 +1,62 @@
+package keys
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+	"time"
+)
+
+func TestStepCARootCertificate(t *testing.T) {
+	notBefore := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, curve := range []ECCCurveID{ECCCurveP256, ECCCurveP384, ECCCurveEd25519} {
+		key, err := GenerateKeyFromMnemonic(t.Context(), KeyTypeECC, int(curve), SALT, MustParseMnemonic(testMnemonic))
+		if err != nil {
+			t.Fatalf("failed to generate ECC key from mnemonic: %v", err)
+		}
+
+		opts := StepCARootOptions("Example Root CA")
+		opts.NotBefore = notBefore
+		der, err := key.Certificate(opts)
+		if err != nil {
+			t.Fatalf("failed to create step-ca root certificate: %v", err)
+		}
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			t.Fatalf("failed to parse step-ca root certificate: %v", err)
+		}
+
+		if !cert.BasicConstraintsValid || !cert.IsCA || cert.MaxPathLen != STEP_CA_ROOT_MAX_PATH_LEN {
+			t.Fatalf("expected a CA certificate with a path length of %d, got CA %v, path length %d", STEP_CA_ROOT_MAX_PATH_LEN, cert.IsCA, cert.MaxPathLen)
+		}
+		if cert.KeyUsage != x509.KeyUsageCertSign|x509.KeyUsageCRLSign || len(cert.ExtKeyUsage) != 0 {
+			t.Fatalf("unexpected key usage %v and extended key usage %v", cert.KeyUsage, cert.ExtKeyUsage)
+		}
+		if cert.Subject.CommonName != "Example Root CA" || cert.Issuer.CommonName != cert.Subject.CommonName {
+			t.Fatalf("unexpected subject %q or issuer %q", cert.Subject.CommonName, cert.Issuer.CommonName)
+		}
+		if !cert.NotAfter.Equal(notBefore.Add(STEP_CA_ROOT_VALIDITY)) {
+			t.Fatalf("unexpected validity end %s", cert.NotAfter)
+		}
+
+		// the certificate is a valid trust anchor for itself
+		roots := x509.NewCertPool()
+		roots.AddCert(cert)
+		if _, err := cert.Verify(x509.VerifyOptions{Roots: roots, CurrentTime: notBefore.Add(time.Hour), KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageAny}}); err != nil {
+			t.Fatalf("expected the step-ca root to verify as a CA certificate: %v", err)
+		}
+
+		// the root key is written as PKCS#8
+		encoded, err := key.Encode(FormatPKCS8)
+		if err != nil {
+			t.Fatalf("failed to encode key: %v", err)
+		}
+		block, _ := pem.Decode([]byte(encoded))
+		if block == nil || block.Type != "PRIVATE KEY" {
+			t.Fatalf("expected a PKCS#8 root key, got:\n%s", encoded)
+		}
+		if _, err := x509.ParsePKCS8PrivateKey(block.Bytes); err != nil {
+			t.Fatalf("failed to parse the PKCS#8 root key: %v", err)
+		}
+	}
+}
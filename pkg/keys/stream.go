@@ -2,12 +2,19 @@ package keys
 
 import (
 	"crypto/cipher"
+	"fmt"
 	"io"
 
 	"github.com/rs/zerolog/log"
 	"golang.org/x/crypto/chacha20"
 )
 
+// DEFAULT_STREAM_BUFFER_SIZE is the number of keystream bytes a ChaCha20 stream produces per XORKeyStream call
+const DEFAULT_STREAM_BUFFER_SIZE = 4096
+
+// MAX_STREAM_BUFFER_SIZE caps the stream buffer, which is allocated for every stream
+const MAX_STREAM_BUFFER_SIZE = 1 << 20
+
 type DeterministicReader interface {
 	io.Reader
 	IgnoresMaybeReadByte() bool
@@ -34,6 +41,13 @@ func (s *StreamChaCha20) Read(dst []byte) (int, error) {
 		return 1, nil
 	}
 
+	return s.fill(dst), nil
+}
+
+// fill writes keystream bytes to the entire dst buffer, returning the number of bytes written
+func (s *StreamChaCha20) fill(dst []byte) int {
+	totalSize := len(dst)
+
 	// n = number of remaining bytes to read
 	n := totalSize
 
@@ -46,10 +60,37 @@ func (s *StreamChaCha20) Read(dst []byte) (int, error) {
 		dst = dst[chunkSize:]
 		n -= chunkSize
 	}
-	return totalSize, nil
+	return totalSize
+}
+
+// Keystream returns a plain io.Reader of the keystream. Unlike Read, it also serves single-byte reads, so every read
+// returns the next keystream bytes regardless of its size.
+func (s *StreamChaCha20) Keystream() io.Reader {
+	return keystreamReader{s}
 }
 
+type keystreamReader struct {
+	s *StreamChaCha20
+}
+
+// Read implements io.Reader by filling dst with the next keystream bytes
+func (r keystreamReader) Read(dst []byte) (int, error) {
+	return r.s.fill(dst), nil
+}
+
+// NewStreamChaCha20 creates a ChaCha20 stream keyed with the first bytes of r, using DEFAULT_STREAM_BUFFER_SIZE
 func NewStreamChaCha20(r io.Reader) (*StreamChaCha20, error) {
+	return NewStreamChaCha20WithBufferSize(r, DEFAULT_STREAM_BUFFER_SIZE)
+}
+
+// NewStreamChaCha20WithBufferSize creates a ChaCha20 stream keyed with the first bytes of r that produces up to size
+// keystream bytes per XORKeyStream call. The buffer size only changes how reads are chunked, never the keystream, so
+// a larger buffer can speed up large RSA keys without changing the keys.
+func NewStreamChaCha20WithBufferSize(r io.Reader, size int) (*StreamChaCha20, error) {
+	if size < 1 || size > MAX_STREAM_BUFFER_SIZE {
+		return nil, fmt.Errorf("stream buffer size must be between 1 and %d bytes, found %d", MAX_STREAM_BUFFER_SIZE, size)
+	}
+
 	key := make([]byte, chacha20.KeySize)
 	nonce := make([]byte, chacha20.NonceSizeX)
 
@@ -71,6 +112,6 @@ func NewStreamChaCha20(r io.Reader) (*StreamChaCha20, error) {
 
 	return &StreamChaCha20{
 		stream: stream,
-		zbuf:   make([]byte, 4096),
+		zbuf:   make([]byte, size),
 	}, nil
 }
@@ -0,0 +1,208 @@
+package keys
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"testing"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+func TestGenerateStreamMatchesKeyGeneration(t *testing.T) {
+	mnemonic := MustParseMnemonic(testMnemonic)
+
+	readSeed := func(info string) []byte {
+		r, err := GenerateStream(t.Context(), SALT, mnemonic, info)
+		if err != nil {
+			t.Fatalf("failed to generate stream: %v", err)
+		}
+		seed := make([]byte, ed25519.SeedSize)
+		if _, err := io.ReadFull(r, seed); err != nil {
+			t.Fatalf("failed to read stream: %v", err)
+		}
+		return seed
+	}
+
+	// an Ed25519 key is the first 32 bytes of its key stream
+	for _, label := range []KeyLabel{{}, {Index: 1}, {Identity: "alice", Index: 2}} {
+		key, err := GenerateLabeledKeyFromMnemonic(t.Context(), KeyTypeECC, int(ECCCurveEd25519), SALT, mnemonic, label)
+		if err != nil {
+			t.Fatalf("failed to generate Ed25519 key: %v", err)
+		}
+		info, err := keyStreamInfo(KeyTypeECC, int(ECCCurveEd25519), label)
+		if err != nil {
+			t.Fatalf("failed to build key stream info: %v", err)
+		}
+
+		priv := ed25519.NewKeyFromSeed(readSeed(string(info)))
+		if !priv.Equal(key.PrivateKey) {
+			t.Fatalf("stream for info %q does not match the Ed25519 key with label %+v", info, label)
+		}
+	}
+
+	if bytes.Equal(readSeed(""), readSeed("other")) {
+		t.Fatalf("streams with different info are not independent")
+	}
+}
+
+func TestGenerateStreamSingleByteReads(t *testing.T) {
+	mnemonic := MustParseMnemonic(testMnemonic)
+
+	r1, err := GenerateStream(t.Context(), SALT, mnemonic, "")
+	if err != nil {
+		t.Fatalf("failed to generate stream: %v", err)
+	}
+	r2, err := GenerateStream(t.Context(), SALT, mnemonic, "")
+	if err != nil {
+		t.Fatalf("failed to generate stream: %v", err)
+	}
+
+	// reading byte by byte yields the same bytes as a single large read
+	bulk := make([]byte, 64)
+	if _, err := io.ReadFull(r1, bulk); err != nil {
+		t.Fatalf("failed to read stream: %v", err)
+	}
+	single := make([]byte, 64)
+	for i := range single {
+		if _, err := r2.Read(single[i : i+1]); err != nil {
+			t.Fatalf("failed to read stream: %v", err)
+		}
+	}
+	if !bytes.Equal(bulk, single) {
+		t.Fatalf("single-byte reads do not match: got %x, want %x", single, bulk)
+	}
+}
+
+func TestStreamChaCha20KnownAnswer(t *testing.T) {
+	// key = bytes 0..31, nonce = bytes 32..55
+	seed := make([]byte, 56)
+	for i := range seed {
+		seed[i] = byte(i)
+	}
+	stream, err := NewStreamChaCha20(bytes.NewReader(seed))
+	if err != nil {
+		t.Fatalf("failed to create stream: %v", err)
+	}
+	out := make([]byte, 32)
+	if _, err := io.ReadFull(stream, out); err != nil {
+		t.Fatalf("failed to read stream: %v", err)
+	}
+	if expected := "f21ff2272fee1d51a1ceef118d73bb0da8391ae74ab178a0fcf5cceea84b1fe3"; hex.EncodeToString(out) != expected {
+		t.Fatalf("unexpected keystream %x, want %s", out, expected)
+	}
+}
+
+func TestStreamBufferSizes(t *testing.T) {
+	seed := make([]byte, 56)
+	for i := range seed {
+		seed[i] = byte(i)
+	}
+	read := func(size int, chunks []int) []byte {
+		stream, err := NewStreamChaCha20WithBufferSize(bytes.NewReader(seed), size)
+		if err != nil {
+			t.Fatalf("failed to create stream with a %d-byte buffer: %v", size, err)
+		}
+		var out []byte
+		for _, n := range chunks {
+			chunk := make([]byte, n)
+			if _, err := io.ReadFull(stream.Keystream(), chunk); err != nil {
+				t.Fatalf("failed to read stream: %v", err)
+			}
+			out = append(out, chunk...)
+		}
+		return out
+	}
+
+	// reads smaller than, equal to, and larger than the buffer, across block boundaries
+	chunks := []int{1, 31, 64, 100, 4096, 5000, 3}
+	expected := read(DEFAULT_STREAM_BUFFER_SIZE, chunks)
+	for _, size := range []int{1, 7, 64, 1000, 65536, MAX_STREAM_BUFFER_SIZE} {
+		if out := read(size, chunks); !bytes.Equal(out, expected) {
+			t.Fatalf("a %d-byte buffer changes the keystream", size)
+		}
+	}
+	for _, size := range []int{0, -1, MAX_STREAM_BUFFER_SIZE + 1} {
+		if _, err := NewStreamChaCha20WithBufferSize(bytes.NewReader(seed), size); err == nil {
+			t.Fatalf("expected a %d-byte buffer to be rejected", size)
+		}
+	}
+
+	// the buffer size does not change derived keys
+	mnemonic := MustParseMnemonic(testMnemonic)
+	reference, err := GenerateKeyFromMnemonic(t.Context(), KeyTypeECC, int(ECCCurveP521), SALT, mnemonic)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	for _, size := range []int{1, 1000, MAX_STREAM_BUFFER_SIZE} {
+		g, err := NewGenerator(Config{StreamBufferSize: size})
+		if err != nil {
+			t.Fatalf("failed to create generator: %v", err)
+		}
+		k, err := g.GenerateKeyFromMnemonic(t.Context(), KeyTypeECC, int(ECCCurveP521), SALT, mnemonic)
+		if err != nil {
+			t.Fatalf("failed to generate key: %v", err)
+		}
+		if k.Fingerprint() != reference.Fingerprint() {
+			t.Fatalf("a %d-byte stream buffer changes the derived key", size)
+		}
+	}
+	if _, err := NewGenerator(Config{StreamBufferSize: -1}); err == nil {
+		t.Fatalf("expected a negative stream buffer size to be rejected")
+	}
+}
+
+func TestStreamSeedSeparation(t *testing.T) {
+	mnemonic := MustParseMnemonic(testMnemonic)
+	g, err := NewGenerator(Config{Derivation: DerivationV3})
+	if err != nil {
+		t.Fatalf("failed to create generator: %v", err)
+	}
+
+	read := func(r io.Reader) []byte {
+		out := make([]byte, 32)
+		if _, err := io.ReadFull(r, out); err != nil {
+			t.Fatalf("failed to read stream: %v", err)
+		}
+		return out
+	}
+	r, err := g.GenerateStream(t.Context(), SALT, mnemonic, "")
+	if err != nil {
+		t.Fatalf("failed to generate stream: %v", err)
+	}
+	v3 := read(r)
+	if expected := "26d7e7b39d5b36ac748dad704203c5ac69c5d7d9a4ebb878aab36222e2994dac"; hex.EncodeToString(v3) != expected {
+		t.Fatalf("unexpected v3 stream %x, want %s", v3, expected)
+	}
+
+	// the v3 stream is keyed from the "chacha-seed" HKDF info rather than from the empty info a direct read would use
+	_, seed, err := g.deriveSeed(mnemonic, SALT)
+	if err != nil {
+		t.Fatalf("failed to derive seed: %v", err)
+	}
+	layered, err := NewStreamChaCha20(hkdf.New(sha256.New, seed, []byte(SALT), []byte(STREAM_SEED_INFO)))
+	if err != nil {
+		t.Fatalf("failed to create stream: %v", err)
+	}
+	if !bytes.Equal(read(layered), v3) {
+		t.Fatalf("v3 stream is not keyed from the %q info", STREAM_SEED_INFO)
+	}
+	direct, err := NewStreamChaCha20(hkdf.New(sha256.New, seed, []byte(SALT), nil))
+	if err != nil {
+		t.Fatalf("failed to create stream: %v", err)
+	}
+	if bytes.Equal(read(direct), v3) {
+		t.Fatalf("v3 stream shares its seed with a direct read of the empty info")
+	}
+
+	// v3 keys are drawn from the layered stream
+	key, err := g.GenerateKeyFromMnemonic(t.Context(), KeyTypeECC, int(ECCCurveEd25519), SALT, mnemonic)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	if !ed25519.NewKeyFromSeed(v3).Equal(key.PrivateKey) {
+		t.Fatalf("v3 Ed25519 key does not match its stream")
+	}
+}
@@ -0,0 +1,73 @@
+package keys
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"golang.org/x/crypto/scrypt"
+)
+
+// SCRYPT_SALT_PREFIX prefixes the salt of the scrypt seed stretch, separating it from the other uses of the salt
+const SCRYPT_SALT_PREFIX = "bipkey-scrypt:"
+
+// default scrypt cost parameters, which take about 128 MiB of memory
+const (
+	DEFAULT_SCRYPT_N = 1 << 17
+	DEFAULT_SCRYPT_R = 8
+	DEFAULT_SCRYPT_P = 1
+)
+
+// ScryptParams holds the cost parameters of the optional scrypt stretch of the BIP-39 seed. The stretch changes every
+// derived key, so a key must be restored with the same parameters it was generated with.
+type ScryptParams struct {
+	N int `json:"n"` // CPU/memory cost, a power of two greater than 1
+	R int `json:"r"` // block size
+	P int `json:"p"` // parallelization
+}
+
+// DefaultScryptParams returns the default scrypt cost parameters
+func DefaultScryptParams() ScryptParams {
+	return ScryptParams{N: DEFAULT_SCRYPT_N, R: DEFAULT_SCRYPT_R, P: DEFAULT_SCRYPT_P}
+}
+
+// String returns the parameters in their display form, e.g. "scrypt (N=131072, r=8, p=1)"
+func (p ScryptParams) String() string {
+	return fmt.Sprintf("scrypt (N=%d, r=%d, p=%d)", p.N, p.R, p.P)
+}
+
+// validate checks the parameters against the limits of scrypt
+func (p ScryptParams) validate() error {
+	if p.N <= 1 || p.N&(p.N-1) != 0 {
+		return fmt.Errorf("scrypt N must be a power of two greater than 1, got %d", p.N)
+	}
+	if p.R <= 0 || p.P <= 0 || uint64(p.R)*uint64(p.P) >= 1<<30 {
+		return fmt.Errorf("scrypt r and p must be positive with r*p < 2^30, got r=%d p=%d", p.R, p.P)
+	}
+	return nil
+}
+
+// SupportedKDFs returns a string listing supported seed stretching functions
+func SupportedKDFs() string {
+	var builder strings.Builder
+	builder.WriteString("Supported seed KDFs:\n")
+	builder.WriteString(" - none (default, the BIP-39 seed is used directly)\n")
+	builder.WriteString(" - scrypt (memory-hard stretch of the BIP-39 seed, configurable N, r, p)\n")
+	return builder.String()
+}
+
+// stretchSeed applies the generator's scrypt stretch to the BIP-39 seed, if one is configured
+func (g *Generator) stretchSeed(seed []byte, salt string) ([]byte, error) {
+	if g.scrypt == nil {
+		return seed, nil
+	}
+
+	p := *g.scrypt
+	stretched, err := scrypt.Key(seed, []byte(SCRYPT_SALT_PREFIX+salt), p.N, p.R, p.P, len(seed))
+	clear(seed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stretch seed with scrypt: %w", err)
+	}
+	log.Debug().Str("kdf", p.String()).Msg("Stretched seed with scrypt.")
+	return stretched, nil
+}
@@ -0,0 +1,77 @@
+package keys
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"io"
+	"testing"
+
+	"github.com/tyler-smith/go-bip39"
+	"golang.org/x/crypto/hkdf"
+	"golang.org/x/crypto/scrypt"
+)
+
+// testScryptParams are cheap scrypt parameters for tests
+var testScryptParams = ScryptParams{N: 1024, R: 8, P: 1}
+
+func TestScryptStretchDeterminism(t *testing.T) {
+	g, err := NewGenerator(Config{Scrypt: &testScryptParams})
+	if err != nil {
+		t.Fatalf("failed to create generator: %v", err)
+	}
+	mnemonic := MustParseMnemonic(testMnemonic)
+
+	// the stretched seed is scrypt over the BIP-39 seed, as described by the spec
+	seed, err := scrypt.Key(bip39.NewSeed(testMnemonic, SALT), []byte(SCRYPT_SALT_PREFIX+SALT), 1024, 8, 1, 64)
+	if err != nil {
+		t.Fatalf("failed to compute scrypt: %v", err)
+	}
+	expected := make([]byte, 32)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, seed, []byte(SALT), []byte(SECRET_INFO_PREFIX+"test")), expected); err != nil {
+		t.Fatalf("failed to compute HKDF: %v", err)
+	}
+
+	secret, err := g.DeriveSecret(t.Context(), SALT, mnemonic, "test", 32)
+	if err != nil {
+		t.Fatalf("failed to derive secret: %v", err)
+	}
+	if !bytes.Equal(secret, expected) {
+		t.Fatalf("unexpected stretched secret: got %x, want %x", secret, expected)
+	}
+
+	// keys are reproducible with the same parameters and change with the stretch or its parameters
+	fingerprint := func(g *Generator) string {
+		key, err := g.GenerateKeyFromMnemonic(t.Context(), KeyTypeECC, int(ECCCurveEd25519), SALT, mnemonic)
+		if err != nil {
+			t.Fatalf("failed to generate key: %v", err)
+		}
+		fp, err := key.PublicFingerprint(FingerprintHex)
+		if err != nil {
+			t.Fatalf("failed to compute fingerprint: %v", err)
+		}
+		return fp
+	}
+
+	const expectedFingerprint = "c2f5352b7ecf98b3c86e8d023d1f5867ddc7446d91914b38b4a62f5aa6d4413e"
+	if fp := fingerprint(g); fp != expectedFingerprint {
+		t.Fatalf("unexpected stretched key fingerprint: got %s, want %s", fp, expectedFingerprint)
+	}
+	if fingerprint(DefaultGenerator()) == expectedFingerprint {
+		t.Fatalf("the scrypt stretch did not change the derived key")
+	}
+	other, err := NewGenerator(Config{Scrypt: &ScryptParams{N: 2048, R: 8, P: 1}})
+	if err != nil {
+		t.Fatalf("failed to create generator: %v", err)
+	}
+	if fingerprint(other) == expectedFingerprint {
+		t.Fatalf("different scrypt parameters derived the same key")
+	}
+}
+
+func TestScryptParamsValidation(t *testing.T) {
+	for _, params := range []ScryptParams{{N: 1000, R: 8, P: 1}, {N: 1, R: 8, P: 1}, {N: 1024, R: 0, P: 1}, {N: 1024, R: 8, P: -1}} {
+		if _, err := NewGenerator(Config{Scrypt: &params}); err == nil {
+			t.Fatalf("expected scrypt parameters %+v to be rejected", params)
+		}
+	}
+}
@@ -0,0 +1,385 @@
+package keys
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math/big"
+)
+
+// type URLs of the Tink private key protos
+const (
+	TINK_ECDSA_TYPE_URL      = "type.googleapis.com/google.crypto.tink.EcdsaPrivateKey"
+	TINK_ED25519_TYPE_URL    = "type.googleapis.com/google.crypto.tink.Ed25519PrivateKey"
+	TINK_RSA_PKCS1_TYPE_URL  = "type.googleapis.com/google.crypto.tink.RsaSsaPkcs1PrivateKey"
+	TINK_ASYMMETRIC_PRIVATE  = "ASYMMETRIC_PRIVATE"
+	TINK_STATUS_ENABLED      = "ENABLED"
+	TINK_OUTPUT_PREFIX_TINK  = "TINK"
+	TINK_RSA_PUBLIC_EXPONENT = 65537 // Tink only accepts the F4 exponent
+)
+
+// Tink proto enum values (tink/proto/common.proto and ecdsa.proto)
+const (
+	tinkHashSHA256    = 3
+	tinkHashSHA384    = 2
+	tinkHashSHA512    = 4
+	tinkCurveNISTP256 = 2
+	tinkCurveNISTP384 = 3
+	tinkCurveNISTP521 = 4
+	tinkEncodingDER   = 2
+	tinkKeyVersion    = 0
+	protoWireVarint   = 0
+	protoWireBytes    = 2
+	tinkKeyIDMask     = 0x7fffffff // key ids are kept below 2^31 for readers that parse them as signed integers
+)
+
+// TinkKeyset is a cleartext Tink keyset in Tink's JSON keyset format
+type TinkKeyset struct {
+	PrimaryKeyID uint32    `json:"primaryKeyId"`
+	Key          []TinkKey `json:"key"`
+}
+
+// TinkKey is a key of a Tink keyset
+type TinkKey struct {
+	KeyData          TinkKeyData `json:"keyData"`
+	Status           string      `json:"status"`
+	KeyID            uint32      `json:"keyId"`
+	OutputPrefixType string      `json:"outputPrefixType"`
+}
+
+// TinkKeyData holds the serialized key proto of a Tink key, base64-encoded in the JSON format
+type TinkKeyData struct {
+	TypeURL         string `json:"typeUrl"`
+	Value           []byte `json:"value"`
+	KeyMaterialType string `json:"keyMaterialType"`
+}
+
+// tinkParams holds the Tink parameters a key type is exported with
+type tinkParams struct {
+	Template string // name of the Tink key template with the same parameters
+	TypeURL  string
+	Hash     int
+	Curve    int
+}
+
+// tinkParameters returns the Tink parameters of the key type and id. ECDSA keys use DER signatures and the hash
+// of their curve's template, RSA keys PKCS#1 v1.5 signatures with SHA-256, or SHA-512 from 4096 bits.
+func tinkParameters(keyType KeyType, keyId int) (tinkParams, error) {
+	switch keyType {
+	case KeyTypeECC:
+		switch ECCCurveID(keyId) {
+		case ECCCurveP256:
+			return tinkParams{"ECDSA_P256", TINK_ECDSA_TYPE_URL, tinkHashSHA256, tinkCurveNISTP256}, nil
+		case ECCCurveP384:
+			return tinkParams{"ECDSA_P384_SHA384", TINK_ECDSA_TYPE_URL, tinkHashSHA384, tinkCurveNISTP384}, nil
+		case ECCCurveP521:
+			return tinkParams{"ECDSA_P521", TINK_ECDSA_TYPE_URL, tinkHashSHA512, tinkCurveNISTP521}, nil
+		case ECCCurveEd25519:
+			return tinkParams{"ED25519", TINK_ED25519_TYPE_URL, 0, 0}, nil
+		}
+	case KeyTypeRSA:
+		switch RSAKeyID(keyId) {
+		case RSAKey2048:
+			return tinkParams{"RSA_SSA_PKCS1_2048_SHA256_F4", TINK_RSA_PKCS1_TYPE_URL, tinkHashSHA256, 0}, nil
+		case RSAKey3072:
+			return tinkParams{"RSA_SSA_PKCS1_3072_SHA256_F4", TINK_RSA_PKCS1_TYPE_URL, tinkHashSHA256, 0}, nil
+		case RSAKey4096:
+			return tinkParams{"RSA_SSA_PKCS1_4096_SHA512_F4", TINK_RSA_PKCS1_TYPE_URL, tinkHashSHA512, 0}, nil
+		case RSAKey8192:
+			return tinkParams{"RSA_SSA_PKCS1_8192_SHA512_F4", TINK_RSA_PKCS1_TYPE_URL, tinkHashSHA512, 0}, nil
+		}
+	}
+	return tinkParams{}, fmt.Errorf("key type %s with id %d is not supported by Tink", keyType, keyId)
+}
+
+// TinkTemplate returns the name of the Tink key template whose parameters the key is exported with. RSA-2048 and
+// RSA-8192 have no predefined template and are named in the same scheme.
+func (k Key) TinkTemplate() (string, error) {
+	params, err := tinkParameters(k.keyType, k.keyId)
+	if err != nil {
+		return "", err
+	}
+	return params.Template, nil
+}
+
+// TinkKeyset returns a cleartext Tink keyset holding the private key as its only, primary key. The key id is taken
+// from the SHA-256 public key fingerprint, so the keyset is deterministic like the key. The key uses the TINK output
+// prefix, as Tink's templates do. The keyset is unencrypted even for a password encrypted key.
+func (k Key) TinkKeyset() (*TinkKeyset, error) {
+	params, err := tinkParameters(k.keyType, k.keyId)
+	if err != nil {
+		return nil, err
+	}
+
+	var value []byte
+	switch priv := k.PrivateKey.(type) {
+	case *ecdsa.PrivateKey:
+		value = tinkMarshalECDSA(priv, params)
+	case ed25519.PrivateKey:
+		value = tinkMarshalEd25519(priv)
+	case *rsa.PrivateKey:
+		if priv.E != TINK_RSA_PUBLIC_EXPONENT {
+			return nil, fmt.Errorf("Tink only supports the RSA public exponent %d, not %d", TINK_RSA_PUBLIC_EXPONENT, priv.E)
+		}
+		value = tinkMarshalRSA(priv, params)
+	default:
+		return nil, fmt.Errorf("unsupported private key type %T", k.PrivateKey)
+	}
+
+	der, err := k.PublicKeyDER()
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(der)
+	keyID := max(binary.BigEndian.Uint32(sum[:4])&tinkKeyIDMask, 1)
+
+	return &TinkKeyset{
+		PrimaryKeyID: keyID,
+		Key: []TinkKey{{
+			KeyData:          TinkKeyData{TypeURL: params.TypeURL, Value: value, KeyMaterialType: TINK_ASYMMETRIC_PRIVATE},
+			Status:           TINK_STATUS_ENABLED,
+			KeyID:            keyID,
+			OutputPrefixType: TINK_OUTPUT_PREFIX_TINK,
+		}},
+	}, nil
+}
+
+// TinkKeysetJSON returns the Tink keyset of the key in Tink's JSON keyset format, e.g. for tinkey or
+// insecurecleartextkeyset.Read with a JSON reader
+func (k Key) TinkKeysetJSON() ([]byte, error) {
+	keyset, err := k.TinkKeyset()
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.MarshalIndent(keyset, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal Tink keyset: %w", err)
+	}
+	return append(data, '\n'), nil
+}
+
+// ParseTinkKeyset parses a cleartext Tink JSON keyset and returns the private key of its primary key. It reads the
+// key types TinkKeyset writes.
+func ParseTinkKeyset(data []byte) (crypto.PrivateKey, error) {
+	var keyset TinkKeyset
+	if err := json.Unmarshal(data, &keyset); err != nil {
+		return nil, fmt.Errorf("invalid Tink keyset: %w", err)
+	}
+	for _, key := range keyset.Key {
+		if key.KeyID != keyset.PrimaryKeyID {
+			continue
+		}
+		switch key.KeyData.TypeURL {
+		case TINK_ECDSA_TYPE_URL:
+			return tinkParseECDSA(key.KeyData.Value)
+		case TINK_ED25519_TYPE_URL:
+			return tinkParseEd25519(key.KeyData.Value)
+		case TINK_RSA_PKCS1_TYPE_URL:
+			return tinkParseRSA(key.KeyData.Value)
+		}
+		return nil, fmt.Errorf("unsupported Tink key type: %s", key.KeyData.TypeURL)
+	}
+	return nil, fmt.Errorf("the Tink keyset has no primary key %d", keyset.PrimaryKeyID)
+}
+
+// tinkMarshalECDSA serializes the EcdsaPrivateKey proto of the key with fixed-size big-endian coordinates and scalar
+func tinkMarshalECDSA(priv *ecdsa.PrivateKey, params tinkParams) []byte {
+	size := (priv.Curve.Params().BitSize + 7) / 8
+	var ecParams, pub, key []byte
+	ecParams = protoAppendVarint(ecParams, 1, uint64(params.Hash))
+	ecParams = protoAppendVarint(ecParams, 2, uint64(params.Curve))
+	ecParams = protoAppendVarint(ecParams, 3, tinkEncodingDER)
+
+	pub = protoAppendVarint(pub, 1, tinkKeyVersion)
+	pub = protoAppendBytes(pub, 2, ecParams)
+	pub = protoAppendBytes(pub, 3, priv.X.FillBytes(make([]byte, size)))
+	pub = protoAppendBytes(pub, 4, priv.Y.FillBytes(make([]byte, size)))
+
+	key = protoAppendVarint(key, 1, tinkKeyVersion)
+	key = protoAppendBytes(key, 2, pub)
+	key = protoAppendBytes(key, 3, priv.D.FillBytes(make([]byte, size)))
+	return key
+}
+
+// tinkMarshalEd25519 serializes the Ed25519PrivateKey proto of the key, whose key value is the 32-byte seed
+func tinkMarshalEd25519(priv ed25519.PrivateKey) []byte {
+	var pub, key []byte
+	pub = protoAppendVarint(pub, 1, tinkKeyVersion)
+	pub = protoAppendBytes(pub, 2, priv.Public().(ed25519.PublicKey))
+
+	key = protoAppendVarint(key, 1, tinkKeyVersion)
+	key = protoAppendBytes(key, 2, priv.Seed())
+	key = protoAppendBytes(key, 3, pub)
+	return key
+}
+
+// tinkMarshalRSA serializes the RsaSsaPkcs1PrivateKey proto of the key with unsigned big-endian integers
+func tinkMarshalRSA(priv *rsa.PrivateKey, params tinkParams) []byte {
+	p, q := priv.Primes[0], priv.Primes[1]
+	one := big.NewInt(1)
+	dp := new(big.Int).Mod(priv.D, new(big.Int).Sub(p, one))
+	dq := new(big.Int).Mod(priv.D, new(big.Int).Sub(q, one))
+	crt := new(big.Int).ModInverse(q, p)
+
+	var rsaParams, pub, key []byte
+	rsaParams = protoAppendVarint(rsaParams, 1, uint64(params.Hash))
+
+	pub = protoAppendVarint(pub, 1, tinkKeyVersion)
+	pub = protoAppendBytes(pub, 2, rsaParams)
+	pub = protoAppendBytes(pub, 3, priv.N.Bytes())
+	pub = protoAppendBytes(pub, 4, big.NewInt(int64(priv.E)).Bytes())
+
+	key = protoAppendVarint(key, 1, tinkKeyVersion)
+	key = protoAppendBytes(key, 2, pub)
+	for i, value := range []*big.Int{priv.D, p, q, dp, dq, crt} {
+		key = protoAppendBytes(key, 3+i, value.Bytes())
+	}
+	return key
+}
+
+// tinkParseECDSA parses an EcdsaPrivateKey proto and checks the public key against the private scalar
+func tinkParseECDSA(value []byte) (crypto.PrivateKey, error) {
+	key, err := protoFields(value)
+	if err != nil {
+		return nil, err
+	}
+	pub, err := protoFields(key[2].bytes)
+	if err != nil {
+		return nil, err
+	}
+	ecParams, err := protoFields(pub[2].bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	var curve elliptic.Curve
+	switch ecParams[2].varint {
+	case tinkCurveNISTP256:
+		curve = elliptic.P256()
+	case tinkCurveNISTP384:
+		curve = elliptic.P384()
+	case tinkCurveNISTP521:
+		curve = elliptic.P521()
+	default:
+		return nil, fmt.Errorf("unsupported Tink ECDSA curve %d", ecParams[2].varint)
+	}
+
+	size := (curve.Params().BitSize + 7) / 8
+	priv, err := ecdsa.ParseRawPrivateKey(curve, new(big.Int).SetBytes(key[3].bytes).FillBytes(make([]byte, size)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid Tink ECDSA key: %w", err)
+	}
+	if priv.X.Cmp(new(big.Int).SetBytes(pub[3].bytes)) != 0 || priv.Y.Cmp(new(big.Int).SetBytes(pub[4].bytes)) != 0 {
+		return nil, fmt.Errorf("the Tink ECDSA public key does not match the private key")
+	}
+	return priv, nil
+}
+
+// tinkParseEd25519 parses an Ed25519PrivateKey proto and checks the public key against the seed
+func tinkParseEd25519(value []byte) (crypto.PrivateKey, error) {
+	key, err := protoFields(value)
+	if err != nil {
+		return nil, err
+	}
+	pub, err := protoFields(key[3].bytes)
+	if err != nil {
+		return nil, err
+	}
+	if len(key[2].bytes) != ed25519.SeedSize {
+		return nil, fmt.Errorf("invalid Tink Ed25519 key size: %d", len(key[2].bytes))
+	}
+	priv := ed25519.NewKeyFromSeed(key[2].bytes)
+	if !priv.Public().(ed25519.PublicKey).Equal(ed25519.PublicKey(pub[2].bytes)) {
+		return nil, fmt.Errorf("the Tink Ed25519 public key does not match the private key")
+	}
+	return priv, nil
+}
+
+// tinkParseRSA parses an RsaSsaPkcs1PrivateKey proto and validates the key
+func tinkParseRSA(value []byte) (crypto.PrivateKey, error) {
+	key, err := protoFields(value)
+	if err != nil {
+		return nil, err
+	}
+	pub, err := protoFields(key[2].bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	integer := func(field protoField) *big.Int { return new(big.Int).SetBytes(field.bytes) }
+	e := integer(pub[4])
+	if !e.IsInt64() || e.Int64() > 1<<31-1 {
+		return nil, fmt.Errorf("invalid Tink RSA public exponent")
+	}
+	priv := &rsa.PrivateKey{
+		PublicKey: rsa.PublicKey{N: integer(pub[3]), E: int(e.Int64())},
+		D:         integer(key[3]),
+		Primes:    []*big.Int{integer(key[4]), integer(key[5])},
+	}
+	if err := priv.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid Tink RSA key: %w", err)
+	}
+	priv.Precompute()
+	return priv, nil
+}
+
+// protoField is a decoded protobuf field, either a varint or length-delimited bytes
+type protoField struct {
+	varint uint64
+	bytes  []byte
+}
+
+// protoAppendVarint appends a varint field to the protobuf message, omitting the proto3 default of zero
+func protoAppendVarint(b []byte, field int, value uint64) []byte {
+	if value == 0 {
+		return b
+	}
+	b = binary.AppendUvarint(b, uint64(field)<<3|protoWireVarint)
+	return binary.AppendUvarint(b, value)
+}
+
+// protoAppendBytes appends a length-delimited field to the protobuf message
+func protoAppendBytes(b []byte, field int, value []byte) []byte {
+	b = binary.AppendUvarint(b, uint64(field)<<3|protoWireBytes)
+	b = binary.AppendUvarint(b, uint64(len(value)))
+	return append(b, value...)
+}
+
+// protoFields decodes the varint and length-delimited fields of a protobuf message by field number. Missing fields
+// decode to their zero value, as in proto3.
+func protoFields(b []byte) (map[int]protoField, error) {
+	fields := make(map[int]protoField)
+	for len(b) > 0 {
+		tag, n := binary.Uvarint(b)
+		if n <= 0 {
+			return nil, fmt.Errorf("invalid protobuf field tag")
+		}
+		b = b[n:]
+
+		value, n := binary.Uvarint(b)
+		if n <= 0 {
+			return nil, fmt.Errorf("invalid protobuf field %d", tag>>3)
+		}
+		b = b[n:]
+
+		switch tag & 7 {
+		case protoWireVarint:
+			fields[int(tag>>3)] = protoField{varint: value}
+		case protoWireBytes:
+			if value > uint64(len(b)) {
+				return nil, fmt.Errorf("truncated protobuf field %d", tag>>3)
+			}
+			fields[int(tag>>3)] = protoField{bytes: b[:value]}
+			b = b[value:]
+		default:
+			return nil, fmt.Errorf("unsupported protobuf wire type %d", tag&7)
+		}
+	}
+	return fields, nil
+}
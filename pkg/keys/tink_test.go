@@ -0,0 +1,79 @@
+package keys
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/json"
+	"testing"
+)
+
+func TestTinkKeyset(t *testing.T) {
+	tests := []struct {
+		keyType  KeyType
+		keyId    int
+		template string
+	}{
+		{KeyTypeECC, int(ECCCurveP256), "ECDSA_P256"},
+		{KeyTypeECC, int(ECCCurveP384), "ECDSA_P384_SHA384"},
+		{KeyTypeECC, int(ECCCurveP521), "ECDSA_P521"},
+		{KeyTypeECC, int(ECCCurveEd25519), "ED25519"},
+		{KeyTypeRSA, int(RSAKey2048), "RSA_SSA_PKCS1_2048_SHA256_F4"},
+	}
+	for _, tc := range tests {
+		key, err := GenerateKeyFromMnemonic(t.Context(), tc.keyType, tc.keyId, SALT, MustParseMnemonic(testMnemonic))
+		if err != nil {
+			t.Fatalf("failed to generate %s key %d from mnemonic: %v", tc.keyType, tc.keyId, err)
+		}
+		if template, err := key.TinkTemplate(); err != nil || template != tc.template {
+			t.Fatalf("unexpected Tink template %q for %s key %d: %v", template, tc.keyType, tc.keyId, err)
+		}
+
+		data, err := key.TinkKeysetJSON()
+		if err != nil {
+			t.Fatalf("failed to export %s key as a Tink keyset: %v", tc.template, err)
+		}
+		again, err := key.TinkKeysetJSON()
+		if err != nil || !bytes.Equal(data, again) {
+			t.Fatalf("expected the %s Tink keyset to be deterministic", tc.template)
+		}
+
+		var keyset TinkKeyset
+		if err := json.Unmarshal(data, &keyset); err != nil {
+			t.Fatalf("%s Tink keyset is not JSON: %v", tc.template, err)
+		}
+		if len(keyset.Key) != 1 || keyset.Key[0].KeyID != keyset.PrimaryKeyID || keyset.PrimaryKeyID == 0 {
+			t.Fatalf("expected a single primary key in the %s Tink keyset", tc.template)
+		}
+		if k := keyset.Key[0]; k.Status != TINK_STATUS_ENABLED || k.OutputPrefixType != TINK_OUTPUT_PREFIX_TINK || k.KeyData.KeyMaterialType != TINK_ASYMMETRIC_PRIVATE {
+			t.Fatalf("unexpected %s Tink key: %+v", tc.template, k)
+		}
+
+		parsed, err := ParseTinkKeyset(data)
+		if err != nil {
+			t.Fatalf("failed to parse %s Tink keyset: %v", tc.template, err)
+		}
+		if err := matchPrivateKey(parsed, key.PrivateKey); err != nil {
+			t.Fatalf("%s Tink keyset does not match the private key: %v", tc.template, err)
+		}
+	}
+}
+
+func TestTinkEd25519Proto(t *testing.T) {
+	key, err := GenerateKeyFromMnemonic(t.Context(), KeyTypeECC, int(ECCCurveEd25519), SALT, MustParseMnemonic(testMnemonic))
+	if err != nil {
+		t.Fatalf("failed to generate Ed25519 key from mnemonic: %v", err)
+	}
+	keyset, err := key.TinkKeyset()
+	if err != nil {
+		t.Fatalf("failed to export Tink keyset: %v", err)
+	}
+
+	// Ed25519PrivateKey{key_value: seed, public_key: Ed25519PublicKey{key_value: public key}}, version 0 omitted
+	priv := key.PrivateKey.(ed25519.PrivateKey)
+	expected := append([]byte{0x12, 0x20}, priv.Seed()...)
+	expected = append(expected, 0x1a, 0x22, 0x12, 0x20)
+	expected = append(expected, priv.Public().(ed25519.PublicKey)...)
+	if !bytes.Equal(keyset.Key[0].KeyData.Value, expected) {
+		t.Fatalf("unexpected Ed25519 key proto: %x", keyset.Key[0].KeyData.Value)
+	}
+}
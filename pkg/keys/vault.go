@@ -0,0 +1,122 @@
+package keys
+
+import (
+	"crypto/aes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+
+	"github.com/youmark/pkcs8"
+)
+
+// VAULT_EPHEMERAL_KEY_SIZE is the size of the ephemeral AES-256 key used to wrap keys for Vault's BYOK import
+const VAULT_EPHEMERAL_KEY_SIZE = 32
+
+// VaultKeyType returns the Vault transit key type matching the key, as passed to the import endpoint's "type" parameter
+func (k Key) VaultKeyType() (string, error) {
+	return VaultKeyTypeOf(k.keyType, k.keyId)
+}
+
+// VaultKeyTypeOf returns the Vault transit key type for the key type and id, so that an unsupported key can be
+// rejected before it is generated
+func VaultKeyTypeOf(keyType KeyType, keyId int) (string, error) {
+	switch keyType {
+	case KeyTypeECC:
+		switch ECCCurveID(keyId) {
+		case ECCCurveP256:
+			return "ecdsa-p256", nil
+		case ECCCurveP384:
+			return "ecdsa-p384", nil
+		case ECCCurveP521:
+			return "ecdsa-p521", nil
+		case ECCCurveEd25519:
+			return "ed25519", nil
+		}
+	case KeyTypeRSA:
+		switch RSAKeyID(keyId) {
+		case RSAKey2048:
+			return "rsa-2048", nil
+		case RSAKey3072:
+			return "rsa-3072", nil
+		case RSAKey4096:
+			return "rsa-4096", nil
+		}
+	}
+	return "", fmt.Errorf("key type %s with size %d is not supported by Vault transit", keyType, Key{keyType: keyType, keyId: keyId}.Size())
+}
+
+// vaultDER returns the unencrypted PKCS#8 DER of the private key, regardless of whether the key is password
+// encrypted, since Vault only imports unencrypted key material
+func (k Key) vaultDER() ([]byte, error) {
+	if _, err := k.VaultKeyType(); err != nil {
+		return nil, err
+	}
+	der, err := pkcs8.MarshalPrivateKey(k.PrivateKey, nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal private key: %w", err)
+	}
+	return der, nil
+}
+
+// VaultBase64 returns the base64-encoded unencrypted PKCS#8 DER of the private key, the key material Vault's BYOK import expects
+func (k Key) VaultBase64() (string, error) {
+	der, err := k.vaultDER()
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(der), nil
+}
+
+// VaultWrap returns the ciphertext for Vault's transit BYOK import (Vault 1.11 and later): an ephemeral AES-256 key
+// encrypted with RSA-OAEP-SHA256 under Vault's wrapping key, followed by the PKCS#8 DER private key wrapped with
+// the ephemeral key using AES-KWP (RFC 5649), all base64-encoded. The ephemeral key is random, so the ciphertext
+// differs on every call even though the imported key is identical.
+func (k Key) VaultWrap(wrappingKey *rsa.PublicKey) (string, error) {
+	der, err := k.vaultDER()
+	if err != nil {
+		return "", err
+	}
+
+	ephemeral := make([]byte, VAULT_EPHEMERAL_KEY_SIZE)
+	if _, err := rand.Read(ephemeral); err != nil {
+		return "", fmt.Errorf("failed to generate ephemeral wrapping key: %w", err)
+	}
+
+	encryptedKey, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, wrappingKey, ephemeral, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to encrypt ephemeral key with the Vault wrapping key: %w", err)
+	}
+
+	wrapped, err := wrapKeyWithPadding(ephemeral, der)
+	if err != nil {
+		return "", fmt.Errorf("failed to wrap private key: %w", err)
+	}
+
+	return base64.StdEncoding.EncodeToString(append(encryptedKey, wrapped...)), nil
+}
+
+// ParseVaultWrappingKey parses Vault's PEM-encoded RSA wrapping key, as returned by "vault read transit/wrapping_key"
+func ParseVaultWrappingKey(data []byte) (*rsa.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "PUBLIC KEY" {
+		return nil, fmt.Errorf("wrapping key is not a PEM-encoded PUBLIC KEY")
+	}
+
+	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse wrapping key: %w", err)
+	}
+
+	rsaPub, ok := pub.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("wrapping key must be an RSA key, found %T", pub)
+	}
+	if rsaPub.Size() < aes.BlockSize*8 {
+		return nil, fmt.Errorf("wrapping key is too small: %d bits", rsaPub.N.BitLen())
+	}
+	return rsaPub, nil
+}
@@ -0,0 +1,164 @@
+package keys
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/pem"
+	"reflect"
+	"testing"
+)
+
+// unwrapKeyWithPadding reverses wrapKeyWithPadding (RFC 5649), as Vault does on import
+func unwrapKeyWithPadding(t *testing.T, kek, ciphertext []byte) []byte {
+	t.Helper()
+
+	block, err := aes.NewCipher(kek)
+	if err != nil {
+		t.Fatalf("failed to create AES cipher: %v", err)
+	}
+
+	var a [8]byte
+	var r []byte
+	if len(ciphertext) == 16 {
+		out := make([]byte, 16)
+		block.Decrypt(out, ciphertext)
+		copy(a[:], out)
+		r = out[8:]
+	} else {
+		n := len(ciphertext)/8 - 1
+		copy(a[:], ciphertext)
+		r = append([]byte(nil), ciphertext[8:]...)
+		buf := make([]byte, 16)
+		for j := 5; j >= 0; j-- {
+			for i := n; i >= 1; i-- {
+				t := uint64(n*j + i)
+				binary.BigEndian.PutUint64(buf, binary.BigEndian.Uint64(a[:])^t)
+				copy(buf[8:], r[(i-1)*8:i*8])
+				block.Decrypt(buf, buf)
+				copy(a[:], buf[:8])
+				copy(r[(i-1)*8:i*8], buf[8:])
+			}
+		}
+	}
+
+	if binary.BigEndian.Uint32(a[:4]) != KWP_ALTERNATIVE_IV {
+		t.Fatalf("key unwrap integrity check failed")
+	}
+	size := int(binary.BigEndian.Uint32(a[4:]))
+	if size > len(r) || len(r)-size >= 8 || !bytes.Equal(r[size:], make([]byte, len(r)-size)) {
+		t.Fatalf("invalid key unwrap padding for length %d", size)
+	}
+	return r[:size]
+}
+
+func TestWrapKeyWithPaddingVectors(t *testing.T) {
+	// test vectors from RFC 5649 section 6
+	kek, _ := hex.DecodeString("5840df6e29b02af1ab493b705bf16ea1ae8338f4dcc176a8")
+	vectors := []struct {
+		key     string
+		wrapped string
+	}{
+		{"c37b7e6492584340bed12207808941155068f738", "138bdeaa9b8fa7fc61f97742e72248ee5ae6ae5360d1ae6a5f54f373fa543b6a"},
+		{"466f7250617369", "afbeb0f07dfbf5419200f2ccb50bb24f"},
+	}
+
+	for _, v := range vectors {
+		key, _ := hex.DecodeString(v.key)
+		wrapped, err := wrapKeyWithPadding(kek, key)
+		if err != nil {
+			t.Fatalf("failed to wrap key %s: %v", v.key, err)
+		}
+		if hex.EncodeToString(wrapped) != v.wrapped {
+			t.Fatalf("unexpected wrapped key for %s: got %x, want %s", v.key, wrapped, v.wrapped)
+		}
+		if unwrapped := unwrapKeyWithPadding(t, kek, wrapped); !bytes.Equal(unwrapped, key) {
+			t.Fatalf("unexpected unwrapped key: got %x, want %s", unwrapped, v.key)
+		}
+	}
+}
+
+func TestVaultExport(t *testing.T) {
+	key, err := GenerateKeyFromMnemonic(t.Context(), KeyTypeECC, int(ECCCurveP256), SALT, MustParseMnemonic(testMnemonic))
+	if err != nil {
+		t.Fatalf("failed to generate ECC key from mnemonic: %v", err)
+	}
+
+	keyType, err := key.VaultKeyType()
+	if err != nil || keyType != "ecdsa-p256" {
+		t.Fatalf("unexpected Vault key type %q: %v", keyType, err)
+	}
+
+	// the plain export is the base64 of an unencrypted PKCS#8 DER private key, even for an encrypted key
+	if err := key.Encrypt(PASSWORD); err != nil {
+		t.Fatalf("failed to encrypt key: %v", err)
+	}
+	encoded, err := key.VaultBase64()
+	if err != nil {
+		t.Fatalf("failed to export key for Vault: %v", err)
+	}
+	der, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		t.Fatalf("Vault export is not standard base64: %v", err)
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		t.Fatalf("Vault export is not PKCS#8 DER: %v", err)
+	}
+	if !reflect.DeepEqual(parsed, key.PrivateKey) {
+		t.Fatalf("Vault export does not match the private key")
+	}
+
+	// the wrapped export is the RSA-OAEP encrypted ephemeral key followed by the AES-KWP wrapped PKCS#8 DER
+	wrappingKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate wrapping key: %v", err)
+	}
+	pubDER, err := x509.MarshalPKIXPublicKey(&wrappingKey.PublicKey)
+	if err != nil {
+		t.Fatalf("failed to marshal wrapping key: %v", err)
+	}
+	pub, err := ParseVaultWrappingKey(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubDER}))
+	if err != nil {
+		t.Fatalf("failed to parse wrapping key: %v", err)
+	}
+
+	encoded, err = key.VaultWrap(pub)
+	if err != nil {
+		t.Fatalf("failed to wrap key for Vault: %v", err)
+	}
+	ciphertext, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		t.Fatalf("wrapped Vault export is not standard base64: %v", err)
+	}
+	if len(ciphertext) <= pub.Size() {
+		t.Fatalf("wrapped Vault export is too short: %d bytes", len(ciphertext))
+	}
+
+	ephemeral, err := rsa.DecryptOAEP(sha256.New(), nil, wrappingKey, ciphertext[:pub.Size()], nil)
+	if err != nil {
+		t.Fatalf("failed to decrypt ephemeral key: %v", err)
+	}
+	if len(ephemeral) != VAULT_EPHEMERAL_KEY_SIZE {
+		t.Fatalf("unexpected ephemeral key size: %d", len(ephemeral))
+	}
+	if unwrapped := unwrapKeyWithPadding(t, ephemeral, ciphertext[pub.Size():]); !bytes.Equal(unwrapped, der) {
+		t.Fatalf("unwrapped key does not match the PKCS#8 DER private key")
+	}
+}
+
+func TestVaultKeyTypeOf(t *testing.T) {
+	if keyType, err := VaultKeyTypeOf(KeyTypeRSA, int(RSAKey4096)); err != nil || keyType != "rsa-4096" {
+		t.Fatalf("unexpected Vault key type %q: %v", keyType, err)
+	}
+	// Vault transit has no RSA-8192 keys, which must be rejected before the key is generated
+	if _, err := VaultKeyTypeOf(KeyTypeRSA, int(RSAKey8192)); err == nil {
+		t.Fatalf("expected RSA-8192 to be rejected for Vault transit")
+	}
+}